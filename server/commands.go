@@ -0,0 +1,184 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"example.com/mathkun-tmp-/server/db"
+	"example.com/mathkun-tmp-/server/handlers/websocket"
+	"example.com/mathkun-tmp-/server/models"
+	"example.com/mathkun-tmp-/server/services"
+)
+
+// runCommand は管理用のサブコマンドを実行する（サーバーは起動しない）
+// 例: go run . season-rollover -name "Season 2" -factor 0.5 -days 90
+func runCommand(args []string) error {
+	switch args[0] {
+	case "season-rollover":
+		return runSeasonRollover(args[1:])
+	case "ws-schema":
+		return runWSSchema(args[1:])
+	case "set-role":
+		return runSetRole(args[1:])
+	case "questions-import":
+		return runQuestionsImport(args[1:])
+	case "questions-export":
+		return runQuestionsExport(args[1:])
+	default:
+		return fmt.Errorf("unknown command: %s", args[0])
+	}
+}
+
+// runSeasonRollover は開催中のシーズンを締めて新しいシーズンを始める
+// -if-due を付けると予定の終了日時を過ぎている場合だけ切り替える（cronから定期実行する想定）
+func runSeasonRollover(args []string) error {
+	fs := flag.NewFlagSet("season-rollover", flag.ContinueOnError)
+	name := fs.String("name", "", "新しいシーズンの名前（省略時は \"Season YYYY-MM\"）")
+	factor := fs.Float64("factor", services.DefaultSoftResetFactor, "ソフトリセットの係数（0で全員1000、1でリセットなし）")
+	days := fs.Int("days", int(services.DefaultSeasonLength/(24*time.Hour)), "新しいシーズンの予定日数")
+	ifDue := fs.Bool("if-due", false, "終了予定を過ぎている場合だけ切り替える")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	season, err := services.NewSeasonService(db.DB).Rollover(services.RolloverOptions{
+		Name:            *name,
+		SoftResetFactor: *factor,
+		Length:          time.Duration(*days) * 24 * time.Hour,
+		IfDue:           *ifDue,
+	})
+	if errors.Is(err, services.ErrSeasonNotDue) {
+		log.Println("current season has not ended yet")
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	log.Printf("started season %d (%s) until %s", season.ID, season.Name, season.EndsAt.Format(time.RFC3339))
+	return nil
+}
+
+// runWSSchema は WebSocket プロトコルの JSON Schema を書き出す（-out 省略時は標準出力）
+// クライアント側の型生成や結合テストでの検証に使う
+func runWSSchema(args []string) error {
+	fs := flag.NewFlagSet("ws-schema", flag.ContinueOnError)
+	out := fs.String("out", "", "書き出すファイル")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	schema, err := websocket.ProtocolSchemaJSON()
+	if err != nil {
+		return err
+	}
+	schema = append(schema, '\n')
+	if *out == "" {
+		_, err = os.Stdout.Write(schema)
+		return err
+	}
+	return os.WriteFile(*out, schema, 0o644)
+}
+
+// runSetRole はユーザーの権限を変更する（問題の管理APIを使うには "admin" にする）
+// 例: go run . set-role -user alice -role admin
+func runSetRole(args []string) error {
+	fs := flag.NewFlagSet("set-role", flag.ContinueOnError)
+	username := fs.String("user", "", "対象のユーザー名")
+	role := fs.String("role", models.RoleAdmin, "設定する権限（user / admin）")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if *username == "" {
+		return errors.New("-user is required")
+	}
+
+	if err := services.NewUserService(db.DB).SetRole(*username, *role); err != nil {
+		return err
+	}
+	log.Printf("set role of %s to %s", *username, *role)
+	return nil
+}
+
+// runQuestionsImport は CSV / JSONL の問題を問題バンクに取り込む
+// 例: go run . questions-import -bank text-major -file samples.csv -dry-run
+// 不正な行は行番号と理由を表示して読み飛ばす（-format を省略するとファイルの拡張子で決める）
+func runQuestionsImport(args []string) error {
+	fs := flag.NewFlagSet("questions-import", flag.ContinueOnError)
+	bank := fs.String("bank", "", "取り込む問題バンク（text-major / text-rare / audio-major / audio-rare）")
+	file := fs.String("file", "", "取り込むファイル")
+	format := fs.String("format", "", "ファイルの形式（csv / jsonl）")
+	dryRun := fs.Bool("dry-run", false, "DBを変えずに処理結果だけを表示する")
+	by := fs.String("by", "import", "作成者・更新者として記録する名前")
+	audioDir := fs.String("audio-dir", services.DefaultAudioDir, "音声ファイルの存在を確かめるディレクトリ")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if *file == "" {
+		return errors.New("-file is required")
+	}
+	if *format == "" {
+		*format = strings.TrimPrefix(strings.ToLower(filepath.Ext(*file)), ".")
+	}
+
+	f, err := os.Open(*file)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	report, err := services.NewQuestionAdminService(db.DB).Import(f, services.QuestionImportOptions{
+		Bank:     *bank,
+		Format:   *format,
+		DryRun:   *dryRun,
+		Actor:    *by,
+		AudioDir: *audioDir,
+	})
+	if err != nil {
+		return err
+	}
+	for _, row := range report.Rows {
+		if row.Action == services.ImportFailed {
+			log.Printf("line %d: %s %s", row.Line, row.Field, row.Error)
+		}
+	}
+	prefix := ""
+	if report.DryRun {
+		prefix = "(dry run) "
+	}
+	log.Printf("%s%d rows: %d created, %d updated, %d unchanged, %d failed",
+		prefix, report.Total, report.Created, report.Updated, report.Unchanged, report.Failed)
+	return nil
+}
+
+// runQuestionsExport は問題バンクの問題（削除済みを除く）を CSV / JSONL で書き出す（-out 省略時は標準出力）
+// 書き出したファイルは questions-import でそのまま取り込める
+func runQuestionsExport(args []string) error {
+	fs := flag.NewFlagSet("questions-export", flag.ContinueOnError)
+	bank := fs.String("bank", "", "書き出す問題バンク（text-major / text-rare / audio-major / audio-rare）")
+	format := fs.String("format", services.FormatCSV, "ファイルの形式（csv / jsonl）")
+	out := fs.String("out", "", "書き出すファイル")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	var buf bytes.Buffer
+	n, err := services.NewQuestionAdminService(db.DB).Export(&buf, *bank, *format)
+	if err != nil {
+		return err
+	}
+	if *out == "" {
+		_, err = os.Stdout.Write(buf.Bytes())
+		return err
+	}
+	if err := os.WriteFile(*out, buf.Bytes(), 0o644); err != nil {
+		return err
+	}
+	log.Printf("exported %d questions to %s", n, *out)
+	return nil
+}
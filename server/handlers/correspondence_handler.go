@@ -0,0 +1,194 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"example.com/mathkun-tmp-/server/db"
+	"example.com/mathkun-tmp-/server/handlers/websocket"
+	"example.com/mathkun-tmp-/server/models"
+	"example.com/mathkun-tmp-/server/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+// correspondenceAnswerRequest は非同期対戦の回答のリクエスト
+type correspondenceAnswerRequest struct {
+	Round  int    `json:"round"`
+	Answer string `json:"answer"`
+}
+
+// CreateCorrespondence は問題セットを固定した非同期対戦の挑戦を作る（要認証）
+// POST /correspondence で呼ばれる（opponent を省略すると誰でも受けられる挑戦になる）
+func CreateCorrespondence(c *gin.Context) {
+	username, err := usernameFromRequest(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+		return
+	}
+	var req services.CreateCorrespondenceInput
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid request"})
+		return
+	}
+	req.Mode = strings.TrimSpace(req.Mode)
+	if !models.IsRatingMode(req.Mode) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid mode"})
+		return
+	}
+
+	// 問題セットとルールは WebSocket の対戦と同じものを使う
+	questions, roundTime, err := websocket.CorrespondenceQuestions(req.Mode, req.Rated)
+	if err != nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "no questions available"})
+		return
+	}
+
+	match, err := services.NewCorrespondenceService(db.DB).Create(username, req, questions, roundTime)
+	if err != nil {
+		writeCorrespondenceError(c, err)
+		return
+	}
+	c.JSON(http.StatusCreated, match)
+}
+
+// ListCorrespondence は自分が挑戦した・挑戦された非同期対戦を返す（要認証）
+// GET /correspondence?status=open で呼ばれる（status を省略すると全件）
+func ListCorrespondence(c *gin.Context) {
+	username, err := usernameFromRequest(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+		return
+	}
+	matches, err := services.NewCorrespondenceService(db.DB).List(username, strings.TrimSpace(c.Query("status")))
+	if err != nil {
+		writeCorrespondenceError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"matches": matches})
+}
+
+// ListOpenCorrespondence は誰でも受けられる受付中の挑戦を返す（要認証、自分の挑戦は除く）
+// GET /correspondence/open?mode=text-major で呼ばれる
+func ListOpenCorrespondence(c *gin.Context) {
+	username, err := usernameFromRequest(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+		return
+	}
+	matches, err := services.NewCorrespondenceService(db.DB).ListOpen(username, strings.TrimSpace(c.Query("mode")))
+	if err != nil {
+		writeCorrespondenceError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"matches": matches})
+}
+
+// GetCorrespondence は非同期対戦の進み具合と結果を返す（要認証、参加者のみ。相手の得点は決着まで隠す）
+// GET /correspondence/:id で呼ばれる
+func GetCorrespondence(c *gin.Context) {
+	correspondenceAction(c, func(s *services.CorrespondenceService, id uint, username string) (any, error) {
+		return s.Get(id, username)
+	})
+}
+
+// DeclineCorrespondence は始める前の挑戦を取り下げる・断る（要認証）
+// DELETE /correspondence/:id で呼ばれる
+func DeclineCorrespondence(c *gin.Context) {
+	correspondenceAction(c, func(s *services.CorrespondenceService, id uint, username string) (any, error) {
+		if err := s.Decline(id, username); err != nil {
+			return nil, err
+		}
+		return s.Get(id, username)
+	})
+}
+
+// CorrespondenceQuestion は出題中の問題を返す（なければ次の問題を出して制限時間を計り始める）
+// POST /correspondence/:id/question で呼ばれる（同じ問題の間は何度呼んでも残り時間が減っていくだけ）
+func CorrespondenceQuestion(c *gin.Context) {
+	correspondenceAction(c, func(s *services.CorrespondenceService, id uint, username string) (any, error) {
+		question, err := s.Question(id, username)
+		if err != nil {
+			return nil, err
+		}
+		// 最後の問題を時間切れで終えた場合もここで決着を試みる
+		if question.Finished {
+			websocket.SettleCorrespondence(id)
+		}
+		return question, nil
+	})
+}
+
+// AnswerCorrespondence は出題中の問題に回答する（要認証）
+// POST /correspondence/:id/answer で呼ばれる。得点は WebSocket の対戦と同じ速さに応じたカーブで計算する
+func AnswerCorrespondence(c *gin.Context) {
+	var req correspondenceAnswerRequest
+	if err := c.ShouldBindJSON(&req); err != nil || req.Round <= 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid request"})
+		return
+	}
+	correspondenceAction(c, func(s *services.CorrespondenceService, id uint, username string) (any, error) {
+		match, err := s.Get(id, username)
+		if err != nil {
+			return nil, err
+		}
+		result, err := s.Answer(id, username, req.Round, req.Answer, websocket.CorrespondencePoints(match.Mode, match.Rated))
+		if err != nil {
+			return nil, err
+		}
+		if result.Finished {
+			websocket.SettleCorrespondence(id)
+		}
+		return result, nil
+	})
+}
+
+// correspondenceAction は認証とIDの取り出しをまとめ、サービスの結果をそのまま返す
+func correspondenceAction(c *gin.Context, action func(*services.CorrespondenceService, uint, string) (any, error)) {
+	username, err := usernameFromRequest(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+		return
+	}
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil || id == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid id"})
+		return
+	}
+	result, err := action(services.NewCorrespondenceService(db.DB), uint(id), username)
+	if err != nil {
+		writeCorrespondenceError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, result)
+}
+
+// writeCorrespondenceError はサービスのエラーをHTTPステータスに変換して返す
+func writeCorrespondenceError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, services.ErrCorrespondenceNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "not found"})
+	case errors.Is(err, services.ErrUserNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "user not found"})
+	case errors.Is(err, services.ErrInvalidMode):
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid mode"})
+	case errors.Is(err, services.ErrInvalidCorrespondence):
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid correspondence match"})
+	case errors.Is(err, services.ErrInvalidAnswerChoice):
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid answer"})
+	case errors.Is(err, services.ErrNotCorrespondencePlayer):
+		c.JSON(http.StatusForbidden, gin.H{"error": "not a player of this match"})
+	case errors.Is(err, services.ErrCorrespondenceClosed):
+		c.JSON(http.StatusConflict, gin.H{"error": "match is closed"})
+	case errors.Is(err, services.ErrCorrespondenceFinished):
+		c.JSON(http.StatusConflict, gin.H{"error": "already finished"})
+	case errors.Is(err, services.ErrCorrespondenceStarted):
+		c.JSON(http.StatusConflict, gin.H{"error": "match has already started"})
+	case errors.Is(err, services.ErrNoCorrespondenceRound):
+		c.JSON(http.StatusConflict, gin.H{"error": "not the current question"})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "internal server error"})
+	}
+}
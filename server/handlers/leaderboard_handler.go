@@ -3,13 +3,17 @@
 import (
 	"net/http"
 	"strconv"
+	"strings"
 
 	"example.com/mathkun-tmp-/server/db"
+	"example.com/mathkun-tmp-/server/models"
 	"example.com/mathkun-tmp-/server/services"
 
 	"github.com/gin-gonic/gin"
 )
 
+// GetLeaderboard はレーティング上位のプレイヤーを返す
+// GET /leaderboard?mode=text-major のようにモードを指定するとモード別のランキングになる
 func GetLeaderboard(c *gin.Context) {
 	limit := 30
 	if raw := c.Query("limit"); raw != "" {
@@ -17,9 +21,22 @@ func GetLeaderboard(c *gin.Context) {
 			limit = v
 		}
 	}
+	mode := strings.TrimSpace(c.Query("mode"))
+	if mode != "" && !models.IsRatingMode(mode) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid mode"})
+		return
+	}
 
 	leaderboardService := services.NewLeaderboardService(db.DB)
-	rows, err := leaderboardService.GetTopPlayers(limit)
+	var (
+		rows []services.LeaderboardRowDTO
+		err  error
+	)
+	if mode != "" {
+		rows, err = leaderboardService.GetTopPlayersByMode(mode, limit)
+	} else {
+		rows, err = leaderboardService.GetTopPlayers(limit)
+	}
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to load leaderboard"})
 		return
@@ -0,0 +1,74 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"example.com/mathkun-tmp-/server/db"
+	"example.com/mathkun-tmp-/server/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+// GetUserMatches は指定ユーザーの対戦履歴をページングして返す（認証不要）
+// GET /users/:username/matches?page=1&perPage=20 で呼ばれる
+func GetUserMatches(c *gin.Context) {
+	username := strings.TrimSpace(c.Param("username"))
+	if username == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid username"})
+		return
+	}
+
+	// ページ番号と1ページあたりの件数（範囲外の値はサービス層で補正）
+	page := 1
+	if raw := c.Query("page"); raw != "" {
+		if v, err := strconv.Atoi(raw); err == nil {
+			page = v
+		}
+	}
+	perPage := 20
+	if raw := c.Query("perPage"); raw != "" {
+		if v, err := strconv.Atoi(raw); err == nil {
+			perPage = v
+		}
+	}
+
+	matchService := services.NewMatchService(db.DB)
+	result, err := matchService.GetUserMatches(username, page, perPage)
+	if err != nil {
+		// ユーザーが見つからない場合だけ404、DBの障害などは500
+		if errors.Is(err, services.ErrUserNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "not found"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to load matches"})
+		return
+	}
+
+	c.JSON(http.StatusOK, result)
+}
+
+// GetMatch は1試合分の詳細（ラウンドごとの回答付き）を返す（認証不要）
+// GET /matches/:id で呼ばれる
+func GetMatch(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil || id == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid match id"})
+		return
+	}
+
+	matchService := services.NewMatchService(db.DB)
+	match, err := matchService.GetMatch(uint(id))
+	if err != nil {
+		if errors.Is(err, services.ErrMatchNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "not found"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to load match"})
+		return
+	}
+
+	c.JSON(http.StatusOK, match)
+}
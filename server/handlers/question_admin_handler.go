@@ -0,0 +1,234 @@
+package handlers
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"example.com/mathkun-tmp-/server/db"
+	"example.com/mathkun-tmp-/server/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+// maxImportBytes は問題の取り込みで受け付けるファイルの大きさの上限
+const maxImportBytes = 10 << 20
+
+// adminUsernameKey は RequireAdmin が確認した管理者のユーザー名を gin.Context に入れるキー
+const adminUsernameKey = "adminUsername"
+
+// RequireAdmin は管理者（role が "admin" のユーザー）だけを通すミドルウェア
+// 未ログインなら401、管理者でなければ403を返して処理を止める
+func RequireAdmin(c *gin.Context) {
+	username, err := usernameFromRequest(c)
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+		return
+	}
+	err = services.NewQuestionAdminService(db.DB).RequireAdmin(username)
+	switch {
+	case err == nil:
+		c.Set(adminUsernameKey, username)
+		c.Next()
+	case errors.Is(err, services.ErrForbidden):
+		c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "forbidden"})
+	case errors.Is(err, services.ErrUserNotFound):
+		c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+	default:
+		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "internal server error"})
+	}
+}
+
+// ListAdminQuestions は問題バンクを検索してページングして返す（管理者のみ）
+// GET /admin/questions/:bank?q=hello&language=English&deleted=include&page=1&perPage=50 で呼ばれる
+// deleted は省略すると削除済みを除き、"only" で削除済みだけ、"include" で両方を返す
+func ListAdminQuestions(c *gin.Context) {
+	page := 1
+	if raw := c.Query("page"); raw != "" {
+		if v, err := strconv.Atoi(raw); err == nil {
+			page = v
+		}
+	}
+	perPage := 50
+	if raw := c.Query("perPage"); raw != "" {
+		if v, err := strconv.Atoi(raw); err == nil {
+			perPage = v
+		}
+	}
+
+	result, err := services.NewQuestionAdminService(db.DB).List(c.Param("bank"), services.QuestionListInput{
+		Query:    c.Query("q"),
+		Language: c.Query("language"),
+		Deleted:  strings.TrimSpace(c.Query("deleted")),
+		Page:     page,
+		PerPage:  perPage,
+	})
+	if err != nil {
+		writeQuestionAdminError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, result)
+}
+
+// GetAdminQuestion は問題を1件返す（管理者のみ、削除済みの問題も返す）
+// GET /admin/questions/:bank/:id で呼ばれる
+func GetAdminQuestion(c *gin.Context) {
+	questionAdminAction(c, func(s *services.QuestionAdminService, bank string, id uint, _ string) (*services.AdminQuestionDTO, error) {
+		return s.Get(bank, id)
+	})
+}
+
+// CreateAdminQuestion は問題を追加する（管理者のみ）
+// POST /admin/questions/:bank で呼ばれる（テキスト問題は prompt と answer、音声問題は language と audioUrl）
+func CreateAdminQuestion(c *gin.Context) {
+	var req services.QuestionInput
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid request"})
+		return
+	}
+	question, err := services.NewQuestionAdminService(db.DB).Create(c.Param("bank"), c.GetString(adminUsernameKey), req)
+	if err != nil {
+		writeQuestionAdminError(c, err)
+		return
+	}
+	c.JSON(http.StatusCreated, question)
+}
+
+// UpdateAdminQuestion は問題の内容を置き換える（管理者のみ、削除済みの問題は404）
+// PUT /admin/questions/:bank/:id で呼ばれる
+func UpdateAdminQuestion(c *gin.Context) {
+	var req services.QuestionInput
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid request"})
+		return
+	}
+	questionAdminAction(c, func(s *services.QuestionAdminService, bank string, id uint, admin string) (*services.AdminQuestionDTO, error) {
+		return s.Update(bank, id, admin, req)
+	})
+}
+
+// DeleteAdminQuestion は問題を論理削除する（管理者のみ）
+// DELETE /admin/questions/:bank/:id で呼ばれる（削除済みの問題は出題されなくなる）
+func DeleteAdminQuestion(c *gin.Context) {
+	questionAdminAction(c, func(s *services.QuestionAdminService, bank string, id uint, admin string) (*services.AdminQuestionDTO, error) {
+		return s.Delete(bank, id, admin)
+	})
+}
+
+// RestoreAdminQuestion は論理削除した問題を元に戻す（管理者のみ）
+// POST /admin/questions/:bank/:id/restore で呼ばれる
+func RestoreAdminQuestion(c *gin.Context) {
+	questionAdminAction(c, func(s *services.QuestionAdminService, bank string, id uint, admin string) (*services.AdminQuestionDTO, error) {
+		return s.Restore(bank, id, admin)
+	})
+}
+
+// ImportAdminQuestions は CSV / JSONL の問題を問題バンクに取り込む（管理者のみ）
+// POST /admin/questions/:bank/import?format=csv&dryRun=true で呼ばれる
+// ファイルは multipart の "file" か、リクエストの本文そのままで送る。format を省略するとファイル名の拡張子で決める
+// 不正な行があっても 200 で返し、行ごとの結果（rows）に理由を載せる
+func ImportAdminQuestions(c *gin.Context) {
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, maxImportBytes)
+	format := strings.ToLower(strings.TrimSpace(c.Query("format")))
+	var body io.Reader = c.Request.Body
+	if strings.HasPrefix(c.ContentType(), "multipart/form-data") {
+		header, err := c.FormFile("file")
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "file is required"})
+			return
+		}
+		if format == "" {
+			format = strings.TrimPrefix(strings.ToLower(filepath.Ext(header.Filename)), ".")
+		}
+		file, err := header.Open()
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid request"})
+			return
+		}
+		defer file.Close()
+		body = file
+	}
+	dryRun, _ := strconv.ParseBool(c.Query("dryRun"))
+
+	report, err := services.NewQuestionAdminService(db.DB).Import(body, services.QuestionImportOptions{
+		Bank:   c.Param("bank"),
+		Format: format,
+		DryRun: dryRun,
+		Actor:  c.GetString(adminUsernameKey),
+	})
+	if err != nil {
+		writeQuestionAdminError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, report)
+}
+
+// ExportAdminQuestions は問題バンクの問題（削除済みを除く）を CSV / JSONL で返す（管理者のみ）
+// GET /admin/questions/:bank/export?format=jsonl で呼ばれる（format の既定は csv）
+func ExportAdminQuestions(c *gin.Context) {
+	bank := c.Param("bank")
+	format := strings.ToLower(strings.TrimSpace(c.DefaultQuery("format", services.FormatCSV)))
+	contentType := "text/csv; charset=utf-8"
+	switch format {
+	case services.FormatCSV:
+	case services.FormatJSONL:
+		contentType = "application/x-ndjson; charset=utf-8"
+	default:
+		writeQuestionAdminError(c, services.ErrInvalidFormat)
+		return
+	}
+
+	// 途中でエラーになっても中途半端なファイルを返さないよう、書き出し終えてから送る
+	var buf bytes.Buffer
+	if _, err := services.NewQuestionAdminService(db.DB).Export(&buf, bank, format); err != nil {
+		writeQuestionAdminError(c, err)
+		return
+	}
+	filename := fmt.Sprintf("%s-%s.%s", bank, time.Now().Format("20060102"), format)
+	c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=%q", filename))
+	c.Data(http.StatusOK, contentType, buf.Bytes())
+}
+
+// questionAdminAction は問題IDの取り出しをまとめ、サービスの結果をそのまま返す
+func questionAdminAction(c *gin.Context, action func(*services.QuestionAdminService, string, uint, string) (*services.AdminQuestionDTO, error)) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil || id == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid question id"})
+		return
+	}
+	question, err := action(services.NewQuestionAdminService(db.DB), c.Param("bank"), uint(id), c.GetString(adminUsernameKey))
+	if err != nil {
+		writeQuestionAdminError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, question)
+}
+
+// writeQuestionAdminError はサービスのエラーをHTTPステータスに変換して返す
+// 入力の検証エラーはどの項目がなぜ不正かも返す
+func writeQuestionAdminError(c *gin.Context, err error) {
+	var fieldErr *services.QuestionFieldError
+	switch {
+	case errors.As(err, &fieldErr):
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid question", "field": fieldErr.Field, "reason": fieldErr.Reason})
+	case errors.Is(err, services.ErrInvalidQuestionBank):
+		c.JSON(http.StatusNotFound, gin.H{"error": "unknown question bank"})
+	case errors.Is(err, services.ErrQuestionNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "not found"})
+	case errors.Is(err, services.ErrInvalidQuestion):
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid request"})
+	case errors.Is(err, services.ErrInvalidFormat):
+		c.JSON(http.StatusBadRequest, gin.H{"error": "format must be csv or jsonl"})
+	case errors.Is(err, services.ErrInvalidImport):
+		// ファイル自体が読めない場合は理由（知らない列・壊れた引用符など）をそのまま返す
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "internal server error"})
+	}
+}
@@ -0,0 +1,76 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	"example.com/mathkun-tmp-/server/db"
+	"example.com/mathkun-tmp-/server/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+// GetRatingHistory は指定ユーザーのレーティング推移を返す（認証不要）
+// GET /users/:username/rating-history?mode=text-major&from=2024-01-01&to=2024-02-01&bucket=week で呼ばれる
+// from / to は日付（YYYY-MM-DD、サーバーのタイムゾーン）か RFC3339、to の日付はその日を含む
+func GetRatingHistory(c *gin.Context) {
+	username := strings.TrimSpace(c.Param("username"))
+	if username == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid username"})
+		return
+	}
+
+	query := services.RatingHistoryQuery{
+		Mode:   strings.TrimSpace(c.Query("mode")),
+		Bucket: strings.TrimSpace(c.Query("bucket")),
+	}
+	var err error
+	if query.From, err = parseHistoryTime(c.Query("from"), false); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid from"})
+		return
+	}
+	if query.To, err = parseHistoryTime(c.Query("to"), true); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid to"})
+		return
+	}
+
+	historyService := services.NewRatingHistoryService(db.DB)
+	result, err := historyService.GetRatingHistory(username, query)
+	if err != nil {
+		switch {
+		case errors.Is(err, services.ErrUserNotFound):
+			c.JSON(http.StatusNotFound, gin.H{"error": "not found"})
+		case errors.Is(err, services.ErrInvalidBucket):
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid bucket"})
+		case errors.Is(err, services.ErrInvalidMode):
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid mode"})
+		default:
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to load rating history"})
+		}
+		return
+	}
+
+	c.JSON(http.StatusOK, result)
+}
+
+// parseHistoryTime は期間指定のクエリを解釈する（空ならゼロ値）
+// 日付だけの to は翌日0時に直して、その日の試合も含める
+func parseHistoryTime(raw string, endOfDay bool) (time.Time, error) {
+	raw = strings.TrimSpace(raw)
+	if raw == "" {
+		return time.Time{}, nil
+	}
+	if t, err := time.Parse(time.RFC3339, raw); err == nil {
+		return t, nil
+	}
+	t, err := time.ParseInLocation("2006-01-02", raw, time.Local)
+	if err != nil {
+		return time.Time{}, err
+	}
+	if endOfDay {
+		t = t.AddDate(0, 0, 1)
+	}
+	return t, nil
+}
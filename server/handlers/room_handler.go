@@ -0,0 +1,22 @@
+package handlers
+
+import (
+	"net/http"
+	"strings"
+
+	"example.com/mathkun-tmp-/server/handlers/websocket"
+	"example.com/mathkun-tmp-/server/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ListRooms は進行中の公開マッチを平均レーティングの高い順に返す（認証不要）
+// GET /rooms?mode=text-major で呼ばれる（modeを省略すると全モード）
+func ListRooms(c *gin.Context) {
+	mode := strings.TrimSpace(c.Query("mode"))
+	if mode != "" && !models.IsRatingMode(mode) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid mode"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"rooms": websocket.RunningRooms(mode)})
+}
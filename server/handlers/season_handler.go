@@ -0,0 +1,56 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"example.com/mathkun-tmp-/server/db"
+	"example.com/mathkun-tmp-/server/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ListSeasons はシーズン一覧を新しい順に返す（認証不要）
+// GET /seasons で呼ばれる
+func ListSeasons(c *gin.Context) {
+	seasonService := services.NewSeasonService(db.DB)
+	seasons, err := seasonService.ListSeasons()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to load seasons"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"seasons": seasons})
+}
+
+// GetSeasonLeaderboard はシーズンのランキングを返す（認証不要）
+// GET /seasons/:id/leaderboard?mode=text-major&limit=30 で呼ばれる（modeを省略すると全モード共通）
+func GetSeasonLeaderboard(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil || id == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid season id"})
+		return
+	}
+	limit := 30
+	if raw := c.Query("limit"); raw != "" {
+		if v, err := strconv.Atoi(raw); err == nil {
+			limit = v
+		}
+	}
+
+	seasonService := services.NewSeasonService(db.DB)
+	result, err := seasonService.GetLeaderboard(uint(id), strings.TrimSpace(c.Query("mode")), limit)
+	if err != nil {
+		switch {
+		case errors.Is(err, services.ErrSeasonNotFound):
+			c.JSON(http.StatusNotFound, gin.H{"error": "not found"})
+		case errors.Is(err, services.ErrInvalidMode):
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid mode"})
+		default:
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to load leaderboard"})
+		}
+		return
+	}
+	c.JSON(http.StatusOK, result)
+}
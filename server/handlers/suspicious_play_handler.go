@@ -0,0 +1,63 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"example.com/mathkun-tmp-/server/db"
+	"example.com/mathkun-tmp-/server/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+// reviewSuspiciousPlayRequest は疑わしいプレイの確認結果のリクエスト
+type reviewSuspiciousPlayRequest struct {
+	Status string `json:"status"` // "cleared"（問題なし）か "confirmed"（不正と判断）
+}
+
+// ListSuspiciousPlays は確認待ちの疑わしいプレイを新しい順に返す（管理者のみ）
+// GET /admin/suspicious-plays?limit=50 で呼ばれる
+func ListSuspiciousPlays(c *gin.Context) {
+	limit := 50
+	if raw := c.Query("limit"); raw != "" {
+		if v, err := strconv.Atoi(raw); err == nil {
+			limit = v
+		}
+	}
+	flags, err := services.NewSuspiciousPlayService(db.DB).ListPending(limit)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "internal server error"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"flags": flags})
+}
+
+// ReviewSuspiciousPlay は疑わしいプレイに確認結果を記録する（管理者のみ）
+// POST /admin/suspicious-plays/:id/review で呼ばれる
+func ReviewSuspiciousPlay(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil || id == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid id"})
+		return
+	}
+	var req reviewSuspiciousPlayRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid request"})
+		return
+	}
+
+	flag, err := services.NewSuspiciousPlayService(db.DB).Review(uint(id), c.GetString(adminUsernameKey), req.Status)
+	switch {
+	case err == nil:
+		c.JSON(http.StatusOK, flag)
+	case errors.Is(err, services.ErrInvalidReviewStatus):
+		c.JSON(http.StatusBadRequest, gin.H{"error": "status must be cleared or confirmed"})
+	case errors.Is(err, services.ErrSuspiciousPlayNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "not found"})
+	case errors.Is(err, services.ErrAlreadyReviewed):
+		c.JSON(http.StatusConflict, gin.H{"error": "already reviewed"})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "internal server error"})
+	}
+}
@@ -0,0 +1,151 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"example.com/mathkun-tmp-/server/db"
+	"example.com/mathkun-tmp-/server/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ListTournaments は大会一覧を開始予定の新しい順に返す（認証不要）
+// GET /tournaments?status=registration で呼ばれる（status を省略すると全件）
+func ListTournaments(c *gin.Context) {
+	tournamentService := services.NewTournamentService(db.DB)
+	tournaments, err := tournamentService.ListTournaments(strings.TrimSpace(c.Query("status")))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to load tournaments"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"tournaments": tournaments})
+}
+
+// CreateTournament は大会を作成する（要認証、作成者が主催者になる）
+// POST /tournaments で呼ばれる
+func CreateTournament(c *gin.Context) {
+	username, err := usernameFromRequest(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+		return
+	}
+	var req services.CreateTournamentInput
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid request"})
+		return
+	}
+
+	tournamentService := services.NewTournamentService(db.DB)
+	tournament, err := tournamentService.CreateTournament(username, req)
+	if err != nil {
+		writeTournamentError(c, err)
+		return
+	}
+	c.JSON(http.StatusCreated, tournament)
+}
+
+// GetTournament は大会の参加者・組み合わせ・途中経過を返す（認証不要）
+// GET /tournaments/:id で呼ばれる
+func GetTournament(c *gin.Context) {
+	id, ok := tournamentIDParam(c)
+	if !ok {
+		return
+	}
+	tournamentService := services.NewTournamentService(db.DB)
+	bracket, err := tournamentService.GetBracket(id)
+	if err != nil {
+		writeTournamentError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, bracket)
+}
+
+// RegisterTournament は大会に参加登録する（要認証）
+// POST /tournaments/:id/register で呼ばれる
+func RegisterTournament(c *gin.Context) {
+	tournamentAction(c, func(s *services.TournamentService, id uint, username string) error {
+		return s.Register(id, username)
+	})
+}
+
+// WithdrawTournament は大会の参加登録を取り消す（要認証、開始前のみ）
+// DELETE /tournaments/:id/register で呼ばれる
+func WithdrawTournament(c *gin.Context) {
+	tournamentAction(c, func(s *services.TournamentService, id uint, username string) error {
+		return s.Withdraw(id, username)
+	})
+}
+
+// StartTournament は開始予定を待たずに大会を始める（要認証、主催者のみ）
+// POST /tournaments/:id/start で呼ばれる
+func StartTournament(c *gin.Context) {
+	tournamentAction(c, func(s *services.TournamentService, id uint, username string) error {
+		return s.Start(id, username)
+	})
+}
+
+// tournamentAction は認証と大会IDの取り出しを済ませてから操作を行い、結果の組み合わせを返す
+func tournamentAction(c *gin.Context, action func(s *services.TournamentService, id uint, username string) error) {
+	username, err := usernameFromRequest(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+		return
+	}
+	id, ok := tournamentIDParam(c)
+	if !ok {
+		return
+	}
+
+	tournamentService := services.NewTournamentService(db.DB)
+	if err := action(tournamentService, id, username); err != nil {
+		writeTournamentError(c, err)
+		return
+	}
+	bracket, err := tournamentService.GetBracket(id)
+	if err != nil {
+		writeTournamentError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, bracket)
+}
+
+// tournamentIDParam はパスの大会IDを取り出す（不正なら400を返してfalse）
+func tournamentIDParam(c *gin.Context) (uint, bool) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil || id == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid tournament id"})
+		return 0, false
+	}
+	return uint(id), true
+}
+
+// writeTournamentError は大会のサービス層のエラーをHTTPステータスに変換する
+func writeTournamentError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, services.ErrTournamentNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "not found"})
+	case errors.Is(err, services.ErrUserNotFound):
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+	case errors.Is(err, services.ErrNotOrganizer):
+		c.JSON(http.StatusForbidden, gin.H{"error": "only the organizer can do this"})
+	case errors.Is(err, services.ErrInvalidMode):
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid mode"})
+	case errors.Is(err, services.ErrInvalidTournament):
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid tournament"})
+	case errors.Is(err, services.ErrRegistrationClosed):
+		c.JSON(http.StatusConflict, gin.H{"error": "registration is closed"})
+	case errors.Is(err, services.ErrTournamentFull):
+		c.JSON(http.StatusConflict, gin.H{"error": "tournament is full"})
+	case errors.Is(err, services.ErrAlreadyRegistered):
+		c.JSON(http.StatusConflict, gin.H{"error": "already registered"})
+	case errors.Is(err, services.ErrNotRegistered):
+		c.JSON(http.StatusNotFound, gin.H{"error": "not registered"})
+	case errors.Is(err, services.ErrNotEnoughPlayers):
+		c.JSON(http.StatusConflict, gin.H{"error": "not enough players"})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "internal server error"})
+	}
+}
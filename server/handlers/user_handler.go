@@ -6,6 +6,7 @@ import (
 	"strings"
 
 	"example.com/mathkun-tmp-/server/db"
+	"example.com/mathkun-tmp-/server/models"
 	"example.com/mathkun-tmp-/server/repositories"
 	"example.com/mathkun-tmp-/server/services"
 
@@ -140,6 +141,7 @@ func GetMe(c *gin.Context) {
 
 // GetMyRank は認証済みユーザーの順位情報を返す
 // GET /api/me/rank で呼ばれる（要認証）
+// ?mode=text-major のようにモードを指定するとそのモードでの順位を返す
 func GetMyRank(c *gin.Context) {
 	// Authorizationヘッダーからユーザー名を取り出す
 	username, err := usernameFromRequest(c)
@@ -149,9 +151,21 @@ func GetMyRank(c *gin.Context) {
 		return
 	}
 
+	// モード指定があれば検証する
+	mode := strings.TrimSpace(c.Query("mode"))
+	if mode != "" && !models.IsRatingMode(mode) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid mode"})
+		return
+	}
+
 	// ユーザーサービスを作成して順位情報を取得
 	userService := services.NewUserService(db.DB)
-	rankInfo, err := userService.GetUserRank(username)
+	var rankInfo *services.UserRankDTO
+	if mode != "" {
+		rankInfo, err = userService.GetUserModeRank(username, mode)
+	} else {
+		rankInfo, err = userService.GetUserRank(username)
+	}
 	if err != nil {
 		// 取得失敗時は500エラー
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to load rank"})
@@ -160,8 +174,13 @@ func GetMyRank(c *gin.Context) {
 
 	// レスポンス用のマップを作成
 	resp := gin.H{
-		"rating":     rankInfo.Rating,     // レーティング（必須）
-		"matchCount": rankInfo.MatchCount, // 試合数（必須）
+		"rating":      rankInfo.Rating,      // レーティング（必須）
+		"matchCount":  rankInfo.MatchCount,  // 試合数（必須）
+		"deviation":   rankInfo.Deviation,   // レーティングの不確かさ（RD）
+		"provisional": rankInfo.Provisional, // 暫定レーティングかどうか
+	}
+	if mode != "" {
+		resp["mode"] = mode
 	}
 	// 順位は試合数が足りている場合のみ返す
 	if rankInfo.Rank != nil {
@@ -321,6 +340,9 @@ func writeError(c *gin.Context, err error) {
 	case services.ErrUsernameTaken:
 		// ユーザー名が既に使われている場合は409 Conflict
 		c.JSON(http.StatusConflict, gin.H{"error": "username already taken"})
+	case services.ErrUsernameReserved:
+		// bot 用の予約名は400 Bad Request
+		c.JSON(http.StatusBadRequest, gin.H{"error": "username is reserved"})
 	case services.ErrInvalidCredentials:
 		// 認証情報が不正な場合は401 Unauthorized
 		c.JSON(http.StatusUnauthorized, gin.H{"error": "invalid username or password"})
@@ -0,0 +1,66 @@
+package websocket
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// 不正検知のしきい値
+const (
+	minHumanTextResponse  = 700 * time.Millisecond  // テキスト問題を読んで答えるのに最低限かかる時間
+	minHumanAudioResponse = 1000 * time.Millisecond // 音声問題を聞いて答えるのに最低限かかる時間
+	suspiciousFastCorrect = 2                       // 1試合でこの回数以上の高速正解があれば記録する
+)
+
+// suspicion は1試合の中で不自然な回答をしたプレイヤーの集計
+type suspicion struct {
+	Username         string
+	FastCorrectCount int
+	CorrectCount     int
+	MinResponse      time.Duration
+	Reason           string
+}
+
+// detectSuspiciousPlay はサーバーで計測した回答時間から不自然に速い正解を探す
+// 人間が問題を読んで（聞いて）選ぶのに必要な時間より速い正解が続いたプレイヤーを返す
+func detectSuspiciousPlay(mode string, history []roundRecord) []suspicion {
+	threshold := minHumanTextResponse
+	if strings.HasPrefix(mode, "audio-") {
+		threshold = minHumanAudioResponse
+	}
+
+	byUser := map[string]*suspicion{}
+	order := []string{}
+	for _, rec := range history {
+		for _, a := range rec.Answers {
+			if !a.Correct {
+				continue
+			}
+			s, ok := byUser[a.Username]
+			if !ok {
+				s = &suspicion{Username: a.Username, MinResponse: a.Elapsed}
+				byUser[a.Username] = s
+				order = append(order, a.Username)
+			}
+			s.CorrectCount++
+			if a.Elapsed < s.MinResponse {
+				s.MinResponse = a.Elapsed
+			}
+			if a.Elapsed < threshold {
+				s.FastCorrectCount++
+			}
+		}
+	}
+
+	var found []suspicion
+	for _, name := range order {
+		s := byUser[name]
+		if s.FastCorrectCount < suspiciousFastCorrect {
+			continue
+		}
+		s.Reason = fmt.Sprintf("%d correct answers faster than %dms", s.FastCorrectCount, threshold.Milliseconds())
+		found = append(found, *s)
+	}
+	return found
+}
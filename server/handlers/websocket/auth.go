@@ -5,6 +5,7 @@ import (
 	"os"
 	"strings"
 
+	"github.com/gin-gonic/gin"
 	"github.com/golang-jwt/jwt/v5"
 )
 
@@ -34,3 +35,13 @@ func usernameFromToken(tokenString string) (string, error) {
 	}
 	return claims.Username, nil
 }
+
+// usernameFromRequest はREST APIの Authorization: Bearer ヘッダーからユーザー名を取り出す
+func usernameFromRequest(c *gin.Context) (string, error) {
+	const prefix = "Bearer "
+	authHeader := strings.TrimSpace(c.GetHeader("Authorization"))
+	if !strings.HasPrefix(authHeader, prefix) {
+		return "", errors.New("missing bearer token")
+	}
+	return usernameFromToken(strings.TrimSpace(strings.TrimPrefix(authHeader, prefix)))
+}
@@ -0,0 +1,235 @@
+package websocket
+
+import (
+	"encoding/json"
+	"math/rand"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"example.com/mathkun-tmp-/server/models"
+)
+
+// defaultBotQueueTimeout はこの時間待っても相手が見つからなければ bot を相手にする既定値
+const defaultBotQueueTimeout = 30 * time.Second
+
+// botProfile は bot の強さ（言語ごとの正答率と回答までの時間）
+type botProfile struct {
+	Name             string
+	Rating           int                // マッチングとレーティング戦で使う固定レーティング
+	Accuracy         float64            // 言語ごとの設定がない問題の正答率
+	LanguageAccuracy map[string]float64 // 正解の言語名ごとの正答率
+	MinLatency       time.Duration      // テキスト問題で回答するまでの最短時間
+	MaxLatency       time.Duration      // テキスト問題で回答するまでの最長時間
+	AudioExtra       time.Duration      // 音声問題では聞く時間としてこれを上乗せする
+}
+
+// botProfiles は用意している bot の強さ（レーティングの低い順）
+// 初心者は有名な言語しか見分けられず、多言語話者は珍しい言語もほぼ正解する
+var botProfiles = []botProfile{
+	{
+		Name:     "beginner",
+		Rating:   800,
+		Accuracy: 0.3,
+		LanguageAccuracy: map[string]float64{
+			"English":  0.95,
+			"Japanese": 0.95,
+			"Chinese":  0.7,
+			"Korean":   0.6,
+			"Spanish":  0.55,
+			"French":   0.55,
+		},
+		MinLatency: 4 * time.Second,
+		MaxLatency: 8 * time.Second,
+		AudioExtra: 3 * time.Second,
+	},
+	{
+		Name:     "intermediate",
+		Rating:   1100,
+		Accuracy: 0.6,
+		LanguageAccuracy: map[string]float64{
+			"English":    0.98,
+			"Japanese":   0.98,
+			"Chinese":    0.9,
+			"Korean":     0.9,
+			"Spanish":    0.85,
+			"French":     0.85,
+			"German":     0.85,
+			"Italian":    0.8,
+			"Portuguese": 0.65,
+			"Russian":    0.75,
+			"Ukrainian":  0.4,
+			"Georgian":   0.3,
+			"Amharic":    0.3,
+			"Khmer":      0.3,
+			"Sinhala":    0.3,
+		},
+		MinLatency: 2 * time.Second,
+		MaxLatency: 6 * time.Second,
+		AudioExtra: 2 * time.Second,
+	},
+	{
+		Name:     "polyglot",
+		Rating:   1450,
+		Accuracy: 0.9,
+		LanguageAccuracy: map[string]float64{
+			"Ukrainian": 0.8,
+			"Mongolian": 0.8,
+			"Maltese":   0.8,
+		},
+		MinLatency: 1500 * time.Millisecond,
+		MaxLatency: 4 * time.Second,
+		AudioExtra: 1500 * time.Millisecond,
+	},
+}
+
+// profileForRating はレーティングが最も近い bot の強さを返す
+func profileForRating(rating int) botProfile {
+	best := botProfiles[0]
+	for _, p := range botProfiles[1:] {
+		if abs(p.Rating-rating) < abs(best.Rating-rating) {
+			best = p
+		}
+	}
+	return best
+}
+
+// accuracyFor は正解の言語に対する正答率を返す
+func (p botProfile) accuracyFor(language string) float64 {
+	if acc, ok := p.LanguageAccuracy[language]; ok {
+		return acc
+	}
+	return p.Accuracy
+}
+
+// latency は回答までの時間を最短〜最長の間でランダムに決める
+func (p botProfile) latency(mode string, rng *rand.Rand) time.Duration {
+	delay := p.MinLatency
+	if spread := p.MaxLatency - p.MinLatency; spread > 0 {
+		delay += time.Duration(rng.Int63n(int64(spread)))
+	}
+	if strings.HasPrefix(mode, "audio-") {
+		delay += p.AudioExtra
+	}
+	return delay
+}
+
+// botConn は bot 用の接続で、実際の接続と同じように送信メッセージを受け取る
+// 出題（match:round）を受け取ると、強さに応じた時間と正答率で回答する
+// ネットワークは使わず、同じプロセス内で processAnswer を呼ぶ
+type botConn struct {
+	client  *client
+	profile botProfile
+	mu      sync.Mutex // rng を守る（回答はタイマーのゴルーチンから行う）
+	rng     *rand.Rand
+}
+
+// newBotClient は指定した強さの bot をクライアントとして作る
+// rng を固定すれば回答内容とタイミングを再現できる
+func newBotClient(profile botProfile, mode string, rng *rand.Rand) *client {
+	if rng == nil {
+		rng = rand.New(rand.NewSource(time.Now().UnixNano()))
+	}
+	conn := &botConn{profile: profile, rng: rng}
+	c := newClient(conn)
+	c.username = models.BotUsernamePrefix + profile.Name
+	c.rating = profile.Rating
+	c.mode = mode
+	c.bot = conn
+	conn.client = c
+	return c
+}
+
+// WriteJSON は bot に送られたメッセージを処理する（出題以外は読み捨てる）
+// 1問目は match:started、2問目以降は match:round として届く
+func (b *botConn) WriteJSON(v any) error {
+	msg, ok := v.(wsMessage)
+	if !ok || (msg.Type != "match:started" && msg.Type != "match:round") {
+		return nil
+	}
+	var round roundPayload
+	if err := json.Unmarshal(msg.Payload, &round); err != nil {
+		return nil
+	}
+	if r := state.GetRoom(round.RoomID); r != nil {
+		b.answerLater(r)
+	}
+	return nil
+}
+
+// answerLater は回答までの時間を待ってから、出題中の問題に回答する
+// 待っている間にラウンドが終わっていれば何もしない
+func (b *botConn) answerLater(r *room) {
+	r.mu.Lock()
+	seq := r.roundSeq
+	mode := r.mode
+	r.mu.Unlock()
+
+	b.mu.Lock()
+	delay := b.profile.latency(mode, b.rng)
+	b.mu.Unlock()
+
+	time.AfterFunc(delay, func() {
+		r.mu.Lock()
+		if r.finished || !r.active || r.roundSeq != seq || r.question == nil {
+			r.mu.Unlock()
+			return
+		}
+		choice := b.pickChoice(r.question)
+		r.mu.Unlock()
+		processAnswer(b.client, r, choice)
+	})
+}
+
+// pickChoice は正答率に従って正解か、正解以外の選択肢をランダムに選ぶ
+func (b *botConn) pickChoice(q *matchQuestion) string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.rng.Float64() < b.profile.accuracyFor(q.Answer) {
+		return q.Answer
+	}
+	wrong := make([]string, 0, len(q.Choices))
+	for _, choice := range q.Choices {
+		if choice != q.Answer {
+			wrong = append(wrong, choice)
+		}
+	}
+	if len(wrong) == 0 {
+		return q.Answer
+	}
+	return wrong[b.rng.Intn(len(wrong))]
+}
+
+// botQueueTimeoutFromEnv は bot が相手になるまでの待ち時間を環境変数 BOT_QUEUE_TIMEOUT から読む
+// "45s" のような time.Duration 形式か秒数で指定し、"0" で bot を無効にする
+func botQueueTimeoutFromEnv() time.Duration {
+	raw := strings.TrimSpace(os.Getenv("BOT_QUEUE_TIMEOUT"))
+	if raw == "" {
+		return defaultBotQueueTimeout
+	}
+	if d, err := time.ParseDuration(raw); err == nil {
+		return d
+	}
+	if sec, err := strconv.Atoi(raw); err == nil {
+		return time.Duration(sec) * time.Second
+	}
+	return defaultBotQueueTimeout
+}
+
+// botMatchesRatedFromEnv は bot 戦をレーティング戦にするかを環境変数 BOT_MATCHES_RATED から読む
+// レーティング戦にした場合、bot は強さごとの固定レーティングとして計算し、bot 側は更新しない
+func botMatchesRatedFromEnv() bool {
+	rated, _ := strconv.ParseBool(strings.TrimSpace(os.Getenv("BOT_MATCHES_RATED")))
+	return rated
+}
+
+// abs は整数の絶対値を返す
+func abs(v int) int {
+	if v < 0 {
+		return -v
+	}
+	return v
+}
@@ -7,11 +7,11 @@ import (
 
 // buildAudioChoices は音声問題用の選択肢を生成する（メジャーモード）
 func buildAudioChoices(correct string, rng *rand.Rand) []string {
-	return buildAudioChoicesWithMode(correct, rng, "major")
+	return buildAudioChoicesWithMode(correct, rng, "major", defaultChoiceCount)
 }
 
-// buildAudioChoicesWithMode はモード別に音声問題用の選択肢を生成する
-func buildAudioChoicesWithMode(correct string, rng *rand.Rand, mode string) []string {
+// buildAudioChoicesWithMode はモード別に音声問題用の選択肢を count 個生成する
+func buildAudioChoicesWithMode(correct string, rng *rand.Rand, mode string, count int) []string {
 	// モードに応じて言語プールを選択
 	var languagePool []string
 	if mode == "rare" {
@@ -27,13 +27,14 @@ func buildAudioChoicesWithMode(correct string, rng *rand.Rand, mode string) []st
 		}
 	}
 	unique := make(map[string]struct{}, len(candidates)+1)
-	choices := make([]string, 0, 4)
+	choices := make([]string, 0, count)
 	if strings.TrimSpace(correct) != "" {
 		choices = append(choices, correct)
 		unique[correct] = struct{}{}
 	}
 
-	for len(choices) < 4 && len(candidates) > 0 {
+	count = min(count, distinctChoiceCount(correct, candidates))
+	for len(choices) < count {
 		cand := candidates[rng.Intn(len(candidates))]
 		if _, exists := unique[cand]; exists {
 			continue
@@ -49,8 +50,8 @@ func buildAudioChoicesWithMode(correct string, rng *rand.Rand, mode string) []st
 	return choices
 }
 
-// buildChoices はテキスト問題用の選択肢を生成する
-func buildChoices(correct string, rng *rand.Rand) []string {
+// buildChoices はテキスト問題用の選択肢を count 個生成する
+func buildChoices(correct string, rng *rand.Rand, count int) []string {
 	candidates := make([]string, 0, len(majorTemplates)+len(fallbackLanguages))
 	if len(majorTemplates) > 0 {
 		for _, t := range majorTemplates {
@@ -64,13 +65,14 @@ func buildChoices(correct string, rng *rand.Rand) []string {
 	}
 
 	unique := make(map[string]struct{}, len(candidates)+1)
-	choices := make([]string, 0, 4)
+	choices := make([]string, 0, count)
 	if strings.TrimSpace(correct) != "" {
 		choices = append(choices, correct)
 		unique[correct] = struct{}{}
 	}
 
-	for len(choices) < 4 && len(candidates) > 0 {
+	count = min(count, distinctChoiceCount(correct, candidates))
+	for len(choices) < count {
 		cand := candidates[rng.Intn(len(candidates))]
 		if _, exists := unique[cand]; exists {
 			continue
@@ -85,3 +87,16 @@ func buildChoices(correct string, rng *rand.Rand) []string {
 
 	return choices
 }
+
+// distinctChoiceCount は正解と候補から作れる選択肢の最大数（重複を除いた数）を返す
+// 候補が足りないときに選択肢を探し続けないよう、要求数をこれで抑える
+func distinctChoiceCount(correct string, candidates []string) int {
+	distinct := make(map[string]struct{}, len(candidates)+1)
+	for _, cand := range candidates {
+		distinct[cand] = struct{}{}
+	}
+	if strings.TrimSpace(correct) != "" {
+		distinct[correct] = struct{}{}
+	}
+	return len(distinct)
+}
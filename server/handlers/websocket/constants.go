@@ -1,5 +1,13 @@
 package websocket
 
+// matchModes は対戦で選べるモード一覧
+var matchModes = []string{
+	"text-major",
+	"text-rare",
+	"audio-major",
+	"audio-rare",
+}
+
 // fallbackLanguages は各問題モード用の言語リスト（フォールバック用）
 var fallbackLanguages = []string{
 	"English",
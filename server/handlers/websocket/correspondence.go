@@ -0,0 +1,145 @@
+package websocket
+
+import (
+	"errors"
+	"log"
+	"math/rand"
+	"sync"
+	"time"
+
+	"example.com/mathkun-tmp-/server/db"
+	"example.com/mathkun-tmp-/server/services"
+	"gorm.io/gorm"
+)
+
+// correspondenceTickInterval は非同期対戦の期限切れを確認する間隔
+const correspondenceTickInterval = time.Minute
+
+// correspondenceSchedulerOnce は非同期対戦の定期処理のゴルーチンを1度だけ起動するためのもの
+var correspondenceSchedulerOnce sync.Once
+
+// errNoQuestions は非同期対戦に出す問題が集まらなかったことを表す
+var errNoQuestions = errors.New("no questions available")
+
+// CorrespondenceQuestions は非同期対戦で両者に出す問題セットと1問の制限時間を返す
+// 延長戦はないので、WebSocket の対戦と同じルールの規定の出題数だけを選ぶ
+func CorrespondenceQuestions(mode string, rated bool) ([]services.MatchQuestionDTO, time.Duration, error) {
+	if !isValidMode(mode) {
+		return nil, 0, services.ErrInvalidMode
+	}
+	rules := rulesFor(mode, rated)
+	questions, err := fetchMatchQuestions(rules.Rounds, mode, rules.Choices)
+	if err != nil {
+		return nil, 0, err
+	}
+	if len(questions) == 0 {
+		return nil, 0, errNoQuestions
+	}
+	rng := rand.New(rand.NewSource(time.Now().UnixNano()))
+	dtos := make([]services.MatchQuestionDTO, 0, len(questions))
+	for _, q := range questions {
+		if len(q.Choices) == 0 {
+			q.Choices = buildChoices(q.Answer, rng, rules.Choices)
+		}
+		dtos = append(dtos, services.MatchQuestionDTO{
+			ID:       q.ID,
+			Prompt:   q.Prompt,
+			Answer:   q.Answer,
+			AudioURL: q.AudioURL,
+			Choices:  q.Choices,
+		})
+	}
+	return dtos, rules.RoundTime, nil
+}
+
+// CorrespondencePoints は非同期対戦の得点の計算（WebSocket の対戦と同じルールの速さに応じたカーブ）を返す
+func CorrespondencePoints(mode string, rated bool) func(elapsed, limit time.Duration) int {
+	return rulesFor(mode, rated).Scoring.Points
+}
+
+// SettleCorrespondence は非同期対戦の決着を試み、決着していれば対戦履歴とレーティングに反映する
+// 失敗してもログに残すだけにする（期限切れの確認で後から再び試みる）
+func SettleCorrespondence(id uint) {
+	if _, err := services.NewCorrespondenceService(db.DB).Settle(id, recordCorrespondenceResult); err != nil {
+		log.Printf("failed to settle correspondence match %d: %v", id, err)
+	}
+}
+
+// recordCorrespondenceResult は決着した非同期対戦を WebSocket の対戦と同じ形で対戦履歴に記録する
+// 保存した回答からルームを組み立て直し、順位・勝者・レーティングの計算を通常の試合と共通にする
+// 書き込みは決着を確定させる Settle のトランザクション tx の中で行う
+func recordCorrespondenceResult(tx *gorm.DB, s services.CorrespondenceSettlement) (uint, string, error) {
+	r := &room{
+		id:        newRoomID(),
+		mode:      s.Match.Mode,
+		rated:     s.Match.Rated,
+		scores:    make(map[string]int, len(s.Players)),
+		forfeited: make(map[string]bool, len(s.Forfeited)),
+		startedAt: s.Match.CreatedAt,
+	}
+	for _, q := range s.Questions {
+		r.questions = append(r.questions, matchQuestion{
+			ID:       q.ID,
+			Prompt:   q.Prompt,
+			Answer:   q.Answer,
+			AudioURL: q.AudioURL,
+			Choices:  q.Choices,
+		})
+	}
+	r.history = make([]roundRecord, len(r.questions))
+	for i := range r.history {
+		r.history[i] = roundRecord{Round: i + 1, Question: r.questions[i]}
+	}
+	for _, p := range s.Players {
+		c := &client{id: newClientID(), username: p.Username, userID: p.UserID}
+		r.players = append(r.players, c)
+		r.scores[c.id] = p.Score
+		for _, a := range p.Answers {
+			if a.Round < 1 || a.Round > len(r.history) {
+				continue
+			}
+			r.history[a.Round-1].Answers = append(r.history[a.Round-1].Answers, answerRecord{
+				Username: p.Username,
+				Answer:   a.Answer,
+				Elapsed:  time.Duration(a.ResponseMs) * time.Millisecond,
+				Correct:  a.Correct,
+				Points:   a.Points,
+			})
+		}
+	}
+	for _, name := range s.Forfeited {
+		r.forfeited[name] = true
+	}
+
+	standings := r.standings()
+	winner := r.winnerName()
+	result, err := recordMatchResultTx(tx, r, standings, winner, s.Status)
+	if err != nil {
+		return 0, "", err
+	}
+	return result.MatchID, winner, nil
+}
+
+// StartCorrespondenceScheduler は非同期対戦の定期処理のゴルーチンを起動する（2回目以降は何もしない）
+// 期限を過ぎた対戦を、解き終えなかった方の不戦敗か不成立として確定させる
+func StartCorrespondenceScheduler() {
+	correspondenceSchedulerOnce.Do(func() {
+		go runCorrespondenceScheduler(correspondenceTickInterval)
+	})
+}
+
+// runCorrespondenceScheduler は一定間隔で期限切れの非同期対戦を確定させる
+func runCorrespondenceScheduler(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for range ticker.C {
+		expired, err := services.NewCorrespondenceService(db.DB).Expired()
+		if err != nil {
+			log.Printf("correspondence tick failed: %v", err)
+			continue
+		}
+		for _, id := range expired {
+			SettleCorrespondence(id)
+		}
+	}
+}
@@ -0,0 +1,51 @@
+package websocket
+
+import (
+	"math"
+	"time"
+)
+
+// eloKFactor はEloレーティング計算のK因子
+// 32はチェスで一般的な値で、レーティング変動をほどよい幅に保つ
+const eloKFactor = 32.0
+
+// eloEngine は固定K因子のEloレーティング
+// 3人以上の場合は全ペアを1対1の対戦とみなすペアワイズEloで計算する
+// レーティング差が大きいほど変動幅も小さくなる（強者が弱者に勝っても少ししか上がらない）
+type eloEngine struct {
+	KFactor     float64
+	MatchWeight float64 // RDの縮め方に使う1試合の重み（意味は glicko2Engine と同じ、0なら相手1人ごとに1試合分）
+}
+
+// Rate はペアワイズEloで新しいレーティングを計算する
+// Elo自体は不確かさを持たないが、暫定判定やランキング掲載の条件を方式によらず揃えるため
+// Deviation は Glicko-2 と同じ式で対戦数に応じて縮めていく
+func (e eloEngine) Rate(players []ratingSnapshot, standings []standingItem, now time.Time) []ratingSnapshot {
+	result := make([]ratingSnapshot, len(players))
+	copy(result, players)
+	if len(players) < 2 {
+		return result
+	}
+
+	// ペアごとの変動を合計する（計算は更新前のレーティングで行う）
+	// 2人対戦では従来の 旧レーティング + K因子 × (実際の結果 - 期待勝率) と同じになる
+	// 例: Aが期待通り勝った（ea=0.76, sa=1）なら +32×(1-0.76) = +7.68点
+	// 例: Aが番狂わせで勝った（ea=0.24, sa=1）なら +32×(1-0.24) = +24.32点
+	k := e.KFactor / float64(len(players)-1) // 人数が増えても1試合の変動幅が膨らまないよう按分
+	weight := opponentWeight(e.MatchWeight, len(players))
+	for i := range players {
+		change := 0.0
+		for j := range players {
+			if i == j {
+				continue
+			}
+			// Eloの期待勝率を計算
+			// 例: iが1600、jが1400なら ≈ 0.76（iが76%の確率で勝つ）
+			expected := 1.0 / (1.0 + math.Pow(10, (players[j].Rating-players[i].Rating)/400.0))
+			change += k * (pairScore(standings[i], standings[j]) - expected)
+		}
+		result[i].Rating = players[i].Rating + change
+		result[i].Deviation = glickoDeviationAfter(players, i, now, weight)
+	}
+	return result
+}
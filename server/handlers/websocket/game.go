@@ -1,23 +1,44 @@
 package websocket
 
 import (
+	"log"
 	"math/rand"
 	"strings"
 	"time"
 )
 
+// 問題の取得と結果の保存は DB を使うので、テストでは差し替えられるよう変数にしておく
+var (
+	loadMatchQuestions = fetchMatchQuestions
+	saveMatchResult    = recordMatchResult
+)
+
 // processAnswer はクライアントの回答を処理し、記録する
 func processAnswer(c *client, r *room, answer string) {
 	r.mu.Lock()
 	if r.finished || !r.active || r.question == nil {
 		r.mu.Unlock()
-		_ = c.conn.WriteJSON(wsMessage{Type: "match:result", Payload: mustJSON(resultPayload{
+		c.send(wsMessage{Type: "match:result", Payload: mustJSON(resultPayload{
 			RoomID: r.id,
 			Status: "closed",
 		})})
 		return
 	}
 
+	// 出題した選択肢以外の回答は受け付けない（改ざんされたクライアント対策）
+	answer = strings.TrimSpace(answer)
+	if !isOfferedChoice(r.question, answer) {
+		r.mu.Unlock()
+		sendError(c, codeInvalidAnswer, "invalid answer")
+		return
+	}
+	// バトルロイヤルで脱落した参加者は見ているだけで回答できない
+	if r.isEliminated(c.username) {
+		r.mu.Unlock()
+		sendErrorFor(c, errEliminated)
+		return
+	}
+
 	if r.answers == nil {
 		r.answers = map[string]string{}
 	}
@@ -25,7 +46,7 @@ func processAnswer(c *client, r *room, answer string) {
 		round := r.round
 		scores := r.scoreSnapshot()
 		r.mu.Unlock()
-		_ = c.conn.WriteJSON(wsMessage{Type: "match:result", Payload: mustJSON(resultPayload{
+		c.send(wsMessage{Type: "match:result", Payload: mustJSON(resultPayload{
 			RoomID: r.id,
 			Status: "locked",
 			Round:  round,
@@ -35,22 +56,38 @@ func processAnswer(c *client, r *room, answer string) {
 	}
 
 	r.answers[c.id] = answer
+	// 回答が届いた時刻をラウンド開始からの経過時間として記録する
+	if r.answerTimes == nil {
+		r.answerTimes = map[string]time.Duration{}
+	}
+	r.answerTimes[c.id] = time.Since(r.roundStartedAt)
+	// 回答できる全員が答えたら制限時間を待たずにラウンドを締める
+	allAnswered := r.allAnswered()
+	seq := r.roundSeq
 	r.mu.Unlock()
+
+	if allAnswered {
+		endRound(r, seq)
+	}
+}
+
+// isOfferedChoice は回答が出題中の問題の選択肢に含まれるかを返す
+func isOfferedChoice(q *matchQuestion, answer string) bool {
+	for _, choice := range q.Choices {
+		if choice == answer {
+			return true
+		}
+	}
+	return false
 }
 
 // startMatch はマッチを開始し、問題を生成する
 func startMatch(r *room) {
 	broadcast(r, wsMessage{Type: "match:preparing", Payload: mustJSON(preparingPayload{Status: "generating"})})
 
-	var (
-		questions []matchQuestion
-		err       error
-	)
-	if strings.HasPrefix(r.mode, "audio-") {
-		questions, err = fetchAudioQuestions(maxRoundsPerMatch, r.mode)
-	} else {
-		questions, err = fetchFallbackQuestions(maxRoundsPerMatch, r.mode)
-	}
+	count := r.rules.Rounds
+	// 延長戦用の予備の問題もまとめて取得しておく
+	questions, err := loadMatchQuestions(count+r.rules.SuddenDeathRounds, r.mode, r.rules.Choices)
 	if err != nil {
 		broadcast(r, wsMessage{Type: "match:finished", Payload: mustJSON(finishedPayload{
 			RoomID: r.id,
@@ -58,15 +95,17 @@ func startMatch(r *room) {
 			Status: "no_questions",
 		})})
 		state.RemoveRoom(r.id)
+		resetTournamentMatch(r)
 		return
 	}
 
 	r.mu.Lock()
-	r.maxRounds = len(questions)
+	r.maxRounds = min(count, len(questions))
 	r.questions = questions
-	r.scores = map[string]int{
-		r.players[0].id: 0,
-		r.players[1].id: 0,
+	r.startedAt = time.Now()
+	r.scores = make(map[string]int, len(r.players))
+	for _, p := range r.players {
+		r.scores[p.id] = 0
 	}
 	r.mu.Unlock()
 
@@ -90,29 +129,38 @@ func startRound(r *room) {
 	r.round++
 	r.question = &r.questions[nextIndex]
 	if len(r.question.Choices) == 0 {
-		r.question.Choices = buildChoices(r.question.Answer, rand.New(rand.NewSource(time.Now().UnixNano())))
+		r.question.Choices = buildChoices(r.question.Answer, rand.New(rand.NewSource(time.Now().UnixNano())), r.rules.Choices)
 	}
 	r.answers = map[string]string{}
+	r.answerTimes = map[string]time.Duration{}
 	r.active = true
 	r.roundSeq++
 	roundSeq := r.roundSeq
 	roundNum := r.round
 	scores := r.scoreSnapshot()
-	r.mu.Unlock()
+	series := r.series.payload(true)
+	teams := r.teamSnapshot()
 
-	sendRound(r, roundNum, scores)
+	roundLimit := r.rules.RoundTime
+	// 再接続時に残り時間を返せるよう開始時刻と制限時間を記録しておく
+	r.roundStartedAt = time.Now()
+	r.roundLimit = roundLimit
+	r.mu.Unlock()
 
-	roundLimit := roundDuration
-	if strings.HasPrefix(r.mode, "audio-") {
-		roundLimit = 15 * time.Second
+	if r.royale != nil {
+		sendRoyaleRound(r, roundNum, scores)
+	} else {
+		sendRound(r, roundNum, scores, series, teams)
 	}
+
 	time.AfterFunc(roundLimit, func() {
-		handleTimeout(r, roundSeq)
+		endRound(r, roundSeq)
 	})
 }
 
-// handleTimeout はラウンドのタイムアウトを処理し、結果を送信する
-func handleTimeout(r *room, seq uint64) {
+// endRound はラウンドを締めて採点し、結果を送信する
+// 制限時間のタイマーと全員回答時の両方から呼ばれ、先に来た方だけが処理される
+func endRound(r *room, seq uint64) {
 	r.mu.Lock()
 	if r.finished || !r.active || r.roundSeq != seq {
 		r.mu.Unlock()
@@ -132,51 +180,98 @@ func handleTimeout(r *room, seq uint64) {
 
 	answers := map[string]string{}
 	correct := map[string]bool{}
+	points := map[string]int{}
+	responseMs := map[string]int64{}
+	curve := r.rules.Scoring
+	record := roundRecord{Round: round}
+	if r.question != nil {
+		record.Question = *r.question
+	}
 	for _, p := range r.players {
 		if p == nil {
 			continue
 		}
 		choice, ok := r.answers[p.id]
+		elapsed := r.answerTimes[p.id]
 		if ok {
 			answers[p.username] = choice
+			responseMs[p.username] = elapsed.Milliseconds()
 		}
+		// 正解は回答の速さに応じて得点が減衰する
 		isCorrect := ok && choice == answer
+		gained := 0
 		if isCorrect {
-			r.scores[p.id]++
+			gained = curve.Points(elapsed, r.roundLimit)
+			r.scores[p.id] += gained
 		}
 		correct[p.username] = isCorrect
+		points[p.username] = gained
+		record.Answers = append(record.Answers, answerRecord{
+			Username: p.username,
+			Answer:   choice,
+			Elapsed:  elapsed,
+			Correct:  isCorrect,
+			Points:   gained,
+		})
+	}
+	r.history = append(r.history, record)
+	if r.isTeamMatch() {
+		r.addTeamPoints(points)
+	}
+	// バトルロイヤルは正解できなかった生存者をここで脱落させる
+	var survivors *royaleSurvivorsPayload
+	if r.royale != nil {
+		payload := r.eliminateLocked(round, correct)
+		survivors = &payload
 	}
 
 	scores := r.scoreSnapshot()
+	teams := r.teamSnapshot()
 	r.mu.Unlock()
 
 	broadcast(r, wsMessage{Type: "match:result", Payload: mustJSON(resultPayload{
-		RoomID:  r.id,
-		Status:  "round_end",
-		Round:   round,
-		Scores:  scores,
-		Answers: answers,
-		Correct: correct,
-		Answer:  answer,
+		RoomID:     r.id,
+		Status:     "round_end",
+		Round:      round,
+		Scores:     scores,
+		Answers:    answers,
+		Correct:    correct,
+		Answer:     answer,
+		Points:     points,
+		ResponseMs: responseMs,
+		Teams:      teams,
 	})})
+	if survivors != nil {
+		broadcast(r, wsMessage{Type: "royale:survivors", Payload: mustJSON(*survivors)})
+	}
 
 	recordRecap(r, round, prompt, "round_end", "")
 	continueOrFinish(r)
 }
 
 // continueOrFinish は次のラウンドに進むか、マッチを終了するか判定する
+// 規定ラウンドを終えて1位が同点なら、モードの設定に従って延長戦に入る
 func continueOrFinish(r *room) {
 	r.mu.Lock()
-	finished := r.round >= r.maxRounds
+	// バトルロイヤルは最後の1人が決まった時点で終わる
+	finished := r.round >= r.maxRounds || (r.royale != nil && r.activeSideCount() < 2)
+	suddenDeath := finished && r.startSuddenDeathLocked()
+	nextRound := r.round + 1
 	r.mu.Unlock()
 
-	if finished {
+	if suddenDeath {
+		broadcast(r, wsMessage{Type: "match:sudden_death", Payload: mustJSON(suddenDeathPayload{
+			RoomID: r.id,
+			Round:  nextRound,
+		})})
+	} else if finished {
 		finishMatch(r, "completed")
 		return
 	}
 
-	if strings.HasPrefix(r.mode, "audio-") {
-		time.AfterFunc(1800*time.Millisecond, func() {
+	// 音声問題は前の音声の再生を止める余裕をもたせるため、ルールの間隔だけ待って次を出す
+	if r.rules.RoundGap > 0 {
+		time.AfterFunc(r.rules.RoundGap, func() {
 			startRound(r)
 		})
 		return
@@ -185,7 +280,8 @@ func continueOrFinish(r *room) {
 }
 
 // finishMatch はマッチを終了し、最終結果とレーティング変動を送信する
-func finishMatch(r *room, status string) {
+// reason は終了理由（"completed" / "forfeit"）で、status は受信者ごとに勝敗を入れて送る
+func finishMatch(r *room, reason string) {
 	r.mu.Lock()
 	if r.finished {
 		r.mu.Unlock()
@@ -193,26 +289,61 @@ func finishMatch(r *room, status string) {
 	}
 	r.finished = true
 	scores := r.scoreSnapshot()
+	// 没収負けのプレイヤーはスコアに関係なく最下位になる
+	standings := r.standings()
 	winner := r.winnerName()
 	recap := r.recap
+	players := make([]*client, len(r.players))
+	copy(players, r.players)
+	if r.series != nil {
+		r.series.record(winner)
+	}
+	series := r.series.payload(false)
+	teams := r.teamSnapshot()
 	r.mu.Unlock()
 
-	ratingResult := ratingResult{}
-	if winner != "" {
-		if rr, err := applyEloForMatch(r, winner); err == nil {
-			ratingResult = rr
-		}
+	// レーティング更新と対戦履歴の保存を同じトランザクションで行う
+	ratingResult, err := saveMatchResult(r, standings, winner, reason)
+	if err != nil {
+		log.Printf("failed to record match %s: %v", r.id, err)
 	}
+	// 大会の試合なら対戦カードに結果を記録して次のラウンドへ進める
+	recordTournamentResult(r, winner, ratingResult.MatchID)
 
-	broadcast(r, wsMessage{Type: "match:finished", Payload: mustJSON(finishedPayload{
-		RoomID: r.id,
-		Winner: winner,
-		Scores: scores,
-		Status: status,
-		Recap:  recap,
-		Ratings: ratingResult.Ratings,
-		Deltas:  ratingResult.Deltas,
-	})})
+	payload := finishedPayload{
+		RoomID:    r.id,
+		Winner:    winner,
+		Scores:    scores,
+		Reason:    reason,
+		Recap:     recap,
+		Standings: standings,
+		Ratings:   ratingResult.Ratings,
+		Deltas:    ratingResult.Deltas,
+		Series:    series,
+		Teams:     teams,
+	}
 
+	// 参加者を解放してから再戦の受付を出す（すぐに再戦を申し込まれても受け付けられるように）
 	state.RemoveRoom(r.id)
+	if state.OfferRematch(r, winner) {
+		payload.RematchSeconds = int(rematchWindow / time.Second)
+	}
+
+	// プレイヤーには自分から見た勝敗を送る
+	for _, p := range players {
+		if p == nil {
+			continue
+		}
+		payload.Status = matchOutcome(standings, p.username)
+		p.send(wsMessage{Type: "match:finished", Payload: mustJSON(payload)})
+	}
+	// 観戦者には引き分けかどうかだけを伝える（再戦はできない）
+	payload.Status = reason
+	payload.RematchSeconds = 0
+	if winner == "" {
+		payload.Status = outcomeDraw
+	}
+	for _, sp := range r.spectatorList() {
+		sp.send(wsMessage{Type: "match:finished", Payload: mustJSON(payload)})
+	}
 }
@@ -0,0 +1,162 @@
+package websocket
+
+import (
+	"math"
+	"time"
+
+	"example.com/mathkun-tmp-/server/models"
+)
+
+// Glicko-2 のパラメータ
+// 参考: Mark E. Glickman "Example of the Glicko-2 system"
+const (
+	glickoScale             = 173.7178                       // Glicko スケールと Glicko-2 内部スケールの変換係数
+	glickoBaseRating        = 1500.0                         // 内部スケールの原点
+	glickoTau               = 0.5                            // 変動性の変化を抑える定数（小さいほど σ が動きにくい）
+	glickoEpsilon           = 0.000001                       // 変動性の反復計算の収束判定
+	glickoDefaultVolatility = models.DefaultRatingVolatility // 新規ユーザーのσ
+)
+
+// glicko2Engine は Glicko-2 によるレーティング
+// 1試合を1レーティング期間とみなし、他の参加者全員との結果（勝ち1・引き分け0.5・負け0）をまとめて反映する
+type glicko2Engine struct {
+	Tau         float64
+	MatchWeight float64 // 1試合の重み（0なら相手1人ごとに1試合分、正の値ならこの重みを相手の人数で按分する）
+}
+
+// glickoOpponent は Glicko-2 内部スケールでの相手1人分の結果
+type glickoOpponent struct {
+	mu     float64
+	phi    float64
+	score  float64
+	weight float64 // この結果を何試合分として数えるか
+}
+
+// Rate は Glicko-2 で新しいレーティング・RD・σ を計算する
+func (e glicko2Engine) Rate(players []ratingSnapshot, standings []standingItem, now time.Time) []ratingSnapshot {
+	result := make([]ratingSnapshot, len(players))
+	copy(result, players)
+	if len(players) < 2 {
+		return result
+	}
+
+	weight := opponentWeight(e.MatchWeight, len(players))
+	for i, p := range players {
+		mu, phi, sigma := glickoInternal(p, now)
+		opponents := make([]glickoOpponent, 0, len(players)-1)
+		for j, o := range players {
+			if i == j {
+				continue
+			}
+			omu, ophi, _ := glickoInternal(o, now)
+			opponents = append(opponents, glickoOpponent{mu: omu, phi: ophi, score: pairScore(standings[i], standings[j]), weight: weight})
+		}
+
+		// ステップ3〜4: 推定分散 v と改善量 Δ
+		v, sum := glickoVariance(mu, opponents)
+		delta := v * sum
+
+		// ステップ5〜7: 新しい σ、φ、μ
+		newSigma := glickoVolatility(delta, phi, v, sigma, e.Tau)
+		phiStar := math.Sqrt(phi*phi + newSigma*newSigma)
+		newPhi := 1 / math.Sqrt(1/(phiStar*phiStar)+1/v)
+		newMu := mu + newPhi*newPhi*sum
+
+		result[i].Rating = newMu*glickoScale + glickoBaseRating
+		result[i].Deviation = newPhi * glickoScale
+		result[i].Volatility = newSigma
+	}
+	return result
+}
+
+// glickoInternal はレーティングを Glicko-2 内部スケールに変換する
+// 最後の対戦からレーティング期間が経過していれば、その分だけRDを広げる（初期値が上限）
+func glickoInternal(p ratingSnapshot, now time.Time) (mu, phi, sigma float64) {
+	// 最後の対戦から経ったレーティング期間の分だけRDを広げる（ランキングの掲載判定と同じ計算）
+	deviation := models.CurrentDeviation(p.Deviation, p.Volatility, p.RatedAt, now)
+	sigma = p.Volatility
+	if sigma <= 0 {
+		sigma = glickoDefaultVolatility
+	}
+	mu = (p.Rating - glickoBaseRating) / glickoScale
+	phi = deviation / glickoScale
+	return mu, phi, sigma
+}
+
+// glickoG は相手のRDに応じて結果の重みを下げる関数 g(φ)
+func glickoG(phi float64) float64 {
+	return 1 / math.Sqrt(1+3*phi*phi/(math.Pi*math.Pi))
+}
+
+// opponentWeight は相手1人との結果の重みを返す
+// matchWeight が0なら1試合分、正の値なら1試合全体の重みを players-1 人の相手で按分する
+func opponentWeight(matchWeight float64, players int) float64 {
+	if matchWeight <= 0 || players < 2 {
+		return 1
+	}
+	return matchWeight / float64(players-1)
+}
+
+// glickoVariance は推定分散 v と Σ w·g(φj)(sj − E) を返す（w は相手ごとの結果の重み）
+func glickoVariance(mu float64, opponents []glickoOpponent) (v, sum float64) {
+	inv := 0.0
+	for _, o := range opponents {
+		g := glickoG(o.phi)
+		expected := 1 / (1 + math.Exp(-g*(mu-o.mu)))
+		inv += o.weight * g * g * expected * (1 - expected)
+		sum += o.weight * g * (o.score - expected)
+	}
+	return 1 / inv, sum
+}
+
+// glickoVolatility は Illinois 法で新しい σ を求める（Glickman の手順のステップ5）
+func glickoVolatility(delta, phi, v, sigma, tau float64) float64 {
+	a := math.Log(sigma * sigma)
+	f := func(x float64) float64 {
+		ex := math.Exp(x)
+		d := phi*phi + v + ex
+		return ex*(delta*delta-phi*phi-v-ex)/(2*d*d) - (x-a)/(tau*tau)
+	}
+
+	A := a
+	var B float64
+	if delta*delta > phi*phi+v {
+		B = math.Log(delta*delta - phi*phi - v)
+	} else {
+		k := 1.0
+		for f(a-k*tau) < 0 {
+			k++
+		}
+		B = a - k*tau
+	}
+
+	fA, fB := f(A), f(B)
+	for math.Abs(B-A) > glickoEpsilon {
+		C := A + (A-B)*fA/(fB-fA)
+		fC := f(C)
+		if fC*fB <= 0 {
+			A, fA = B, fB
+		} else {
+			fA /= 2
+		}
+		B, fB = C, fC
+	}
+	return math.Exp(A / 2)
+}
+
+// glickoDeviationAfter は i 番目のプレイヤーが他の参加者と対戦した後のRDを返す（σ は変えない）
+// Elo を使う場合でも対戦数に応じて不確かさを縮めるために使う（weight は相手1人との結果の重み）
+func glickoDeviationAfter(players []ratingSnapshot, i int, now time.Time, weight float64) float64 {
+	mu, phi, sigma := glickoInternal(players[i], now)
+	opponents := make([]glickoOpponent, 0, len(players)-1)
+	for j, o := range players {
+		if i == j {
+			continue
+		}
+		omu, ophi, _ := glickoInternal(o, now)
+		opponents = append(opponents, glickoOpponent{mu: omu, phi: ophi, weight: weight})
+	}
+	v, _ := glickoVariance(mu, opponents)
+	phiStar := math.Sqrt(phi*phi + sigma*sigma)
+	return glickoScale / math.Sqrt(1/(phiStar*phiStar)+1/v)
+}
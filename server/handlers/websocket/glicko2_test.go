@@ -0,0 +1,102 @@
+package websocket
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"example.com/mathkun-tmp-/server/models"
+)
+
+// glickmanExample は Glickman "Example of the Glicko-2 system" の例
+// 1500（RD 200）のプレイヤーが 1400（RD 30）に勝ち、1550（RD 100）と 1700（RD 300）に負ける
+func glickmanExample() ([]ratingSnapshot, []standingItem) {
+	players := []ratingSnapshot{
+		{Rating: 1500, Deviation: 200, Volatility: 0.06},
+		{Rating: 1400, Deviation: 30, Volatility: 0.06},
+		{Rating: 1550, Deviation: 100, Volatility: 0.06},
+		{Rating: 1700, Deviation: 300, Volatility: 0.06},
+	}
+	// 順位で勝敗を表す: 1550 と 1700 が上、1400 が下
+	standings := []standingItem{
+		{Rank: 2, Username: "player"},
+		{Rank: 3, Username: "a"},
+		{Rank: 1, Username: "b"},
+		{Rank: 1, Username: "c"},
+	}
+	return players, standings
+}
+
+func TestGlicko2MatchesGlickmanExample(t *testing.T) {
+	players, standings := glickmanExample()
+	after := glicko2Engine{Tau: glickoTau}.Rate(players, standings, time.Now())[0]
+
+	if math.Abs(after.Rating-1464.06) > 0.05 {
+		t.Errorf("rating = %.2f, want about 1464.06", after.Rating)
+	}
+	if math.Abs(after.Deviation-151.52) > 0.05 {
+		t.Errorf("RD = %.2f, want about 151.52", after.Deviation)
+	}
+	if math.Abs(after.Volatility-0.05999) > 0.00001 {
+		t.Errorf("volatility = %.6f, want about 0.05999", after.Volatility)
+	}
+}
+
+func TestGlickoInternalWidensDeviationWithInactivity(t *testing.T) {
+	now := time.Date(2026, 6, 1, 0, 0, 0, 0, time.UTC)
+	step := 0.06 * glickoScale
+	cases := []struct {
+		name    string
+		rd      float64
+		idle    time.Duration
+		wantRD  float64
+		ratedAt bool
+	}{
+		{"never rated", 80, 0, 80, false},
+		{"within a period", 80, models.RatingPeriod - time.Second, 80, true},
+		{"one period", 80, models.RatingPeriod, math.Sqrt(80*80 + step*step), true},
+		{"ten periods", 80, 10 * models.RatingPeriod, math.Sqrt(80*80 + 10*step*step), true},
+		{"capped at the default", 300, 1000 * models.RatingPeriod, models.DefaultRatingDeviation, true},
+	}
+	for _, tc := range cases {
+		p := ratingSnapshot{Rating: 1500, Deviation: tc.rd, Volatility: 0.06}
+		if tc.ratedAt {
+			ratedAt := now.Add(-tc.idle)
+			p.RatedAt = &ratedAt
+		}
+		_, phi, _ := glickoInternal(p, now)
+		if got := phi * glickoScale; math.Abs(got-tc.wantRD) > 1e-6 {
+			t.Errorf("%s: RD %.4f, want %.4f", tc.name, got, tc.wantRD)
+		}
+	}
+}
+
+func TestGlicko2InactivePlayerMovesMore(t *testing.T) {
+	now := time.Date(2026, 6, 1, 0, 0, 0, 0, time.UTC)
+	recent := now.Add(-time.Hour)
+	longAgo := now.Add(-52 * models.RatingPeriod)
+	standings := []standingItem{{Rank: 1, Username: "a"}, {Rank: 2, Username: "b"}}
+	opponent := ratingSnapshot{Rating: 1500, Deviation: 60, Volatility: 0.06, RatedAt: &recent}
+
+	active := glicko2Engine{Tau: glickoTau}.Rate([]ratingSnapshot{{Rating: 1500, Deviation: 60, Volatility: 0.06, RatedAt: &recent}, opponent}, standings, now)[0]
+	inactive := glicko2Engine{Tau: glickoTau}.Rate([]ratingSnapshot{{Rating: 1500, Deviation: 60, Volatility: 0.06, RatedAt: &longAgo}, opponent}, standings, now)[0]
+	if inactive.Rating-1500 <= active.Rating-1500 {
+		t.Errorf("a player back after a year should gain more: active %+.1f, inactive %+.1f", active.Rating-1500, inactive.Rating-1500)
+	}
+	if inactive.Deviation <= active.Deviation {
+		t.Errorf("a player back after a year should keep a wider RD: active %.1f, inactive %.1f", active.Deviation, inactive.Deviation)
+	}
+}
+
+func TestEloShrinksDeviationLikeGlicko(t *testing.T) {
+	players, standings := glickmanExample()
+	elo := eloEngine{KFactor: eloKFactor}.Rate(players, standings, time.Now())[0]
+	glicko := glicko2Engine{Tau: glickoTau}.Rate(players, standings, time.Now())[0]
+	// Elo でも対戦した分だけRDは縮み、σ を変えないぶん Glicko-2 とほぼ同じ値になる
+	if elo.Deviation >= players[0].Deviation || math.Abs(elo.Deviation-glicko.Deviation) > 0.5 {
+		t.Errorf("elo RD %.2f, glicko RD %.2f", elo.Deviation, glicko.Deviation)
+	}
+	if elo.Volatility != players[0].Volatility {
+		t.Errorf("elo should not change volatility, got %v", elo.Volatility)
+	}
+}
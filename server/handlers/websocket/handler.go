@@ -4,6 +4,7 @@ import (
 	"encoding/json"
 	"net/http"
 	"strings"
+	"time"
 
 	"example.com/mathkun-tmp-/server/db"
 	"example.com/mathkun-tmp-/server/repositories"
@@ -27,16 +28,37 @@ func WebSocket(c *gin.Context) {
 	if err != nil {
 		return
 	}
-	defer conn.Close()
 
-	// 新規クライアントを作成
-	client := &client{
-		id:   newClientID(),
-		conn: conn,
+	// 要求されたプロトコルバージョンに対応していなければ理由を送って切断する
+	// （送信ゴルーチンを起動する前なので直接書き込んでよい）
+	version, ok := negotiateProtocol(c.Query("protocol"))
+	if !ok {
+		_ = conn.WriteJSON(wsMessage{Type: "error", Payload: mustJSON(errorPayload{
+			Code:    codeUnsupportedVersion,
+			Message: "unsupported protocol version",
+		})})
+		_ = conn.Close()
+		return
 	}
 
+	// 新規クライアントを作成（送信は専用のゴルーチンが行う）
+	// 接続を閉じるのは送信ゴルーチンで、抜けるときは送信待ちのメッセージを書き終えてから閉じる
+	client := newClient(conn)
+	defer client.close()
+
+	// 応答のない接続とアイドル接続を検出するため、ping フレームと読み込み期限を設定する
+	prepareConn(conn)
+	act := &activity{}
+	act.touch()
+	done := make(chan struct{})
+	defer close(done)
+	go keepAlive(conn, client, act, done)
+
 	// 接続完了を通知
-	_ = conn.WriteJSON(wsMessage{Type: "welcome"})
+	client.send(wsMessage{Type: "welcome", Payload: mustJSON(welcomePayload{
+		ProtocolVersion:    version,
+		MinProtocolVersion: minProtocolVersion,
+	})})
 
 	// メッセージ受信ループ
 	for {
@@ -45,16 +67,42 @@ func WebSocket(c *gin.Context) {
 			state.RemoveClient(client)
 			return
 		}
+		act.touch()
+		_ = conn.SetReadDeadline(time.Now().Add(pongWait))
 
 		switch msg.Type {
 		case "ping":
-			_ = conn.WriteJSON(wsMessage{Type: "pong"})
+			client.send(wsMessage{Type: "pong"})
 		case "match:join":
 			handleJoin(client, msg.Payload)
+		case "match:cancel":
+			handleCancel(client)
 		case "match:answer":
 			handleAnswer(client, msg.Payload)
+		case "match:rematch":
+			handleRematch(client, msg.Payload)
+		case "match:rematch_decline":
+			handleRematchDecline(client, msg.Payload)
+		case "match:resume":
+			handleResume(client, msg.Payload)
+		case "tournament:join":
+			handleTournamentJoin(client, msg.Payload)
+		case "party:create":
+			handlePartyCreate(client, msg.Payload)
+		case "party:join":
+			handlePartyJoin(client, msg.Payload)
+		case "party:leave":
+			handlePartyLeave(client)
+		case "room:spectate":
+			handleSpectate(client, msg.Payload)
+		case "room:create":
+			handleRoomCreate(client, msg.Payload)
+		case "room:join":
+			handleRoomJoin(client, msg.Payload)
+		case "room:ready":
+			handleRoomReady(client, msg.Payload)
 		default:
-			_ = conn.WriteJSON(wsMessage{Type: "error", Payload: mustJSON(errorPayload{Message: "unknown event"})})
+			sendError(client, codeUnknownEvent, "unknown event")
 		}
 	}
 }
@@ -63,40 +111,49 @@ func WebSocket(c *gin.Context) {
 func handleJoin(c *client, payload json.RawMessage) {
 	var req joinPayload
 	if err := json.Unmarshal(payload, &req); err != nil || strings.TrimSpace(req.Token) == "" {
-		_ = c.conn.WriteJSON(wsMessage{Type: "error", Payload: mustJSON(errorPayload{Message: "invalid join payload"})})
+		sendError(c, codeInvalidPayload, "invalid join payload")
 		return
 	}
-	username, err := usernameFromToken(req.Token)
-	if err != nil {
-		_ = c.conn.WriteJSON(wsMessage{Type: "error", Payload: mustJSON(errorPayload{Message: "unauthorized"})})
+	if !authenticateClient(c, req.Token) {
 		return
 	}
-	c.username = username
-
-	// ユーザー情報（アバター画像）を取得
-	if repo := repositories.NewUserRepository(db.DB); repo != nil {
-		if user, err := repo.FindByUsername(username); err == nil && user != nil {
-			c.imageURL = user.ImageURL
-		}
-	}
 
 	// モードを確定（デフォルトは text-major）
 	mode := strings.TrimSpace(req.Mode)
 	if mode == "" {
 		mode = "text-major"
 	}
-	c.mode = mode
+	if !isValidMode(mode) {
+		sendErrorFor(c, errInvalidMode)
+		return
+	}
+	// チーム戦はチーム戦用のキューに入る（パーティーならメンバー全員）
+	if req.Team {
+		startMatchmaker()
+		handleTeamJoin(c, mode)
+		return
+	}
+	// バトルロイヤルはモードごとのロビーに入り、人数が揃うのを待つ
+	if req.Royale {
+		handleRoyaleJoin(c, mode)
+		return
+	}
 
-	// マッチングキューに参加
+	// 同じモードのレーティング同士でマッチングする
+	useModeRating(c, mode)
+
+	// マッチングキューに参加（待ち時間で許容差を広げるため定期マッチングも起動）
+	startMatchmaker()
 	room, _, err := state.Join(c, mode)
 	if err != nil {
-		_ = c.conn.WriteJSON(wsMessage{Type: "error", Payload: mustJSON(errorPayload{Message: err.Error()})})
+		sendErrorFor(c, err)
 		return
 	}
 
 	// マッチング待機中
 	if room == nil {
-		_ = c.conn.WriteJSON(wsMessage{Type: "match:queued"})
+		status, _ := state.QueueStatus(c)
+		c.send(wsMessage{Type: "match:queued", Payload: mustJSON(status)})
 		return
 	}
 
@@ -104,17 +161,68 @@ func handleJoin(c *client, payload json.RawMessage) {
 	startMatch(room)
 }
 
+// handleCancel は待機キューからの離脱リクエストを処理する
+func handleCancel(c *client) {
+	if mode, ok := state.LeaveRoyaleLobby(c); ok {
+		c.send(wsMessage{Type: "match:cancelled", Payload: mustJSON(queueEndedPayload{Mode: mode})})
+		return
+	}
+	members, status, ok := state.CancelQueue(c)
+	if !ok {
+		sendErrorFor(c, errNotQueued)
+		return
+	}
+	for _, m := range members {
+		m.send(wsMessage{Type: "match:cancelled", Payload: mustJSON(queueEndedPayload{Mode: status.Mode})})
+	}
+}
+
+// authenticateClient はJWTを検証してクライアントにユーザー情報を設定する
+// 失敗時はエラーを送信してfalseを返す
+func authenticateClient(c *client, token string) bool {
+	username, err := usernameFromToken(token)
+	if err != nil {
+		sendError(c, codeUnauthorized, "unauthorized")
+		return false
+	}
+	c.username = username
+
+	// ユーザー情報（アバター画像とマッチング用レーティング）を取得
+	c.rating = defaultRating
+	if repo := repositories.NewUserRepository(db.DB); repo != nil {
+		if user, err := repo.FindByUsername(username); err == nil && user != nil {
+			c.userID = user.ID
+			c.imageURL = user.ImageURL
+			c.rating = user.Rating
+		}
+	}
+	return true
+}
+
+// useModeRating はクライアントのレーティングを指定モードのものに置き換える
+// 取得できなければ authenticateClient で設定した値のまま
+func useModeRating(c *client, mode string) {
+	if c.userID == 0 {
+		return
+	}
+	modeRating, err := repositories.NewUserModeRatingRepository(db.DB).FindOrDefault(c.userID, mode)
+	if err == nil && modeRating != nil {
+		c.rating = modeRating.Rating
+	}
+}
+
 // handleAnswer はクライアントの回答を処理する
 func handleAnswer(c *client, payload json.RawMessage) {
 	var req answerPayload
 	if err := json.Unmarshal(payload, &req); err != nil || req.RoomID == "" {
-		_ = c.conn.WriteJSON(wsMessage{Type: "error", Payload: mustJSON(errorPayload{Message: "invalid answer payload"})})
+		sendError(c, codeInvalidPayload, "invalid answer payload")
 		return
 	}
 
-	room := state.GetRoom(req.RoomID)
-	if room == nil {
-		_ = c.conn.WriteJSON(wsMessage{Type: "error", Payload: mustJSON(errorPayload{Message: "room not found"})})
+	// 観戦者や別ルームのクライアントからの回答は受け付けない
+	room, err := state.PlayerRoom(c, req.RoomID)
+	if err != nil {
+		sendErrorFor(c, err)
 		return
 	}
 
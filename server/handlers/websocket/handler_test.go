@@ -0,0 +1,63 @@
+package websocket
+
+import (
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gorilla/websocket"
+)
+
+// dialTestServer は WebSocket ハンドラーだけを載せたサーバーに接続する
+func dialTestServer(t *testing.T) *websocket.Conn {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.GET("/ws", WebSocket)
+	srv := httptest.NewServer(r)
+	t.Cleanup(srv.Close)
+
+	conn, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(srv.URL, "http")+"/ws", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = conn.Close() })
+	return conn
+}
+
+func TestWebSocketFlushesQueuedMessagesBeforeClosing(t *testing.T) {
+	conn := dialTestServer(t)
+	_ = conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+
+	var welcome wsMessage
+	if err := conn.ReadJSON(&welcome); err != nil || welcome.Type != "welcome" {
+		t.Fatalf("expected welcome, got %+v (%v)", welcome, err)
+	}
+
+	// 応答を積ませた直後に読み込みループをエラーで抜けさせる
+	const pings = sendBufferSize - 1
+	for i := 0; i < pings; i++ {
+		if err := conn.WriteJSON(wsMessage{Type: "ping"}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := conn.WriteMessage(websocket.TextMessage, []byte("not json")); err != nil {
+		t.Fatal(err)
+	}
+
+	pongs := 0
+	for {
+		var msg wsMessage
+		if err := conn.ReadJSON(&msg); err != nil {
+			break
+		}
+		if msg.Type == "pong" {
+			pongs++
+		}
+	}
+	if pongs != pings {
+		t.Errorf("received %d pongs before the connection closed, want %d", pongs, pings)
+	}
+}
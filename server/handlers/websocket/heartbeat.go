@@ -0,0 +1,93 @@
+package websocket
+
+import (
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// 接続維持の定数
+const (
+	writeWait           = 10 * time.Second  // 1回の書き込みに許す時間
+	pongWait            = 60 * time.Second  // この時間 pong もメッセージも届かなければ切断とみなす
+	pingPeriod          = pongWait * 9 / 10 // サーバーから ping フレームを送る間隔（pongWait より短くする）
+	idleTimeout         = 10 * time.Minute  // 待機も対戦も観戦もしていない接続を閉じるまでの時間
+	defaultQueueTimeout = 5 * time.Minute   // 待機キューで待てる最長時間の既定値
+	maxInboundMessage   = 64 * 1024         // 受信メッセージの最大サイズ（バイト）
+)
+
+// activity はクライアントから最後にメッセージを受け取った時刻を記録する
+// 読み込みループと keepAlive のゴルーチンの両方から触るのでロックで守る
+type activity struct {
+	mu   sync.Mutex
+	last time.Time
+}
+
+// touch は現在時刻を最終受信時刻として記録する
+func (a *activity) touch() {
+	a.mu.Lock()
+	a.last = time.Now()
+	a.mu.Unlock()
+}
+
+// idleFor は最後にメッセージを受け取ってからの経過時間を返す
+func (a *activity) idleFor() time.Duration {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return time.Since(a.last)
+}
+
+// prepareConn は読み込み期限と pong の扱いを設定する
+// pong が届くたびに期限を延ばすので、応答しない相手は ReadJSON がエラーになって片付けられる
+func prepareConn(conn *websocket.Conn) {
+	conn.SetReadLimit(maxInboundMessage)
+	_ = conn.SetReadDeadline(time.Now().Add(pongWait))
+	conn.SetPongHandler(func(string) error {
+		return conn.SetReadDeadline(time.Now().Add(pongWait))
+	})
+}
+
+// keepAlive は done が閉じられるまで定期的に ping フレームを送り、アイドル接続を閉じる
+// WriteControl は他の書き込みと並行して呼んでよいので、送信の排他は気にしなくてよい
+func keepAlive(conn *websocket.Conn, c *client, act *activity, done <-chan struct{}) {
+	ticker := time.NewTicker(pingPeriod)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-done:
+			return
+		case <-ticker.C:
+			if err := conn.WriteControl(websocket.PingMessage, nil, time.Now().Add(writeWait)); err != nil {
+				_ = conn.Close()
+				return
+			}
+			// 何もしていない接続は閉じる（読み込みループがエラーで抜けて後片付けする）
+			if act.idleFor() >= idleTimeout && state.IsIdle(c) {
+				msg := websocket.FormatCloseMessage(websocket.CloseNormalClosure, "idle connection")
+				_ = conn.WriteControl(websocket.CloseMessage, msg, time.Now().Add(writeWait))
+				_ = conn.Close()
+				return
+			}
+		}
+	}
+}
+
+// queueTimeoutFromEnv は待機キューの最長時間を環境変数 QUEUE_TIMEOUT から読む
+// "10m" のような time.Duration 形式か秒数で指定し、"0" でタイムアウトしない
+func queueTimeoutFromEnv() time.Duration {
+	raw := strings.TrimSpace(os.Getenv("QUEUE_TIMEOUT"))
+	if raw == "" {
+		return defaultQueueTimeout
+	}
+	if d, err := time.ParseDuration(raw); err == nil {
+		return d
+	}
+	if sec, err := strconv.Atoi(raw); err == nil {
+		return time.Duration(sec) * time.Second
+	}
+	return defaultQueueTimeout
+}
@@ -0,0 +1,247 @@
+package websocket
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+
+	"example.com/mathkun-tmp-/server/db"
+	"example.com/mathkun-tmp-/server/models"
+	"example.com/mathkun-tmp-/server/repositories"
+	"gorm.io/gorm"
+)
+
+// recordMatchResult はレーティング更新と対戦履歴の保存を1つのトランザクションで行う
+// 非レーティング戦はレーティングを変えずに履歴だけ保存する（1位が同点の試合は引き分けとして計算する）
+func recordMatchResult(r *room, standings []standingItem, winner, status string) (ratingResult, error) {
+	var result ratingResult
+	err := db.DB.Transaction(func(tx *gorm.DB) error {
+		var err error
+		result, err = recordMatchResultTx(tx, r, standings, winner, status)
+		return err
+	})
+	if err != nil {
+		return ratingResult{}, err
+	}
+	return result, nil
+}
+
+// recordMatchResultTx は recordMatchResult と同じ保存を呼び出し側のトランザクション tx の中で行う
+// 非同期対戦の決着のように、対戦の行をロックしたまま履歴とレーティングを書く場合に使う
+func recordMatchResultTx(tx *gorm.DB, r *room, standings []standingItem, winner, status string) (ratingResult, error) {
+	result := ratingResult{
+		Ratings: map[string]int{},
+		Deltas:  map[string]int{},
+	}
+	if len(standings) == 0 {
+		return result, nil
+	}
+
+	// 保存に必要なルームの状態をロック中にコピーしておく
+	r.mu.Lock()
+	rated := r.rated
+	team := r.isTeamMatch()
+	royale := r.royale != nil
+	match := models.Match{
+		Mode:        r.mode,
+		Status:      status,
+		Rated:       rated,
+		QuestionIDs: joinQuestionIDs(r.questions),
+		Winner:      winner,
+		StartedAt:   r.startedAt,
+		FinishedAt:  time.Now(),
+	}
+	history := make([]roundRecord, len(r.history))
+	copy(history, r.history)
+	// bot はユーザーとして登録されていないので、固定レーティングだけ控えておく
+	bots := map[string]int{}
+	for _, p := range r.players {
+		if p != nil && p.bot != nil {
+			bots[p.username] = p.rating
+		}
+	}
+	r.mu.Unlock()
+	if match.StartedAt.IsZero() {
+		match.StartedAt = match.FinishedAt
+	}
+	// チーム戦・バトルロイヤルはそれぞれ専用のレーティングを使う
+	ratingMode := match.Mode
+	switch {
+	case team:
+		ratingMode = models.TeamRatingMode(match.Mode)
+	case royale:
+		ratingMode = models.RoyaleRatingMode(match.Mode)
+	}
+
+	// DB更新は tx の中で行う（途中エラー時は呼び出し側で全てロールバックされる）
+	err := func() error {
+		// ユーザーIDと更新前のモード別レーティングを取得
+		repo := repositories.NewUserRepository(tx)
+		modeRepo := repositories.NewUserModeRatingRepository(tx)
+		users := make(map[string]*models.User, len(standings))
+		ratingsBefore := make(map[string]int, len(standings))
+		for _, s := range standings {
+			if rating, ok := bots[s.Username]; ok {
+				ratingsBefore[s.Username] = rating
+				continue
+			}
+			u, err := repo.FindByUsername(s.Username)
+			if err != nil {
+				return err
+			}
+			if u == nil {
+				return errors.New("user not found")
+			}
+			mr, err := modeRepo.FindOrDefault(u.ID, ratingMode)
+			if err != nil {
+				return err
+			}
+			users[s.Username] = u
+			ratingsBefore[s.Username] = mr.Rating
+		}
+
+		if rated {
+			var rr ratingResult
+			var err error
+			switch {
+			case team:
+				rr, err = applyTeamRatingForMatch(tx, match.Mode, standings)
+			case royale:
+				rr, err = applyRoyaleRatingForMatch(tx, match.Mode, standings)
+			default:
+				rr, err = applyRatingForMatch(tx, match.Mode, standings, bots)
+			}
+			if err != nil {
+				return err
+			}
+			result = rr
+		}
+
+		for _, s := range standings {
+			before := ratingsBefore[s.Username]
+			after := before
+			if v, ok := result.Ratings[s.Username]; ok {
+				after = v
+			}
+			// bot の参加者はユーザーIDを0として記録する
+			var userID uint
+			if u := users[s.Username]; u != nil {
+				userID = u.ID
+			}
+			match.Participants = append(match.Participants, models.MatchParticipant{
+				UserID:       userID,
+				Username:     s.Username,
+				Score:        s.Score,
+				Rank:         s.Rank,
+				Forfeited:    s.Forfeited,
+				Team:         s.Team,
+				RatingBefore: before,
+				RatingAfter:  after,
+			})
+		}
+
+		for _, rec := range history {
+			for _, a := range rec.Answers {
+				u := users[a.Username]
+				if u == nil {
+					continue
+				}
+				row := models.MatchRound{
+					Round:         rec.Round,
+					QuestionID:    rec.Question.ID,
+					CorrectAnswer: rec.Question.Answer,
+					UserID:        u.ID,
+					Username:      u.Username,
+					Answer:        a.Answer,
+					Correct:       a.Correct,
+					Points:        a.Points,
+				}
+				if a.Answer != "" {
+					row.ResponseMs = a.Elapsed.Milliseconds()
+				}
+				match.Rounds = append(match.Rounds, row)
+			}
+		}
+
+		if err := repositories.NewMatchRepository(tx).Create(&match); err != nil {
+			return err
+		}
+		result.MatchID = match.ID
+
+		// レーティング戦なら推移グラフ用に参加者ごとの変動を記録する
+		if rated {
+			historyRepo := repositories.NewRatingHistoryRepository(tx)
+			for _, p := range match.Participants {
+				if p.UserID == 0 {
+					continue
+				}
+				if err := historyRepo.Create(&models.RatingHistory{
+					UserID:       p.UserID,
+					MatchID:      match.ID,
+					Mode:         ratingMode,
+					RatingBefore: p.RatingBefore,
+					RatingAfter:  p.RatingAfter,
+					Outcome:      matchOutcome(standings, p.Username),
+					Opponent:     joinOpponents(standings, p.Username),
+					PlayedAt:     match.FinishedAt,
+				}); err != nil {
+					return err
+				}
+			}
+		}
+
+		// 不自然に速い正解があれば管理者レビュー用に記録する
+		flagRepo := repositories.NewSuspiciousPlayRepository(tx)
+		for _, s := range detectSuspiciousPlay(match.Mode, history) {
+			u := users[s.Username]
+			if u == nil {
+				continue
+			}
+			if err := flagRepo.Create(&models.SuspiciousPlayFlag{
+				UserID:           u.ID,
+				Username:         u.Username,
+				MatchID:          match.ID,
+				Mode:             match.Mode,
+				Reason:           s.Reason,
+				FastCorrectCount: s.FastCorrectCount,
+				CorrectCount:     s.CorrectCount,
+				MinResponseMs:    s.MinResponse.Milliseconds(),
+				Status:           "pending",
+			}); err != nil {
+				return err
+			}
+		}
+		return nil
+	}()
+	if err != nil {
+		return ratingResult{}, err
+	}
+	return result, nil
+}
+
+// joinQuestionIDs は出題順の問題IDをカンマ区切りの文字列にする
+func joinQuestionIDs(questions []matchQuestion) string {
+	ids := make([]string, 0, len(questions))
+	for _, q := range questions {
+		ids = append(ids, strconv.FormatUint(uint64(q.ID), 10))
+	}
+	return strings.Join(ids, ",")
+}
+
+// joinOpponents は指定ユーザー以外の参加者名を順位順にカンマ区切りで返す（チーム戦では相手チームのメンバーだけ）
+func joinOpponents(standings []standingItem, username string) string {
+	self := standingItem{}
+	for _, s := range standings {
+		if s.Username == username {
+			self = s
+		}
+	}
+	names := make([]string, 0, len(standings))
+	for _, s := range standings {
+		if s.Username != username && !sameSide(s, self) {
+			names = append(names, s.Username)
+		}
+	}
+	return strings.Join(names, ",")
+}
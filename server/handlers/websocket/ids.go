@@ -1,6 +1,8 @@
 package websocket
 
 import (
+	"crypto/rand"
+	"math/big"
 	"strconv"
 	"sync/atomic"
 )
@@ -29,3 +31,26 @@ func newRoomID() string {
 	// "r-" プレフィックスをつけて文字列化（例: "r-456"）
 	return "r-" + strconv.FormatUint(id, 10)
 }
+
+// inviteCodeAlphabet は招待コードに使う文字（読み間違えやすい 0/O, 1/I/L は除外）
+const inviteCodeAlphabet = "ABCDEFGHJKMNPQRSTUVWXYZ23456789"
+
+// inviteCodeLength は招待コードの文字数
+const inviteCodeLength = 6
+
+// newInviteCode はプライベートルーム用の推測されにくい招待コードを生成する
+// 戻り値: "K7QX2M" のような形式の文字列
+func newInviteCode() string {
+	code := make([]byte, inviteCodeLength)
+	max := big.NewInt(int64(len(inviteCodeAlphabet)))
+	for i := range code {
+		// crypto/rand で1文字ずつランダムに選ぶ（失敗時は先頭文字で埋める）
+		n, err := rand.Int(rand.Reader, max)
+		if err != nil {
+			code[i] = inviteCodeAlphabet[0]
+			continue
+		}
+		code[i] = inviteCodeAlphabet[n.Int64()]
+	}
+	return string(code)
+}
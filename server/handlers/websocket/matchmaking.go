@@ -0,0 +1,308 @@
+package websocket
+
+import (
+	"math"
+	"time"
+)
+
+// マッチメイキングの定数
+const (
+	defaultRating          = 1000             // レーティング取得失敗時に使う初期値
+	queueBaseWindow        = 100              // 参加直後に許容するレーティング差
+	queueWindowGrowth      = 20               // 待機1秒ごとに広げるレーティング差
+	queueMaxWindow         = 800              // 許容レーティング差の上限
+	queueTickInterval      = 1 * time.Second  // 待機キューを再評価する間隔
+	defaultEstimatedWait   = 15 * time.Second // 実績がないときの推定待ち時間
+	estimatedWaitSmoothing = 0.3              // 推定待ち時間の指数移動平均の重み
+)
+
+// clock は現在時刻を返す関数（テストでは固定時刻に差し替える）
+type clock func() time.Time
+
+// queueEntry は待機キュー内の1クライアント分の情報
+type queueEntry struct {
+	client       *client
+	rating       int
+	joinedAt     time.Time
+	lastPosition int // 最後に通知した順位（変化したときだけ再通知する）
+}
+
+// queueMatch はキューから成立した1組のマッチ
+type queueMatch struct {
+	mode string
+	a    *client // 先に待っていた方
+	b    *client
+}
+
+// queueNotice は順位変化を通知する相手と内容
+type queueNotice struct {
+	client  *client
+	payload queuedPayload
+}
+
+// matchQueue はモードごとの待機キューとレーティング近接マッチングを管理する
+// ロックは持たないので、呼び出し側（matchState）が排他制御する
+type matchQueue struct {
+	now      clock
+	entries  map[string][]*queueEntry // モード → 参加順の待機リスト
+	avgWaits map[string]time.Duration // モード → マッチ成立までの平均待ち時間
+}
+
+// newMatchQueue は時刻関数を受け取って待機キューを作る
+func newMatchQueue(now clock) *matchQueue {
+	if now == nil {
+		now = time.Now
+	}
+	return &matchQueue{
+		now:      now,
+		entries:  make(map[string][]*queueEntry),
+		avgWaits: make(map[string]time.Duration),
+	}
+}
+
+// Enqueue はクライアントをキューに追加し、成立したマッチがあれば返す
+// 同じクライアントが既にいれば何もせず、同じユーザーの古い接続は置き換える
+func (q *matchQueue) Enqueue(c *client, mode string, rating int) *queueMatch {
+	if rating <= 0 {
+		rating = defaultRating
+	}
+	list := q.entries[mode]
+	for i, e := range list {
+		if e.client.id == c.id {
+			return nil
+		}
+		if c.username != "" && e.client.username == c.username {
+			list = append(list[:i], list[i+1:]...)
+			break
+		}
+	}
+	entry := &queueEntry{
+		client:   c,
+		rating:   rating,
+		joinedAt: q.now(),
+	}
+
+	// 新しく来たクライアントの相手だけを探す（既存の待機者同士はTickで再評価する）
+	now := q.now()
+	best := q.bestPartner(list, entry, now, nil)
+	if best == -1 {
+		q.entries[mode] = append(list, entry)
+		return nil
+	}
+	partner := list[best]
+	q.entries[mode] = append(list[:best], list[best+1:]...)
+	q.recordWait(mode, now.Sub(partner.joinedAt))
+	return &queueMatch{mode: mode, a: partner.client, b: c}
+}
+
+// Remove はクライアントをキューから外す（見つかればtrue）
+func (q *matchQueue) Remove(c *client) bool {
+	for mode, list := range q.entries {
+		for i, e := range list {
+			if e.client.id == c.id {
+				q.entries[mode] = append(list[:i], list[i+1:]...)
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// Contains はクライアントが待機中かどうかを返す
+func (q *matchQueue) Contains(c *client) bool {
+	for _, list := range q.entries {
+		for _, e := range list {
+			if e.client.id == c.id {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// Tick は全モードのキューを再評価し、成立したマッチを返す
+// 待ち時間が伸びると許容レーティング差が広がるので、定期的に呼ぶ必要がある
+func (q *matchQueue) Tick() []queueMatch {
+	var matches []queueMatch
+	for mode := range q.entries {
+		matches = append(matches, q.matchMode(mode)...)
+	}
+	return matches
+}
+
+// queueExpired は待ち時間が長すぎてキューから外したクライアント
+type queueExpired struct {
+	mode   string
+	client *client
+	rating int
+	waited time.Duration
+}
+
+// Expire は timeout 以上待っているクライアントをキューから外して返す（bot との対戦やタイムアウト用）
+// timeout が0以下なら誰も外さない
+func (q *matchQueue) Expire(timeout time.Duration) []queueExpired {
+	if timeout <= 0 {
+		return nil
+	}
+	now := q.now()
+	var expired []queueExpired
+	for mode, list := range q.entries {
+		remaining := list[:0]
+		for _, e := range list {
+			if now.Sub(e.joinedAt) >= timeout {
+				expired = append(expired, queueExpired{mode: mode, client: e.client, rating: e.rating, waited: now.Sub(e.joinedAt)})
+				continue
+			}
+			remaining = append(remaining, e)
+		}
+		q.entries[mode] = remaining
+	}
+	return expired
+}
+
+// Notices は順位が前回通知時から変わったクライアントへの通知内容を返す
+func (q *matchQueue) Notices() []queueNotice {
+	var notices []queueNotice
+	for mode, list := range q.entries {
+		for i, e := range list {
+			if e.lastPosition == i+1 {
+				continue
+			}
+			notices = append(notices, queueNotice{client: e.client, payload: q.statusAt(mode, i)})
+		}
+	}
+	return notices
+}
+
+// Status はクライアントの現在の待機状況を返す（待機中でなければfalse）
+func (q *matchQueue) Status(c *client) (queuedPayload, bool) {
+	for mode, list := range q.entries {
+		for i, e := range list {
+			if e.client.id == c.id {
+				return q.statusAt(mode, i), true
+			}
+		}
+	}
+	return queuedPayload{}, false
+}
+
+// statusAt は指定位置のエントリの待機状況を作り、通知済みとして記録する
+func (q *matchQueue) statusAt(mode string, index int) queuedPayload {
+	list := q.entries[mode]
+	e := list[index]
+	e.lastPosition = index + 1
+
+	// 推定待ち時間 = 平均待ち時間 - 既に待った時間（最低0秒）
+	waited := q.now().Sub(e.joinedAt)
+	estimate := q.estimatedWait(mode) - waited
+	if estimate < 0 {
+		estimate = 0
+	}
+	return queuedPayload{
+		Mode:                 mode,
+		Position:             index + 1,
+		QueueSize:            len(list),
+		Rating:               e.rating,
+		SearchWindow:         searchWindow(waited),
+		EstimatedWaitSeconds: int(math.Ceil(estimate.Seconds())),
+	}
+}
+
+// matchMode は1モード分のキューを走査し、許容範囲内で最も近いレーティング同士を組む
+// 待ち時間の長い順に相手を探すので、長く待っている人が優先される
+func (q *matchQueue) matchMode(mode string) []queueMatch {
+	list := q.entries[mode]
+	now := q.now()
+	matched := make(map[int]bool, len(list))
+	var matches []queueMatch
+
+	for i, e := range list {
+		if matched[i] {
+			continue
+		}
+		// 自分より後に参加した人の中から相手を探す
+		offset := i + 1
+		best := q.bestPartner(list[offset:], e, now, func(j int) bool { return matched[offset+j] })
+		if best == -1 {
+			continue
+		}
+		best += offset
+		matched[i] = true
+		matched[best] = true
+		q.recordWait(mode, now.Sub(e.joinedAt))
+		q.recordWait(mode, now.Sub(list[best].joinedAt))
+		matches = append(matches, queueMatch{mode: mode, a: e.client, b: list[best].client})
+	}
+
+	if len(matches) == 0 {
+		return nil
+	}
+	remaining := list[:0]
+	for i, e := range list {
+		if !matched[i] {
+			remaining = append(remaining, e)
+		}
+	}
+	q.entries[mode] = remaining
+	return matches
+}
+
+// bestPartner は候補の中から許容範囲内で最もレーティングが近い相手の位置を返す
+// 見つからなければ-1、skip がtrueを返す候補は除外する
+func (q *matchQueue) bestPartner(candidates []*queueEntry, e *queueEntry, now time.Time, skip func(int) bool) int {
+	best := -1
+	bestDiff := 0
+	for j, other := range candidates {
+		if skip != nil && skip(j) {
+			continue
+		}
+		diff := e.rating - other.rating
+		if diff < 0 {
+			diff = -diff
+		}
+		// どちらかの許容範囲に入っていれば組める（長く待った側の範囲が広い）
+		window := searchWindow(now.Sub(e.joinedAt))
+		if w := searchWindow(now.Sub(other.joinedAt)); w > window {
+			window = w
+		}
+		if diff > window {
+			continue
+		}
+		if best == -1 || diff < bestDiff {
+			best = j
+			bestDiff = diff
+		}
+	}
+	return best
+}
+
+// recordWait はマッチ成立までの待ち時間を平均に反映する
+func (q *matchQueue) recordWait(mode string, waited time.Duration) {
+	prev, ok := q.avgWaits[mode]
+	if !ok {
+		q.avgWaits[mode] = waited
+		return
+	}
+	q.avgWaits[mode] = time.Duration(float64(prev)*(1-estimatedWaitSmoothing) + float64(waited)*estimatedWaitSmoothing)
+}
+
+// estimatedWait はモードの平均待ち時間を返す（実績がなければデフォルト値）
+func (q *matchQueue) estimatedWait(mode string) time.Duration {
+	if avg, ok := q.avgWaits[mode]; ok {
+		return avg
+	}
+	return defaultEstimatedWait
+}
+
+// searchWindow は待ち時間に応じた許容レーティング差を返す
+// 例: 0秒で100、10秒で300、35秒以降は上限の800
+func searchWindow(waited time.Duration) int {
+	if waited < 0 {
+		waited = 0
+	}
+	window := queueBaseWindow + int(waited/time.Second)*queueWindowGrowth
+	if window > queueMaxWindow {
+		return queueMaxWindow
+	}
+	return window
+}
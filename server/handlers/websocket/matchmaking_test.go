@@ -0,0 +1,255 @@
+package websocket
+
+import (
+	"testing"
+	"time"
+)
+
+// fakeClock は手で進める時計（matchQueue / matchState に clock として渡す）
+type fakeClock struct {
+	now time.Time
+}
+
+// Now は現在の時刻を返す
+func (f *fakeClock) Now() time.Time {
+	return f.now
+}
+
+// Advance は時計を d だけ進める
+func (f *fakeClock) Advance(d time.Duration) {
+	f.now = f.now.Add(d)
+}
+
+// newFakeClock は固定の時刻から始まる時計を作る
+func newFakeClock() *fakeClock {
+	return &fakeClock{now: time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)}
+}
+
+// newQueueClient はキューのテスト用のクライアントを作る（接続は持たない）
+func newQueueClient(username string, rating int) *client {
+	return &client{id: newClientID(), username: username, rating: rating}
+}
+
+func TestSearchWindowWidensWithWait(t *testing.T) {
+	cases := []struct {
+		waited time.Duration
+		want   int
+	}{
+		{-time.Second, queueBaseWindow},
+		{0, queueBaseWindow},
+		{999 * time.Millisecond, queueBaseWindow},
+		{time.Second, queueBaseWindow + queueWindowGrowth},
+		{10 * time.Second, 300},
+		{35 * time.Second, queueMaxWindow},
+		{10 * time.Minute, queueMaxWindow},
+	}
+	for _, tc := range cases {
+		if got := searchWindow(tc.waited); got != tc.want {
+			t.Errorf("searchWindow(%v) = %d, want %d", tc.waited, got, tc.want)
+		}
+	}
+}
+
+func TestQueuePairsOnceWindowCoversGap(t *testing.T) {
+	clk := newFakeClock()
+	q := newMatchQueue(clk.Now)
+	newcomer := newQueueClient("newcomer", 1000)
+	regular := newQueueClient("regular", 1300)
+
+	if m := q.Enqueue(newcomer, "text-major", newcomer.rating); m != nil {
+		t.Fatalf("unexpected match for the first client: %+v", m)
+	}
+	if m := q.Enqueue(regular, "text-major", regular.rating); m != nil {
+		t.Fatalf("300 apart should not pair on arrival (window %d)", queueBaseWindow)
+	}
+
+	// 9秒では許容差が280なので、まだ組まない
+	clk.Advance(9 * time.Second)
+	if matches := q.Tick(); len(matches) != 0 {
+		t.Fatalf("paired too early at window %d: %+v", searchWindow(9*time.Second), matches)
+	}
+
+	// 10秒で許容差が300に広がり、組める
+	clk.Advance(time.Second)
+	matches := q.Tick()
+	if len(matches) != 1 {
+		t.Fatalf("expected one match at window 300, got %d", len(matches))
+	}
+	if matches[0].a != newcomer || matches[0].b != regular {
+		t.Errorf("expected the longer waiter first, got %s vs %s", matches[0].a.username, matches[0].b.username)
+	}
+	if q.Contains(newcomer) || q.Contains(regular) {
+		t.Error("matched clients should leave the queue")
+	}
+}
+
+func TestQueueUsesLongerWaitersWindow(t *testing.T) {
+	clk := newFakeClock()
+	q := newMatchQueue(clk.Now)
+	veteran := newQueueClient("veteran", 1600)
+	q.Enqueue(veteran, "text-major", veteran.rating)
+
+	// 先に待っている側の許容差（20秒で500）に入れば、後から来た人とすぐに組む
+	clk.Advance(20 * time.Second)
+	newcomer := newQueueClient("newcomer", 1150)
+	m := q.Enqueue(newcomer, "text-major", newcomer.rating)
+	if m == nil {
+		t.Fatal("expected the veteran's widened window to cover the newcomer")
+	}
+	if m.a != veteran || m.b != newcomer {
+		t.Errorf("unexpected pairing %s vs %s", m.a.username, m.b.username)
+	}
+}
+
+func TestQueuePicksClosestRating(t *testing.T) {
+	clk := newFakeClock()
+	q := newMatchQueue(clk.Now)
+	low := newQueueClient("low", 1000)
+	high := newQueueClient("high", 1300)
+	q.Enqueue(low, "text-major", low.rating)
+	q.Enqueue(high, "text-major", high.rating)
+
+	// 10秒待った2人の許容差（300）にはどちらも入るが、近い方（120差）と組む
+	clk.Advance(10 * time.Second)
+	newcomer := newQueueClient("newcomer", 1180)
+	m := q.Enqueue(newcomer, "text-major", newcomer.rating)
+	if m == nil || m.a != high {
+		t.Fatalf("expected to pair with the closest rating, got %+v", m)
+	}
+	if !q.Contains(low) {
+		t.Error("the unmatched client should stay queued")
+	}
+}
+
+func TestQueueKeepsModesApart(t *testing.T) {
+	clk := newFakeClock()
+	q := newMatchQueue(clk.Now)
+	a := newQueueClient("a", 1000)
+	b := newQueueClient("b", 1000)
+	q.Enqueue(a, "text-major", a.rating)
+	if m := q.Enqueue(b, "audio-major", b.rating); m != nil {
+		t.Fatal("clients in different modes must not pair")
+	}
+	clk.Advance(time.Minute)
+	if matches := q.Tick(); len(matches) != 0 {
+		t.Fatalf("clients in different modes must not pair: %+v", matches)
+	}
+}
+
+func TestQueueStatusReportsPositionAndEstimate(t *testing.T) {
+	clk := newFakeClock()
+	q := newMatchQueue(clk.Now)
+	first := newQueueClient("first", 1000)
+	second := newQueueClient("second", 2000)
+	q.Enqueue(first, "text-major", first.rating)
+	clk.Advance(5 * time.Second)
+	q.Enqueue(second, "text-major", second.rating)
+
+	status, ok := q.Status(second)
+	if !ok {
+		t.Fatal("expected the client to be queued")
+	}
+	if status.Position != 2 || status.QueueSize != 2 {
+		t.Errorf("position %d/%d, want 2/2", status.Position, status.QueueSize)
+	}
+	if status.SearchWindow != queueBaseWindow {
+		t.Errorf("search window %d, want %d", status.SearchWindow, queueBaseWindow)
+	}
+	if want := int(defaultEstimatedWait / time.Second); status.EstimatedWaitSeconds != want {
+		t.Errorf("estimated wait %ds, want %ds", status.EstimatedWaitSeconds, want)
+	}
+
+	// 待った分だけ推定待ち時間が減る
+	status, _ = q.Status(first)
+	if want := int((defaultEstimatedWait - 5*time.Second) / time.Second); status.EstimatedWaitSeconds != want {
+		t.Errorf("estimated wait %ds, want %ds", status.EstimatedWaitSeconds, want)
+	}
+	if status.SearchWindow != queueBaseWindow+5*queueWindowGrowth {
+		t.Errorf("search window %d, want %d", status.SearchWindow, queueBaseWindow+5*queueWindowGrowth)
+	}
+}
+
+func TestQueueExpireRemovesLongWaiters(t *testing.T) {
+	clk := newFakeClock()
+	q := newMatchQueue(clk.Now)
+	c := newQueueClient("lonely", 1000)
+	q.Enqueue(c, "text-major", c.rating)
+
+	clk.Advance(29 * time.Second)
+	if expired := q.Expire(30 * time.Second); len(expired) != 0 {
+		t.Fatalf("expired too early: %+v", expired)
+	}
+	clk.Advance(time.Second)
+	expired := q.Expire(30 * time.Second)
+	if len(expired) != 1 || expired[0].client != c || expired[0].waited != 30*time.Second {
+		t.Fatalf("unexpected expiry: %+v", expired)
+	}
+	if q.Contains(c) {
+		t.Error("expired client should leave the queue")
+	}
+}
+
+func TestJoinRejectsClientQueuedInAnotherMode(t *testing.T) {
+	s := newMatchState(newFakeClock().Now)
+	c := newQueueClient("alice", 1000)
+	if _, _, err := s.Join(c, "text-major"); err != nil {
+		t.Fatalf("first join: %v", err)
+	}
+	if _, _, err := s.Join(c, "audio-major"); err != errAlreadyInRoom {
+		t.Fatalf("join in a second mode: got %v, want errAlreadyInRoom", err)
+	}
+	if _, _, err := s.Join(c, "text-major"); err != errAlreadyInRoom {
+		t.Fatalf("join the same mode twice: got %v, want errAlreadyInRoom", err)
+	}
+}
+
+func TestJoinRejectsBusyClients(t *testing.T) {
+	s := newMatchState(newFakeClock().Now)
+
+	spectator := newQueueClient("spectator", 1000)
+	spectator.spectating = "room-1"
+	if _, _, err := s.Join(spectator, "text-major"); err != errAlreadyInRoom {
+		t.Errorf("spectator: got %v, want errAlreadyInRoom", err)
+	}
+
+	lobby := newQueueClient("lobby", 1000)
+	if _, _, _, _, err := s.JoinRoyale(lobby, "text-major"); err != nil {
+		t.Fatalf("join royale lobby: %v", err)
+	}
+	if _, _, err := s.Join(lobby, "text-major"); err != errAlreadyInRoom {
+		t.Errorf("royale lobby: got %v, want errAlreadyInRoom", err)
+	}
+
+	teamed := newQueueClient("teamed", 1000)
+	s.teamQueue.Enqueue([]*client{teamed}, "text-major", teamed.rating)
+	if _, _, err := s.Join(teamed, "text-major"); err != errAlreadyInRoom {
+		t.Errorf("team queue: got %v, want errAlreadyInRoom", err)
+	}
+}
+
+func TestJoinPairsAfterWindowWidens(t *testing.T) {
+	clk := newFakeClock()
+	s := newMatchState(clk.Now)
+	a := newQueueClient("a", 1000)
+	b := newQueueClient("b", 1400)
+	if r, _, _ := s.Join(a, "text-major"); r != nil {
+		t.Fatal("unexpected room for the first client")
+	}
+	if r, _, _ := s.Join(b, "text-major"); r != nil {
+		t.Fatal("400 apart should not pair on arrival")
+	}
+
+	clk.Advance(14 * time.Second)
+	if result := s.Tick(matchmakerConfig{}); len(result.rooms) != 0 {
+		t.Fatal("paired before the window reached 400")
+	}
+	clk.Advance(time.Second)
+	result := s.Tick(matchmakerConfig{})
+	if len(result.rooms) != 1 {
+		t.Fatalf("expected a room once the window reached 400, got %d", len(result.rooms))
+	}
+	r := result.rooms[0]
+	if a.roomID != r.id || b.roomID != r.id || s.GetRoom(r.id) != r {
+		t.Error("both clients should be seated in the registered room")
+	}
+}
@@ -4,14 +4,16 @@ import (
 	"encoding/json"
 	"sync"
 	"time"
-
-	"github.com/gorilla/websocket"
 )
 
 // マッチングの定数
 const (
-	maxRoundsPerMatch = 3                // 1試合あたりのラウンド数
-	roundDuration     = 10 * time.Second // 1ラウンドの制限時間
+	maxRoundsPerMatch = 3                // 1試合あたりの既定のラウンド数（MatchRules の既定値）
+	roundDuration     = 10 * time.Second // 1ラウンドの既定の制限時間（MatchRules の既定値）
+	maxPrivateRounds  = 10               // プライベートルームで指定できる最大ラウンド数
+	reconnectGrace    = 30 * time.Second // 切断後に席を確保しておく時間
+	minRoomPlayers    = 2                // 1ルームの最少人数
+	maxRoomPlayers    = 8                // 1ルームの最大人数（フリーフォーオール）
 )
 
 // wsMessage はWebSocketメッセージの共通フォーマット
@@ -22,8 +24,46 @@ type wsMessage struct {
 
 // joinPayload はマッチ参加リクエストのペイロード
 type joinPayload struct {
+	Token  string `json:"token"`
+	Mode   string `json:"mode"`
+	Team   bool   `json:"team,omitempty"`   // trueなら2対2のチーム戦の待機キューに入る（パーティーならリーダーが送る）
+	Royale bool   `json:"royale,omitempty"` // trueならバトルロイヤル（脱落戦）のロビーに入る
+}
+
+// createRoomPayload はプライベートルーム作成リクエストのペイロード
+type createRoomPayload struct {
+	Token  string `json:"token"`
+	Mode   string `json:"mode"`
+	Rounds int    `json:"rounds"`          // 0ならデフォルトのラウンド数（rules.rounds と同じ）
+	Rated  *bool  `json:"rated,omitempty"` // falseならレーティングを変動させない（未指定はtrue）
+	// サーバー設定のルールから変えたい項目（未指定の項目はモードの既定のまま）
+	Rules *matchRulesOverride `json:"rules,omitempty"`
+	// 参加人数の範囲（未指定は2人対戦）。最少人数が揃い全員準備完了で開始する
+	MinPlayers int `json:"minPlayers"`
+	MaxPlayers int `json:"maxPlayers"`
+}
+
+// joinRoomPayload は招待コードでプライベートルームに参加するペイロード
+type joinRoomPayload struct {
+	Token string `json:"token"`
+	Code  string `json:"code"`
+}
+
+// readyPayload はプライベートルームの準備完了を切り替えるペイロード
+type readyPayload struct {
+	RoomID string `json:"roomId"`
+	Ready  bool   `json:"ready"`
+}
+
+// resumePayload は切断後の再接続（試合復帰）リクエストのペイロード
+type resumePayload struct {
 	Token string `json:"token"`
-	Mode  string `json:"mode"`
+}
+
+// spectatePayload は観戦リクエストのペイロード（公開マッチはroomId、プライベートはcode）
+type spectatePayload struct {
+	RoomID string `json:"roomId"`
+	Code   string `json:"code"`
 }
 
 // answerPayload はクライアントの回答を受け取るペイロード
@@ -35,43 +75,232 @@ type answerPayload struct {
 // roundPayload は新ラウンド開始時にクライアントへ送る情報
 type roundPayload struct {
 	RoomID           string          `json:"roomId"`
-	Opponent         string          `json:"opponent"`
+	Opponent         string          `json:"opponent"` // 2人対戦時の相手（3人以上では先頭の相手）
 	OpponentImageURL string          `json:"opponentImageUrl,omitempty"`
+	Opponents        []opponentInfo  `json:"opponents"` // 自分以外の全参加者
 	Question         questionPayload `json:"question"`
 	Round            int             `json:"round"`
 	TotalRounds      int             `json:"totalRounds"`
+	TimeLimitMs      int64           `json:"timeLimitMs"` // このラウンドの制限時間
 	Scores           map[string]int  `json:"scores"`
+	Series           *seriesPayload  `json:"series,omitempty"` // 再戦で続けているシリーズの勝敗
+	Teams            []teamPayload   `json:"teams,omitempty"`  // チーム戦のチームと得点
+}
+
+// opponentInfo は対戦相手1人分の表示情報
+type opponentInfo struct {
+	Username string `json:"username"`
+	ImageURL string `json:"imageUrl,omitempty"`
 }
 
 // questionPayload は問題データを送信する構造
 type questionPayload struct {
-	ID      uint     `json:"id"`
-	Prompt  string   `json:"prompt"`
-	AudioURL string  `json:"audioUrl,omitempty"`
-	Answer  string   `json:"answer,omitempty"`    // デバッグ用（本番では送らない）
-	Choices []string `json:"choices,omitempty"`
+	ID       uint     `json:"id"`
+	Prompt   string   `json:"prompt"`
+	AudioURL string   `json:"audioUrl,omitempty"`
+	Choices  []string `json:"choices,omitempty"` // 正解はラウンド終了時の resultPayload.Answer でのみ送る
 }
 
 // resultPayload はラウンド終了時の結果を送る構造
 type resultPayload struct {
-	RoomID   string         `json:"roomId"`
-	Status   string         `json:"status"`
-	Round    int            `json:"round"`
-	Scores   map[string]int `json:"scores"`
-	Answers  map[string]string `json:"answers,omitempty"`
-	Correct  map[string]bool   `json:"correct,omitempty"`
-	Answer   string            `json:"answer,omitempty"`
+	RoomID  string            `json:"roomId"`
+	Status  string            `json:"status"`
+	Round   int               `json:"round"`
+	Scores  map[string]int    `json:"scores"`
+	Answers map[string]string `json:"answers,omitempty"`
+	Correct map[string]bool   `json:"correct,omitempty"`
+	Answer  string            `json:"answer,omitempty"`
+	// このラウンドで得た点数（速いほど高い）とラウンド開始から回答までの時間
+	Points     map[string]int   `json:"points,omitempty"`
+	ResponseMs map[string]int64 `json:"responseMs,omitempty"`
+	Teams      []teamPayload    `json:"teams,omitempty"` // チーム戦のチームと得点
 }
 
 // finishedPayload はマッチ終了時の最終結果を送る構造
 type finishedPayload struct {
 	RoomID string         `json:"roomId"`
-	Winner string         `json:"winner,omitempty"` // 勝者がいれば設定
+	Winner string         `json:"winner,omitempty"` // 勝者がいれば設定（チーム戦では勝ったチーム名）
 	Scores map[string]int `json:"scores"`
-	Status string         `json:"status"` // "victory", "defeat", "draw"
+	Status string         `json:"status"`           // "victory", "defeat", "draw"（観戦者には終了理由または "draw"）
+	Reason string         `json:"reason,omitempty"` // 終了理由（"completed", "forfeit"）
 	Recap  []recapItem    `json:"recap,omitempty"`
-	Ratings map[string]int `json:"ratings,omitempty"` // 更新後のレーティング
-	Deltas  map[string]int `json:"deltas,omitempty"`  // レーティング変動
+	// 最終順位（スコア順、同点は同順位）
+	Standings []standingItem `json:"standings,omitempty"`
+	Ratings   map[string]int `json:"ratings,omitempty"` // 更新後のレーティング
+	Deltas    map[string]int `json:"deltas,omitempty"`  // レーティング変動
+	// 再戦で続けているシリーズの勝敗（この試合の結果を含む）
+	Series *seriesPayload `json:"series,omitempty"`
+	// 再戦を受け付ける秒数（0なら再戦できない試合）
+	RematchSeconds int `json:"rematchSeconds,omitempty"`
+	// チーム戦のチームと最終得点
+	Teams []teamPayload `json:"teams,omitempty"`
+}
+
+// seriesPayload は再戦で続けているシリーズ（best-of-N）の状況
+type seriesPayload struct {
+	BestOf int            `json:"bestOf"`
+	Game   int            `json:"game"`             // 何戦目か
+	Wins   map[string]int `json:"wins"`             // ユーザー名 → 勝った試合数
+	Winner string         `json:"winner,omitempty"` // 過半数を取ってシリーズの勝ちが決まったユーザー
+}
+
+// rematchPayload は再戦の申し込み・承諾・辞退のペイロード
+type rematchPayload struct {
+	RoomID string `json:"roomId"`           // 終了した試合のルームID
+	BestOf int    `json:"bestOf,omitempty"` // 新しく始めるシリーズの試合数（3か5、未指定は3）
+}
+
+// rematchStatusPayload は再戦の受付状況を送る構造
+type rematchStatusPayload struct {
+	RoomID           string   `json:"roomId"`
+	BestOf           int      `json:"bestOf"`
+	Accepted         []string `json:"accepted"` // 同意済みのユーザー名
+	ExpiresInSeconds int      `json:"expiresInSeconds"`
+}
+
+// rematchCancelledPayload は再戦の受付が締め切られたことを送る構造
+type rematchCancelledPayload struct {
+	RoomID   string `json:"roomId"`
+	Reason   string `json:"reason"`             // "declined", "timeout", "unavailable"
+	Username string `json:"username,omitempty"` // 辞退したユーザー
+}
+
+// tournamentJoinPayload は大会の現在の対戦に参加するリクエストの構造
+type tournamentJoinPayload struct {
+	Token        string `json:"token"`
+	TournamentID uint   `json:"tournamentId"`
+}
+
+// tournamentWaitingPayload は大会の対戦相手が来るのを待っていることを知らせる構造
+type tournamentWaitingPayload struct {
+	TournamentID      uint   `json:"tournamentId"`
+	Round             int    `json:"round"`
+	RoomID            string `json:"roomId"`
+	Opponent          string `json:"opponent"`
+	DeadlineInSeconds int    `json:"deadlineInSeconds"` // 相手がこの時間内に来なければ不戦勝になる
+}
+
+// tournamentWalkoverPayload は相手が締め切りまでに来ず、大会の対戦が終わったことを知らせる構造
+type tournamentWalkoverPayload struct {
+	TournamentID uint   `json:"tournamentId"`
+	Round        int    `json:"round"`
+	RoomID       string `json:"roomId"`
+}
+
+// teamPayload はチーム戦の1チーム分の情報
+type teamPayload struct {
+	Name    string   `json:"name"` // "red", "blue"
+	Members []string `json:"members"`
+	Score   int      `json:"score"`
+}
+
+// royaleLobbyPayload はバトルロイヤルのロビーの状況を送る構造（大人数に送るので人数だけ）
+type royaleLobbyPayload struct {
+	RoomID          string `json:"roomId"`
+	Mode            string `json:"mode"`
+	Players         int    `json:"players"` // 現在の参加人数
+	MinPlayers      int    `json:"minPlayers"`
+	MaxPlayers      int    `json:"maxPlayers"`
+	StartsInSeconds int    `json:"startsInSeconds,omitempty"` // 開始までの秒数（カウントダウン前は省略）
+}
+
+// royaleSurvivorsPayload はバトルロイヤルのラウンドごとの生存者を送る構造
+type royaleSurvivorsPayload struct {
+	RoomID     string   `json:"roomId"`
+	Round      int      `json:"round"`
+	Survivors  []string `json:"survivors"`  // まだ残っている参加者
+	Eliminated []string `json:"eliminated"` // このラウンドで脱落した参加者
+	Remaining  int      `json:"remaining"`
+}
+
+// partyPayload はパーティー（チーム戦を一緒に組む仲間）の状況を送る構造
+type partyPayload struct {
+	Code    string   `json:"code"` // 仲間を招待するコード
+	Leader  string   `json:"leader"`
+	Members []string `json:"members"` // リーダーを含む参加順
+}
+
+// partyJoinPayload はパーティー参加リクエストの構造
+type partyJoinPayload struct {
+	Token string `json:"token"`
+	Code  string `json:"code"`
+}
+
+// partyCreatePayload はパーティー作成リクエストの構造
+type partyCreatePayload struct {
+	Token string `json:"token"`
+}
+
+// suddenDeathPayload は延長戦に入ったことを知らせる構造
+type suddenDeathPayload struct {
+	RoomID string `json:"roomId"`
+	Round  int    `json:"round"` // 延長戦として行うラウンド番号
+}
+
+// resumedPayload は試合復帰時に現在の進行状況を送る構造
+type resumedPayload struct {
+	RoomID           string           `json:"roomId"`
+	Mode             string           `json:"mode"`
+	Opponent         string           `json:"opponent"`
+	OpponentImageURL string           `json:"opponentImageUrl,omitempty"`
+	Opponents        []opponentInfo   `json:"opponents"`
+	Question         *questionPayload `json:"question,omitempty"` // ラウンド間や準備中はnil
+	Round            int              `json:"round"`
+	TotalRounds      int              `json:"totalRounds"`
+	Scores           map[string]int   `json:"scores"`
+	RemainingMs      int64            `json:"remainingMs"`        // 現在のラウンドの残り時間
+	MyAnswer         string           `json:"myAnswer,omitempty"` // このラウンドで既に送った回答
+	Teams            []teamPayload    `json:"teams,omitempty"`    // チーム戦のチームと得点
+}
+
+// spectatingPayload は観戦開始時に現在の進行状況を送る構造（正解は含めない）
+type spectatingPayload struct {
+	RoomID      string           `json:"roomId"`
+	Mode        string           `json:"mode"`
+	Players     []opponentInfo   `json:"players"`
+	Question    *questionPayload `json:"question,omitempty"` // ラウンド間や準備中はnil
+	Round       int              `json:"round"`
+	TotalRounds int              `json:"totalRounds"`
+	Scores      map[string]int   `json:"scores"`
+	RemainingMs int64            `json:"remainingMs"`
+}
+
+// RoomSummary は進行中ルーム一覧（REST）の1件分
+type RoomSummary struct {
+	RoomID        string              `json:"roomId"`
+	Mode          string              `json:"mode"`
+	Players       []RoomSummaryPlayer `json:"players"`
+	AverageRating int                 `json:"averageRating"`
+	Round         int                 `json:"round"`
+	TotalRounds   int                 `json:"totalRounds"`
+	Spectators    int                 `json:"spectators"`
+}
+
+// RoomSummaryPlayer は進行中ルーム一覧に載せるプレイヤー情報
+type RoomSummaryPlayer struct {
+	Username string `json:"username"`
+	ImageURL string `json:"imageUrl,omitempty"`
+	Rating   int    `json:"rating"`
+	Score    int    `json:"score"`
+}
+
+// connectionPayload は対戦相手の切断・復帰を知らせる構造
+type connectionPayload struct {
+	RoomID       string `json:"roomId"`
+	Username     string `json:"username"`
+	GraceSeconds int    `json:"graceSeconds,omitempty"` // 没収負けになるまでの猶予（切断時のみ）
+}
+
+// standingItem は最終順位の1行分
+type standingItem struct {
+	Rank      int    `json:"rank"`
+	Username  string `json:"username"`
+	Score     int    `json:"score"`
+	Forfeited bool   `json:"forfeited,omitempty"` // 切断による没収負け
+	Bot       bool   `json:"bot,omitempty"`       // bot の参加者
+	Team      string `json:"team,omitempty"`      // チーム戦で所属するチーム（順位はチーム単位）
+	// バトルロイヤルで脱落したラウンド（最後まで残った人は0、順位は生き残った順）
+	EliminatedRound int `json:"eliminatedRound,omitempty"`
 }
 
 // recapItem はラウンドごとの振り返り情報
@@ -82,9 +311,74 @@ type recapItem struct {
 	Status string `json:"status"`
 }
 
+// lobbyPayload はプライベートルームの待機状況を送る構造
+type lobbyPayload struct {
+	RoomID string `json:"roomId"`
+	Code   string `json:"code"`
+	Mode   string `json:"mode"`
+	Rounds int    `json:"rounds"`
+	Rated  bool   `json:"rated"`
+	Host   string `json:"host"`
+	// 参加人数の範囲
+	MinPlayers int           `json:"minPlayers"`
+	MaxPlayers int           `json:"maxPlayers"`
+	Players    []lobbyPlayer `json:"players"`
+	Rules      rulesPayload  `json:"rules"`
+}
+
+// rulesPayload は試合のルールを送る構造
+type rulesPayload struct {
+	Rounds            int            `json:"rounds"`
+	RoundMs           int64          `json:"roundMs"`    // 1ラウンドの制限時間
+	RoundGapMs        int64          `json:"roundGapMs"` // ラウンド間の待ち時間
+	Choices           int            `json:"choices"`
+	SuddenDeathRounds int            `json:"suddenDeathRounds"`     // 同点時の延長戦の最大ラウンド数
+	TeamScoring       string         `json:"teamScoring,omitempty"` // チーム戦の得点の出し方（"sum", "best"）
+	Scoring           scoringPayload `json:"scoring"`               // 回答の速さに応じた得点のカーブ
+}
+
+// scoringPayload は得点の減衰カーブを送る構造
+type scoringPayload struct {
+	MaxPoints int    `json:"maxPoints"`
+	MinPoints int    `json:"minPoints"`
+	GraceMs   int64  `json:"graceMs"` // 満点がもらえる猶予時間
+	Decay     string `json:"decay"`   // "linear", "ease-in", "ease-out"
+}
+
+// lobbyPlayer はプライベートルーム内の1プレイヤー分の情報
+type lobbyPlayer struct {
+	Username string `json:"username"`
+	ImageURL string `json:"imageUrl,omitempty"`
+	Ready    bool   `json:"ready"`
+}
+
+// queueEndedPayload は待機をやめたとき（キャンセル・タイムアウト）に送る構造
+type queueEndedPayload struct {
+	Mode          string `json:"mode"`
+	WaitedSeconds int    `json:"waitedSeconds,omitempty"` // タイムアウトまでに待った時間
+}
+
+// queuedPayload はマッチング待機中の状況を送る構造
+type queuedPayload struct {
+	Mode                 string `json:"mode"`
+	Position             int    `json:"position"`             // キュー内の順位（1始まり、待ち時間の長い順）
+	QueueSize            int    `json:"queueSize"`            // 同じモードの待機人数
+	Rating               int    `json:"rating"`               // マッチングに使う自分のレーティング
+	SearchWindow         int    `json:"searchWindow"`         // 現在許容しているレーティング差
+	EstimatedWaitSeconds int    `json:"estimatedWaitSeconds"` // 推定残り待ち時間（秒）
+	Team                 bool   `json:"team,omitempty"`       // チーム戦の待機キューか
+}
+
+// welcomePayload は接続直後に送るプロトコル情報
+type welcomePayload struct {
+	ProtocolVersion    int `json:"protocolVersion"`    // この接続で使うバージョン
+	MinProtocolVersion int `json:"minProtocolVersion"` // サーバーが受け付ける最も古いバージョン
+}
+
 // errorPayload はエラーメッセージを送る構造
 type errorPayload struct {
-	Message string `json:"message"`
+	Code    errorCode `json:"code"`    // 機械判定用のコード（protocol.go の一覧）
+	Message string    `json:"message"` // 表示・ログ用の説明
 }
 
 // preparingPayload はマッチ準備中を通知する構造
@@ -94,11 +388,27 @@ type preparingPayload struct {
 
 // matchQuestion はマッチで使う問題データの内部表現
 type matchQuestion struct {
-	ID      uint
-	Prompt  string
-	Answer  string
+	ID       uint
+	Prompt   string
+	Answer   string
 	AudioURL string
-	Choices []string
+	Choices  []string
+}
+
+// roundRecord は終了した1ラウンド分の出題と回答の記録
+type roundRecord struct {
+	Round    int
+	Question matchQuestion
+	Answers  []answerRecord
+}
+
+// answerRecord は1ラウンドにおける1プレイヤー分の回答
+type answerRecord struct {
+	Username string
+	Answer   string // 未回答なら空
+	Elapsed  time.Duration
+	Correct  bool
+	Points   int // 速さに応じて得た点数
 }
 
 // templateSet はテンプレート生成用の言語とテンプレートリスト
@@ -109,35 +419,84 @@ type templateSet struct {
 
 // client は接続中のクライアント情報
 type client struct {
-	id       string
-	username string
-	imageURL string
-	conn     *websocket.Conn
-	roomID   string
-	mode     string
+	id         string
+	userID     uint
+	username   string
+	imageURL   string
+	rating     int
+	conn       clientConn
+	roomID     string
+	mode       string
+	spectating string   // 観戦中のルームID（プレイヤーとしての参加とは排他）
+	bot        *botConn // bot の場合だけ設定される
+	party      *party   // 参加しているパーティー（s.mu で保護）
+
+	// 送信は writeLoop だけが行う（newClient で初期化）
+	outbound     chan wsMessage // 送信待ちのメッセージ
+	done         chan struct{}  // 閉じたら送信をやめる
+	flushOnClose bool           // 閉じるときに送信待ちを書き終えるか（done を閉じる前に設定する）
+	closeOnce    sync.Once
+}
+
+// clientConn はクライアントへの送信先（実際のWebSocket接続か bot）
+// 書き込むのは client.writeLoop だけで、他の場所からは client.send を使う
+type clientConn interface {
+	WriteJSON(v any) error
 }
 
-// room はマッチングルーム（2人対戦）
+// room はマッチングルーム（2〜8人のフリーフォーオール）
 type room struct {
-	id        string
-	players   [2]*client
-	questions []matchQuestion
-	question  *matchQuestion
-	answers   map[string]string
-	round     int
-	maxRounds int
-	scores    map[string]int
-	active    bool
-	finished  bool
-	roundSeq  uint64
-	recap     []recapItem
-	mode      string
-	mu        sync.Mutex // ルーム内の排他制御
+	id         string
+	players    []*client // 参加者（先頭がホスト、参加順）
+	spectators []*client // 観戦者（回答はできない）
+	minPlayers int       // 開始に必要な最少人数
+	maxPlayers int       // 参加できる最大人数
+	questions  []matchQuestion
+	question   *matchQuestion
+	answers    map[string]string
+	round      int
+	maxRounds  int
+	scores     map[string]int
+	active     bool
+	finished   bool
+	roundSeq   uint64
+	recap      []recapItem
+	mode       string
+	rules      MatchRules      // 出題数や制限時間などの形式（ルーム作成時に決まり、以後変えない）
+	rated      bool            // falseならレーティングを更新しない
+	code       string          // プライベートルームの招待コード（公開マッチは空）
+	ready      map[string]bool // プライベートルームの準備完了状態（クライアントID → 準備完了）
+	started    bool            // マッチが開始済みか（プライベートルームは全員準備完了で開始）
+
+	startedAt      time.Time                // 問題の出題を始めた時刻（対戦履歴用）
+	answerTimes    map[string]time.Duration // 現在のラウンドで回答が届くまでの時間（クライアントID → 経過時間）
+	history        []roundRecord            // 終了したラウンドごとの回答記録（対戦履歴用）
+	roundStartedAt time.Time                // 現在のラウンドの開始時刻
+	roundLimit     time.Duration            // 現在のラウンドの制限時間
+	disconnected   map[string]*client       // 切断中で席を確保している接続（ユーザー名 → 切断したクライアント）
+	forfeited      map[string]bool          // 切断猶予切れで没収負けになったユーザー名
+	suddenDeath    int                      // 延長戦として追加したラウンド数
+	series         *matchSeries             // 再戦で続けているシリーズ（通常の試合はnil）
+	tournament     *tournamentSeat          // 大会の対戦カードの試合（通常の試合はnil）
+	teams          map[string]string        // チーム戦の所属（ユーザー名 → チーム名、個人戦はnil）
+	teamScores     map[string]int           // チーム戦のチームごとの得点（チーム名 → 得点）
+	royale         *royaleState             // バトルロイヤルの脱落状況（通常の試合はnil）
+	mu             sync.Mutex               // ルーム内の排他制御
 }
 
 // matchState はマッチング全体の状態管理
 type matchState struct {
-	mu      sync.Mutex
-	waiting map[string]*client // マッチング待機中のクライアント
-	rooms   map[string]*room   // 進行中のルーム
+	mu    sync.Mutex
+	queue *matchQueue      // マッチング待機中のクライアント（モード別）
+	rooms map[string]*room // 進行中のルーム
+	codes map[string]*room // 招待コード → プライベートルーム
+
+	rematches map[string]*rematchOffer // 終了した試合のルームID → 再戦の受付
+
+	tournamentRooms map[uint]*room // 大会の対戦カードID → その試合のルーム（両者が揃うまで開始しない）
+
+	teamQueue *teamQueue        // チーム戦の待機キュー（1人またはパーティー単位）
+	parties   map[string]*party // 招待コード → パーティー
+
+	royaleLobbies map[string]*room // モード → 参加者を集めているバトルロイヤルのロビー
 }
@@ -0,0 +1,297 @@
+package websocket
+
+import (
+	"encoding/json"
+	"errors"
+	"strings"
+
+	"example.com/mathkun-tmp-/server/db"
+	"example.com/mathkun-tmp-/server/models"
+	"example.com/mathkun-tmp-/server/repositories"
+)
+
+// パーティー関連のエラー
+var (
+	errPartyNotFound  = errors.New("party not found")
+	errPartyFull      = errors.New("party is full")
+	errAlreadyInParty = errors.New("already in a party")
+	errNotInParty     = errors.New("not in a party")
+	errNotPartyLeader = errors.New("only the party leader can queue")
+)
+
+// party はチーム戦を一緒に組む仲間（招待コードで集まる、最大 teamSize 人）
+// 試合が終わっても解散しないので、同じ仲間で続けてキューに入れる
+type party struct {
+	code    string
+	members []*client // 先頭がリーダー
+}
+
+// payload はパーティーの状況を送信用の形にする（s.mu を保持して呼ぶ）
+func (p *party) payload() partyPayload {
+	payload := partyPayload{Code: p.code, Members: make([]string, 0, len(p.members))}
+	if len(p.members) > 0 {
+		payload.Leader = p.members[0].username
+	}
+	for _, m := range p.members {
+		payload.Members = append(payload.Members, m.username)
+	}
+	return payload
+}
+
+// handlePartyCreate はパーティーの作成を処理し、招待コードを返す
+func handlePartyCreate(c *client, payload json.RawMessage) {
+	var req partyCreatePayload
+	if err := json.Unmarshal(payload, &req); err != nil || strings.TrimSpace(req.Token) == "" {
+		sendError(c, codeInvalidPayload, "invalid party payload")
+		return
+	}
+	if !authenticateClient(c, req.Token) {
+		return
+	}
+
+	p, err := state.CreateParty(c)
+	if err != nil {
+		sendErrorFor(c, err)
+		return
+	}
+	c.send(wsMessage{Type: "party:updated", Payload: mustJSON(state.PartyStatus(p))})
+}
+
+// handlePartyJoin は招待コードでのパーティー参加を処理し、メンバー全員に知らせる
+func handlePartyJoin(c *client, payload json.RawMessage) {
+	var req partyJoinPayload
+	if err := json.Unmarshal(payload, &req); err != nil || strings.TrimSpace(req.Token) == "" || strings.TrimSpace(req.Code) == "" {
+		sendError(c, codeInvalidPayload, "invalid party payload")
+		return
+	}
+	if !authenticateClient(c, req.Token) {
+		return
+	}
+
+	members, status, err := state.JoinParty(c, req.Code)
+	if err != nil {
+		sendErrorFor(c, err)
+		return
+	}
+	for _, m := range members {
+		m.send(wsMessage{Type: "party:updated", Payload: mustJSON(status)})
+	}
+}
+
+// handlePartyLeave はパーティーからの離脱を処理する
+func handlePartyLeave(c *client) {
+	if !state.LeaveParty(c) {
+		sendErrorFor(c, errNotInParty)
+	}
+}
+
+// handleTeamJoin はチーム戦の待機キューへの参加を処理する（パーティーならリーダーが全員分を入れる）
+func handleTeamJoin(c *client, mode string) {
+	members, rooms, err := state.JoinTeamQueue(c, mode)
+	if err != nil {
+		sendErrorFor(c, err)
+		return
+	}
+	if len(rooms) == 0 {
+		status, _ := state.TeamQueueStatus(c)
+		for _, m := range members {
+			m.send(wsMessage{Type: "match:queued", Payload: mustJSON(status)})
+		}
+		return
+	}
+	for _, r := range rooms {
+		startMatch(r)
+	}
+}
+
+// CreateParty はクライアントをリーダーとするパーティーを作る
+func (s *matchState) CreateParty(c *client) (*party, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if c.party != nil {
+		return nil, errAlreadyInParty
+	}
+	code := newInviteCode()
+	for s.parties[code] != nil {
+		code = newInviteCode()
+	}
+	p := &party{code: code, members: []*client{c}}
+	s.parties[code] = p
+	c.party = p
+	return p, nil
+}
+
+// JoinParty は招待コードのパーティーに参加し、通知先のメンバーと新しい状況を返す
+// 同じユーザーの古い接続が残っていれば置き換える
+func (s *matchState) JoinParty(c *client, code string) ([]*client, partyPayload, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if c.party != nil {
+		return nil, partyPayload{}, errAlreadyInParty
+	}
+	p := s.parties[strings.ToUpper(strings.TrimSpace(code))]
+	if p == nil {
+		return nil, partyPayload{}, errPartyNotFound
+	}
+	// キューに入っているパーティーのメンバーは変えられない
+	if s.teamQueue.Contains(p.members[0]) {
+		return nil, partyPayload{}, errAlreadyInRoom
+	}
+	for i, m := range p.members {
+		if m.username == c.username {
+			m.party = nil
+			p.members[i] = c
+			c.party = p
+			return append([]*client(nil), p.members...), p.payload(), nil
+		}
+	}
+	if len(p.members) >= teamSize {
+		return nil, partyPayload{}, errPartyFull
+	}
+	p.members = append(p.members, c)
+	c.party = p
+	return append([]*client(nil), p.members...), p.payload(), nil
+}
+
+// PartyStatus はパーティーの現在の状況を返す
+func (s *matchState) PartyStatus(p *party) partyPayload {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return p.payload()
+}
+
+// LeaveParty はクライアントをパーティーから外す（パーティーに入っていなければfalse）
+func (s *matchState) LeaveParty(c *client) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if c.party == nil {
+		return false
+	}
+	s.leavePartyLocked(c)
+	return true
+}
+
+// leavePartyLocked はクライアントをパーティーから外し、残りのメンバーに知らせる（s.mu を保持して呼ぶ）
+// リーダーが抜けたらパーティーを解散し、キューに入っていればパーティーごと外す
+func (s *matchState) leavePartyLocked(c *client) {
+	p := c.party
+	if p == nil {
+		return
+	}
+	c.party = nil
+	if queued := s.teamQueue.Remove(c); queued != nil {
+		for _, m := range queued {
+			if m.id != c.id {
+				m.send(wsMessage{Type: "match:cancelled", Payload: mustJSON(queueEndedPayload{Mode: m.mode})})
+			}
+		}
+	}
+
+	if len(p.members) > 0 && p.members[0].id == c.id {
+		delete(s.parties, p.code)
+		for _, m := range p.members[1:] {
+			m.party = nil
+			m.send(wsMessage{Type: "party:closed", Payload: mustJSON(p.payload())})
+		}
+		return
+	}
+	remaining := make([]*client, 0, len(p.members))
+	for _, m := range p.members {
+		if m.id != c.id {
+			remaining = append(remaining, m)
+		}
+	}
+	p.members = remaining
+	for _, m := range p.members {
+		m.send(wsMessage{Type: "party:updated", Payload: mustJSON(p.payload())})
+	}
+}
+
+// JoinTeamQueue はクライアント（パーティーならメンバー全員）をチーム戦の待機キューに入れる
+// 成立したマッチがあればルームを作って返す
+func (s *matchState) JoinTeamQueue(c *client, mode string) ([]*client, []*room, error) {
+	s.mu.Lock()
+	members := []*client{c}
+	if c.party != nil {
+		if c.party.members[0].id != c.id {
+			s.mu.Unlock()
+			return nil, nil, errNotPartyLeader
+		}
+		members = append([]*client(nil), c.party.members...)
+	}
+	for _, m := range members {
+		if !s.isAvailableLocked(m) {
+			s.mu.Unlock()
+			return nil, nil, errAlreadyInRoom
+		}
+	}
+	s.mu.Unlock()
+
+	// レーティングの取得はDBを使うのでロックの外で行う
+	total := 0
+	for _, m := range members {
+		total += teamRatingOf(m, mode)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	// 取得中に状況が変わっていないか確かめ直す
+	for _, m := range members {
+		if !s.isAvailableLocked(m) || (len(members) > 1 && m.party != c.party) {
+			return nil, nil, errAlreadyInRoom
+		}
+	}
+	for _, m := range members {
+		m.mode = mode
+	}
+	var rooms []*room
+	for _, m := range s.teamQueue.Enqueue(members, mode, total/len(members)) {
+		rooms = append(rooms, s.newTeamRoomLocked(m))
+	}
+	return members, rooms, nil
+}
+
+// TeamQueueStatus はクライアントのチーム戦の待機状況を返す（待機中でなければfalse）
+func (s *matchState) TeamQueueStatus(c *client) (queuedPayload, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.teamQueue.Status(c)
+}
+
+// newTeamRoomLocked は成立したチーム戦のマッチからルームを作って登録する（s.mu を保持して呼ぶ）
+func (s *matchState) newTeamRoomLocked(m teamQueueMatch) *room {
+	r := &room{
+		id:         newRoomID(),
+		minPlayers: teamSize * len(teamNames),
+		maxPlayers: teamSize * len(teamNames),
+		mode:       m.mode,
+		rated:      true,
+		rules:      teamRulesFor(m.mode),
+		started:    true,
+		teams:      map[string]string{},
+		teamScores: map[string]int{},
+	}
+	for i, members := range m.teams {
+		for _, p := range members {
+			r.players = append(r.players, p)
+			r.teams[p.username] = teamNames[i]
+			p.roomID = r.id
+		}
+	}
+	s.rooms[r.id] = r
+	return r
+}
+
+// teamRatingOf はクライアントのチーム戦のレーティングを返す（取得できなければ初期値）
+func teamRatingOf(c *client, mode string) int {
+	if c.userID == 0 {
+		return defaultRating
+	}
+	mr, err := repositories.NewUserModeRatingRepository(db.DB).FindOrDefault(c.userID, models.TeamRatingMode(mode))
+	if err != nil || mr == nil {
+		return defaultRating
+	}
+	return mr.Rating
+}
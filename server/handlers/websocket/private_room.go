@@ -0,0 +1,300 @@
+package websocket
+
+import (
+	"encoding/json"
+	"errors"
+	"strings"
+)
+
+// プライベートルーム関連のエラー
+var (
+	errRoomNotFound   = errors.New("room not found")
+	errRoomFull       = errors.New("room is full")
+	errAlreadyInRoom  = errors.New("already in room")
+	errRoomStarted    = errors.New("room already started")
+	errNotRoomPlayer  = errors.New("not a player of this room")
+	errInvalidMode    = errors.New("invalid mode")
+	errInvalidRounds  = errors.New("invalid rounds")
+	errInvalidPlayers = errors.New("invalid player count")
+	errNotQueued      = errors.New("not in queue")
+)
+
+// isValidMode は対戦モードとして受け付ける値かどうかを返す
+func isValidMode(mode string) bool {
+	for _, m := range matchModes {
+		if m == mode {
+			return true
+		}
+	}
+	return false
+}
+
+// handleRoomCreate はプライベートルームの作成リクエストを処理する
+func handleRoomCreate(c *client, payload json.RawMessage) {
+	var req createRoomPayload
+	if err := json.Unmarshal(payload, &req); err != nil || strings.TrimSpace(req.Token) == "" {
+		sendError(c, codeInvalidPayload, "invalid room payload")
+		return
+	}
+	if !authenticateClient(c, req.Token) {
+		return
+	}
+
+	// モードを確定（デフォルトは text-major）
+	mode := strings.TrimSpace(req.Mode)
+	if mode == "" {
+		mode = "text-major"
+	}
+	if !isValidMode(mode) {
+		sendErrorFor(c, errInvalidMode)
+		return
+	}
+	useModeRating(c, mode)
+	rated := true
+	if req.Rated != nil {
+		rated = *req.Rated
+	}
+
+	// サーバー設定のルールに、ホストが指定した項目を重ねる（従来の rounds も受け付ける）
+	if req.Rounds < 0 {
+		sendErrorFor(c, errInvalidRounds)
+		return
+	}
+	override := matchRulesOverride{}
+	if req.Rules != nil {
+		override = *req.Rules
+	}
+	if override.Rounds == nil && req.Rounds > 0 {
+		override.Rounds = &req.Rounds
+	}
+	rules, err := override.apply(rulesFor(mode, rated))
+	if err != nil {
+		sendErrorFor(c, err)
+		return
+	}
+
+	// 人数の範囲を確定（未指定は2人対戦、最大人数だけ指定されたら最少人数は2人）
+	minPlayers := req.MinPlayers
+	if minPlayers == 0 {
+		minPlayers = minRoomPlayers
+	}
+	maxPlayers := req.MaxPlayers
+	if maxPlayers == 0 {
+		maxPlayers = minPlayers
+	}
+
+	r, err := state.CreatePrivateRoom(c, mode, rules, rated, minPlayers, maxPlayers)
+	if err != nil {
+		sendErrorFor(c, err)
+		return
+	}
+	c.send(wsMessage{Type: "room:created", Payload: mustJSON(state.Lobby(r))})
+}
+
+// handleRoomJoin は招待コードでのプライベートルーム参加を処理する
+func handleRoomJoin(c *client, payload json.RawMessage) {
+	var req joinRoomPayload
+	if err := json.Unmarshal(payload, &req); err != nil || strings.TrimSpace(req.Token) == "" || strings.TrimSpace(req.Code) == "" {
+		sendError(c, codeInvalidPayload, "invalid room payload")
+		return
+	}
+	if !authenticateClient(c, req.Token) {
+		return
+	}
+
+	r, err := state.JoinPrivateRoom(c, req.Code)
+	if err != nil {
+		sendErrorFor(c, err)
+		return
+	}
+	useModeRating(c, r.mode)
+	broadcast(r, wsMessage{Type: "room:updated", Payload: mustJSON(state.Lobby(r))})
+}
+
+// handleRoomReady は準備完了の切り替えを処理し、全員揃えばマッチを開始する
+func handleRoomReady(c *client, payload json.RawMessage) {
+	var req readyPayload
+	if err := json.Unmarshal(payload, &req); err != nil || req.RoomID == "" {
+		sendError(c, codeInvalidPayload, "invalid ready payload")
+		return
+	}
+
+	r, start, err := state.SetReady(c, req.RoomID, req.Ready)
+	if err != nil {
+		sendErrorFor(c, err)
+		return
+	}
+	broadcast(r, wsMessage{Type: "room:updated", Payload: mustJSON(state.Lobby(r))})
+
+	if start {
+		startMatch(r)
+	}
+}
+
+// CreatePrivateRoom は招待コード付きのルームを作り、作成者をホストとして座らせる
+// rules は rulesFor で決めたルールにホストの指定を重ねたもの
+func (s *matchState) CreatePrivateRoom(host *client, mode string, rules MatchRules, rated bool, minPlayers, maxPlayers int) (*room, error) {
+	if !isValidMode(mode) {
+		return nil, errInvalidMode
+	}
+	if err := rules.validate(); err != nil {
+		return nil, err
+	}
+	if minPlayers < minRoomPlayers || maxPlayers > maxRoomPlayers || minPlayers > maxPlayers {
+		return nil, errInvalidPlayers
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if host.roomID != "" || s.inQueueLocked(host) {
+		return nil, errAlreadyInRoom
+	}
+
+	// 既存のコードと衝突しないまで生成し直す
+	code := newInviteCode()
+	for s.codes[code] != nil {
+		code = newInviteCode()
+	}
+
+	r := &room{
+		id:         newRoomID(),
+		players:    []*client{host},
+		minPlayers: minPlayers,
+		maxPlayers: maxPlayers,
+		mode:       mode,
+		rules:      rules,
+		rated:      rated,
+		code:       code,
+		ready:      map[string]bool{},
+	}
+	host.roomID = r.id
+	host.mode = mode
+	s.rooms[r.id] = r
+	s.codes[code] = r
+	return r, nil
+}
+
+// JoinPrivateRoom は招待コードに対応するルームの空き席に参加する
+func (s *matchState) JoinPrivateRoom(c *client, code string) (*room, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if c.roomID != "" || s.inQueueLocked(c) {
+		return nil, errAlreadyInRoom
+	}
+	r := s.codes[strings.ToUpper(strings.TrimSpace(code))]
+	if r == nil {
+		return nil, errRoomNotFound
+	}
+	if r.started {
+		return nil, errRoomStarted
+	}
+	if len(r.players) >= r.maxPlayers {
+		return nil, errRoomFull
+	}
+	// 同じユーザーが別の接続で二重に入るのは不可
+	for _, p := range r.players {
+		if p.username == c.username {
+			return nil, errAlreadyInRoom
+		}
+	}
+
+	r.players = append(r.players, c)
+	c.roomID = r.id
+	c.mode = r.mode
+	return r, nil
+}
+
+// SetReady は準備完了状態を更新し、最少人数が揃って全員準備完了ならマッチ開始可能としてtrueを返す
+func (s *matchState) SetReady(c *client, roomID string, ready bool) (*room, bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	r := s.rooms[roomID]
+	if r == nil || r.code == "" {
+		return nil, false, errRoomNotFound
+	}
+	if r.started {
+		return nil, false, errRoomStarted
+	}
+	if c.roomID != r.id {
+		return nil, false, errNotRoomPlayer
+	}
+
+	r.ready[c.id] = ready
+	if len(r.players) < r.minPlayers {
+		return r, false, nil
+	}
+	for _, p := range r.players {
+		if !r.ready[p.id] {
+			return r, false, nil
+		}
+	}
+	r.started = true
+	return r, true, nil
+}
+
+// Lobby はプライベートルームの現在の待機状況を返す
+func (s *matchState) Lobby(r *room) lobbyPayload {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return lobbyLocked(r)
+}
+
+// leaveLobbyLocked は開始前のプライベートルームからクライアントを外す（s.mu を保持して呼ぶ）
+// ホストが抜けた場合はルームを閉じ、ゲストが抜けた場合は席を空けて残りの参加者に知らせる
+func (s *matchState) leaveLobbyLocked(r *room, c *client) {
+	c.roomID = ""
+	delete(r.ready, c.id)
+
+	if len(r.players) > 0 && r.players[0].id == c.id {
+		delete(s.rooms, r.id)
+		delete(s.codes, r.code)
+		for _, guest := range r.players[1:] {
+			guest.roomID = ""
+			guest.send(wsMessage{Type: "room:closed", Payload: mustJSON(lobbyLocked(r))})
+		}
+		return
+	}
+
+	remaining := make([]*client, 0, len(r.players))
+	for _, p := range r.players {
+		if p.id != c.id {
+			remaining = append(remaining, p)
+		}
+	}
+	r.players = remaining
+	for _, p := range r.players {
+		p.send(wsMessage{Type: "room:updated", Payload: mustJSON(lobbyLocked(r))})
+	}
+}
+
+// lobbyLocked はルームの待機状況を組み立てる（s.mu を保持して呼ぶ）
+func lobbyLocked(r *room) lobbyPayload {
+	payload := lobbyPayload{
+		RoomID:     r.id,
+		Code:       r.code,
+		Mode:       r.mode,
+		Rounds:     r.rules.Rounds,
+		Rated:      r.rated,
+		MinPlayers: r.minPlayers,
+		MaxPlayers: r.maxPlayers,
+		Players:    make([]lobbyPlayer, 0, len(r.players)),
+		Rules:      r.rules.payload(),
+	}
+	if len(r.players) > 0 {
+		payload.Host = r.players[0].username
+	}
+	for _, p := range r.players {
+		if p == nil {
+			continue
+		}
+		payload.Players = append(payload.Players, lobbyPlayer{
+			Username: p.username,
+			ImageURL: p.imageURL,
+			Ready:    r.ready[p.id],
+		})
+	}
+	return payload
+}
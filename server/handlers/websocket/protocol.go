@@ -0,0 +1,112 @@
+package websocket
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+
+	"example.com/mathkun-tmp-/server/services"
+)
+
+// プロトコルのバージョン
+// メッセージの形を互換性のない形で変えたら protocolVersion を上げ、古いクライアントを切る場合は minProtocolVersion も上げる
+const (
+	protocolVersion    = 1
+	minProtocolVersion = 1
+)
+
+// errorCode は error メッセージに付ける機械判定用のコード（message は表示・ログ用で変わることがある）
+type errorCode string
+
+// エラーコード一覧
+const (
+	codeInvalidPayload     errorCode = "invalid_payload"      // ペイロードが読めない・必須項目がない
+	codeUnknownEvent       errorCode = "unknown_event"        // 未対応のメッセージ種別
+	codeUnsupportedVersion errorCode = "unsupported_protocol" // 対応していないプロトコルバージョン
+	codeUnauthorized       errorCode = "unauthorized"         // トークンが無効
+	codeAlreadyInRoom      errorCode = "already_in_room"      // 既に対戦・待機・観戦中
+	codeInvalidMode        errorCode = "invalid_mode"         // 存在しない対戦モード
+	codeInvalidRounds      errorCode = "invalid_rounds"       // 指定できないラウンド数
+	codeInvalidPlayers     errorCode = "invalid_players"      // 指定できない参加人数
+	codeInvalidRules       errorCode = "invalid_rules"        // 指定できない試合のルール
+	codeNotQueued          errorCode = "not_queued"           // 待機キューに入っていない
+	codeRoomNotFound       errorCode = "room_not_found"       // ルームがない・既に終了した
+	codeRoomFull           errorCode = "room_full"            // ルームが満員
+	codeRoomStarted        errorCode = "room_started"         // ルームが既に開始している
+	codeNotRoomPlayer      errorCode = "not_room_player"      // そのルームの参加者ではない
+	codeNoHeldSeat         errorCode = "no_match_to_resume"   // 復帰できる試合がない
+	codeNoRematch          errorCode = "no_rematch"           // 再戦の受付がない・締め切られた
+	codeInvalidAnswer      errorCode = "invalid_answer"       // 出題した選択肢以外の回答
+	codeTournamentNotFound errorCode = "tournament_not_found" // 大会がない
+	codeNoTournamentMatch  errorCode = "no_tournament_match"  // 大会で今戦う対戦がない（未登録・敗退・決着済み）
+	codeRoundNotStarted    errorCode = "round_not_started"    // 次のラウンドの開始時刻前
+	codePartyNotFound      errorCode = "party_not_found"      // 招待コードのパーティーがない
+	codePartyFull          errorCode = "party_full"           // パーティーが満員
+	codeAlreadyInParty     errorCode = "already_in_party"     // 既に別のパーティーにいる
+	codeNotInParty         errorCode = "not_in_party"         // パーティーに入っていない
+	codeNotPartyLeader     errorCode = "not_party_leader"     // パーティーのリーダーしかできない操作
+	codeEliminated         errorCode = "eliminated"           // バトルロイヤルで脱落済み
+	codeInternal           errorCode = "internal_error"       // サーバー側の想定外のエラー
+)
+
+// knownErrorCodes はスキーマに載せるエラーコードの一覧
+var knownErrorCodes = []errorCode{
+	codeInvalidPayload, codeUnknownEvent, codeUnsupportedVersion, codeUnauthorized,
+	codeAlreadyInRoom, codeInvalidMode, codeInvalidRounds, codeInvalidPlayers, codeInvalidRules,
+	codeNotQueued, codeRoomNotFound, codeRoomFull, codeRoomStarted,
+	codeNotRoomPlayer, codeNoHeldSeat, codeNoRematch, codeInvalidAnswer,
+	codeTournamentNotFound, codeNoTournamentMatch, codeRoundNotStarted,
+	codePartyNotFound, codePartyFull, codeAlreadyInParty, codeNotInParty, codeNotPartyLeader,
+	codeEliminated, codeInternal,
+}
+
+// errorCodes は状態操作が返すエラーと送信するコードの対応
+var errorCodes = map[error]errorCode{
+	errRoomNotFound:   codeRoomNotFound,
+	errRoomFull:       codeRoomFull,
+	errAlreadyInRoom:  codeAlreadyInRoom,
+	errRoomStarted:    codeRoomStarted,
+	errNotRoomPlayer:  codeNotRoomPlayer,
+	errInvalidMode:    codeInvalidMode,
+	errInvalidRounds:  codeInvalidRounds,
+	errInvalidPlayers: codeInvalidPlayers,
+	errInvalidRules:   codeInvalidRules,
+	errNotQueued:      codeNotQueued,
+	errNoHeldSeat:     codeNoHeldSeat,
+	errNoRematch:      codeNoRematch,
+	errPartyNotFound:  codePartyNotFound,
+	errPartyFull:      codePartyFull,
+	errAlreadyInParty: codeAlreadyInParty,
+	errNotInParty:     codeNotInParty,
+	errNotPartyLeader: codeNotPartyLeader,
+	errEliminated:     codeEliminated,
+
+	services.ErrUserNotFound:       codeUnauthorized,
+	services.ErrTournamentNotFound: codeTournamentNotFound,
+	services.ErrNoTournamentMatch:  codeNoTournamentMatch,
+	services.ErrRoundNotStarted:    codeRoundNotStarted,
+}
+
+// errorCodeFor はエラーに対応するコードを返す（一覧にないエラーは internal_error）
+func errorCodeFor(err error) errorCode {
+	for target, code := range errorCodes {
+		if errors.Is(err, target) {
+			return code
+		}
+	}
+	return codeInternal
+}
+
+// negotiateProtocol はクライアントが要求したバージョン（/ws?protocol=N、省略時は最新）を確認する
+// 対応範囲外ならfalseを返す
+func negotiateProtocol(requested string) (int, bool) {
+	requested = strings.TrimSpace(requested)
+	if requested == "" {
+		return protocolVersion, true
+	}
+	v, err := strconv.Atoi(requested)
+	if err != nil || v < minProtocolVersion || v > protocolVersion {
+		return 0, false
+	}
+	return v, true
+}
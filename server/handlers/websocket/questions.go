@@ -2,16 +2,27 @@ package websocket
 
 import (
 	"errors"
+	"math/rand"
 	"strings"
 
 	"example.com/mathkun-tmp-/server/db"
 	"example.com/mathkun-tmp-/server/services"
 )
 
-// fetchAudioQuestions はマッチ用にランダムな音声問題を取得する
-func fetchAudioQuestions(count int, mode string) ([]matchQuestion, error) {
+// fetchMatchQuestions はモードに合った問題を取得する（音声モードは音声問題、それ以外はテキスト問題）
+func fetchMatchQuestions(count int, mode string, choiceCount int) ([]matchQuestion, error) {
+	if strings.HasPrefix(mode, "audio-") {
+		return fetchAudioQuestions(count, mode, choiceCount)
+	}
+	return fetchFallbackQuestions(count, mode, choiceCount)
+}
+
+// fetchAudioQuestions はマッチ用にランダムな音声問題を取得する（選択肢は choiceCount 個）
+func fetchAudioQuestions(count int, mode string, choiceCount int) ([]matchQuestion, error) {
 	questionSvc := services.NewQuestionService(db.DB)
-	dtos, err := questionSvc.GetMatchAudioQuestions(count, mode, buildAudioChoicesWithMode)
+	dtos, err := questionSvc.GetMatchAudioQuestions(count, mode, func(correct string, rng *rand.Rand, pool string) []string {
+		return buildAudioChoicesWithMode(correct, rng, pool, choiceCount)
+	})
 	if err != nil {
 		return nil, err
 	}
@@ -31,15 +42,17 @@ func fetchAudioQuestions(count int, mode string) ([]matchQuestion, error) {
 	return questions, nil
 }
 
-// fetchFallbackQuestions はマッチ用にランダムなテキスト問題を取得する
-func fetchFallbackQuestions(count int, mode string) ([]matchQuestion, error) {
+// fetchFallbackQuestions はマッチ用にランダムなテキスト問題を取得する（選択肢は choiceCount 個）
+func fetchFallbackQuestions(count int, mode string, choiceCount int) ([]matchQuestion, error) {
 	modeKey := strings.TrimSpace(mode)
 	if modeKey == "" {
 		modeKey = "text-major"
 	}
 
 	questionSvc := services.NewQuestionService(db.DB)
-	dtos, err := questionSvc.GetMatchTextQuestions(count, modeKey, buildChoices)
+	dtos, err := questionSvc.GetMatchTextQuestions(count, modeKey, func(correct string, rng *rand.Rand) []string {
+		return buildChoices(correct, rng, choiceCount)
+	})
 	if err != nil {
 		return nil, err
 	}
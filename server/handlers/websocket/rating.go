@@ -3,108 +3,229 @@ package websocket
 import (
 	"errors"
 	"math"
+	"os"
+	"strings"
+	"time"
 
-	"example.com/mathkun-tmp-/server/db"
+	"example.com/mathkun-tmp-/server/models"
 	"example.com/mathkun-tmp-/server/repositories"
 	"gorm.io/gorm"
 )
 
-// eloKFactor はEloレーティング計算のK因子
-// 32はチェスで一般的な値で、レーティング変動をほどよい幅に保つ
-const eloKFactor = 32.0
-
 // ratingResult はレーティング更新結果を保持する構造体
 type ratingResult struct {
 	Ratings map[string]int // 更新後のレーティング（username → 新レーティング）
 	Deltas  map[string]int // レーティング変動量（username → 増減値）
+	MatchID uint           // 保存した対戦履歴のID（保存に失敗した場合は0）
+}
+
+// ratingSnapshot はレーティング計算に使う1人分の状態
+// Elo は Rating だけを動かし、Glicko-2 は Deviation と Volatility も更新する
+type ratingSnapshot struct {
+	Rating     float64    // レーティング
+	Deviation  float64    // レーティングの不確かさ（RD、大きいほど実力が定まっていない）
+	Volatility float64    // 実力の変動しやすさ（Glicko-2 の σ）
+	RatedAt    *time.Time // 最後にレーティングが更新された日時（未対戦ならnil）
+}
+
+// ratingEngine はマッチの最終順位からレーティングを計算する方式
+// players と standings は同じ並びで渡され、戻り値も同じ並びで返す
+type ratingEngine interface {
+	Rate(players []ratingSnapshot, standings []standingItem, now time.Time) []ratingSnapshot
+}
+
+// ratingEngineFromEnv は環境変数 RATING_SYSTEM で指定されたレーティング方式を返す
+// "glicko2" で Glicko-2、それ以外（未設定を含む）は従来の Elo を使う
+// .env の読み込み後に参照できるよう、パッケージ初期化時ではなく呼び出し時に読む
+func ratingEngineFromEnv() ratingEngine {
+	return newRatingEngine(os.Getenv("RATING_SYSTEM"))
+}
+
+// weightedRatingEngineFromEnv は RATING_SYSTEM の方式で、1試合を weight 試合分として数えるものを返す
+// バトルロイヤルのように大人数と同時に対戦する形式で、1試合で動く幅を決めるのに使う
+func weightedRatingEngineFromEnv(weight float64) ratingEngine {
+	return newWeightedRatingEngine(os.Getenv("RATING_SYSTEM"), weight)
+}
+
+// newRatingEngine は名前に対応するレーティング方式を返す
+func newRatingEngine(name string) ratingEngine {
+	switch strings.ToLower(strings.TrimSpace(name)) {
+	case "glicko2", "glicko-2":
+		return glicko2Engine{Tau: glickoTau}
+	default:
+		return eloEngine{KFactor: eloKFactor}
+	}
+}
+
+// newWeightedRatingEngine は名前に対応するレーティング方式を、1試合の重みを weight にして返す
+// Elo は K因子を weight 倍し、Glicko-2 は weight を相手の人数で按分した重みで各相手との結果を数える
+func newWeightedRatingEngine(name string, weight float64) ratingEngine {
+	switch strings.ToLower(strings.TrimSpace(name)) {
+	case "glicko2", "glicko-2":
+		return glicko2Engine{Tau: glickoTau, MatchWeight: weight}
+	default:
+		return eloEngine{KFactor: eloKFactor * weight, MatchWeight: weight}
+	}
 }
 
-// applyEloForMatch はマッチ結果に基づいてEloレーティングを更新する
-// 勝者のレーティングが上がり、敗者のレーティングが下がる
-// レーティング差が大きいほど変動幅も小さくなる（強者が弱者に勝っても少ししか上がらない）
-func applyEloForMatch(r *room, winner string) (ratingResult, error) {
+// applyRatingForMatch はマッチの最終順位に基づいてレーティングを更新する
+// 計算方式は ratingEngineFromEnv で選ばれたものを使う
+// 全モード共通のレーティングと、対戦したモードのモード別レーティングをそれぞれ計算し、
+// 結果として返すのはモード別の値（ランキングやマッチングに使う方）
+// 同順位のペアは引き分け（0.5対0.5）として扱うので、1位が同点でもレーティングは動く
+// bots（ユーザー名 → 固定レーティング）に含まれる参加者は bot として計算にだけ使い、更新しない
+// 対戦履歴の保存と同じトランザクションで実行するため、呼び出し側から tx を受け取る
+func applyRatingForMatch(tx *gorm.DB, mode string, standings []standingItem, bots map[string]int) (ratingResult, error) {
 	// 結果を格納する構造体を初期化
 	result := ratingResult{
 		Ratings: map[string]int{},
 		Deltas:  map[string]int{},
 	}
-	// ルームまたは勝者情報が無効な場合は何もしない
-	if r == nil || winner == "" {
-		return result, nil
-	}
-	// プレイヤーが2人揃っていない場合も何もしない
-	if r.players[0] == nil || r.players[1] == nil {
+	// 参加者が2人未満なら何もしない
+	if len(standings) < 2 {
 		return result, nil
 	}
-	// 両プレイヤーのユーザー名を取得
-	userA := r.players[0].username
-	userB := r.players[1].username
-	// どちらかのユーザー名が空なら処理しない
-	if userA == "" || userB == "" {
-		return result, nil
+	// どれかのユーザー名が空なら処理しない
+	for _, s := range standings {
+		if s.Username == "" {
+			return result, nil
+		}
 	}
 
-	// DB更新をトランザクション内で実行（途中エラー時は全てロールバック）
-	err := db.DB.Transaction(func(tx *gorm.DB) error {
-		// リポジトリを作成してユーザー情報を取得
-		repo := repositories.NewUserRepository(tx)
-		uA, err := repo.FindByUsername(userA)
-		if err != nil || uA == nil {
-			return err
+	// リポジトリを作成して全参加者のユーザー情報とモード別レーティングを取得
+	repo := repositories.NewUserRepository(tx)
+	modeRepo := repositories.NewUserModeRatingRepository(tx)
+	users := make([]*models.User, len(standings))
+	modeRatings := make([]*models.UserModeRating, len(standings))
+	overallBefore := make([]ratingSnapshot, len(standings))
+	modeBefore := make([]ratingSnapshot, len(standings))
+	for i, s := range standings {
+		if rating, ok := bots[s.Username]; ok {
+			overallBefore[i] = botRatingSnapshot(rating)
+			modeBefore[i] = botRatingSnapshot(rating)
+			continue
+		}
+		u, err := repo.FindByUsername(s.Username)
+		if err != nil {
+			return result, err
 		}
-		uB, err := repo.FindByUsername(userB)
-		if err != nil || uB == nil {
-			return err
+		if u == nil {
+			return result, errors.New("user not found")
 		}
+		mr, err := modeRepo.FindOrDefault(u.ID, mode)
+		if err != nil {
+			return result, err
+		}
+		users[i] = u
+		modeRatings[i] = mr
+		overallBefore[i] = ratingSnapshot{Rating: float64(u.Rating), Deviation: u.RatingDeviation, Volatility: u.RatingVolatility, RatedAt: u.RatedAt}
+		modeBefore[i] = ratingSnapshot{Rating: float64(mr.Rating), Deviation: mr.RatingDeviation, Volatility: mr.RatingVolatility, RatedAt: mr.RatedAt}
+	}
 
-		// 現在のレーティングを取得（計算用にfloat64に変換）
-		ra := float64(uA.Rating)
-		rb := float64(uB.Rating)
-		// Eloの期待勝率を計算
-		// ea: プレイヤーAの期待勝率（0.0〜1.0）
-		// 例: Aが1600、Bが1400なら ea ≈ 0.76（Aが76%の確率で勝つ）
-		ea := 1.0 / (1.0 + math.Pow(10, (rb-ra)/400.0))
-		eb := 1.0 - ea // Bの期待勝率（1 - ea）
-
-		// 実際の結果スコアを設定
-		var sa, sb float64
-		if winner == userA {
-			// Aが勝った場合: sa=1（勝利）, sb=0（敗北）
-			sa, sb = 1, 0
-			uA.Wins++   // Aの勝利数をインクリメント
-			uB.Losses++ // Bの敗北数をインクリメント
-		} else if winner == userB {
-			// Bが勝った場合: sa=0（敗北）, sb=1（勝利）
-			sa, sb = 0, 1
-			uB.Wins++   // Bの勝利数をインクリメント
-			uA.Losses++ // Aの敗北数をインクリメント
-		} else {
-			// 勝者が見つからない場合はエラー
-			return errors.New("winner not found")
+	now := time.Now()
+	engine := ratingEngineFromEnv()
+	overallAfter := engine.Rate(overallBefore, standings, now)
+	modeAfter := engine.Rate(modeBefore, standings, now)
+
+	// 単独1位を勝利、同点の1位を引き分け、それ以外を敗北として数える
+	for i := range standings {
+		u := users[i]
+		mr := modeRatings[i]
+		if u == nil {
+			continue // bot
+		}
+		switch matchOutcome(standings, u.Username) {
+		case outcomeVictory:
+			u.Wins++
+			mr.Wins++
+		case outcomeDraw:
+			u.Draws++
+			mr.Draws++
+		default:
+			u.Losses++
+			mr.Losses++
 		}
 
-		// Eloレーティングの更新式: 新レーティング = 旧レーティング + K因子 × (実際の結果 - 期待勝率)
-		// 例: Aが期待通り勝った（ea=0.76, sa=1）なら +32×(1-0.76) = +7.68点
-		// 例: Aが番狂わせで勝った（ea=0.24, sa=1）なら +32×(1-0.24) = +24.32点
-		newRa := int(math.Round(ra + eloKFactor*(sa-ea)))
-		newRb := int(math.Round(rb + eloKFactor*(sb-eb)))
-		// 結果構造体に新レーティングを格納
-		result.Ratings[userA] = newRa
-		result.Ratings[userB] = newRb
-		// レーティング変動量も計算して格納（+20, -15 など）
-		result.Deltas[userA] = newRa - uA.Rating
-		result.Deltas[userB] = newRb - uB.Rating
-		// DBに新レーティングと勝敗数を書き込む
-		if err := repo.UpdateRatingByUsername(userA, newRa, uA.Wins, uA.Losses); err != nil {
-			return err
+		// 全モード共通のレーティングを書き込む
+		u.Rating = int(math.Round(overallAfter[i].Rating))
+		u.RatingDeviation = overallAfter[i].Deviation
+		u.RatingVolatility = overallAfter[i].Volatility
+		u.RatedAt = &now
+		if err := repo.UpdateRatingStats(u); err != nil {
+			return result, err
 		}
-		if err := repo.UpdateRatingByUsername(userB, newRb, uB.Wins, uB.Losses); err != nil {
-			return err
+
+		// モード別のレーティングを書き込み、結果として返す（+20, -15 など）
+		newRating := int(math.Round(modeAfter[i].Rating))
+		result.Ratings[u.Username] = newRating
+		result.Deltas[u.Username] = newRating - mr.Rating
+		mr.Rating = newRating
+		mr.RatingDeviation = modeAfter[i].Deviation
+		mr.RatingVolatility = modeAfter[i].Volatility
+		mr.RatedAt = &now
+		if err := modeRepo.Save(mr); err != nil {
+			return result, err
 		}
-		return nil
-	})
-	if err != nil {
-		return result, err
 	}
 	return result, nil
 }
+
+// botRatingSnapshot は bot の固定レーティングを計算用の状態にする
+// 強さが変わらないので、RDは低い値に固定して対戦相手としての重みを持たせる
+func botRatingSnapshot(rating int) ratingSnapshot {
+	return ratingSnapshot{
+		Rating:     float64(rating),
+		Deviation:  botRatingDeviation,
+		Volatility: glickoDefaultVolatility,
+	}
+}
+
+// botRatingDeviation は bot の固定レーティングのRD
+const botRatingDeviation = 50.0
+
+// マッチ結果の区分（match:finished の status として各プレイヤーに送る）
+const (
+	outcomeVictory = "victory"
+	outcomeDefeat  = "defeat"
+	outcomeDraw    = "draw"
+)
+
+// matchOutcome は最終順位から指定ユーザーの結果を返す
+// 単独1位なら勝利、没収負けでない1位が複数いればその全員が引き分け、それ以外は敗北
+// チーム戦では同じチームのメンバーが同じ1位にいても単独1位として扱う
+func matchOutcome(standings []standingItem, username string) string {
+	if len(standings) == 0 {
+		return outcomeDraw
+	}
+	tiedTop := false
+	for _, s := range standings[1:] {
+		if s.Rank == standings[0].Rank && !sameSide(s, standings[0]) {
+			tiedTop = true
+		}
+	}
+	for _, s := range standings {
+		if s.Username != username {
+			continue
+		}
+		if s.Rank != standings[0].Rank || s.Forfeited {
+			return outcomeDefeat
+		}
+		if tiedTop {
+			return outcomeDraw
+		}
+		return outcomeVictory
+	}
+	return outcomeDefeat
+}
+
+// pairScore は2人の順位を比べた実際の結果スコアを返す（勝ち1、引き分け0.5、負け0）
+func pairScore(a, b standingItem) float64 {
+	switch {
+	case a.Rank < b.Rank:
+		return 1
+	case a.Rank > b.Rank:
+		return 0
+	default:
+		return 0.5
+	}
+}
@@ -0,0 +1,225 @@
+package websocket
+
+import (
+	"encoding/json"
+	"math"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestMatchOutcome(t *testing.T) {
+	cases := []struct {
+		name      string
+		standings []standingItem
+		want      map[string]string
+	}{
+		{
+			name:      "single winner",
+			standings: []standingItem{{Rank: 1, Username: "a", Score: 300}, {Rank: 2, Username: "b", Score: 100}},
+			want:      map[string]string{"a": outcomeVictory, "b": outcomeDefeat},
+		},
+		{
+			name:      "tie",
+			standings: []standingItem{{Rank: 1, Username: "a", Score: 200}, {Rank: 1, Username: "b", Score: 200}},
+			want:      map[string]string{"a": outcomeDraw, "b": outcomeDraw},
+		},
+		{
+			name:      "shared first of three",
+			standings: []standingItem{{Rank: 1, Username: "a"}, {Rank: 1, Username: "b"}, {Rank: 3, Username: "c"}},
+			want:      map[string]string{"a": outcomeDraw, "b": outcomeDraw, "c": outcomeDefeat},
+		},
+		{
+			name:      "opponent forfeited",
+			standings: []standingItem{{Rank: 1, Username: "a"}, {Rank: 2, Username: "b", Forfeited: true}},
+			want:      map[string]string{"a": outcomeVictory, "b": outcomeDefeat},
+		},
+		{
+			name:      "both forfeited",
+			standings: []standingItem{{Rank: 1, Username: "a", Forfeited: true}, {Rank: 1, Username: "b", Forfeited: true}},
+			want:      map[string]string{"a": outcomeDefeat, "b": outcomeDefeat},
+		},
+		{
+			name: "team win",
+			standings: []standingItem{
+				{Rank: 1, Username: "a", Team: teamRed}, {Rank: 1, Username: "b", Team: teamRed},
+				{Rank: 2, Username: "c", Team: teamBlue}, {Rank: 2, Username: "d", Team: teamBlue},
+			},
+			want: map[string]string{"a": outcomeVictory, "b": outcomeVictory, "c": outcomeDefeat, "d": outcomeDefeat},
+		},
+		{
+			name: "team tie",
+			standings: []standingItem{
+				{Rank: 1, Username: "a", Team: teamRed}, {Rank: 1, Username: "b", Team: teamRed},
+				{Rank: 1, Username: "c", Team: teamBlue}, {Rank: 1, Username: "d", Team: teamBlue},
+			},
+			want: map[string]string{"a": outcomeDraw, "b": outcomeDraw, "c": outcomeDraw, "d": outcomeDraw},
+		},
+		{
+			name: "forfeited member of the winning team",
+			standings: []standingItem{
+				{Rank: 1, Username: "a", Team: teamRed}, {Rank: 1, Username: "b", Team: teamRed, Forfeited: true},
+				{Rank: 2, Username: "c", Team: teamBlue}, {Rank: 2, Username: "d", Team: teamBlue},
+			},
+			want: map[string]string{"a": outcomeVictory, "b": outcomeDefeat, "c": outcomeDefeat, "d": outcomeDefeat},
+		},
+		{
+			name:      "not a participant",
+			standings: []standingItem{{Rank: 1, Username: "a"}, {Rank: 2, Username: "b"}},
+			want:      map[string]string{"z": outcomeDefeat},
+		},
+	}
+	for _, tc := range cases {
+		for username, want := range tc.want {
+			if got := matchOutcome(tc.standings, username); got != want {
+				t.Errorf("%s: %s got %q, want %q", tc.name, username, got, want)
+			}
+		}
+	}
+}
+
+// scoredRoom は参加者と得点（参加順）を持つルームを作る
+func scoredRoom(scores []int, usernames ...string) *room {
+	r := &room{scores: map[string]int{}, forfeited: map[string]bool{}}
+	for i, name := range usernames {
+		c := newQueueClient(name, defaultRating)
+		r.players = append(r.players, c)
+		r.scores[c.id] = scores[i]
+	}
+	return r
+}
+
+func TestWinnerName(t *testing.T) {
+	cases := []struct {
+		name  string
+		build func() *room
+		want  string
+	}{
+		{"higher score wins", func() *room { return scoredRoom([]int{100, 300}, "a", "b") }, "b"},
+		{"tie has no winner", func() *room { return scoredRoom([]int{200, 200}, "a", "b") }, ""},
+		{"tie for first of three", func() *room { return scoredRoom([]int{200, 200, 300}, "a", "b", "c") }, "c"},
+		{"shared first of three", func() *room { return scoredRoom([]int{300, 300, 100}, "a", "b", "c") }, ""},
+		{"alone", func() *room { return scoredRoom([]int{100}, "a") }, ""},
+		{"forfeit loses despite score", func() *room {
+			r := scoredRoom([]int{500, 100}, "a", "b")
+			r.forfeited["a"] = true
+			return r
+		}, "b"},
+		{"team with the higher score", func() *room {
+			r := scoredRoom([]int{100, 50, 80, 60}, "a", "b", "c", "d")
+			r.teams = map[string]string{"a": teamRed, "b": teamRed, "c": teamBlue, "d": teamBlue}
+			r.teamScores = map[string]int{teamRed: 150, teamBlue: 140}
+			return r
+		}, teamRed},
+		{"team tie", func() *room {
+			r := scoredRoom([]int{100, 50, 80, 70}, "a", "b", "c", "d")
+			r.teams = map[string]string{"a": teamRed, "b": teamRed, "c": teamBlue, "d": teamBlue}
+			r.teamScores = map[string]int{teamRed: 150, teamBlue: 150}
+			return r
+		}, ""},
+		{"team fully forfeited", func() *room {
+			r := scoredRoom([]int{100, 50, 0, 0}, "a", "b", "c", "d")
+			r.teams = map[string]string{"a": teamRed, "b": teamRed, "c": teamBlue, "d": teamBlue}
+			r.teamScores = map[string]int{teamRed: 150, teamBlue: 0}
+			r.forfeited["a"], r.forfeited["b"] = true, true
+			return r
+		}, teamBlue},
+	}
+	for _, tc := range cases {
+		if got := tc.build().winnerName(); got != tc.want {
+			t.Errorf("%s: winner %q, want %q", tc.name, got, tc.want)
+		}
+	}
+}
+
+func TestDrawGivesEqualAndOppositeDeltas(t *testing.T) {
+	tied := []standingItem{{Rank: 1, Username: "a"}, {Rank: 1, Username: "b"}}
+	engines := map[string]ratingEngine{"elo": eloEngine{KFactor: eloKFactor}, "glicko2": glicko2Engine{Tau: glickoTau}}
+	for name, engine := range engines {
+		// 同じレーティング同士の引き分けでは動かない
+		even := engine.Rate([]ratingSnapshot{
+			{Rating: 1500, Deviation: 100, Volatility: 0.06},
+			{Rating: 1500, Deviation: 100, Volatility: 0.06},
+		}, tied, time.Now())
+		if math.Abs(even[0].Rating-1500) > 1e-9 || math.Abs(even[1].Rating-1500) > 1e-9 {
+			t.Errorf("%s: even draw moved ratings to %.2f / %.2f", name, even[0].Rating, even[1].Rating)
+		}
+
+		// レーティングに差があれば、低い方が上がり、高い方が同じだけ下がる
+		uneven := engine.Rate([]ratingSnapshot{
+			{Rating: 1600, Deviation: 100, Volatility: 0.06},
+			{Rating: 1400, Deviation: 100, Volatility: 0.06},
+		}, tied, time.Now())
+		high, low := uneven[0].Rating-1600, uneven[1].Rating-1400
+		if high >= 0 || low <= 0 || math.Abs(high+low) > 1e-6 {
+			t.Errorf("%s: draw deltas %+.2f / %+.2f should be equal and opposite", name, high, low)
+		}
+	}
+}
+
+// finishedStatuses はシミュレーションした試合で各プレイヤーに届いた match:finished の status を返す
+func finishedStatuses(t *testing.T, aIdle, bIdle bool) map[string]string {
+	t.Helper()
+	useSimulatedMatches(t)
+
+	var mu sync.Mutex
+	statuses := map[string]string{}
+	record := func(username string) func(wsMessage) {
+		return func(msg wsMessage) {
+			if msg.Type != "match:finished" {
+				return
+			}
+			var payload finishedPayload
+			if err := json.Unmarshal(msg.Payload, &payload); err != nil {
+				t.Error(err)
+				return
+			}
+			mu.Lock()
+			statuses[username] = payload.Status
+			mu.Unlock()
+		}
+	}
+	a, aConn := newSimClient("alice")
+	b, bConn := newSimClient("bob")
+	aConn.idle, bConn.idle = aIdle, bIdle
+	aConn.check, bConn.check = record("alice"), record("bob")
+	defer a.close()
+	defer b.close()
+
+	if _, _, err := state.Join(a, "text-major"); err != nil {
+		t.Fatal(err)
+	}
+	r, _, err := state.Join(b, "text-major")
+	if err != nil || r == nil {
+		t.Fatalf("expected a room, got %v (%v)", r, err)
+	}
+	r.rules.RoundTime = 100 * time.Millisecond
+	r.rules.RoundGap = 0
+	r.rules.SuddenDeathRounds = 0
+	go startMatch(r)
+
+	for _, conn := range []*simConn{aConn, bConn} {
+		select {
+		case <-conn.finished:
+		case <-time.After(10 * time.Second):
+			t.Fatal("match did not finish")
+		}
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	return statuses
+}
+
+func TestMatchFinishedSendsEachPlayerTheirOwnStatus(t *testing.T) {
+	statuses := finishedStatuses(t, false, true)
+	if statuses["alice"] != outcomeVictory || statuses["bob"] != outcomeDefeat {
+		t.Errorf("alice answered and bob did not: got %v", statuses)
+	}
+}
+
+func TestTiedMatchSendsDrawToBothPlayers(t *testing.T) {
+	statuses := finishedStatuses(t, true, true)
+	if statuses["alice"] != outcomeDraw || statuses["bob"] != outcomeDraw {
+		t.Errorf("nobody scored: got %v, want a draw for both", statuses)
+	}
+}
@@ -0,0 +1,166 @@
+package websocket
+
+import (
+	"encoding/json"
+	"errors"
+	"strings"
+	"time"
+)
+
+// errNoHeldSeat は復帰できる試合が見つからない場合のエラー
+var errNoHeldSeat = errors.New("no match to resume")
+
+// handleResume は切断後に新しい接続から試合へ復帰するリクエストを処理する
+func handleResume(c *client, payload json.RawMessage) {
+	var req resumePayload
+	if err := json.Unmarshal(payload, &req); err != nil || strings.TrimSpace(req.Token) == "" {
+		sendError(c, codeInvalidPayload, "invalid resume payload")
+		return
+	}
+	if !authenticateClient(c, req.Token) {
+		return
+	}
+
+	r, err := state.Resume(c)
+	if err != nil {
+		sendErrorFor(c, err)
+		return
+	}
+
+	c.send(wsMessage{Type: "match:resumed", Payload: mustJSON(r.resumeSnapshot(c))})
+	for _, other := range exclude(r.playerList(), c) {
+		other.send(wsMessage{Type: "match:opponent_reconnected", Payload: mustJSON(connectionPayload{
+			RoomID:   r.id,
+			Username: c.username,
+		})})
+	}
+}
+
+// holdSeatLocked は試合中に切断したクライアントの席を猶予時間だけ確保する（s.mu を保持して呼ぶ）
+// ラウンドのタイマーはそのまま進み、猶予内に復帰がなければ没収負けにする
+func (s *matchState) holdSeatLocked(r *room, c *client) {
+	r.mu.Lock()
+	if r.finished {
+		r.mu.Unlock()
+		return
+	}
+	if r.disconnected == nil {
+		r.disconnected = map[string]*client{}
+	}
+	r.disconnected[c.username] = c
+	others := r.opponents(c)
+	r.mu.Unlock()
+
+	for _, other := range others {
+		other.send(wsMessage{Type: "match:opponent_disconnected", Payload: mustJSON(connectionPayload{
+			RoomID:       r.id,
+			Username:     c.username,
+			GraceSeconds: int(reconnectGrace / time.Second),
+		})})
+	}
+
+	time.AfterFunc(reconnectGrace, func() {
+		forfeitSeat(r, c)
+	})
+}
+
+// forfeitSeat は猶予時間が過ぎても復帰しなかったクライアントを没収負けにする
+// 残りが1人（チーム戦では1チーム）になれば試合を終了し、それ以外は没収者を最下位扱いにして続行する
+// 既に復帰済み、または試合が終わっていれば何もしない
+func forfeitSeat(r *room, c *client) {
+	r.mu.Lock()
+	if r.finished || r.disconnected[c.username] != c {
+		r.mu.Unlock()
+		return
+	}
+	if r.forfeited == nil {
+		r.forfeited = map[string]bool{}
+	}
+	// バトルロイヤルでは生き残っていた人はこのラウンドで脱落したものとして順位を付ける
+	if r.royale != nil && !r.isEliminated(c.username) {
+		r.royale.eliminated[c.username] = r.round
+	}
+	r.forfeited[c.username] = true
+	delete(r.disconnected, c.username)
+	remaining := r.activeSideCount()
+	r.mu.Unlock()
+
+	if remaining < 2 {
+		finishMatch(r, "forfeit")
+	}
+}
+
+// Resume は同じユーザーの切断中の席を探し、新しい接続に引き継ぐ
+func (s *matchState) Resume(c *client) (*room, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if c.roomID != "" || s.inQueueLocked(c) {
+		return nil, errAlreadyInRoom
+	}
+
+	for _, r := range s.rooms {
+		r.mu.Lock()
+		old := r.disconnected[c.username]
+		if old == nil || r.finished {
+			r.mu.Unlock()
+			continue
+		}
+		delete(r.disconnected, c.username)
+
+		// 席とクライアントIDをキーにした状態を新しい接続に付け替える
+		for i, p := range r.players {
+			if p == old {
+				r.players[i] = c
+			}
+		}
+		if score, ok := r.scores[old.id]; ok {
+			delete(r.scores, old.id)
+			r.scores[c.id] = score
+		}
+		if answer, ok := r.answers[old.id]; ok {
+			delete(r.answers, old.id)
+			r.answers[c.id] = answer
+		}
+		if elapsed, ok := r.answerTimes[old.id]; ok {
+			delete(r.answerTimes, old.id)
+			r.answerTimes[c.id] = elapsed
+		}
+		c.roomID = r.id
+		c.mode = r.mode
+		r.mu.Unlock()
+		return r, nil
+	}
+	return nil, errNoHeldSeat
+}
+
+// resumeSnapshot は復帰したクライアントに送る現在の進行状況を組み立てる
+func (r *room) resumeSnapshot(c *client) resumedPayload {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	payload := resumedPayload{
+		RoomID:      r.id,
+		Mode:        r.mode,
+		Round:       r.round,
+		TotalRounds: r.maxRounds,
+		Scores:      r.scoreSnapshot(),
+		Teams:       r.teamSnapshot(),
+	}
+	payload.Opponents = opponentInfos(r.opponents(c))
+	if len(payload.Opponents) > 0 {
+		payload.Opponent = payload.Opponents[0].Username
+		payload.OpponentImageURL = payload.Opponents[0].ImageURL
+	}
+	// ラウンド進行中なら問題と残り時間を返す
+	if r.active && r.question != nil {
+		question := newQuestionPayload(r.question)
+		payload.Question = &question
+		remaining := r.roundLimit - time.Since(r.roundStartedAt)
+		if remaining > 0 {
+			payload.RemainingMs = remaining.Milliseconds()
+		}
+		payload.MyAnswer = r.answers[c.id]
+	}
+	return payload
+}
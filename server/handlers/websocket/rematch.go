@@ -0,0 +1,350 @@
+package websocket
+
+import (
+	"encoding/json"
+	"errors"
+	"time"
+)
+
+// 再戦の定数
+const (
+	rematchWindow   = 20 * time.Second // 試合終了後に再戦を受け付ける時間
+	defaultBestOf   = 3                // 再戦で始めるシリーズの既定の試合数
+	maxSeriesBestOf = 5                // 指定できるシリーズの最大試合数
+)
+
+// 再戦関連のエラー
+var (
+	errNoRematch          = errors.New("no rematch available")
+	errRematchUnavailable = errors.New("opponent is no longer available")
+)
+
+// matchSeries は再戦で続けている一連の試合（best-of-N）の勝敗
+type matchSeries struct {
+	bestOf int            // シリーズの試合数（過半数を取った方の勝ち）
+	games  int            // 終了した試合数
+	wins   map[string]int // ユーザー名 → 勝った試合数（引き分けはどちらにも数えない）
+}
+
+// newSeries は終了した試合を1戦目としてシリーズを始める
+func newSeries(bestOf int, firstWinner string) *matchSeries {
+	s := &matchSeries{bestOf: bestOf, wins: map[string]int{}}
+	s.record(firstWinner)
+	return s
+}
+
+// record は終了した試合の勝者を記録する（引き分けは空文字）
+func (s *matchSeries) record(winner string) {
+	s.games++
+	if winner != "" {
+		s.wins[winner]++
+	}
+}
+
+// winner は過半数を取ってシリーズの勝ちが決まったユーザー名を返す（未決着なら空文字）
+func (s *matchSeries) winner() string {
+	for username, wins := range s.wins {
+		if wins*2 > s.bestOf {
+			return username
+		}
+	}
+	return ""
+}
+
+// clone は次の試合に引き継ぐためのコピーを返す
+func (s *matchSeries) clone() *matchSeries {
+	wins := make(map[string]int, len(s.wins))
+	for k, v := range s.wins {
+		wins[k] = v
+	}
+	return &matchSeries{bestOf: s.bestOf, games: s.games, wins: wins}
+}
+
+// payload はクライアントに送るシリーズの状況を返す（シリーズでなければnil）
+// inProgress が true なら進行中の試合を何戦目として数える
+func (s *matchSeries) payload(inProgress bool) *seriesPayload {
+	if s == nil {
+		return nil
+	}
+	game := s.games
+	if inProgress {
+		game++
+	}
+	wins := make(map[string]int, len(s.wins))
+	for k, v := range s.wins {
+		wins[k] = v
+	}
+	return &seriesPayload{BestOf: s.bestOf, Game: game, Wins: wins, Winner: s.winner()}
+}
+
+// rematchOffer は終了した試合の参加者に出している再戦の受付
+type rematchOffer struct {
+	roomID     string    // 終了した試合のルームID
+	players    []*client // 終了した試合の参加者（全員が同意したら再戦する）
+	mode       string
+	rules      MatchRules
+	rated      bool
+	code       string // プライベートルームなら同じ招待コードを引き継ぐ
+	minPlayers int
+	maxPlayers int
+	winner     string       // 終了した試合の勝者（シリーズの1戦目として数える）
+	series     *matchSeries // 終了した試合が属していたシリーズ（なければnil）
+	bestOf     int          // 最初に再戦を申し込んだ人が指定したシリーズの試合数
+	accepted   map[string]bool
+	expiresAt  time.Time
+	timer      *time.Timer
+}
+
+// status は再戦の受付状況を送信用の形にする（s.mu を保持して呼ぶ）
+func (o *rematchOffer) status() rematchStatusPayload {
+	payload := rematchStatusPayload{
+		RoomID:           o.roomID,
+		BestOf:           o.bestOf,
+		Accepted:         make([]string, 0, len(o.players)),
+		ExpiresInSeconds: int(time.Until(o.expiresAt).Round(time.Second) / time.Second),
+	}
+	for _, p := range o.players {
+		if o.accepted[p.id] {
+			payload.Accepted = append(payload.Accepted, p.username)
+		}
+	}
+	return payload
+}
+
+// isRematchable は再戦を受け付けられる試合か（bot や没収負けの参加者がいない人間同士の試合か）を返す（r.mu を保持して呼ぶ）
+// 大会の試合（組み合わせが決まっている）、チーム戦（パーティー単位で組み直す）、バトルロイヤル（大人数）は再戦できない
+func (r *room) isRematchable() bool {
+	if len(r.players) < minRoomPlayers || r.tournament != nil || r.isTeamMatch() || r.royale != nil {
+		return false
+	}
+	for _, p := range r.players {
+		if p == nil || p.bot != nil || r.forfeited[p.username] {
+			return false
+		}
+	}
+	return true
+}
+
+// OfferRematch は終了した試合の参加者に再戦の受付を出す（受け付けない試合ならfalse）
+// 受付時間を過ぎると全員に match:rematch_cancelled を送って締め切る
+func (s *matchState) OfferRematch(r *room, winner string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	r.mu.Lock()
+	if !r.isRematchable() {
+		r.mu.Unlock()
+		return false
+	}
+	offer := &rematchOffer{
+		roomID:     r.id,
+		players:    append([]*client(nil), r.players...),
+		mode:       r.mode,
+		rules:      r.rules,
+		rated:      r.rated,
+		code:       r.code,
+		minPlayers: r.minPlayers,
+		maxPlayers: r.maxPlayers,
+		winner:     winner,
+		series:     r.series,
+		accepted:   map[string]bool{},
+		expiresAt:  time.Now().Add(rematchWindow),
+	}
+	r.mu.Unlock()
+
+	// 決着のついていないシリーズの続きなら試合数は変えられない
+	if offer.series != nil && offer.series.winner() == "" {
+		offer.bestOf = offer.series.bestOf
+	}
+	offer.timer = time.AfterFunc(rematchWindow, func() {
+		if players, ok := s.expireRematch(offer); ok {
+			notifyRematchCancelled(players, rematchCancelledPayload{RoomID: offer.roomID, Reason: "timeout"})
+		}
+	})
+	s.rematches[r.id] = offer
+	return true
+}
+
+// RequestRematch は再戦の申し込み（2人目以降は承諾）を記録する
+// 全員が揃えば同じモードと参加者で新しいルームを作って返す
+// 参加者の誰かが既に別の対戦を始めていたり切断していれば受付を締め切り errRematchUnavailable を返す
+func (s *matchState) RequestRematch(c *client, roomID string, bestOf int) ([]*client, rematchStatusPayload, *room, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	offer := s.rematches[roomID]
+	if offer == nil {
+		return nil, rematchStatusPayload{}, nil, errNoRematch
+	}
+	if !offer.hasPlayer(c) {
+		return nil, rematchStatusPayload{}, nil, errNotRoomPlayer
+	}
+	if !s.isAvailableLocked(c) {
+		return nil, rematchStatusPayload{}, nil, errAlreadyInRoom
+	}
+
+	// シリーズの試合数は最初に申し込んだ人の指定で決まる
+	if offer.bestOf == 0 {
+		offer.bestOf = bestOf
+		if offer.bestOf == 0 {
+			offer.bestOf = defaultBestOf
+		}
+	}
+	offer.accepted[c.id] = true
+	players := append([]*client(nil), offer.players...)
+	for _, p := range offer.players {
+		if !offer.accepted[p.id] {
+			return players, offer.status(), nil, nil
+		}
+	}
+
+	// 全員が同意したので、もう一度全員が空いているかを確かめてからルームを作る
+	s.closeRematchLocked(offer)
+	for _, p := range offer.players {
+		if !s.isAvailableLocked(p) {
+			return players, rematchStatusPayload{}, nil, errRematchUnavailable
+		}
+	}
+	return players, offer.status(), s.newRematchRoomLocked(offer), nil
+}
+
+// DeclineRematch は再戦を断り、受付を締め切る
+func (s *matchState) DeclineRematch(c *client, roomID string) ([]*client, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	offer := s.rematches[roomID]
+	if offer == nil {
+		return nil, errNoRematch
+	}
+	if !offer.hasPlayer(c) {
+		return nil, errNotRoomPlayer
+	}
+	s.closeRematchLocked(offer)
+	return append([]*client(nil), offer.players...), nil
+}
+
+// expireRematch は受付時間を過ぎた再戦を締め切る（既に成立・辞退していればfalse）
+func (s *matchState) expireRematch(offer *rematchOffer) ([]*client, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.rematches[offer.roomID] != offer {
+		return nil, false
+	}
+	delete(s.rematches, offer.roomID)
+	return append([]*client(nil), offer.players...), true
+}
+
+// closeRematchLocked は再戦の受付を取り下げる（s.mu を保持して呼ぶ）
+func (s *matchState) closeRematchLocked(offer *rematchOffer) {
+	delete(s.rematches, offer.roomID)
+	if offer.timer != nil {
+		offer.timer.Stop()
+	}
+}
+
+// isAvailableLocked はクライアントが接続中で、待機・対戦・観戦のどれもしていないかを返す（s.mu を保持して呼ぶ）
+func (s *matchState) isAvailableLocked(c *client) bool {
+	return !c.isClosed() && c.roomID == "" && c.spectating == "" && !s.inQueueLocked(c)
+}
+
+// newRematchRoomLocked は再戦用のルームを作って登録する（s.mu を保持して呼ぶ）
+// 問題は startMatch で新しく取得し、シリーズの勝敗は引き継ぐ（決着済みなら新しいシリーズを始める）
+func (s *matchState) newRematchRoomLocked(offer *rematchOffer) *room {
+	var series *matchSeries
+	switch {
+	case offer.series == nil:
+		series = newSeries(offer.bestOf, offer.winner)
+	case offer.series.winner() != "":
+		series = &matchSeries{bestOf: offer.bestOf, wins: map[string]int{}}
+	default:
+		series = offer.series.clone()
+	}
+
+	r := &room{
+		id:         newRoomID(),
+		players:    append([]*client(nil), offer.players...),
+		minPlayers: offer.minPlayers,
+		maxPlayers: offer.maxPlayers,
+		mode:       offer.mode,
+		rules:      offer.rules,
+		rated:      offer.rated,
+		code:       offer.code,
+		ready:      map[string]bool{},
+		started:    true,
+		series:     series,
+	}
+	for _, p := range r.players {
+		p.roomID = r.id
+		p.mode = r.mode
+	}
+	s.rooms[r.id] = r
+	if r.code != "" {
+		s.codes[r.code] = r
+	}
+	return r
+}
+
+// hasPlayer はクライアントが終了した試合の参加者かを返す
+func (o *rematchOffer) hasPlayer(c *client) bool {
+	for _, p := range o.players {
+		if p.id == c.id {
+			return true
+		}
+	}
+	return false
+}
+
+// handleRematch は再戦の申し込み・承諾を処理し、全員が同意したら新しい試合を始める
+func handleRematch(c *client, payload json.RawMessage) {
+	var req rematchPayload
+	if err := json.Unmarshal(payload, &req); err != nil || req.RoomID == "" || !isValidBestOf(req.BestOf) {
+		sendError(c, codeInvalidPayload, "invalid rematch payload")
+		return
+	}
+
+	players, status, r, err := state.RequestRematch(c, req.RoomID, req.BestOf)
+	if errors.Is(err, errRematchUnavailable) {
+		notifyRematchCancelled(players, rematchCancelledPayload{RoomID: req.RoomID, Reason: "unavailable"})
+		return
+	}
+	if err != nil {
+		sendErrorFor(c, err)
+		return
+	}
+	if r != nil {
+		startMatch(r)
+		return
+	}
+	for _, p := range players {
+		p.send(wsMessage{Type: "match:rematch_requested", Payload: mustJSON(status)})
+	}
+}
+
+// handleRematchDecline は再戦を断り、他の参加者に知らせる
+func handleRematchDecline(c *client, payload json.RawMessage) {
+	var req rematchPayload
+	if err := json.Unmarshal(payload, &req); err != nil || req.RoomID == "" {
+		sendError(c, codeInvalidPayload, "invalid rematch payload")
+		return
+	}
+
+	players, err := state.DeclineRematch(c, req.RoomID)
+	if err != nil {
+		sendErrorFor(c, err)
+		return
+	}
+	notifyRematchCancelled(players, rematchCancelledPayload{RoomID: req.RoomID, Reason: "declined", Username: c.username})
+}
+
+// notifyRematchCancelled は再戦の受付が締め切られたことを参加者に知らせる
+func notifyRematchCancelled(players []*client, payload rematchCancelledPayload) {
+	for _, p := range players {
+		p.send(wsMessage{Type: "match:rematch_cancelled", Payload: mustJSON(payload)})
+	}
+}
+
+// isValidBestOf はシリーズの試合数として受け付ける値か（未指定の0か、maxSeriesBestOf までの奇数）を返す
+func isValidBestOf(bestOf int) bool {
+	return bestOf == 0 || (bestOf >= defaultBestOf && bestOf <= maxSeriesBestOf && bestOf%2 == 1)
+}
@@ -1,11 +1,13 @@
 package websocket
 
+import "sort"
+
 // scoreSnapshot は現在のスコアのスナップショットを返す
 // WebSocketメッセージでスコア状況を送信する際に使用
 // 戻り値: map[username]score （例: {"alice": 3, "bob": 2}）
 func (r *room) scoreSnapshot() map[string]int {
-	// プレイヤー2人分のマップを作成
-	snapshot := make(map[string]int, 2)
+	// 参加人数分のマップを作成
+	snapshot := make(map[string]int, len(r.players))
 	// 各プレイヤーのユーザー名とスコアをマッピング
 	for _, p := range r.players {
 		if p != nil {
@@ -16,44 +18,115 @@ func (r *room) scoreSnapshot() map[string]int {
 	return snapshot
 }
 
-// winnerName は勝者のユーザー名を返す（引き分けの場合は空文字）
+// standings はスコア順の最終順位を返す
+// 同点は同順位（1, 1, 3 のような競技方式）、没収負けのプレイヤーはスコアに関係なく最下位グループ
+// チーム戦ではチームの得点で順位を付ける（teamStandings）
+func (r *room) standings() []standingItem {
+	if r.isTeamMatch() {
+		return r.teamStandings()
+	}
+	if r.royale != nil {
+		return r.royaleStandings()
+	}
+	items := make([]standingItem, 0, len(r.players))
+	for _, p := range r.players {
+		if p == nil {
+			continue
+		}
+		items = append(items, standingItem{
+			Username:  p.username,
+			Score:     r.scores[p.id],
+			Forfeited: r.forfeited[p.username],
+			Bot:       p.bot != nil,
+		})
+	}
+
+	// 没収負けでない方を先に、その中ではスコアの高い順に並べる（同点は参加順を維持）
+	sort.SliceStable(items, func(i, j int) bool {
+		if items[i].Forfeited != items[j].Forfeited {
+			return !items[i].Forfeited
+		}
+		return items[i].Score > items[j].Score
+	})
+
+	// 順位を付ける（前の人と同じ成績なら同順位）
+	for i := range items {
+		if i > 0 && items[i].Score == items[i-1].Score && items[i].Forfeited == items[i-1].Forfeited {
+			items[i].Rank = items[i-1].Rank
+			continue
+		}
+		items[i].Rank = i + 1
+	}
+	return items
+}
+
+// winnerName は勝者のユーザー名を返す（1位が同点の場合は空文字、チーム戦では勝ったチーム名）
 // マッチ終了時に勝者を判定し、レーティング更新や結果表示に使用
 func (r *room) winnerName() string {
+	standings := r.standings()
 	// プレイヤーが2人揃っていない場合は判定不可
-	if len(r.players) < 2 {
+	if len(standings) < 2 {
 		return ""
 	}
-	a := r.players[0]
-	b := r.players[1]
-	// どちらかがnilなら判定不可
-	if a == nil || b == nil {
-		return ""
+	// 別の陣営が同じ1位にいれば引き分け（空文字を返す）
+	for _, s := range standings[1:] {
+		if s.Rank == standings[0].Rank && !sameSide(s, standings[0]) {
+			return ""
+		}
 	}
-	// 各プレイヤーのスコアを取得
-	scoreA := r.scores[a.id]
-	scoreB := r.scores[b.id]
-	// スコアが同じなら引き分け（空文字を返す）
-	if scoreA == scoreB {
-		return ""
+	if standings[0].Team != "" {
+		return standings[0].Team
 	}
-	// スコアが高い方のユーザー名を返す
-	if scoreA > scoreB {
-		return a.username
+	return standings[0].Username
+}
+
+// opponents は指定クライアント以外の参加者を返す（r.mu を保持して呼ぶ）
+// ラウンド配信や切断通知で「相手プレイヤー」を特定するために使用
+func (r *room) opponents(c *client) []*client {
+	return exclude(r.players, c)
+}
+
+// playerList は参加者の一覧をコピーして返す（r.mu を保持せずに呼ぶ）
+// 再接続で r.players の席が付け替えられても、送信中の一覧は変わらない
+func (r *room) playerList() []*client {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]*client(nil), r.players...)
+}
+
+// exclude は一覧から指定クライアントと空席を除いたものを返す
+func exclude(players []*client, c *client) []*client {
+	others := make([]*client, 0, len(players))
+	for _, p := range players {
+		if p != nil && p.id != c.id {
+			others = append(others, p)
+		}
 	}
-	return b.username
+	return others
 }
 
-// otherPlayer は指定クライアントの対戦相手を返す
-// ラウンド結果やメッセージ送信時に「相手プレイヤー」を特定するために使用
-func (r *room) otherPlayer(c *client) *client {
-	// プレイヤー0が引数のクライアントなら、プレイヤー1を返す
-	if r.players[0] != nil && r.players[0].id == c.id {
-		return r.players[1]
+// allAnswered は回答できる全員（切断中・没収負け・脱落済みを除く）が現在のラウンドに回答済みかを返す（r.mu を保持して呼ぶ）
+func (r *room) allAnswered() bool {
+	waiting := 0
+	for _, p := range r.players {
+		if p == nil || r.forfeited[p.username] || r.disconnected[p.username] != nil || r.isEliminated(p.username) {
+			continue
+		}
+		if _, ok := r.answers[p.id]; !ok {
+			return false
+		}
+		waiting++
 	}
-	// プレイヤー1が引数のクライアントなら、プレイヤー0を返す
-	if r.players[1] != nil && r.players[1].id == c.id {
-		return r.players[0]
+	return waiting > 0
+}
+
+// activePlayerCount は没収負けになっていない参加者の数を返す
+func (r *room) activePlayerCount() int {
+	count := 0
+	for _, p := range r.players {
+		if p != nil && !r.forfeited[p.username] {
+			count++
+		}
 	}
-	// どちらにも該当しない場合はnil（通常ありえない）
-	return nil
+	return count
 }
@@ -0,0 +1,116 @@
+package websocket
+
+import (
+	"fmt"
+	"math"
+	"testing"
+	"time"
+)
+
+func TestStandingsRanks(t *testing.T) {
+	cases := []struct {
+		name      string
+		scores    []int
+		forfeited []string
+		want      []standingItem // Username と Rank だけを比べる
+	}{
+		{
+			name:   "ties share a rank and the next rank is skipped",
+			scores: []int{200, 300, 300},
+			want:   []standingItem{{Username: "p2", Rank: 1}, {Username: "p3", Rank: 1}, {Username: "p1", Rank: 3}},
+		},
+		{
+			name:   "tie below the leader",
+			scores: []int{100, 300, 100, 50},
+			want:   []standingItem{{Username: "p2", Rank: 1}, {Username: "p1", Rank: 2}, {Username: "p3", Rank: 2}, {Username: "p4", Rank: 4}},
+		},
+		{
+			name:      "forfeit goes last despite its score",
+			scores:    []int{500, 100, 200},
+			forfeited: []string{"p1"},
+			want:      []standingItem{{Username: "p3", Rank: 1}, {Username: "p2", Rank: 2}, {Username: "p1", Rank: 3}},
+		},
+		{
+			name:      "forfeit does not tie with a player on the same score",
+			scores:    []int{100, 100},
+			forfeited: []string{"p1"},
+			want:      []standingItem{{Username: "p2", Rank: 1}, {Username: "p1", Rank: 2}},
+		},
+		{
+			name:      "forfeits are ranked among themselves by score",
+			scores:    []int{0, 300, 200, 200},
+			forfeited: []string{"p3", "p4", "p2"},
+			want:      []standingItem{{Username: "p1", Rank: 1}, {Username: "p2", Rank: 2}, {Username: "p3", Rank: 3}, {Username: "p4", Rank: 3}},
+		},
+	}
+	for _, tc := range cases {
+		names := make([]string, len(tc.scores))
+		for i := range names {
+			names[i] = fmt.Sprintf("p%d", i+1)
+		}
+		r := scoredRoom(tc.scores, names...)
+		for _, name := range tc.forfeited {
+			r.forfeited[name] = true
+		}
+		got := r.standings()
+		if len(got) != len(tc.want) {
+			t.Fatalf("%s: got %d standings, want %d", tc.name, len(got), len(tc.want))
+		}
+		for i, w := range tc.want {
+			if got[i].Username != w.Username || got[i].Rank != w.Rank {
+				t.Errorf("%s: standings[%d] = %s rank %d, want %s rank %d", tc.name, i, got[i].Username, got[i].Rank, w.Username, w.Rank)
+			}
+		}
+	}
+}
+
+func TestPairwiseEloTwoPlayersMatchesClassicFormula(t *testing.T) {
+	players := []ratingSnapshot{{Rating: 1600, Deviation: 100}, {Rating: 1400, Deviation: 100}}
+	standings := []standingItem{{Rank: 1}, {Rank: 2}}
+	after := eloEngine{KFactor: eloKFactor}.Rate(players, standings, time.Now())
+
+	expected := 1 / (1 + math.Pow(10, -200.0/400))
+	want := eloKFactor * (1 - expected)
+	if math.Abs((after[0].Rating-1600)-want) > 1e-9 || math.Abs((after[1].Rating-1400)+want) > 1e-9 {
+		t.Errorf("deltas %+.4f / %+.4f, want ±%.4f", after[0].Rating-1600, after[1].Rating-1400, want)
+	}
+}
+
+func TestPairwiseEloDeltasSumToZero(t *testing.T) {
+	for n := 3; n <= 8; n++ {
+		players := make([]ratingSnapshot, n)
+		standings := make([]standingItem, n)
+		for i := range players {
+			// レーティングはばらばら、順位は1-1-3のような同点も含める
+			players[i] = ratingSnapshot{Rating: float64(1200 + (i*137)%500), Deviation: 120}
+			standings[i] = standingItem{Rank: i/2*2 + 1}
+		}
+		after := eloEngine{KFactor: eloKFactor}.Rate(players, standings, time.Now())
+
+		sum := 0.0
+		for i := range players {
+			delta := after[i].Rating - players[i].Rating
+			sum += delta
+			// 全員に勝っても負けても、1試合で動くのは K 以内
+			if math.Abs(delta) > eloKFactor {
+				t.Errorf("n=%d: player %d moved %+.2f, more than K", n, i, delta)
+			}
+		}
+		if math.Abs(sum) > 1e-9 {
+			t.Errorf("n=%d: deltas sum to %+.6f, want 0", n, sum)
+		}
+	}
+}
+
+func TestPairwiseEloSharedFirstSplitsTheGain(t *testing.T) {
+	// 1-1-3: 同じレーティングの3人で、上の2人は引き分け同士なので同じだけ上がる
+	players := []ratingSnapshot{{Rating: 1500, Deviation: 100}, {Rating: 1500, Deviation: 100}, {Rating: 1500, Deviation: 100}}
+	standings := []standingItem{{Rank: 1}, {Rank: 1}, {Rank: 3}}
+	after := eloEngine{KFactor: eloKFactor}.Rate(players, standings, time.Now())
+
+	first, second, last := after[0].Rating-1500, after[1].Rating-1500, after[2].Rating-1500
+	// K/2 × (1 − 0.5) = 8 を2人が受け取り、最下位が2人分の16を失う
+	if math.Abs(first-8) > 1e-9 || math.Abs(second-8) > 1e-9 || math.Abs(last+16) > 1e-9 {
+		t.Errorf("deltas %+.2f / %+.2f / %+.2f, want +8 / +8 / -16", first, second, last)
+	}
+}
@@ -0,0 +1,391 @@
+package websocket
+
+import (
+	"errors"
+	"math"
+	"sort"
+	"time"
+
+	"example.com/mathkun-tmp-/server/models"
+	"example.com/mathkun-tmp-/server/repositories"
+	"gorm.io/gorm"
+)
+
+// バトルロイヤル（脱落戦）の定数
+const (
+	royaleMinPlayers = 3                // カウントダウンを始める人数
+	royaleMaxPlayers = 50               // 1ロビーの最大人数（満員になったらすぐ始める）
+	royaleCountdown  = 30 * time.Second // 最少人数が揃ってから開始までの待ち時間
+	royaleMaxRounds  = 20               // 最後の1人が決まらなくてもここで打ち切る
+	royaleWeight     = 2.0              // 1試合を個人戦の何試合分としてレーティングに反映するか（Elo では K因子が64になる）
+)
+
+// errEliminated は脱落した参加者が回答しようとした場合のエラー
+var errEliminated = errors.New("already eliminated")
+
+// royaleState はバトルロイヤルのルームの状態
+type royaleState struct {
+	eliminated   map[string]int // 脱落した参加者（ユーザー名 → 脱落したラウンド）
+	startsAt     time.Time      // 開始予定（カウントダウン中でなければゼロ値）
+	countdownSeq uint64         // カウントダウンの世代（取り消したタイマーを無視するため）
+}
+
+// handleRoyaleJoin はバトルロイヤルのロビーへの参加を処理する
+// 最少人数が揃ったらカウントダウンを始め、満員になったらすぐに始める
+func handleRoyaleJoin(c *client, mode string) {
+	r, lobby, countdownSeq, ready, err := state.JoinRoyale(c, mode)
+	if err != nil {
+		sendErrorFor(c, err)
+		return
+	}
+	if ready {
+		startMatch(r)
+		return
+	}
+	if countdownSeq != 0 {
+		time.AfterFunc(royaleCountdown, func() {
+			launchRoyale(r, countdownSeq)
+		})
+	}
+	fanout(lobby.players, wsMessage{Type: "royale:lobby", Payload: mustJSON(lobby.payload)})
+}
+
+// launchRoyale はカウントダウンが終わったロビーの試合を始める
+// 人数が最少人数を割っていればカウントダウンを取り消して次の参加者を待つ
+func launchRoyale(r *room, seq uint64) {
+	lobby, started := state.LaunchRoyale(r, seq)
+	if started {
+		startMatch(r)
+		return
+	}
+	if len(lobby.players) > 0 {
+		fanout(lobby.players, wsMessage{Type: "royale:lobby", Payload: mustJSON(lobby.payload)})
+	}
+}
+
+// royaleLobby はロビーの通知先と通知内容
+type royaleLobby struct {
+	players []*client
+	payload royaleLobbyPayload
+}
+
+// JoinRoyale はクライアントをモードのロビーに入れる（なければ作る）
+// 満員になれば開始済みにして ready を返し、最少人数に達してカウントダウンを始めたらその世代を返す
+func (s *matchState) JoinRoyale(c *client, mode string) (*room, royaleLobby, uint64, bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if !s.isAvailableLocked(c) {
+		return nil, royaleLobby{}, 0, false, errAlreadyInRoom
+	}
+	r := s.royaleLobbies[mode]
+	if r == nil {
+		r = &room{
+			id:         newRoomID(),
+			minPlayers: royaleMinPlayers,
+			maxPlayers: royaleMaxPlayers,
+			mode:       mode,
+			rated:      true,
+			rules:      royaleRulesFor(mode),
+			royale:     &royaleState{eliminated: map[string]int{}},
+		}
+		s.rooms[r.id] = r
+		s.royaleLobbies[mode] = r
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.players = append(r.players, c)
+	c.roomID = r.id
+	c.mode = mode
+
+	if len(r.players) >= r.maxPlayers {
+		r.started = true
+		r.royale.startsAt = time.Time{}
+		delete(s.royaleLobbies, mode)
+		return r, royaleLobby{}, 0, true, nil
+	}
+	var seq uint64
+	if len(r.players) >= r.minPlayers && r.royale.startsAt.IsZero() {
+		r.royale.countdownSeq++
+		r.royale.startsAt = time.Now().Add(royaleCountdown)
+		seq = r.royale.countdownSeq
+	}
+	return r, r.royaleLobbyLocked(), seq, false, nil
+}
+
+// LaunchRoyale はカウントダウンが終わったロビーを開始済みにする（取り消されたカウントダウンなら何もしない）
+// 人数が足りなければカウントダウンを取り消し、ロビーの通知内容を返す
+func (s *matchState) LaunchRoyale(r *room, seq uint64) (royaleLobby, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.started || r.royale.countdownSeq != seq || r.royale.startsAt.IsZero() {
+		return royaleLobby{}, false
+	}
+	r.royale.startsAt = time.Time{}
+	if len(r.players) < r.minPlayers {
+		return r.royaleLobbyLocked(), false
+	}
+	r.started = true
+	if s.royaleLobbies[r.mode] == r {
+		delete(s.royaleLobbies, r.mode)
+	}
+	return royaleLobby{}, true
+}
+
+// LeaveRoyaleLobby は開始前のロビーからクライアントを外し、ロビーのモードを返す（ロビーにいなければfalse）
+func (s *matchState) LeaveRoyaleLobby(c *client) (string, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	r := s.rooms[c.roomID]
+	if r == nil || r.royale == nil || r.started {
+		return "", false
+	}
+	s.leaveRoyaleLobbyLocked(r, c)
+	return r.mode, true
+}
+
+// leaveRoyaleLobbyLocked は開始前のロビーからクライアントを外し、残りの参加者に知らせる（s.mu を保持して呼ぶ）
+// 最少人数を割ったらカウントダウンを取り消し、誰もいなくなればロビーを閉じる
+func (s *matchState) leaveRoyaleLobbyLocked(r *room, c *client) {
+	c.roomID = ""
+	r.mu.Lock()
+	remaining := make([]*client, 0, len(r.players))
+	for _, p := range r.players {
+		if p.id != c.id {
+			remaining = append(remaining, p)
+		}
+	}
+	r.players = remaining
+	if len(remaining) < r.minPlayers && !r.royale.startsAt.IsZero() {
+		r.royale.startsAt = time.Time{}
+		r.royale.countdownSeq++
+	}
+	lobby := r.royaleLobbyLocked()
+	r.mu.Unlock()
+
+	if len(remaining) == 0 {
+		delete(s.rooms, r.id)
+		if s.royaleLobbies[r.mode] == r {
+			delete(s.royaleLobbies, r.mode)
+		}
+		return
+	}
+	fanout(lobby.players, wsMessage{Type: "royale:lobby", Payload: mustJSON(lobby.payload)})
+}
+
+// royaleLobbyLocked はロビーの現在の状況を返す（r.mu を保持して呼ぶ）
+// 大人数に送るので、参加者の一覧ではなく人数だけを載せる
+func (r *room) royaleLobbyLocked() royaleLobby {
+	payload := royaleLobbyPayload{
+		RoomID:     r.id,
+		Mode:       r.mode,
+		Players:    len(r.players),
+		MinPlayers: r.minPlayers,
+		MaxPlayers: r.maxPlayers,
+	}
+	if !r.royale.startsAt.IsZero() {
+		payload.StartsInSeconds = max(int(math.Ceil(time.Until(r.royale.startsAt).Seconds())), 0)
+	}
+	return royaleLobby{players: append([]*client(nil), r.players...), payload: payload}
+}
+
+// royaleRulesFor はバトルロイヤルのルールを決める（常にレーティング戦、延長戦なし）
+// rulesFor のルールに MATCH_RULES の "royale" と "<mode>:royale" を重ねる
+// 出題数は最後の1人が決まるまで続けるため royaleMaxRounds に固定する
+func royaleRulesFor(mode string) MatchRules {
+	rules := applyRulesConfig(rulesFor(mode, true), []string{"royale", mode + ":royale"})
+	rules.Rounds = royaleMaxRounds
+	rules.SuddenDeathRounds = 0
+	return rules
+}
+
+// isEliminated は参加者がバトルロイヤルで脱落済みかを返す（r.mu を保持して呼ぶ）
+func (r *room) isEliminated(username string) bool {
+	if r.royale == nil {
+		return false
+	}
+	_, ok := r.royale.eliminated[username]
+	return ok
+}
+
+// royaleSurvivors は脱落も没収負けもしていない参加者を返す（r.mu を保持して呼ぶ）
+func (r *room) royaleSurvivors() []*client {
+	survivors := make([]*client, 0, len(r.players))
+	for _, p := range r.players {
+		if p != nil && !r.forfeited[p.username] && !r.isEliminated(p.username) {
+			survivors = append(survivors, p)
+		}
+	}
+	return survivors
+}
+
+// eliminateLocked はラウンドで正解できなかった生存者を脱落させ、生存者の状況を返す（r.mu を保持して呼ぶ）
+// 時間内に答えなかった人も不正解と同じ扱いになる
+// 生存者が全員脱落してしまう場合は、そのラウンドは誰も落とさない
+func (r *room) eliminateLocked(round int, correct map[string]bool) royaleSurvivorsPayload {
+	survivors := r.royaleSurvivors()
+	var out []string
+	for _, p := range survivors {
+		if !correct[p.username] {
+			out = append(out, p.username)
+		}
+	}
+	if len(out) == len(survivors) {
+		out = nil
+	}
+	for _, name := range out {
+		r.royale.eliminated[name] = round
+	}
+
+	payload := royaleSurvivorsPayload{
+		RoomID:     r.id,
+		Round:      round,
+		Survivors:  []string{},
+		Eliminated: []string{},
+	}
+	for _, p := range r.royaleSurvivors() {
+		payload.Survivors = append(payload.Survivors, p.username)
+	}
+	payload.Eliminated = append(payload.Eliminated, out...)
+	payload.Remaining = len(payload.Survivors)
+	return payload
+}
+
+// royaleStandings は生き残った順の順位を付けた参加者の一覧を返す（r.mu を保持して呼ぶ）
+// 最後まで残った人が上位、脱落した人は遅く脱落したほど上位で、同じラウンドで脱落した人同士は得点順
+// 脱落したラウンドと得点がどちらも同じなら同順位
+func (r *room) royaleStandings() []standingItem {
+	items := make([]standingItem, 0, len(r.players))
+	for _, p := range r.players {
+		if p == nil {
+			continue
+		}
+		items = append(items, standingItem{
+			Username:        p.username,
+			Score:           r.scores[p.id],
+			Forfeited:       r.forfeited[p.username],
+			Bot:             p.bot != nil,
+			EliminatedRound: r.royale.eliminated[p.username],
+		})
+	}
+	// 生存者（脱落ラウンド0）を最後まで残ったものとして扱う
+	survivedUntil := func(s standingItem) int {
+		if s.EliminatedRound == 0 {
+			return math.MaxInt
+		}
+		return s.EliminatedRound
+	}
+	sort.SliceStable(items, func(i, j int) bool {
+		if a, b := survivedUntil(items[i]), survivedUntil(items[j]); a != b {
+			return a > b
+		}
+		return items[i].Score > items[j].Score
+	})
+	for i := range items {
+		if i > 0 && items[i].EliminatedRound == items[i-1].EliminatedRound && items[i].Score == items[i-1].Score {
+			items[i].Rank = items[i-1].Rank
+			continue
+		}
+		items[i].Rank = i + 1
+	}
+	return items
+}
+
+// fanout は同じメッセージを複数の接続に送る
+// ペイロードは呼び出し側で1度だけJSONにしたものを共有し、大人数でも参加者ごとに組み立て直さない
+func fanout(clients []*client, msg wsMessage) {
+	for _, c := range clients {
+		if c != nil {
+			c.send(msg)
+		}
+	}
+}
+
+// sendRoyaleRound は全参加者と観戦者に同じ問題を送る
+// 参加者ごとに「自分以外の参加者」を作ると人数の2乗に比例して重くなるので、全員を並べた1つのメッセージにする
+func sendRoyaleRound(r *room, roundNum int, scores map[string]int) {
+	event := "match:round"
+	if roundNum == 1 {
+		event = "match:started"
+	}
+	r.mu.Lock()
+	players := append([]*client(nil), r.players...)
+	question := newQuestionPayload(r.question)
+	totalRounds := r.maxRounds
+	r.mu.Unlock()
+	msg := wsMessage{Type: event, Payload: mustJSON(roundPayload{
+		RoomID:      r.id,
+		Opponents:   opponentInfos(players),
+		Question:    question,
+		Round:       roundNum,
+		TotalRounds: totalRounds,
+		TimeLimitMs: r.rules.RoundTime.Milliseconds(),
+		Scores:      scores,
+	})}
+	fanout(players, msg)
+	fanout(r.spectatorList(), msg)
+}
+
+// applyRoyaleRatingForMatch はバトルロイヤルの順位でバトルロイヤル用のレーティングを更新する
+// 全員と1対1で戦ったとみなし、上の順位の相手に勝ち、下の順位の相手に負けたものとして RATING_SYSTEM の方式で計算する
+// 1試合を royaleWeight 試合分として相手の人数で按分するので、大人数でも1試合で動く量は人数によらずほぼ一定になる
+// 個人戦のレーティング（全モード共通・モード別）は変えない
+func applyRoyaleRatingForMatch(tx *gorm.DB, mode string, standings []standingItem) (ratingResult, error) {
+	result := ratingResult{
+		Ratings: map[string]int{},
+		Deltas:  map[string]int{},
+	}
+	if len(standings) < 2 {
+		return result, nil
+	}
+	ratingMode := models.RoyaleRatingMode(mode)
+	repo := repositories.NewUserRepository(tx)
+	modeRepo := repositories.NewUserModeRatingRepository(tx)
+
+	ratings := make([]*models.UserModeRating, len(standings))
+	before := make([]ratingSnapshot, len(standings))
+	for i, s := range standings {
+		u, err := repo.FindByUsername(s.Username)
+		if err != nil {
+			return result, err
+		}
+		if u == nil {
+			return result, errors.New("user not found")
+		}
+		mr, err := modeRepo.FindOrDefault(u.ID, ratingMode)
+		if err != nil {
+			return result, err
+		}
+		ratings[i] = mr
+		before[i] = ratingSnapshot{Rating: float64(mr.Rating), Deviation: mr.RatingDeviation, Volatility: mr.RatingVolatility, RatedAt: mr.RatedAt}
+	}
+
+	now := time.Now()
+	after := weightedRatingEngineFromEnv(royaleWeight).Rate(before, standings, now)
+	for i, s := range standings {
+		mr := ratings[i]
+		switch matchOutcome(standings, s.Username) {
+		case outcomeVictory:
+			mr.Wins++
+		case outcomeDraw:
+			mr.Draws++
+		default:
+			mr.Losses++
+		}
+		newRating := int(math.Round(after[i].Rating))
+		result.Ratings[s.Username] = newRating
+		result.Deltas[s.Username] = newRating - mr.Rating
+		mr.Rating = newRating
+		mr.RatingDeviation = after[i].Deviation
+		mr.RatingVolatility = after[i].Volatility
+		mr.RatedAt = &now
+		if err := modeRepo.Save(mr); err != nil {
+			return result, err
+		}
+	}
+	return result, nil
+}
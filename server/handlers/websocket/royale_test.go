@@ -0,0 +1,65 @@
+package websocket
+
+import (
+	"fmt"
+	"testing"
+	"time"
+)
+
+// royaleField は同じレーティング・RDの n 人が1位から順に並んだバトルロイヤルの結果を作る
+func royaleField(n int) ([]ratingSnapshot, []standingItem) {
+	players := make([]ratingSnapshot, n)
+	standings := make([]standingItem, n)
+	for i := range players {
+		players[i] = ratingSnapshot{Rating: 1500, Deviation: 200, Volatility: 0.06}
+		standings[i] = standingItem{Rank: i + 1, Username: fmt.Sprintf("p%d", i+1)}
+	}
+	return players, standings
+}
+
+func TestWeightedRatingEngineFollowsRatingSystem(t *testing.T) {
+	if e, ok := newWeightedRatingEngine("elo", royaleWeight).(eloEngine); !ok || e.KFactor != eloKFactor*royaleWeight || e.MatchWeight != royaleWeight {
+		t.Errorf("elo: got %#v", newWeightedRatingEngine("elo", royaleWeight))
+	}
+	if e, ok := newWeightedRatingEngine("glicko2", royaleWeight).(glicko2Engine); !ok || e.MatchWeight != royaleWeight || e.Tau != glickoTau {
+		t.Errorf("glicko2: got %#v", newWeightedRatingEngine("glicko2", royaleWeight))
+	}
+}
+
+func TestRoyaleRatingMovesAboutTheSameForAnyFieldSize(t *testing.T) {
+	now := time.Now()
+	for _, name := range []string{"elo", "glicko2"} {
+		engine := newWeightedRatingEngine(name, royaleWeight)
+		var spreads []float64
+		for _, n := range []int{3, 10, 50} {
+			players, standings := royaleField(n)
+			after := engine.Rate(players, standings, now)
+			first, last := after[0], after[n-1]
+			if first.Rating <= 1500 || last.Rating >= 1500 {
+				t.Errorf("%s n=%d: winner %.1f, last %.1f", name, n, first.Rating, last.Rating)
+			}
+			for i, a := range after {
+				if a.Deviation >= players[i].Deviation {
+					t.Errorf("%s n=%d: RD should shrink, got %.1f", name, n, a.Deviation)
+				}
+			}
+			spreads = append(spreads, first.Rating-last.Rating)
+		}
+		// 人数が増えても1位と最下位の差は大きく変わらない（相手ごとに1試合として数えると何十倍にもなる）
+		for _, spread := range spreads[1:] {
+			if spread > spreads[0]*1.5 || spread < spreads[0]/1.5 {
+				t.Errorf("%s: spread between first and last should not depend on field size: %v", name, spreads)
+			}
+		}
+	}
+}
+
+func TestRoyaleGlickoDeviationDoesNotCollapseInLargeField(t *testing.T) {
+	players, standings := royaleField(50)
+	weighted := newWeightedRatingEngine("glicko2", royaleWeight).Rate(players, standings, time.Now())
+	unweighted := glicko2Engine{Tau: glickoTau}.Rate(players, standings, time.Now())
+	// 49人それぞれを1試合と数えるとRDは一気に縮むが、2試合分ならほどほどに縮む
+	if weighted[0].Deviation <= unweighted[0].Deviation || weighted[0].Deviation < 150 {
+		t.Errorf("weighted RD %.1f, unweighted RD %.1f", weighted[0].Deviation, unweighted[0].Deviation)
+	}
+}
@@ -0,0 +1,214 @@
+package websocket
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"time"
+)
+
+// ルールとして指定できる範囲
+const (
+	defaultChoiceCount = 4                       // 選択肢の既定の数
+	minChoiceCount     = 2                       // 選択肢の最少数
+	maxChoiceCount     = 6                       // 選択肢の最大数（珍しい言語の音声問題は候補が8言語しかない）
+	minRoundTime       = 3 * time.Second         // 制限時間の最短
+	maxRoundTime       = 60 * time.Second        // 制限時間の最長
+	maxRoundGap        = 10 * time.Second        // ラウンド間の待ち時間の最長
+	audioRoundTime     = 15 * time.Second        // 音声問題の既定の制限時間（聞き終わるまで時間がかかる）
+	audioRoundGap      = 1800 * time.Millisecond // 音声問題の既定のラウンド間隔（前の音声の再生を止める余裕）
+)
+
+// errInvalidRules は範囲外のルールが指定された場合のエラー
+var errInvalidRules = errors.New("invalid match rules")
+
+// MatchRules は1試合の形式（出題数、制限時間、選択肢の数など）
+// サーバー設定からモードとレーティング戦かどうかで決まり、プライベートルームでは作成時に上書きできる
+type MatchRules struct {
+	Rounds            int           // 出題数
+	RoundTime         time.Duration // 1ラウンドの制限時間
+	RoundGap          time.Duration // 結果を見せてから次のラウンドを出すまでの間隔
+	Choices           int           // 選択肢の数
+	SuddenDeathRounds int           // 1位が同点のときに追加する延長戦の最大ラウンド数
+	TeamScoring       string        // チーム戦のラウンドごとの得点の出し方（teamScoringSum / teamScoringBest、個人戦は空）
+	Scoring           scoringCurve  // 回答の速さに応じた得点の減衰カーブ
+}
+
+// matchRulesOverride はルールの一部だけを上書きする指定（未指定の項目は元のまま）
+// サーバー設定（MATCH_RULES）とプライベートルーム作成のペイロードで同じ形を使う
+type matchRulesOverride struct {
+	Rounds            *int    `json:"rounds,omitempty"`
+	RoundMs           *int    `json:"roundMs,omitempty"`
+	RoundGapMs        *int    `json:"roundGapMs,omitempty"`
+	Choices           *int    `json:"choices,omitempty"`
+	SuddenDeathRounds *int    `json:"suddenDeathRounds,omitempty"`
+	TeamScoring       *string `json:"teamScoring,omitempty"` // "sum", "best"（チーム戦だけで使う）
+	MaxPoints         *int    `json:"maxPoints,omitempty"`   // 即答したときの得点
+	MinPoints         *int    `json:"minPoints,omitempty"`   // 制限時間ぎりぎりで正解したときの得点
+	GraceMs           *int    `json:"graceMs,omitempty"`     // 満点がもらえる猶予時間
+	Decay             *string `json:"decay,omitempty"`       // 得点の減り方（"linear", "ease-in", "ease-out"）
+}
+
+// defaultRulesFor はサーバー設定がない場合のモードごとのルールを返す
+func defaultRulesFor(mode string) MatchRules {
+	rules := MatchRules{
+		Rounds:            maxRoundsPerMatch,
+		RoundTime:         roundDuration,
+		Choices:           defaultChoiceCount,
+		SuddenDeathRounds: suddenDeathRounds[mode],
+		Scoring:           scoringCurveFor(mode),
+	}
+	if strings.HasPrefix(mode, "audio-") {
+		rules.RoundTime = audioRoundTime
+		rules.RoundGap = audioRoundGap
+	}
+	return rules
+}
+
+// rulesFor はモードとレーティング戦かどうかからサーバー設定のルールを決める
+// 起動時に読んだ MATCH_RULES（JSON、LoadMatchRules）の次のキーを順に重ねる（後のものほど優先）
+//
+//	"ranked" / "casual"            … 全モード共通
+//	"<mode>"                       … モード別（例: "audio-rare"）
+//	"<mode>:ranked" / "<mode>:casual" … モードとレーティング戦の組み合わせ
+//	"team" / "<mode>:team"           … チーム戦（teamRulesFor で上の設定にさらに重ねる）
+//
+// 例: MATCH_RULES='{"casual":{"rounds":5},"audio-rare:ranked":{"roundMs":20000}}'
+func rulesFor(mode string, rated bool) MatchRules {
+	rules := defaultRulesFor(mode)
+	queue := "casual"
+	if rated {
+		queue = "ranked"
+	}
+	return applyRulesConfig(rules, []string{queue, mode, mode + ":" + queue})
+}
+
+// teamRulesFor はチーム戦のルールを決める（チーム戦は常にレーティング戦）
+// rulesFor のルールに MATCH_RULES の "team" と "<mode>:team" を重ね、得点の出し方の既定は合計にする
+func teamRulesFor(mode string) MatchRules {
+	rules := rulesFor(mode, true)
+	rules.TeamScoring = teamScoringSum
+	return applyRulesConfig(rules, []string{"team", mode + ":team"})
+}
+
+// applyRulesConfig は MATCH_RULES の指定されたキーの上書きを順に重ねる（範囲外の指定はログに出して無視する）
+func applyRulesConfig(rules MatchRules, keys []string) MatchRules {
+	for _, key := range keys {
+		if override, ok := matchRulesConfig[key]; ok {
+			applied, err := override.apply(rules)
+			if err != nil {
+				log.Printf("ignoring MATCH_RULES[%s]: %v", key, err)
+				continue
+			}
+			rules = applied
+		}
+	}
+	return rules
+}
+
+// matchRulesConfig は起動時に読んだ MATCH_RULES（未設定なら空）
+var matchRulesConfig map[string]matchRulesOverride
+
+// LoadMatchRules は環境変数 MATCH_RULES を読んで、以降の試合のルールに使う
+// 起動時に一度だけ呼び、JSONとして読めなければエラーを返す（設定の誤りに起動時に気づけるように）
+func LoadMatchRules() error {
+	config, err := parseMatchRulesConfig(os.Getenv("MATCH_RULES"))
+	if err != nil {
+		return err
+	}
+	matchRulesConfig = config
+	return nil
+}
+
+// parseMatchRulesConfig は MATCH_RULES の値を解釈する（空ならnil）
+func parseMatchRulesConfig(raw string) (map[string]matchRulesOverride, error) {
+	raw = strings.TrimSpace(raw)
+	if raw == "" {
+		return nil, nil
+	}
+	var config map[string]matchRulesOverride
+	if err := json.Unmarshal([]byte(raw), &config); err != nil {
+		return nil, fmt.Errorf("invalid MATCH_RULES: %w", err)
+	}
+	return config, nil
+}
+
+// apply は指定された項目だけを上書きしたルールを返す（範囲外なら errInvalidRules）
+func (o matchRulesOverride) apply(rules MatchRules) (MatchRules, error) {
+	if o.Rounds != nil {
+		rules.Rounds = *o.Rounds
+	}
+	if o.RoundMs != nil {
+		rules.RoundTime = time.Duration(*o.RoundMs) * time.Millisecond
+	}
+	if o.RoundGapMs != nil {
+		rules.RoundGap = time.Duration(*o.RoundGapMs) * time.Millisecond
+	}
+	if o.Choices != nil {
+		rules.Choices = *o.Choices
+	}
+	if o.SuddenDeathRounds != nil {
+		rules.SuddenDeathRounds = *o.SuddenDeathRounds
+	}
+	// 個人戦のルールにチーム戦の指定が来ても使われないだけなので、チーム戦のときだけ反映する
+	if o.TeamScoring != nil && rules.TeamScoring != "" {
+		rules.TeamScoring = *o.TeamScoring
+	}
+	if o.MaxPoints != nil {
+		rules.Scoring.MaxPoints = *o.MaxPoints
+	}
+	if o.MinPoints != nil {
+		rules.Scoring.MinPoints = *o.MinPoints
+	}
+	if o.GraceMs != nil {
+		rules.Scoring.Grace = time.Duration(*o.GraceMs) * time.Millisecond
+	}
+	if o.Decay != nil {
+		rules.Scoring.Decay = *o.Decay
+	}
+	if err := rules.validate(); err != nil {
+		return MatchRules{}, err
+	}
+	return rules, nil
+}
+
+// validate はルールが受け付けられる範囲かを確かめる
+func (r MatchRules) validate() error {
+	switch {
+	case r.Rounds < 1 || r.Rounds > maxPrivateRounds:
+		return errInvalidRounds
+	case r.RoundTime < minRoundTime || r.RoundTime > maxRoundTime:
+		return errInvalidRules
+	case r.RoundGap < 0 || r.RoundGap > maxRoundGap:
+		return errInvalidRules
+	case r.Choices < minChoiceCount || r.Choices > maxChoiceCount:
+		return errInvalidRules
+	case r.SuddenDeathRounds < 0 || r.SuddenDeathRounds > maxPrivateRounds:
+		return errInvalidRules
+	case r.TeamScoring != "" && r.TeamScoring != teamScoringSum && r.TeamScoring != teamScoringBest:
+		return errInvalidRules
+	}
+	return r.Scoring.validate()
+}
+
+// timeLimit は延長戦まで全ラウンドを制限時間いっぱいまで使った場合の試合の長さを返す
+// 切断からの復帰を待つ時間も含める（大会の進行中の締め切りに使う）
+func (r MatchRules) timeLimit() time.Duration {
+	return time.Duration(r.Rounds+r.SuddenDeathRounds)*(r.RoundTime+r.RoundGap) + reconnectGrace
+}
+
+// payload はルールをクライアント送信用の形にする
+func (r MatchRules) payload() rulesPayload {
+	return rulesPayload{
+		Rounds:            r.Rounds,
+		RoundMs:           r.RoundTime.Milliseconds(),
+		RoundGapMs:        r.RoundGap.Milliseconds(),
+		Choices:           r.Choices,
+		SuddenDeathRounds: r.SuddenDeathRounds,
+		TeamScoring:       r.TeamScoring,
+		Scoring:           r.Scoring.payload(),
+	}
+}
@@ -0,0 +1,125 @@
+package websocket
+
+import (
+	"testing"
+	"time"
+)
+
+// useMatchRules はテストの間だけ MATCH_RULES を差し替える
+func useMatchRules(t *testing.T, raw string) {
+	t.Helper()
+	t.Setenv("MATCH_RULES", raw)
+	if err := LoadMatchRules(); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { matchRulesConfig = nil })
+}
+
+func TestLoadMatchRulesRejectsInvalidJSON(t *testing.T) {
+	for _, raw := range []string{`{"casual":`, `[1,2]`, `{"casual":{"rounds":"5"}}`} {
+		t.Setenv("MATCH_RULES", raw)
+		if err := LoadMatchRules(); err == nil {
+			t.Errorf("MATCH_RULES=%s: expected an error", raw)
+		}
+	}
+	t.Setenv("MATCH_RULES", "  ")
+	if err := LoadMatchRules(); err != nil || matchRulesConfig != nil {
+		t.Errorf("empty MATCH_RULES should load no config, got %v (%v)", matchRulesConfig, err)
+	}
+}
+
+func TestRulesForUsesLoadedConfig(t *testing.T) {
+	useMatchRules(t, `{"casual":{"rounds":5},"audio-rare:ranked":{"roundMs":20000,"choices":3},"team":{"teamScoring":"best"}}`)
+
+	if r := rulesFor("text-major", false); r.Rounds != 5 {
+		t.Errorf("casual rounds = %d, want 5", r.Rounds)
+	}
+	if r := rulesFor("text-major", true); r.Rounds != maxRoundsPerMatch {
+		t.Errorf("ranked rounds = %d, want the default %d", r.Rounds, maxRoundsPerMatch)
+	}
+	if r := rulesFor("audio-rare", true); r.RoundTime != 20*time.Second || r.Choices != 3 {
+		t.Errorf("audio-rare ranked = %+v, want 20s and 3 choices", r)
+	}
+	if r := teamRulesFor("text-major"); r.TeamScoring != teamScoringBest {
+		t.Errorf("team scoring = %q, want %q", r.TeamScoring, teamScoringBest)
+	}
+
+	// 読み込んだ後に環境変数が変わっても、起動時の設定のまま
+	t.Setenv("MATCH_RULES", `{"casual":{"rounds":7}}`)
+	if r := rulesFor("text-major", false); r.Rounds != 5 {
+		t.Errorf("rules should not re-read MATCH_RULES, got %d rounds", r.Rounds)
+	}
+}
+
+func TestRulesForIgnoresOutOfRangeOverride(t *testing.T) {
+	useMatchRules(t, `{"ranked":{"choices":9},"text-major":{"rounds":3}}`)
+
+	r := rulesFor("text-major", true)
+	if r.Choices != defaultChoiceCount || r.Rounds != 3 {
+		t.Errorf("got %+v, want default choices and 3 rounds", r)
+	}
+}
+
+func TestScoringCurveDecayShapes(t *testing.T) {
+	limit := 10 * time.Second
+	cases := []struct {
+		decay   string
+		elapsed time.Duration
+		want    int
+	}{
+		{decayLinear, 500 * time.Millisecond, 100},
+		{decayLinear, 5500 * time.Millisecond, 75},
+		{decayLinear, limit, 50},
+		{decayEaseIn, 5500 * time.Millisecond, 87},
+		{decayEaseIn, 3250 * time.Millisecond, 97},
+		{decayEaseOut, 5500 * time.Millisecond, 62},
+		{decayEaseOut, 3250 * time.Millisecond, 78},
+		{decayEaseOut, 11 * time.Second, 50},
+	}
+	for _, tc := range cases {
+		curve := scoringCurve{MaxPoints: 100, MinPoints: 50, Grace: time.Second, Decay: tc.decay}
+		if got := curve.Points(tc.elapsed, limit); got != tc.want {
+			t.Errorf("%s at %v: %d points, want %d", tc.decay, tc.elapsed, got, tc.want)
+		}
+	}
+}
+
+func TestRulesForScoringDefaultsPerMode(t *testing.T) {
+	useMatchRules(t, "")
+	for mode, curve := range modeScoringCurves {
+		if got := rulesFor(mode, true).Scoring; got != curve {
+			t.Errorf("%s: scoring %+v, want the built-in %+v", mode, got, curve)
+		}
+	}
+}
+
+func TestRulesForScoringFromConfig(t *testing.T) {
+	useMatchRules(t, `{"audio-rare":{"maxPoints":200,"minPoints":20,"graceMs":5000,"decay":"ease-out"},"text-major":{"decay":"ease-in"}}`)
+
+	want := scoringCurve{MaxPoints: 200, MinPoints: 20, Grace: 5 * time.Second, Decay: decayEaseOut}
+	if got := rulesFor("audio-rare", false).Scoring; got != want {
+		t.Errorf("audio-rare scoring %+v, want %+v", got, want)
+	}
+	// 指定しなかった項目はモードの既定のまま
+	text := rulesFor("text-major", true).Scoring
+	if text.Decay != decayEaseIn || text.MaxPoints != 100 || text.Grace != time.Second {
+		t.Errorf("text-major scoring %+v", text)
+	}
+	if p := rulesFor("text-major", true).payload().Scoring; p.Decay != decayEaseIn || p.GraceMs != 1000 {
+		t.Errorf("scoring payload %+v", p)
+	}
+}
+
+func TestRulesForIgnoresInvalidScoring(t *testing.T) {
+	for _, raw := range []string{
+		`{"text-major":{"decay":"cubic"}}`,
+		`{"text-major":{"minPoints":150}}`,
+		`{"text-major":{"maxPoints":0}}`,
+		`{"text-major":{"graceMs":-1}}`,
+	} {
+		useMatchRules(t, raw)
+		if got := rulesFor("text-major", true).Scoring; got != scoringCurveFor("text-major") {
+			t.Errorf("MATCH_RULES=%s: scoring %+v, want the default", raw, got)
+		}
+	}
+}
@@ -0,0 +1,377 @@
+package websocket
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"net/http"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/gin-gonic/gin"
+)
+
+// メッセージの向き
+const (
+	directionClient = "client" // クライアント → サーバー
+	directionServer = "server" // サーバー → クライアント
+)
+
+// messageSpec はプロトコルに含まれるメッセージ1種類分の定義
+type messageSpec struct {
+	Type        string
+	Direction   string
+	Description string
+	Payload     any // ペイロードの型のゼロ値（ペイロードなしはnil）
+}
+
+// protocolMessages は WebSocket で送受信する全メッセージの一覧
+// メッセージを追加・変更したらここも更新する（スキーマとテストでの検証はこの一覧から作る）
+var protocolMessages = []messageSpec{
+	{"ping", directionClient, "生存確認（pong が返る）", nil},
+	{"match:join", directionClient, "ランダムマッチの待機キューに入る（team でチーム戦、royale でバトルロイヤルのロビー）", joinPayload{}},
+	{"match:cancel", directionClient, "待機キューから抜ける", nil},
+	{"match:answer", directionClient, "現在のラウンドに回答する", answerPayload{}},
+	{"match:resume", directionClient, "切断した試合に復帰する", resumePayload{}},
+	{"match:rematch", directionClient, "終了した試合の参加者に再戦を申し込む（申し込まれた側は承諾になる）", rematchPayload{}},
+	{"match:rematch_decline", directionClient, "再戦を断る", rematchPayload{}},
+	{"room:create", directionClient, "プライベートルームを作る", createRoomPayload{}},
+	{"room:join", directionClient, "招待コードでプライベートルームに入る", joinRoomPayload{}},
+	{"room:ready", directionClient, "準備完了を切り替える", readyPayload{}},
+	{"room:spectate", directionClient, "進行中の試合を観戦する", spectatePayload{}},
+	{"party:create", directionClient, "チーム戦のパーティーを作る（招待コードが返る）", partyCreatePayload{}},
+	{"party:join", directionClient, "招待コードでパーティーに入る", partyJoinPayload{}},
+	{"party:leave", directionClient, "パーティーから抜ける（リーダーが抜けると解散）", nil},
+	{"tournament:join", directionClient, "大会の現在の対戦に参加する（相手が揃えば試合が始まる）", tournamentJoinPayload{}},
+
+	{"welcome", directionServer, "接続直後にプロトコルバージョンを知らせる", welcomePayload{}},
+	{"pong", directionServer, "ping への応答", nil},
+	{"error", directionServer, "リクエストを処理できなかった理由", errorPayload{}},
+	{"match:queued", directionServer, "待機キューの状況", queuedPayload{}},
+	{"match:cancelled", directionServer, "待機キューから抜けた", queueEndedPayload{}},
+	{"match:queue_timeout", directionServer, "待ち時間の上限を過ぎてキューから外された", queueEndedPayload{}},
+	{"match:preparing", directionServer, "対戦相手が決まり問題を準備している", preparingPayload{}},
+	{"match:started", directionServer, "1ラウンド目の出題", roundPayload{}},
+	{"match:round", directionServer, "2ラウンド目以降の出題", roundPayload{}},
+	{"match:result", directionServer, "ラウンドの結果、または回答を受け付けなかった理由", resultPayload{}},
+	{"match:sudden_death", directionServer, "同点のため延長戦に入る", suddenDeathPayload{}},
+	{"match:finished", directionServer, "試合の最終結果", finishedPayload{}},
+	{"match:rematch_requested", directionServer, "再戦の申し込み・承諾の状況", rematchStatusPayload{}},
+	{"match:rematch_cancelled", directionServer, "再戦が辞退・時間切れで締め切られた", rematchCancelledPayload{}},
+	{"match:resumed", directionServer, "試合に復帰した時点の進行状況", resumedPayload{}},
+	{"match:opponent_disconnected", directionServer, "対戦相手が切断した", connectionPayload{}},
+	{"match:opponent_reconnected", directionServer, "対戦相手が復帰した", connectionPayload{}},
+	{"room:created", directionServer, "プライベートルームを作った", lobbyPayload{}},
+	{"room:updated", directionServer, "プライベートルームの待機状況が変わった", lobbyPayload{}},
+	{"room:closed", directionServer, "ホストが抜けてプライベートルームが閉じた", lobbyPayload{}},
+	{"room:spectating", directionServer, "観戦を始めた時点の進行状況", spectatingPayload{}},
+	{"party:updated", directionServer, "パーティーのメンバーが変わった", partyPayload{}},
+	{"party:closed", directionServer, "リーダーが抜けてパーティーが解散した", partyPayload{}},
+	{"tournament:waiting", directionServer, "大会の対戦相手が来るのを待っている", tournamentWaitingPayload{}},
+	{"royale:lobby", directionServer, "バトルロイヤルのロビーの人数と開始までの秒数が変わった", royaleLobbyPayload{}},
+	{"royale:survivors", directionServer, "バトルロイヤルのラウンドの生存者と脱落者", royaleSurvivorsPayload{}},
+	{"tournament:walkover", directionServer, "大会の対戦相手が締め切りまでに来ず、対戦が終わった", tournamentWalkoverPayload{}},
+}
+
+// protocolSchema は Go の構造体から生成した JSON Schema
+type protocolSchema struct {
+	doc      map[string]any            // 配布用のスキーマ全体（draft 2020-12）
+	messages map[string]map[string]any // メッセージ種別 → そのメッセージのスキーマ
+}
+
+var (
+	schemaOnce   sync.Once
+	cachedSchema *protocolSchema
+)
+
+// currentSchema は生成済みのスキーマを返す（初回だけ生成する）
+func currentSchema() *protocolSchema {
+	schemaOnce.Do(func() {
+		cachedSchema = buildProtocolSchema()
+	})
+	return cachedSchema
+}
+
+// ProtocolSchema は WebSocket プロトコルの JSON Schema を返す
+// GET /ws/schema で呼ばれる
+func ProtocolSchema(c *gin.Context) {
+	c.JSON(http.StatusOK, currentSchema().doc)
+}
+
+// ProtocolSchemaJSON はスキーマを整形した JSON で返す（ファイルへの書き出し用）
+func ProtocolSchemaJSON() ([]byte, error) {
+	return json.MarshalIndent(currentSchema().doc, "", "  ")
+}
+
+// buildProtocolSchema は protocolMessages から全メッセージのスキーマを組み立てる
+// 各メッセージは type の値で区別し、ペイロードの構造体は $defs にまとめて参照する
+func buildProtocolSchema() *protocolSchema {
+	defs := map[string]any{}
+	messages := make(map[string]map[string]any, len(protocolMessages))
+	variants := make([]any, 0, len(protocolMessages))
+	for _, spec := range protocolMessages {
+		properties := map[string]any{
+			"type": map[string]any{"const": spec.Type},
+		}
+		required := []any{"type"}
+		if spec.Payload != nil {
+			properties["payload"] = schemaForType(reflect.TypeOf(spec.Payload), defs)
+			required = append(required, "payload")
+		}
+		message := map[string]any{
+			"title":                spec.Type,
+			"description":          spec.Description,
+			"x-direction":          spec.Direction,
+			"type":                 "object",
+			"properties":           properties,
+			"required":             required,
+			"additionalProperties": false,
+		}
+		messages[spec.Type] = message
+		variants = append(variants, message)
+	}
+
+	doc := map[string]any{
+		"$schema":            "https://json-schema.org/draft/2020-12/schema",
+		"$id":                fmt.Sprintf("urn:guess-this-language:ws:v%d", protocolVersion),
+		"title":              "Guess This Language WebSocket protocol",
+		"protocolVersion":    protocolVersion,
+		"minProtocolVersion": minProtocolVersion,
+		"oneOf":              variants,
+		"$defs":              defs,
+	}
+	return &protocolSchema{doc: doc, messages: messages}
+}
+
+// schemaForType は Go の型を JSON Schema に変換する（encoding/json と同じ規則で出力される形）
+// 名前付きの構造体は defs に登録して $ref で参照する
+func schemaForType(t reflect.Type, defs map[string]any) map[string]any {
+	switch t {
+	case reflect.TypeOf(json.RawMessage{}):
+		return map[string]any{}
+	case reflect.TypeOf(errorCode("")):
+		codes := make([]any, 0, len(knownErrorCodes))
+		for _, code := range knownErrorCodes {
+			codes = append(codes, string(code))
+		}
+		return map[string]any{"type": "string", "enum": codes}
+	}
+
+	switch t.Kind() {
+	case reflect.Pointer:
+		return nullable(schemaForType(t.Elem(), defs))
+	case reflect.String:
+		return map[string]any{"type": "string"}
+	case reflect.Bool:
+		return map[string]any{"type": "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return map[string]any{"type": "integer"}
+	case reflect.Float32, reflect.Float64:
+		return map[string]any{"type": "number"}
+	case reflect.Slice, reflect.Array:
+		// nil のスライスは null になる
+		return nullable(map[string]any{"type": "array", "items": schemaForType(t.Elem(), defs)})
+	case reflect.Map:
+		return nullable(map[string]any{"type": "object", "additionalProperties": schemaForType(t.Elem(), defs)})
+	case reflect.Struct:
+		name := t.Name()
+		if _, ok := defs[name]; !ok {
+			defs[name] = map[string]any{} // 再帰的な参照に備えて先に登録しておく
+			defs[name] = structSchema(t, defs)
+		}
+		return map[string]any{"$ref": "#/$defs/" + name}
+	default:
+		return map[string]any{}
+	}
+}
+
+// structSchema は構造体のフィールドを properties に並べる（omitempty のないフィールドは必須）
+func structSchema(t reflect.Type, defs map[string]any) map[string]any {
+	properties := map[string]any{}
+	required := []any{}
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if !field.IsExported() {
+			continue
+		}
+		name, opts, _ := strings.Cut(field.Tag.Get("json"), ",")
+		if name == "-" {
+			continue
+		}
+		if name == "" {
+			name = field.Name
+		}
+		properties[name] = schemaForType(field.Type, defs)
+		if !strings.Contains(","+opts+",", ",omitempty,") {
+			required = append(required, name)
+		}
+	}
+	return map[string]any{
+		"type":                 "object",
+		"properties":           properties,
+		"required":             required,
+		"additionalProperties": false,
+	}
+}
+
+// nullable は null も受け付けるスキーマにする
+func nullable(schema map[string]any) map[string]any {
+	if typ, ok := schema["type"].(string); ok {
+		out := make(map[string]any, len(schema))
+		for k, v := range schema {
+			out[k] = v
+		}
+		out["type"] = []any{typ, "null"}
+		return out
+	}
+	return map[string]any{"anyOf": []any{schema, map[string]any{"type": "null"}}}
+}
+
+// validateMessage は送信するメッセージがスキーマに合っているかを検査する（schema_test.go で使う）
+// スキーマ生成で使うキーワード（type, const, enum, properties, required, additionalProperties, items, $ref, anyOf）だけを扱う
+func validateMessage(msg wsMessage) error {
+	schema := currentSchema()
+	message, ok := schema.messages[msg.Type]
+	if !ok {
+		return fmt.Errorf("unknown message type %q", msg.Type)
+	}
+	raw, err := json.Marshal(msg)
+	if err != nil {
+		return err
+	}
+	var value any
+	if err := json.Unmarshal(raw, &value); err != nil {
+		return err
+	}
+	return validateValue(message, value, schema.doc["$defs"].(map[string]any), msg.Type)
+}
+
+// validateValue はスキーマ1つ分の検査を行い、最初に見つかった違反を返す
+func validateValue(schema map[string]any, value any, defs map[string]any, path string) error {
+	if ref, ok := schema["$ref"].(string); ok {
+		def, _ := defs[strings.TrimPrefix(ref, "#/$defs/")].(map[string]any)
+		if def == nil {
+			return fmt.Errorf("%s: unresolved %s", path, ref)
+		}
+		return validateValue(def, value, defs, path)
+	}
+	if anyOf, ok := schema["anyOf"].([]any); ok {
+		var firstErr error
+		for _, option := range anyOf {
+			err := validateValue(option.(map[string]any), value, defs, path)
+			if err == nil {
+				return nil
+			}
+			if firstErr == nil {
+				firstErr = err
+			}
+		}
+		return firstErr
+	}
+	if want, ok := schema["const"]; ok && value != want {
+		return fmt.Errorf("%s: must be %v", path, want)
+	}
+	if enum, ok := schema["enum"].([]any); ok {
+		found := false
+		for _, v := range enum {
+			if v == value {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return fmt.Errorf("%s: %v is not allowed", path, value)
+		}
+	}
+	if typ, ok := schema["type"]; ok && !matchesType(typ, value) {
+		return fmt.Errorf("%s: expected %v", path, typ)
+	}
+
+	switch v := value.(type) {
+	case map[string]any:
+		properties, _ := schema["properties"].(map[string]any)
+		required, _ := schema["required"].([]any)
+		for _, name := range required {
+			if _, ok := v[name.(string)]; !ok {
+				return fmt.Errorf("%s: missing %s", path, name)
+			}
+		}
+		keys := make([]string, 0, len(v))
+		for key := range v {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			child := path + "." + key
+			if prop, ok := properties[key].(map[string]any); ok {
+				if err := validateValue(prop, v[key], defs, child); err != nil {
+					return err
+				}
+				continue
+			}
+			switch extra := schema["additionalProperties"].(type) {
+			case bool:
+				if !extra {
+					return fmt.Errorf("%s: unexpected property", child)
+				}
+			case map[string]any:
+				if err := validateValue(extra, v[key], defs, child); err != nil {
+					return err
+				}
+			}
+		}
+	case []any:
+		if items, ok := schema["items"].(map[string]any); ok {
+			for i, item := range v {
+				if err := validateValue(items, item, defs, fmt.Sprintf("%s[%d]", path, i)); err != nil {
+					return err
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// matchesType は値が type キーワード（単一または配列）のいずれかに当てはまるかを返す
+func matchesType(typ any, value any) bool {
+	types, ok := typ.([]any)
+	if !ok {
+		types = []any{typ}
+	}
+	for _, t := range types {
+		switch t {
+		case "null":
+			if value == nil {
+				return true
+			}
+		case "object":
+			if _, ok := value.(map[string]any); ok {
+				return true
+			}
+		case "array":
+			if _, ok := value.([]any); ok {
+				return true
+			}
+		case "string":
+			if _, ok := value.(string); ok {
+				return true
+			}
+		case "boolean":
+			if _, ok := value.(bool); ok {
+				return true
+			}
+		case "number":
+			if _, ok := value.(float64); ok {
+				return true
+			}
+		case "integer":
+			if f, ok := value.(float64); ok && f == math.Trunc(f) {
+				return true
+			}
+		}
+	}
+	return false
+}
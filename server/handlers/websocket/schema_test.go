@@ -0,0 +1,399 @@
+package websocket
+
+import (
+	"encoding/json"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// sampleServerPayloads はサーバーが送る全メッセージの、値を埋めたペイロードの例
+// protocolMessages にサーバーからのメッセージを足したらここにも足す（足さないと TestServerMessageSamplesMatchSchema が落ちる）
+var sampleServerPayloads = map[string]any{
+	"welcome": welcomePayload{ProtocolVersion: protocolVersion, MinProtocolVersion: minProtocolVersion},
+	"pong":    nil,
+	"error":   errorPayload{Code: codeRoomNotFound, Message: "room not found"},
+	"match:queued": queuedPayload{
+		Mode: "text-major", Position: 1, QueueSize: 2, Rating: 1200, SearchWindow: 100, EstimatedWaitSeconds: 15, Team: true,
+	},
+	"match:cancelled":     queueEndedPayload{Mode: "text-major"},
+	"match:queue_timeout": queueEndedPayload{Mode: "text-major", WaitedSeconds: 120},
+	"match:preparing":     preparingPayload{Status: "generating"},
+	"match:started":       sampleRoundPayload(1),
+	"match:round":         sampleRoundPayload(2),
+	"match:result": resultPayload{
+		RoomID:     "room-1",
+		Status:     "correct",
+		Round:      1,
+		Scores:     map[string]int{"alice": 95, "bob": 0},
+		Answers:    map[string]string{"alice": "Deutsch", "bob": ""},
+		Correct:    map[string]bool{"alice": true, "bob": false},
+		Answer:     "Deutsch",
+		Points:     map[string]int{"alice": 95},
+		ResponseMs: map[string]int64{"alice": 1200},
+		Teams:      sampleTeams(),
+	},
+	"match:sudden_death": suddenDeathPayload{RoomID: "room-1", Round: 4},
+	"match:finished": finishedPayload{
+		RoomID: "room-1",
+		Winner: "alice",
+		Scores: map[string]int{"alice": 250, "bob": 120},
+		Status: "victory",
+		Reason: "completed",
+		Recap:  []recapItem{{Round: "1", Prompt: "Guten Tag", Winner: "alice", Status: "correct"}},
+		Standings: []standingItem{
+			{Rank: 1, Username: "alice", Score: 250, Team: "red"},
+			{Rank: 2, Username: "bob", Score: 120, Forfeited: true, Bot: true, EliminatedRound: 2},
+		},
+		Ratings:        map[string]int{"alice": 1216},
+		Deltas:         map[string]int{"alice": 16},
+		Series:         &seriesPayload{BestOf: 3, Game: 2, Wins: map[string]int{"alice": 2}, Winner: "alice"},
+		RematchSeconds: 30,
+		Teams:          sampleTeams(),
+	},
+	"match:rematch_requested": rematchStatusPayload{RoomID: "room-1", BestOf: 3, Accepted: []string{"alice"}, ExpiresInSeconds: 30},
+	"match:rematch_cancelled": rematchCancelledPayload{RoomID: "room-1", Reason: "declined", Username: "bob"},
+	"match:resumed": resumedPayload{
+		RoomID:      "room-1",
+		Mode:        "text-major",
+		Opponent:    "bob",
+		Opponents:   []opponentInfo{{Username: "bob", ImageURL: "/images/bob.png"}},
+		Question:    &questionPayload{ID: 7, Prompt: "Guten Tag", Choices: []string{"Deutsch", "Nederlands"}},
+		Round:       2,
+		TotalRounds: 3,
+		Scores:      map[string]int{"alice": 95},
+		RemainingMs: 4000,
+		MyAnswer:    "Deutsch",
+		Teams:       sampleTeams(),
+	},
+	"match:opponent_disconnected": connectionPayload{RoomID: "room-1", Username: "bob", GraceSeconds: 30},
+	"match:opponent_reconnected":  connectionPayload{RoomID: "room-1", Username: "bob"},
+	"room:created":                sampleLobbyPayload(),
+	"room:updated":                sampleLobbyPayload(),
+	"room:closed":                 sampleLobbyPayload(),
+	"room:spectating": spectatingPayload{
+		RoomID:      "room-1",
+		Mode:        "audio-rare",
+		Players:     []opponentInfo{{Username: "alice"}, {Username: "bob"}},
+		Question:    &questionPayload{ID: 7, Prompt: "listen", AudioURL: "/audio/7.mp3"},
+		Round:       1,
+		TotalRounds: 3,
+		Scores:      map[string]int{"alice": 0, "bob": 0},
+		RemainingMs: 8000,
+	},
+	"party:updated":       partyPayload{Code: "ABC123", Leader: "alice", Members: []string{"alice", "bob"}},
+	"party:closed":        partyPayload{Code: "ABC123", Leader: "alice", Members: []string{"alice"}},
+	"tournament:waiting":  tournamentWaitingPayload{TournamentID: 1, Round: 2, RoomID: "room-1", Opponent: "bob", DeadlineInSeconds: 300},
+	"tournament:walkover": tournamentWalkoverPayload{TournamentID: 1, Round: 2, RoomID: "room-1"},
+	"royale:lobby":        royaleLobbyPayload{RoomID: "room-1", Mode: "text-major", Players: 5, MinPlayers: 4, MaxPlayers: 16, StartsInSeconds: 10},
+	"royale:survivors":    royaleSurvivorsPayload{RoomID: "room-1", Round: 2, Survivors: []string{"alice"}, Eliminated: []string{"bob"}, Remaining: 1},
+}
+
+// sampleRoundPayload は出題のペイロードの例を作る
+func sampleRoundPayload(round int) roundPayload {
+	return roundPayload{
+		RoomID:           "room-1",
+		Opponent:         "bob",
+		OpponentImageURL: "/images/bob.png",
+		Opponents:        []opponentInfo{{Username: "bob", ImageURL: "/images/bob.png"}},
+		Question:         questionPayload{ID: 7, Prompt: "Guten Tag", Choices: []string{"Deutsch", "Nederlands"}},
+		Round:            round,
+		TotalRounds:      3,
+		TimeLimitMs:      10000,
+		Scores:           map[string]int{"alice": 0, "bob": 0},
+		Series:           &seriesPayload{BestOf: 3, Game: 1, Wins: map[string]int{}},
+		Teams:            sampleTeams(),
+	}
+}
+
+// sampleLobbyPayload はプライベートルームの待機状況の例を作る
+func sampleLobbyPayload() lobbyPayload {
+	return lobbyPayload{
+		RoomID:     "room-1",
+		Code:       "ABC123",
+		Mode:       "text-major",
+		Rounds:     3,
+		Rated:      true,
+		Host:       "alice",
+		MinPlayers: 2,
+		MaxPlayers: 4,
+		Players:    []lobbyPlayer{{Username: "alice", Ready: true}, {Username: "bob", ImageURL: "/images/bob.png"}},
+		Rules:      rulesPayload{Rounds: 3, RoundMs: 10000, RoundGapMs: 2000, Choices: 4, SuddenDeathRounds: 3, TeamScoring: "sum", Scoring: scoringPayload{MaxPoints: 100, MinPoints: 50, GraceMs: 1000, Decay: "linear"}},
+	}
+}
+
+// sampleTeams はチーム戦のチームの例を作る
+func sampleTeams() []teamPayload {
+	return []teamPayload{
+		{Name: "red", Members: []string{"alice", "carol"}, Score: 95},
+		{Name: "blue", Members: []string{"bob", "dave"}, Score: 0},
+	}
+}
+
+// sampleMessage はペイロードの例からメッセージを組み立てる（ペイロードなしは省略する）
+func sampleMessage(msgType string, payload any) wsMessage {
+	msg := wsMessage{Type: msgType}
+	if payload != nil {
+		msg.Payload = mustJSON(payload)
+	}
+	return msg
+}
+
+func TestServerMessageSamplesMatchSchema(t *testing.T) {
+	for _, spec := range protocolMessages {
+		if spec.Direction != directionServer {
+			continue
+		}
+		payload, ok := sampleServerPayloads[spec.Type]
+		if !ok {
+			t.Errorf("%s: no sample payload", spec.Type)
+			continue
+		}
+		if reflect.TypeOf(payload) != reflect.TypeOf(spec.Payload) {
+			t.Errorf("%s: sample is %T, schema declares %T", spec.Type, payload, spec.Payload)
+			continue
+		}
+		if err := validateMessage(sampleMessage(spec.Type, payload)); err != nil {
+			t.Errorf("%s: %v", spec.Type, err)
+		}
+		// nil のスライスや省略できる項目を空にした形も受け付ける（エラーコードは一覧の値しか使えないので除く）
+		if spec.Payload != nil && spec.Type != "error" {
+			if err := validateMessage(sampleMessage(spec.Type, spec.Payload)); err != nil {
+				t.Errorf("%s (zero value): %v", spec.Type, err)
+			}
+		}
+	}
+	for msgType := range sampleServerPayloads {
+		if spec, ok := findMessageSpec(msgType); !ok || spec.Direction != directionServer {
+			t.Errorf("%s: sample for a message the server does not send", msgType)
+		}
+	}
+}
+
+func TestValidateMessageRejectsMismatches(t *testing.T) {
+	cases := []struct {
+		name string
+		msg  wsMessage
+	}{
+		{"unknown type", wsMessage{Type: "match:unknown"}},
+		{"missing payload", wsMessage{Type: "match:finished"}},
+		{"wrong payload type", sampleMessage("match:result", errorPayload{Code: codeRoomNotFound, Message: "x"})},
+		{"unknown error code", sampleMessage("error", errorPayload{Code: "teapot", Message: "x"})},
+		{"extra property", wsMessage{Type: "match:sudden_death", Payload: json.RawMessage(`{"roomId":"r","round":4,"extra":true}`)}},
+		{"wrong field type", wsMessage{Type: "match:sudden_death", Payload: json.RawMessage(`{"roomId":"r","round":"4"}`)}},
+		{"fractional integer", wsMessage{Type: "match:sudden_death", Payload: json.RawMessage(`{"roomId":"r","round":4.5}`)}},
+		{"nested mismatch", wsMessage{Type: "party:updated", Payload: json.RawMessage(`{"code":"c","leader":"l","members":[1]}`)}},
+	}
+	for _, tc := range cases {
+		if err := validateMessage(tc.msg); err == nil {
+			t.Errorf("%s: expected a violation", tc.name)
+		}
+	}
+}
+
+// TestSentMessagesUseDeclaredPayloads はパッケージのソースにある送信箇所を読み、
+// メッセージ種別が schema.go に載っていて、ペイロードの型が一覧の定義と同じかを確かめる
+func TestSentMessagesUseDeclaredPayloads(t *testing.T) {
+	fset := token.NewFileSet()
+	files, err := filepath.Glob("*.go")
+	if err != nil {
+		t.Fatal(err)
+	}
+	literals := map[string]bool{}
+	for _, name := range files {
+		if strings.HasSuffix(name, "_test.go") {
+			continue
+		}
+		file, err := parser.ParseFile(fset, name, nil, 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+		ast.Inspect(file, func(n ast.Node) bool {
+			switch n := n.(type) {
+			case *ast.BasicLit:
+				if n.Kind == token.STRING {
+					if s, err := strconv.Unquote(n.Value); err == nil {
+						literals[s] = true
+					}
+				}
+			case *ast.CompositeLit:
+				if ident, ok := n.Type.(*ast.Ident); ok && ident.Name == "wsMessage" {
+					checkSentMessage(t, fset, n)
+				}
+			}
+			return true
+		})
+	}
+
+	// 一覧に載っているのにどこからも送っていないメッセージがないか
+	for _, spec := range protocolMessages {
+		if spec.Direction == directionServer && !literals[spec.Type] {
+			t.Errorf("%s is declared but never sent", spec.Type)
+		}
+	}
+}
+
+// checkSentMessage は wsMessage{Type: "...", Payload: mustJSON(T{...})} の形の送信箇所を一覧と突き合わせる
+// 種別やペイロードを変数で渡している箇所は、種別の文字列だけを TestSentMessagesUseDeclaredPayloads で確かめる
+func checkSentMessage(t *testing.T, fset *token.FileSet, lit *ast.CompositeLit) {
+	t.Helper()
+	var msgType string
+	var payload ast.Expr
+	for _, elt := range lit.Elts {
+		kv, ok := elt.(*ast.KeyValueExpr)
+		if !ok {
+			continue
+		}
+		switch key := kv.Key.(*ast.Ident); {
+		case key == nil:
+		case key.Name == "Type":
+			if s, ok := kv.Value.(*ast.BasicLit); ok {
+				msgType, _ = strconv.Unquote(s.Value)
+			}
+		case key.Name == "Payload":
+			payload = kv.Value
+		}
+	}
+	if msgType == "" {
+		return
+	}
+	pos := fset.Position(lit.Pos())
+	spec, ok := findMessageSpec(msgType)
+	if !ok || spec.Direction != directionServer {
+		t.Errorf("%s: sends %q, which is not a server message in schema.go", pos, msgType)
+		return
+	}
+	if payload == nil {
+		if spec.Payload != nil {
+			t.Errorf("%s: %s is sent without a payload", pos, msgType)
+		}
+		return
+	}
+	call, ok := payload.(*ast.CallExpr)
+	if !ok || len(call.Args) != 1 {
+		return
+	}
+	arg, ok := call.Args[0].(*ast.CompositeLit)
+	if !ok {
+		return
+	}
+	ident, ok := arg.Type.(*ast.Ident)
+	if !ok {
+		return
+	}
+	if spec.Payload == nil || reflect.TypeOf(spec.Payload).Name() != ident.Name {
+		t.Errorf("%s: %s is sent with %s, schema declares %T", pos, msgType, ident.Name, spec.Payload)
+	}
+}
+
+// findMessageSpec はメッセージ種別の定義を探す
+func findMessageSpec(msgType string) (messageSpec, bool) {
+	for _, spec := range protocolMessages {
+		if spec.Type == msgType {
+			return spec, true
+		}
+	}
+	return messageSpec{}, false
+}
+
+// schemaRecorder は届いたメッセージをスキーマで検査し、種別ごとに記録する
+type schemaRecorder struct {
+	mu         sync.Mutex
+	seen       map[string]bool
+	violations []string
+}
+
+// check は simConn に渡す検査（違反は後でまとめて報告する）
+func (s *schemaRecorder) check(msg wsMessage) {
+	err := validateMessage(msg)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.seen[msg.Type] = true
+	if err != nil {
+		s.violations = append(s.violations, err.Error())
+	}
+}
+
+// waitFor は指定した種別のメッセージが届くまで待つ
+func (s *schemaRecorder) waitFor(t *testing.T, msgType string) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		s.mu.Lock()
+		ok := s.seen[msgType]
+		s.mu.Unlock()
+		if ok {
+			return
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	t.Fatalf("%s was not sent", msgType)
+}
+
+// TestMatchMessagesConformToSchema は実際に試合を進め、プレイヤー・観戦者・復帰した接続に届いた全メッセージを検査する
+func TestMatchMessagesConformToSchema(t *testing.T) {
+	useSimulatedMatches(t)
+	rec := &schemaRecorder{seen: map[string]bool{}}
+	newChecked := func(username string) (*client, *simConn) {
+		c, conn := newSimClient(username)
+		conn.check = rec.check
+		return c, conn
+	}
+
+	a, aConn := newChecked("alice")
+	b, bConn := newChecked("bob")
+	bConn.idle = true // b が回答しないので、各ラウンドは制限時間まで続く
+	defer a.close()
+	if _, _, err := state.Join(a, "text-major"); err != nil {
+		t.Fatal(err)
+	}
+	r, _, err := state.Join(b, "text-major")
+	if err != nil || r == nil {
+		t.Fatalf("expected a room, got %v (%v)", r, err)
+	}
+	r.rules.RoundTime = 500 * time.Millisecond
+	r.rules.RoundGap = 0
+	go startMatch(r)
+	rec.waitFor(t, "match:started")
+
+	viewer, _ := newChecked("viewer")
+	defer state.RemoveClient(viewer)
+	handleSpectate(viewer, mustJSON(spectatePayload{RoomID: r.id}))
+	handleSpectate(viewer, json.RawMessage(`{}`))
+
+	// b が切断し、同じユーザーの新しい接続で復帰する（handleResume と同じメッセージを送る）
+	state.RemoveClient(b)
+	b.close()
+	rec.waitFor(t, "match:opponent_disconnected")
+	resumed, _ := newChecked("bob")
+	defer resumed.close()
+	if rr, err := state.Resume(resumed); err == nil {
+		resumed.send(wsMessage{Type: "match:resumed", Payload: mustJSON(rr.resumeSnapshot(resumed))})
+	} else if err != errNoHeldSeat {
+		t.Fatalf("resume: %v", err)
+	}
+
+	select {
+	case <-aConn.finished:
+	case <-time.After(10 * time.Second):
+		t.Fatal("match did not finish")
+	}
+
+	rec.mu.Lock()
+	defer rec.mu.Unlock()
+	for _, v := range rec.violations {
+		t.Error(v)
+	}
+	for _, msgType := range []string{"match:preparing", "match:started", "match:result", "match:finished", "room:spectating", "error"} {
+		if !rec.seen[msgType] {
+			t.Errorf("%s was not observed", msgType)
+		}
+	}
+}
@@ -0,0 +1,90 @@
+package websocket
+
+import (
+	"math"
+	"time"
+)
+
+// 得点の減り方（MATCH_RULES の decay で指定する）
+const (
+	decayLinear  = "linear"   // 制限時間に向けて一定の割合で減る
+	decayEaseIn  = "ease-in"  // 初めはゆっくり減り、制限時間が近づくほど大きく減る
+	decayEaseOut = "ease-out" // 初めに大きく減り、制限時間が近づくとゆっくり減る
+)
+
+// maxScoringPoints は1問の得点として指定できる上限
+const maxScoringPoints = 1000
+
+// scoringCurve は回答の速さに応じた得点の減衰カーブ
+// Grace 以内の正解は満点、そこから制限時間に向けて Decay の形で MinPoints まで減る
+type scoringCurve struct {
+	MaxPoints int           // 即答したときの得点
+	MinPoints int           // 制限時間ぎりぎりで正解したときの得点
+	Grace     time.Duration // 満点がもらえる猶予時間
+	Decay     string        // 減り方（decayLinear / decayEaseIn / decayEaseOut）
+}
+
+// defaultScoringCurve はモード別の既定がない場合のカーブ
+var defaultScoringCurve = scoringCurve{MaxPoints: 100, MinPoints: 50, Grace: 1 * time.Second, Decay: decayLinear}
+
+// modeScoringCurves はモードごとの既定の得点カーブ（MATCH_RULES で上書きできる）
+// 音声問題は聞き終わるまで時間がかかるので満点の猶予を長めにとる
+var modeScoringCurves = map[string]scoringCurve{
+	"text-major":  {MaxPoints: 100, MinPoints: 50, Grace: 1 * time.Second, Decay: decayLinear},
+	"text-rare":   {MaxPoints: 100, MinPoints: 50, Grace: 2 * time.Second, Decay: decayLinear},
+	"audio-major": {MaxPoints: 100, MinPoints: 50, Grace: 3 * time.Second, Decay: decayLinear},
+	"audio-rare":  {MaxPoints: 100, MinPoints: 50, Grace: 4 * time.Second, Decay: decayLinear},
+}
+
+// scoringCurveFor はモードの既定の得点カーブを返す（試合ではルールの Scoring を使う）
+func scoringCurveFor(mode string) scoringCurve {
+	if curve, ok := modeScoringCurves[mode]; ok {
+		return curve
+	}
+	return defaultScoringCurve
+}
+
+// validate は得点カーブが受け付けられる範囲かを確かめる
+func (c scoringCurve) validate() error {
+	switch {
+	case c.MaxPoints < 1 || c.MaxPoints > maxScoringPoints:
+		return errInvalidRules
+	case c.MinPoints < 0 || c.MinPoints > c.MaxPoints:
+		return errInvalidRules
+	case c.Grace < 0 || c.Grace > maxRoundTime:
+		return errInvalidRules
+	case c.Decay != decayLinear && c.Decay != decayEaseIn && c.Decay != decayEaseOut:
+		return errInvalidRules
+	}
+	return nil
+}
+
+// Points は正解までの経過時間と制限時間から得点を計算する
+// 例: 10秒制限・猶予1秒・100〜50点・linear なら、1秒で100点、5.5秒で75点、10秒で50点
+// 同じ条件で ease-in なら5.5秒で87点、ease-out なら5.5秒で62点
+func (c scoringCurve) Points(elapsed, limit time.Duration) int {
+	if elapsed <= c.Grace || limit <= c.Grace {
+		return c.MaxPoints
+	}
+	if elapsed >= limit {
+		return c.MinPoints
+	}
+	ratio := float64(elapsed-c.Grace) / float64(limit-c.Grace)
+	switch c.Decay {
+	case decayEaseIn:
+		ratio = ratio * ratio
+	case decayEaseOut:
+		ratio = 1 - (1-ratio)*(1-ratio)
+	}
+	return c.MaxPoints - int(math.Round(float64(c.MaxPoints-c.MinPoints)*ratio))
+}
+
+// payload は得点カーブをクライアント送信用の形にする
+func (c scoringCurve) payload() scoringPayload {
+	return scoringPayload{
+		MaxPoints: c.MaxPoints,
+		MinPoints: c.MinPoints,
+		GraceMs:   c.Grace.Milliseconds(),
+		Decay:     c.Decay,
+	}
+}
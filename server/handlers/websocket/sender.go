@@ -3,26 +3,34 @@ package websocket
 import "encoding/json"
 
 // sendRound は各プレイヤーに新ラウンドの問題を送信する
-func sendRound(r *room, roundNum int, scores map[string]int) {
-	for _, p := range r.players {
+// チーム戦では teams にチームごとのメンバーと得点を入れる
+func sendRound(r *room, roundNum int, scores map[string]int, series *seriesPayload, teams []teamPayload) {
+	// 再接続で席が付け替えられることがあるので、参加者と問題はロック中にコピーしてから送る
+	r.mu.Lock()
+	players := append([]*client(nil), r.players...)
+	question := newQuestionPayload(r.question)
+	totalRounds := r.maxRounds
+	r.mu.Unlock()
+
+	for _, p := range players {
 		if p == nil {
 			continue
 		}
-		opponent := r.otherPlayer(p)
 		payload := roundPayload{
-			RoomID:           r.id,
-			Opponent:         opponent.username,
-			OpponentImageURL: opponent.imageURL,
-			Question: questionPayload{
-				ID:       r.question.ID,
-				Prompt:   r.question.Prompt,
-				AudioURL: r.question.AudioURL,
-				Answer:   r.question.Answer,
-				Choices:  r.question.Choices,
-			},
+			RoomID:      r.id,
+			Opponents:   opponentInfos(exclude(players, p)),
+			Question:    question,
 			Round:       roundNum,
-			TotalRounds: r.maxRounds,
+			TotalRounds: totalRounds,
+			TimeLimitMs: r.rules.RoundTime.Milliseconds(),
 			Scores:      scores,
+			Series:      series,
+			Teams:       teams,
+		}
+		// 2人対戦用の従来フィールドには先頭の相手を入れておく
+		if len(payload.Opponents) > 0 {
+			payload.Opponent = payload.Opponents[0].Username
+			payload.OpponentImageURL = payload.Opponents[0].ImageURL
 		}
 
 		event := "match:round"
@@ -30,19 +38,70 @@ func sendRound(r *room, roundNum int, scores map[string]int) {
 			event = "match:started"
 		}
 
-		_ = p.conn.WriteJSON(wsMessage{Type: event, Payload: mustJSON(payload)})
+		p.send(wsMessage{Type: event, Payload: mustJSON(payload)})
+	}
+
+	// 観戦者には全参加者を並べた問題を送る
+	spectators := r.spectatorList()
+	if len(spectators) == 0 {
+		return
+	}
+	event := "match:round"
+	if roundNum == 1 {
+		event = "match:started"
+	}
+	msg := wsMessage{Type: event, Payload: mustJSON(roundPayload{
+		RoomID:      r.id,
+		Opponents:   opponentInfos(players),
+		Question:    question,
+		Round:       roundNum,
+		TotalRounds: totalRounds,
+		TimeLimitMs: r.rules.RoundTime.Milliseconds(),
+		Scores:      scores,
+		Series:      series,
+		Teams:       teams,
+	})}
+	for _, sp := range spectators {
+		sp.send(msg)
 	}
 }
 
-// broadcast はルーム内の全プレイヤーにメッセージを送信する
-func broadcast(r *room, msg wsMessage) {
-	for _, p := range r.players {
-		if p != nil {
-			_ = p.conn.WriteJSON(msg)
-		}
+// opponentInfos は対戦相手の一覧を送信用の形に変換する
+func opponentInfos(players []*client) []opponentInfo {
+	infos := make([]opponentInfo, 0, len(players))
+	for _, p := range players {
+		infos = append(infos, opponentInfo{Username: p.username, ImageURL: p.imageURL})
+	}
+	return infos
+}
+
+// newQuestionPayload は出題中の問題をクライアント送信用の形に変換する
+// 正解はラウンド終了まで送らない（開発者ツールで覗けてしまうため）
+func newQuestionPayload(q *matchQuestion) questionPayload {
+	return questionPayload{
+		ID:       q.ID,
+		Prompt:   q.Prompt,
+		AudioURL: q.AudioURL,
+		Choices:  q.Choices,
 	}
 }
 
+// broadcast はルーム内の全プレイヤーと観戦者にメッセージを送信する
+func broadcast(r *room, msg wsMessage) {
+	fanout(r.playerList(), msg)
+	fanout(r.spectatorList(), msg)
+}
+
+// sendError はクライアントにエラーコードとメッセージを送信する
+func sendError(c *client, code errorCode, message string) {
+	c.send(wsMessage{Type: "error", Payload: mustJSON(errorPayload{Code: code, Message: message})})
+}
+
+// sendErrorFor は状態操作が返したエラーをコード付きで送信する
+func sendErrorFor(c *client, err error) {
+	sendError(c, errorCodeFor(err), err.Error())
+}
+
 // mustJSON は値をJSON RawMessageに変換する（エラーは無視）
 func mustJSON(v any) json.RawMessage {
 	raw, _ := json.Marshal(v)
@@ -0,0 +1,163 @@
+package websocket
+
+import (
+	"encoding/json"
+	"sort"
+	"strings"
+	"time"
+)
+
+// handleSpectate は観戦リクエストを処理する（ルームIDまたは招待コードで指定）
+func handleSpectate(c *client, payload json.RawMessage) {
+	var req spectatePayload
+	if err := json.Unmarshal(payload, &req); err != nil || (req.RoomID == "" && strings.TrimSpace(req.Code) == "") {
+		sendError(c, codeInvalidPayload, "invalid spectate payload")
+		return
+	}
+
+	r, err := state.Spectate(c, req.RoomID, req.Code)
+	if err != nil {
+		sendErrorFor(c, err)
+		return
+	}
+	c.send(wsMessage{Type: "room:spectating", Payload: mustJSON(r.spectatorSnapshot())})
+}
+
+// Spectate はクライアントをルームの観戦者として登録する
+// 公開マッチはルームID、プライベートルームは招待コードで指定する
+func (s *matchState) Spectate(c *client, roomID, code string) (*room, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if c.roomID != "" || c.spectating != "" || s.inQueueLocked(c) {
+		return nil, errAlreadyInRoom
+	}
+
+	var r *room
+	if code != "" {
+		r = s.codes[strings.ToUpper(strings.TrimSpace(code))]
+	} else if found := s.rooms[roomID]; found != nil && found.code == "" {
+		r = found
+	}
+	if r == nil {
+		return nil, errRoomNotFound
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.finished {
+		return nil, errRoomNotFound
+	}
+	r.spectators = append(r.spectators, c)
+	c.spectating = r.id
+	return r, nil
+}
+
+// removeSpectatorLocked は観戦中のクライアントをルームから外す（s.mu を保持して呼ぶ）
+func (s *matchState) removeSpectatorLocked(c *client) {
+	r := s.rooms[c.spectating]
+	c.spectating = ""
+	if r == nil {
+		return
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	remaining := r.spectators[:0]
+	for _, sp := range r.spectators {
+		if sp.id != c.id {
+			remaining = append(remaining, sp)
+		}
+	}
+	r.spectators = remaining
+}
+
+// spectatorSnapshot は観戦開始時に送る現在の進行状況を組み立てる
+func (r *room) spectatorSnapshot() spectatingPayload {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	payload := spectatingPayload{
+		RoomID:      r.id,
+		Mode:        r.mode,
+		Players:     opponentInfos(r.players),
+		Round:       r.round,
+		TotalRounds: r.maxRounds,
+		Scores:      r.scoreSnapshot(),
+	}
+	if r.active && r.question != nil {
+		question := newQuestionPayload(r.question)
+		payload.Question = &question
+		remaining := r.roundLimit - time.Since(r.roundStartedAt)
+		if remaining > 0 {
+			payload.RemainingMs = remaining.Milliseconds()
+		}
+	}
+	return payload
+}
+
+// spectatorList は観戦者一覧のコピーを返す（送信中にロックを持たないため）
+func (r *room) spectatorList() []*client {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	list := make([]*client, len(r.spectators))
+	copy(list, r.spectators)
+	return list
+}
+
+// RunningRooms は進行中の公開マッチを平均レーティングの高い順に返す（modeが空なら全モード）
+// REST の進行中ルーム一覧（GET /rooms）から呼ばれる
+func RunningRooms(mode string) []RoomSummary {
+	return state.RunningRooms(mode)
+}
+
+// RunningRooms は開始済みで終了していない公開マッチの一覧を返す
+// プライベートルームは招待コードを知っている人だけが観戦できるので含めない
+func (s *matchState) RunningRooms(mode string) []RoomSummary {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	summaries := make([]RoomSummary, 0, len(s.rooms))
+	for _, r := range s.rooms {
+		if r.code != "" || (mode != "" && r.mode != mode) {
+			continue
+		}
+		r.mu.Lock()
+		if !r.started || r.finished {
+			r.mu.Unlock()
+			continue
+		}
+		summary := RoomSummary{
+			RoomID:      r.id,
+			Mode:        r.mode,
+			Players:     make([]RoomSummaryPlayer, 0, len(r.players)),
+			Round:       r.round,
+			TotalRounds: r.maxRounds,
+			Spectators:  len(r.spectators),
+		}
+		total := 0
+		for _, p := range r.players {
+			summary.Players = append(summary.Players, RoomSummaryPlayer{
+				Username: p.username,
+				ImageURL: p.imageURL,
+				Rating:   p.rating,
+				Score:    r.scores[p.id],
+			})
+			total += p.rating
+		}
+		if len(r.players) > 0 {
+			summary.AverageRating = total / len(r.players)
+		}
+		r.mu.Unlock()
+		summaries = append(summaries, summary)
+	}
+
+	// 注目のマッチを上に出すため平均レーティングの高い順に並べる
+	sort.Slice(summaries, func(i, j int) bool {
+		if summaries[i].AverageRating != summaries[j].AverageRating {
+			return summaries[i].AverageRating > summaries[j].AverageRating
+		}
+		return summaries[i].RoomID < summaries[j].RoomID
+	})
+	return summaries
+}
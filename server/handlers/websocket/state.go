@@ -1,48 +1,205 @@
 package websocket
 
+import (
+	"sync"
+	"time"
+)
+
 // state はマッチング状態を管理するグローバル変数
-var state = &matchState{
-	waiting: make(map[string]*client),
-	rooms:   make(map[string]*room),
+var state = newMatchState(time.Now)
+
+// matchmakerOnce は定期マッチングのゴルーチンを1度だけ起動するためのもの
+var matchmakerOnce sync.Once
+
+// newMatchState は時刻関数を受け取ってマッチング状態を作る
+func newMatchState(now clock) *matchState {
+	return &matchState{
+		queue: newMatchQueue(now),
+		rooms: make(map[string]*room),
+		codes: make(map[string]*room),
+
+		rematches: make(map[string]*rematchOffer),
+
+		tournamentRooms: make(map[uint]*room),
+
+		teamQueue: newTeamQueue(now),
+		parties:   make(map[string]*party),
+
+		royaleLobbies: make(map[string]*room),
+	}
 }
 
 // Join はクライアントをマッチングキューに参加させる
+// レーティングの近い相手がいればルームを作って返し、いなければ待機させる
+// 既に別のモードやチーム戦のキューで待っている、対戦・観戦中、ロビーにいる場合は errAlreadyInRoom
 func (s *matchState) Join(c *client, mode string) (*room, *client, error) {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
+	if !s.isAvailableLocked(c) {
+		return nil, nil, errAlreadyInRoom
+	}
 	key := mode
 	if key == "" {
 		key = "text-major"
 	}
+	c.mode = key
 
-	if waiting, ok := s.waiting[key]; !ok || waiting == nil {
-		s.waiting[key] = c
+	m := s.queue.Enqueue(c, key, c.rating)
+	if m == nil {
 		return nil, nil, nil
 	}
+	return s.newRoomLocked(*m, true), m.a, nil
+}
 
-	if s.waiting[key].id == c.id {
-		return nil, nil, nil
+// PlayerRoom は指定IDのルームを、クライアントがその参加者である場合だけ返す
+// 観戦者や別ルームのクライアントには errNotRoomPlayer を返す
+func (s *matchState) PlayerRoom(c *client, roomID string) (*room, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	r := s.rooms[roomID]
+	if r == nil {
+		return nil, errRoomNotFound
 	}
+	if c.roomID != r.id {
+		return nil, errNotRoomPlayer
+	}
+	return r, nil
+}
 
-	opponent := s.waiting[key]
-	if opponent != nil && opponent.username != "" && opponent.username == c.username {
-		s.waiting[key] = c
-		return nil, nil, nil
+// QueueStatus はクライアントの待機状況を返す（待機中でなければfalse）
+func (s *matchState) QueueStatus(c *client) (queuedPayload, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.queue.Status(c)
+}
+
+// matchmakerConfig は定期マッチングの設定（起動時に環境変数から読む）
+type matchmakerConfig struct {
+	botAfter     time.Duration // この時間待っても相手がいなければ bot と組ませる（0以下なら bot なし）
+	botRated     bool          // bot 戦をレーティング戦にするか
+	queueTimeout time.Duration // この時間待っても組めなければキューから外す（0以下なら無制限）
+}
+
+// matchmakerConfigFromEnv は環境変数から定期マッチングの設定を読む
+func matchmakerConfigFromEnv() matchmakerConfig {
+	return matchmakerConfig{
+		botAfter:     botQueueTimeoutFromEnv(),
+		botRated:     botMatchesRatedFromEnv(),
+		queueTimeout: queueTimeoutFromEnv(),
+	}
+}
+
+// tickResult は定期マッチング1回分の結果
+type tickResult struct {
+	rooms    []*room        // 新しく成立したルーム
+	notices  []queueNotice  // 順位が変わった待機者への通知
+	timedOut []queueExpired // 待ち時間の上限を超えてキューから外したクライアント
+}
+
+// Tick は待機キューを再評価し、新しく成立したルームと順位変化の通知を返す
+// bot の待ち時間を超えたクライアントはレーティングの近い bot と組ませ、
+// キューの上限を超えたクライアント（bot 無効時など）はキューから外す
+func (s *matchState) Tick(cfg matchmakerConfig) tickResult {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var result tickResult
+	for _, m := range s.queue.Tick() {
+		result.rooms = append(result.rooms, s.newRoomLocked(m, true))
+	}
+	for _, m := range s.teamQueue.Tick() {
+		result.rooms = append(result.rooms, s.newTeamRoomLocked(m))
+	}
+	for _, e := range s.queue.Expire(cfg.botAfter) {
+		s.queue.recordWait(e.mode, e.waited)
+		bot := newBotClient(profileForRating(e.rating), e.mode, nil)
+		r := s.newRoomLocked(queueMatch{mode: e.mode, a: e.client, b: bot}, cfg.botRated)
+		result.rooms = append(result.rooms, r)
+	}
+	// チーム戦には bot を入れないので、キューの上限だけを適用する
+	result.timedOut = append(s.queue.Expire(cfg.queueTimeout), s.teamQueue.Expire(cfg.queueTimeout)...)
+	result.notices = s.queue.Notices()
+	return result
+}
+
+// CancelQueue はクライアントを待機キューから外し、キューから外れた全員を返す（待機中でなければfalse）
+// チーム戦のキューにパーティーで入っていれば、パーティーの全員が外れる
+func (s *matchState) CancelQueue(c *client) ([]*client, queuedPayload, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if status, ok := s.teamQueue.Status(c); ok {
+		return s.teamQueue.Remove(c), status, true
 	}
-	s.waiting[key] = nil
+	status, ok := s.queue.Status(c)
+	if !ok {
+		return nil, queuedPayload{}, false
+	}
+	s.queue.Remove(c)
+	return []*client{c}, status, true
+}
+
+// inQueueLocked はクライアントが個人戦・チーム戦のどちらかの待機キューにいるかを返す（s.mu を保持して呼ぶ）
+func (s *matchState) inQueueLocked(c *client) bool {
+	return s.queue.Contains(c) || s.teamQueue.Contains(c)
+}
 
+// IsIdle はクライアントが待機・対戦・観戦のどれもしていないかを返す
+func (s *matchState) IsIdle(c *client) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return c.roomID == "" && c.spectating == "" && !s.inQueueLocked(c)
+}
+
+// newRoomLocked は成立したマッチからルームを作って登録する（s.mu を保持して呼ぶ）
+// ルールはモードとレーティング戦かどうかからサーバー設定で決める
+func (s *matchState) newRoomLocked(m queueMatch, rated bool) *room {
 	roomID := newRoomID()
 	r := &room{
-		id:      roomID,
-		players: [2]*client{opponent, c},
-		mode:    key,
+		id:         roomID,
+		players:    []*client{m.a, m.b},
+		minPlayers: minRoomPlayers,
+		maxPlayers: minRoomPlayers,
+		mode:       m.mode,
+		rated:      rated,
+		rules:      rulesFor(m.mode, rated),
+		started:    true,
 	}
-	opponent.roomID = roomID
-	c.roomID = roomID
+	m.a.roomID = roomID
+	m.b.roomID = roomID
 	s.rooms[roomID] = r
+	return r
+}
 
-	return r, opponent, nil
+// startMatchmaker は定期マッチングのゴルーチンを起動する（2回目以降は何もしない）
+func startMatchmaker() {
+	matchmakerOnce.Do(func() {
+		go runMatchmaker(state, queueTickInterval)
+	})
+}
+
+// runMatchmaker は一定間隔でキューを再評価し、成立したマッチを開始する
+// 待ち時間が伸びて許容レーティング差が広がった組み合わせや、bot との対戦はここで拾われる
+func runMatchmaker(s *matchState, interval time.Duration) {
+	cfg := matchmakerConfigFromEnv()
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for range ticker.C {
+		result := s.Tick(cfg)
+		for _, e := range result.timedOut {
+			e.client.send(wsMessage{Type: "match:queue_timeout", Payload: mustJSON(queueEndedPayload{
+				Mode:          e.mode,
+				WaitedSeconds: int(e.waited / time.Second),
+			})})
+		}
+		for _, n := range result.notices {
+			n.client.send(wsMessage{Type: "match:queued", Payload: mustJSON(n.payload)})
+		}
+		for _, r := range result.rooms {
+			go startMatch(r)
+		}
+	}
 }
 
 // GetRoom は指定IDのルームを取得する
@@ -56,7 +213,33 @@ func (s *matchState) GetRoom(roomID string) *room {
 func (s *matchState) RemoveRoom(roomID string) {
 	s.mu.Lock()
 	defer s.mu.Unlock()
+	r, ok := s.rooms[roomID]
+	if !ok {
+		return
+	}
+	if r.code != "" {
+		delete(s.codes, r.code)
+	}
 	delete(s.rooms, roomID)
+	if r.tournament != nil && s.tournamentRooms[r.tournament.matchID] == r {
+		delete(s.tournamentRooms, r.tournament.matchID)
+	}
+
+	// 参加者と観戦者を解放して、次のマッチに参加できるようにする
+	for _, p := range r.players {
+		if p != nil && p.roomID == roomID {
+			p.roomID = ""
+		}
+		// bot はこのマッチ専用なので送信ゴルーチンも止める
+		if p != nil && p.bot != nil {
+			p.close()
+		}
+	}
+	for _, sp := range r.spectatorList() {
+		if sp.spectating == roomID {
+			sp.spectating = ""
+		}
+	}
 }
 
 // RemoveClient はクライアントを待機キューまたはルームから削除する
@@ -64,11 +247,14 @@ func (s *matchState) RemoveClient(c *client) {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
-	if c.mode != "" {
-		if waiting, ok := s.waiting[c.mode]; ok && waiting != nil && waiting.id == c.id {
-			s.waiting[c.mode] = nil
-			return
-		}
+	// パーティーから抜ける（チーム戦のキューに入っていればパーティーごと外れる）
+	s.leavePartyLocked(c)
+	if s.queue.Remove(c) || s.teamQueue.Remove(c) != nil {
+		return
+	}
+	if c.spectating != "" {
+		s.removeSpectatorLocked(c)
+		return
 	}
 
 	room, ok := s.rooms[c.roomID]
@@ -76,15 +262,24 @@ func (s *matchState) RemoveClient(c *client) {
 		return
 	}
 
-	delete(s.rooms, c.roomID)
+	// 開始前の大会のルームは席を空けるだけ（参加の記録は残るので締め切りまでに戻ればよい）
+	if room.tournament != nil && !room.started {
+		s.leaveTournamentRoomLocked(room, c)
+		return
+	}
 
-	other := room.otherPlayer(c)
-	if other != nil {
-		_ = other.conn.WriteJSON(wsMessage{Type: "match:ended", Payload: mustJSON(finishedPayload{
-			RoomID: room.id,
-			Scores: room.scoreSnapshot(),
-			Status: "opponent_left",
-		})})
-		other.roomID = ""
+	// 開始前のバトルロイヤルのロビーは席を空けるだけ
+	if room.royale != nil && !room.started {
+		s.leaveRoyaleLobbyLocked(room, c)
+		return
 	}
+
+	// 開始前のプライベートルームは席を空けるだけ（ホストが抜けたらルームごと閉じる）
+	if room.code != "" && !room.started {
+		s.leaveLobbyLocked(room, c)
+		return
+	}
+
+	// 試合中の切断はすぐに没収せず、猶予時間のあいだ席を確保する
+	s.holdSeatLocked(room, c)
 }
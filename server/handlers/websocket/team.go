@@ -0,0 +1,289 @@
+package websocket
+
+import (
+	"errors"
+	"math"
+	"sort"
+	"time"
+
+	"example.com/mathkun-tmp-/server/models"
+	"example.com/mathkun-tmp-/server/repositories"
+	"gorm.io/gorm"
+)
+
+// チーム戦の定数
+const (
+	teamSize        = 2      // 1チームの人数
+	teamRed         = "red"  // 先に待っていた側のチーム名
+	teamBlue        = "blue" // 後から来た側のチーム名
+	teamScoringSum  = "sum"  // メンバー全員の得点を合計する
+	teamScoringBest = "best" // ラウンドごとにメンバーの最高得点だけを数える
+)
+
+// teamNames はチームの並び順
+var teamNames = []string{teamRed, teamBlue}
+
+// isTeamMatch はチーム戦のルームかを返す
+func (r *room) isTeamMatch() bool {
+	return len(r.teams) > 0
+}
+
+// teamMembers はチームに所属する参加者を参加順に返す（r.mu を保持して呼ぶ）
+func (r *room) teamMembers(team string) []*client {
+	members := make([]*client, 0, teamSize)
+	for _, p := range r.players {
+		if p != nil && r.teams[p.username] == team {
+			members = append(members, p)
+		}
+	}
+	return members
+}
+
+// teamSnapshot はチームごとのメンバーと得点を返す（個人戦はnil、r.mu を保持して呼ぶ）
+func (r *room) teamSnapshot() []teamPayload {
+	if !r.isTeamMatch() {
+		return nil
+	}
+	teams := make([]teamPayload, 0, len(teamNames))
+	for _, name := range teamNames {
+		team := teamPayload{Name: name, Members: []string{}, Score: r.teamScores[name]}
+		for _, p := range r.teamMembers(name) {
+			team.Members = append(team.Members, p.username)
+		}
+		teams = append(teams, team)
+	}
+	return teams
+}
+
+// addTeamPoints はラウンドで各メンバーが得た点数（ユーザー名 → 点数）をチームの得点に加える（r.mu を保持して呼ぶ）
+// 合計方式は全員の点数を足し、最高点方式はチームで一番良かった1人の点数だけを数える
+func (r *room) addTeamPoints(points map[string]int) {
+	if r.teamScores == nil {
+		r.teamScores = map[string]int{}
+	}
+	for _, name := range teamNames {
+		gained := 0
+		for _, p := range r.teamMembers(name) {
+			if r.rules.TeamScoring == teamScoringBest {
+				gained = max(gained, points[p.username])
+				continue
+			}
+			gained += points[p.username]
+		}
+		r.teamScores[name] += gained
+	}
+}
+
+// teamForfeited はチームの全員が没収負けになったかを返す（r.mu を保持して呼ぶ）
+func (r *room) teamForfeited(team string) bool {
+	members := r.teamMembers(team)
+	for _, p := range members {
+		if !r.forfeited[p.username] {
+			return false
+		}
+	}
+	return len(members) > 0
+}
+
+// teamStandings はチーム単位の順位を付けた参加者の一覧を返す（r.mu を保持して呼ぶ）
+// 全員が没収負けのチームは最下位、それ以外はチームの得点順で、同点なら同順位
+// 同じチームのメンバーは同じ順位になり、チーム内は個人の得点順に並べる
+func (r *room) teamStandings() []standingItem {
+	type teamRank struct {
+		name      string
+		score     int
+		forfeited bool
+		rank      int
+	}
+	ranks := make([]teamRank, 0, len(teamNames))
+	for _, name := range teamNames {
+		ranks = append(ranks, teamRank{name: name, score: r.teamScores[name], forfeited: r.teamForfeited(name)})
+	}
+	sort.SliceStable(ranks, func(i, j int) bool {
+		if ranks[i].forfeited != ranks[j].forfeited {
+			return !ranks[i].forfeited
+		}
+		return ranks[i].score > ranks[j].score
+	})
+	rankOf := make(map[string]int, len(ranks))
+	for i := range ranks {
+		ranks[i].rank = i + 1
+		if i > 0 && ranks[i].score == ranks[i-1].score && ranks[i].forfeited == ranks[i-1].forfeited {
+			ranks[i].rank = ranks[i-1].rank
+		}
+		rankOf[ranks[i].name] = ranks[i].rank
+	}
+
+	items := make([]standingItem, 0, len(r.players))
+	for _, p := range r.players {
+		if p == nil {
+			continue
+		}
+		team := r.teams[p.username]
+		items = append(items, standingItem{
+			Rank:      rankOf[team],
+			Username:  p.username,
+			Score:     r.scores[p.id],
+			Forfeited: r.forfeited[p.username],
+			Bot:       p.bot != nil,
+			Team:      team,
+		})
+	}
+	sort.SliceStable(items, func(i, j int) bool {
+		if items[i].Rank != items[j].Rank {
+			return items[i].Rank < items[j].Rank
+		}
+		if items[i].Team != items[j].Team {
+			return items[i].Team == teamRed
+		}
+		return items[i].Score > items[j].Score
+	})
+	return items
+}
+
+// activeSideCount は没収負けになっていない陣営の数を返す（個人戦は参加者、チーム戦はチーム、バトルロイヤルは生存者の数）
+// 1つになったら試合を続けられない
+func (r *room) activeSideCount() int {
+	if r.royale != nil {
+		return len(r.royaleSurvivors())
+	}
+	if !r.isTeamMatch() {
+		return r.activePlayerCount()
+	}
+	count := 0
+	for _, name := range teamNames {
+		if !r.teamForfeited(name) {
+			count++
+		}
+	}
+	return count
+}
+
+// sameSide は2人が同じ陣営（同じチーム）かを返す（個人戦では常にfalse）
+func sameSide(a, b standingItem) bool {
+	return a.Team != "" && a.Team == b.Team
+}
+
+// applyTeamRatingForMatch はチーム戦の結果でチーム戦用のレーティングを更新する
+// メンバーごとに、自分のチームの平均レーティングと自分のRD・σで相手チーム（平均の強さ）と対戦したものとして
+// RATING_SYSTEM の方式で計算し、平均からの変動量を自分のレーティングに加える
+// Elo ならメンバー全員に同じだけ加わり、Glicko-2 ならメンバーごとのRDに応じて幅とRD・σが変わる
+// 没収負けになったメンバーはチームの結果によらず負けとして数え、負けの変動量を受ける（個人戦の順位と同じ扱い）
+// 個人戦のレーティング（全モード共通・モード別）は変えない
+func applyTeamRatingForMatch(tx *gorm.DB, mode string, standings []standingItem) (ratingResult, error) {
+	result := ratingResult{
+		Ratings: map[string]int{},
+		Deltas:  map[string]int{},
+	}
+	ratingMode := models.TeamRatingMode(mode)
+	repo := repositories.NewUserRepository(tx)
+	modeRepo := repositories.NewUserModeRatingRepository(tx)
+
+	// チームごとにメンバーのレーティングを集める
+	ratings := make([]*models.UserModeRating, len(standings))
+	snapshots := make([]ratingSnapshot, len(standings))
+	members := map[string][]ratingSnapshot{}
+	ranks := map[string]int{}
+	for i, s := range standings {
+		if s.Team == "" {
+			return result, errors.New("team match participant without team")
+		}
+		u, err := repo.FindByUsername(s.Username)
+		if err != nil {
+			return result, err
+		}
+		if u == nil {
+			return result, errors.New("user not found")
+		}
+		mr, err := modeRepo.FindOrDefault(u.ID, ratingMode)
+		if err != nil {
+			return result, err
+		}
+		ratings[i] = mr
+		snapshots[i] = ratingSnapshot{Rating: float64(mr.Rating), Deviation: mr.RatingDeviation, Volatility: mr.RatingVolatility, RatedAt: mr.RatedAt}
+		members[s.Team] = append(members[s.Team], snapshots[i])
+		ranks[s.Team] = s.Rank
+	}
+	if len(members) < 2 {
+		return result, nil
+	}
+
+	now := time.Now()
+	after := rateTeamMembers(ratingEngineFromEnv(), snapshots, standings, members, ranks, now)
+	for i, s := range standings {
+		mr := ratings[i]
+		switch matchOutcome(standings, s.Username) {
+		case outcomeVictory:
+			mr.Wins++
+		case outcomeDraw:
+			mr.Draws++
+		default:
+			mr.Losses++
+		}
+		newRating := int(math.Round(after[i].Rating))
+		result.Ratings[s.Username] = newRating
+		result.Deltas[s.Username] = newRating - mr.Rating
+		mr.Rating = newRating
+		mr.RatingDeviation = after[i].Deviation
+		mr.RatingVolatility = after[i].Volatility
+		mr.RatedAt = &now
+		if err := modeRepo.Save(mr); err != nil {
+			return result, err
+		}
+	}
+	return result, nil
+}
+
+// rateTeamMembers はチーム戦の各メンバーの新しいレーティング・RD・σを engine で計算する
+// snapshots と standings は同じ並び、members はチームごとのメンバーの状態、ranks はチームの順位
+// メンバーは自分のチームの平均レーティングで、他のチームそれぞれ（teamRatingSnapshot）と対戦したものとする
+// 没収負けのメンバーはどのチームよりも下の順位として計算する
+func rateTeamMembers(engine ratingEngine, snapshots []ratingSnapshot, standings []standingItem, members map[string][]ratingSnapshot, ranks map[string]int, now time.Time) []ratingSnapshot {
+	teams := make(map[string]ratingSnapshot, len(members))
+	for team, snaps := range members {
+		teams[team] = teamRatingSnapshot(snaps, now)
+	}
+
+	result := make([]ratingSnapshot, len(snapshots))
+	for i, s := range standings {
+		self := snapshots[i]
+		average := teams[s.Team].Rating
+		self.Rating = average
+		players := []ratingSnapshot{self}
+		items := []standingItem{{Rank: ranks[s.Team]}}
+		if s.Forfeited {
+			items[0].Rank = len(teams) + 1
+		}
+		for _, name := range teamNames {
+			if name == s.Team {
+				continue
+			}
+			if team, ok := teams[name]; ok {
+				players = append(players, team)
+				items = append(items, standingItem{Rank: ranks[name]})
+			}
+		}
+		rated := engine.Rate(players, items, now)[0]
+		rated.Rating = snapshots[i].Rating + (rated.Rating - average)
+		result[i] = rated
+	}
+	return result
+}
+
+// teamRatingSnapshot はチームのメンバーをまとめた1人分の相手としての状態を返す
+// レーティングとσは平均、RDは現在のRD（対戦しない期間の広がりを含む）の二乗平均の平方根にする
+func teamRatingSnapshot(members []ratingSnapshot, now time.Time) ratingSnapshot {
+	var rating, variance, volatility float64
+	for _, m := range members {
+		deviation := models.CurrentDeviation(m.Deviation, m.Volatility, m.RatedAt, now)
+		rating += m.Rating
+		variance += deviation * deviation
+		volatility += m.Volatility
+	}
+	n := float64(len(members))
+	return ratingSnapshot{
+		Rating:     rating / n,
+		Deviation:  math.Sqrt(variance / n),
+		Volatility: volatility / n,
+	}
+}
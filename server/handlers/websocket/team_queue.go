@@ -0,0 +1,269 @@
+package websocket
+
+import (
+	"math"
+	"sort"
+	"time"
+)
+
+// teamUnit はチーム戦の待機キューの1単位（1人で待つクライアントか、パーティーの全員）
+type teamUnit struct {
+	members  []*client
+	rating   int // メンバーのチーム戦レーティングの平均
+	joinedAt time.Time
+}
+
+// teamQueueMatch はチーム戦の待機キューから成立した1組のマッチ
+type teamQueueMatch struct {
+	mode  string
+	teams [2][]*client // 先に待っていたチームが先頭
+}
+
+// teamCandidate は待機中の単位から組んだチーム候補
+type teamCandidate struct {
+	units    []int // 元になった単位の位置
+	rating   int
+	joinedAt time.Time // メンバーの中で最も早く待ち始めた時刻
+}
+
+// teamQueue はチーム戦の待機キュー
+// パーティーはそのまま1チームになり、1人で待っているクライアント同士はレーティングの近い相手と組んでチームになる
+// ロックは持たないので、呼び出し側（matchState）が排他制御する
+type teamQueue struct {
+	now      clock
+	entries  map[string][]*teamUnit   // モード → 参加順の待機リスト
+	avgWaits map[string]time.Duration // モード → マッチ成立までの平均待ち時間
+}
+
+// newTeamQueue は時刻関数を受け取ってチーム戦の待機キューを作る
+func newTeamQueue(now clock) *teamQueue {
+	if now == nil {
+		now = time.Now
+	}
+	return &teamQueue{
+		now:      now,
+		entries:  make(map[string][]*teamUnit),
+		avgWaits: make(map[string]time.Duration),
+	}
+}
+
+// Enqueue はメンバー（1人かパーティー）をキューに追加し、成立したマッチを返す
+func (q *teamQueue) Enqueue(members []*client, mode string, rating int) []teamQueueMatch {
+	if rating <= 0 {
+		rating = defaultRating
+	}
+	q.entries[mode] = append(q.entries[mode], &teamUnit{
+		members:  append([]*client(nil), members...),
+		rating:   rating,
+		joinedAt: q.now(),
+	})
+	return q.matchMode(mode)
+}
+
+// Remove はクライアントを含む単位をキューから外し、その単位のメンバーを返す（待機中でなければnil）
+// パーティーの1人が抜けたらパーティーごと外す
+func (q *teamQueue) Remove(c *client) []*client {
+	for mode, list := range q.entries {
+		for i, u := range list {
+			if u.has(c) {
+				q.entries[mode] = append(list[:i], list[i+1:]...)
+				return u.members
+			}
+		}
+	}
+	return nil
+}
+
+// Contains はクライアントが待機中かどうかを返す
+func (q *teamQueue) Contains(c *client) bool {
+	for _, list := range q.entries {
+		for _, u := range list {
+			if u.has(c) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// Status はクライアントの現在の待機状況を返す（待機中でなければfalse）
+// 順位は単位（1人かパーティー）の並び、待機人数はモードの全メンバー数で数える
+func (q *teamQueue) Status(c *client) (queuedPayload, bool) {
+	for mode, list := range q.entries {
+		for i, u := range list {
+			if !u.has(c) {
+				continue
+			}
+			size := 0
+			for _, other := range list {
+				size += len(other.members)
+			}
+			waited := q.now().Sub(u.joinedAt)
+			estimate := max(q.estimatedWait(mode)-waited, 0)
+			return queuedPayload{
+				Mode:                 mode,
+				Position:             i + 1,
+				QueueSize:            size,
+				Rating:               u.rating,
+				SearchWindow:         searchWindow(waited),
+				EstimatedWaitSeconds: int(math.Ceil(estimate.Seconds())),
+				Team:                 true,
+			}, true
+		}
+	}
+	return queuedPayload{}, false
+}
+
+// Tick は全モードのキューを再評価し、成立したマッチを返す
+// 待ち時間が伸びると許容レーティング差が広がるので、定期的に呼ぶ必要がある
+func (q *teamQueue) Tick() []teamQueueMatch {
+	var matches []teamQueueMatch
+	for mode := range q.entries {
+		matches = append(matches, q.matchMode(mode)...)
+	}
+	return matches
+}
+
+// Expire は timeout 以上待っている単位をキューから外し、メンバーごとに返す（timeout が0以下なら誰も外さない）
+func (q *teamQueue) Expire(timeout time.Duration) []queueExpired {
+	if timeout <= 0 {
+		return nil
+	}
+	now := q.now()
+	var expired []queueExpired
+	for mode, list := range q.entries {
+		remaining := list[:0]
+		for _, u := range list {
+			if now.Sub(u.joinedAt) < timeout {
+				remaining = append(remaining, u)
+				continue
+			}
+			for _, m := range u.members {
+				expired = append(expired, queueExpired{mode: mode, client: m, rating: u.rating, waited: now.Sub(u.joinedAt)})
+			}
+		}
+		q.entries[mode] = remaining
+	}
+	return expired
+}
+
+// matchMode は1モード分のキューからチームを組み、許容範囲内で最もレーティングの近いチーム同士を当てる
+// 1人で待っている人同士は、待ち時間の長い順にレーティングの近い相手とチームを組む
+func (q *teamQueue) matchMode(mode string) []teamQueueMatch {
+	list := q.entries[mode]
+	now := q.now()
+
+	// チーム候補を作る（パーティーはそのまま、1人同士は近いレーティングで組む）
+	var candidates []teamCandidate
+	paired := make(map[int]bool, len(list))
+	for i, u := range list {
+		if len(u.members) >= teamSize {
+			candidates = append(candidates, teamCandidate{units: []int{i}, rating: u.rating, joinedAt: u.joinedAt})
+			continue
+		}
+		if paired[i] {
+			continue
+		}
+		best, bestDiff := -1, 0
+		for j := i + 1; j < len(list); j++ {
+			other := list[j]
+			if paired[j] || len(other.members) >= teamSize {
+				continue
+			}
+			diff := abs(u.rating - other.rating)
+			if diff > max(searchWindow(now.Sub(u.joinedAt)), searchWindow(now.Sub(other.joinedAt))) {
+				continue
+			}
+			if best == -1 || diff < bestDiff {
+				best, bestDiff = j, diff
+			}
+		}
+		if best == -1 {
+			continue
+		}
+		paired[i], paired[best] = true, true
+		candidates = append(candidates, teamCandidate{
+			units:    []int{i, best},
+			rating:   (u.rating + list[best].rating) / 2,
+			joinedAt: u.joinedAt,
+		})
+	}
+	sort.SliceStable(candidates, func(i, j int) bool { return candidates[i].joinedAt.Before(candidates[j].joinedAt) })
+
+	// チーム同士を当てる（長く待っているチームから相手を探す）
+	used := make(map[int]bool, len(list))
+	matchedTeams := make(map[int]bool, len(candidates))
+	var matches []teamQueueMatch
+	for i, a := range candidates {
+		if matchedTeams[i] {
+			continue
+		}
+		best, bestDiff := -1, 0
+		for j := i + 1; j < len(candidates); j++ {
+			b := candidates[j]
+			if matchedTeams[j] {
+				continue
+			}
+			diff := abs(a.rating - b.rating)
+			if diff > max(searchWindow(now.Sub(a.joinedAt)), searchWindow(now.Sub(b.joinedAt))) {
+				continue
+			}
+			if best == -1 || diff < bestDiff {
+				best, bestDiff = j, diff
+			}
+		}
+		if best == -1 {
+			continue
+		}
+		matchedTeams[i], matchedTeams[best] = true, true
+		m := teamQueueMatch{mode: mode}
+		for side, c := range []teamCandidate{a, candidates[best]} {
+			for _, idx := range c.units {
+				used[idx] = true
+				m.teams[side] = append(m.teams[side], list[idx].members...)
+				q.recordWait(mode, now.Sub(list[idx].joinedAt))
+			}
+		}
+		matches = append(matches, m)
+	}
+
+	if len(matches) == 0 {
+		return nil
+	}
+	remaining := list[:0]
+	for i, u := range list {
+		if !used[i] {
+			remaining = append(remaining, u)
+		}
+	}
+	q.entries[mode] = remaining
+	return matches
+}
+
+// recordWait はマッチ成立までの待ち時間を平均に反映する
+func (q *teamQueue) recordWait(mode string, waited time.Duration) {
+	prev, ok := q.avgWaits[mode]
+	if !ok {
+		q.avgWaits[mode] = waited
+		return
+	}
+	q.avgWaits[mode] = time.Duration(float64(prev)*(1-estimatedWaitSmoothing) + float64(waited)*estimatedWaitSmoothing)
+}
+
+// estimatedWait はモードの平均待ち時間を返す（実績がなければデフォルト値）
+func (q *teamQueue) estimatedWait(mode string) time.Duration {
+	if avg, ok := q.avgWaits[mode]; ok {
+		return avg
+	}
+	return defaultEstimatedWait
+}
+
+// has はクライアントがこの単位のメンバーかを返す
+func (u *teamUnit) has(c *client) bool {
+	for _, m := range u.members {
+		if m.id == c.id {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,100 @@
+package websocket
+
+import (
+	"math"
+	"testing"
+	"time"
+)
+
+// teamFixture は赤チームが勝った2対2の試合の参加者と順位を作る
+// 赤: ann 1500, ben 1300（平均1400）、青: cat 1450, dan 1350（平均1400）
+func teamFixture(deviation float64) ([]ratingSnapshot, []standingItem, map[string][]ratingSnapshot, map[string]int) {
+	snaps := []ratingSnapshot{
+		{Rating: 1500, Deviation: deviation, Volatility: 0.06},
+		{Rating: 1300, Deviation: deviation, Volatility: 0.06},
+		{Rating: 1450, Deviation: deviation, Volatility: 0.06},
+		{Rating: 1350, Deviation: deviation, Volatility: 0.06},
+	}
+	standings := []standingItem{
+		{Rank: 1, Username: "ann", Team: teamRed},
+		{Rank: 1, Username: "ben", Team: teamRed},
+		{Rank: 2, Username: "cat", Team: teamBlue},
+		{Rank: 2, Username: "dan", Team: teamBlue},
+	}
+	members := map[string][]ratingSnapshot{
+		teamRed:  {snaps[0], snaps[1]},
+		teamBlue: {snaps[2], snaps[3]},
+	}
+	ranks := map[string]int{teamRed: 1, teamBlue: 2}
+	return snaps, standings, members, ranks
+}
+
+func TestRateTeamMembersEloMovesMembersByTeamDelta(t *testing.T) {
+	snaps, standings, members, ranks := teamFixture(200)
+	after := rateTeamMembers(eloEngine{KFactor: eloKFactor}, snaps, standings, members, ranks, time.Now())
+
+	// 平均が同じチーム同士なので、勝った側は +K/2、負けた側は -K/2
+	want := []float64{1516, 1316, 1434, 1334}
+	for i, w := range want {
+		if math.Abs(after[i].Rating-w) > 1e-9 {
+			t.Errorf("%s: rating %.2f, want %.2f", standings[i].Username, after[i].Rating, w)
+		}
+		if after[i].Deviation >= snaps[i].Deviation {
+			t.Errorf("%s: RD should shrink after a match, got %.1f", standings[i].Username, after[i].Deviation)
+		}
+	}
+}
+
+func TestRateTeamMembersGlickoUpdatesDeviationPerMember(t *testing.T) {
+	snaps, standings, members, ranks := teamFixture(200)
+	snaps[1].Deviation = 80
+	members[teamRed][1].Deviation = 80
+	after := rateTeamMembers(glicko2Engine{Tau: glickoTau}, snaps, standings, members, ranks, time.Now())
+
+	for i := range after {
+		if after[i].Deviation >= snaps[i].Deviation {
+			t.Errorf("%s: RD should shrink, %.1f -> %.1f", standings[i].Username, snaps[i].Deviation, after[i].Deviation)
+		}
+		if after[i].Volatility <= 0 {
+			t.Errorf("%s: volatility should be set, got %v", standings[i].Username, after[i].Volatility)
+		}
+	}
+	// RDが小さい（実力が定まっている）メンバーほど1試合で動く幅は小さい
+	annGain := after[0].Rating - snaps[0].Rating
+	benGain := after[1].Rating - snaps[1].Rating
+	if annGain <= 0 || benGain <= 0 || benGain >= annGain {
+		t.Errorf("expected both winners to gain and ben (RD 80) less than ann (RD 200): ann %+.1f, ben %+.1f", annGain, benGain)
+	}
+}
+
+func TestRateTeamMembersForfeitedWinnerLoses(t *testing.T) {
+	snaps, standings, members, ranks := teamFixture(200)
+	standings[1].Forfeited = true
+
+	for name, engine := range map[string]ratingEngine{"elo": eloEngine{KFactor: eloKFactor}, "glicko2": glicko2Engine{Tau: glickoTau}} {
+		after := rateTeamMembers(engine, snaps, standings, members, ranks, time.Now())
+		if after[0].Rating <= snaps[0].Rating {
+			t.Errorf("%s: ann stayed and should gain, got %.1f", name, after[0].Rating-snaps[0].Rating)
+		}
+		if after[1].Rating >= snaps[1].Rating {
+			t.Errorf("%s: ben forfeited and should lose, got %+.1f", name, after[1].Rating-snaps[1].Rating)
+		}
+	}
+	if got := matchOutcome(standings, "ben"); got != outcomeDefeat {
+		t.Errorf("forfeited member on the winning team: outcome %q, want %q", got, outcomeDefeat)
+	}
+	if got := matchOutcome(standings, "ann"); got != outcomeVictory {
+		t.Errorf("remaining member on the winning team: outcome %q, want %q", got, outcomeVictory)
+	}
+}
+
+func TestTeamRatingSnapshotAveragesMembers(t *testing.T) {
+	now := time.Now()
+	team := teamRatingSnapshot([]ratingSnapshot{
+		{Rating: 1500, Deviation: 60, Volatility: 0.05},
+		{Rating: 1300, Deviation: 80, Volatility: 0.07},
+	}, now)
+	if team.Rating != 1400 || math.Abs(team.Deviation-math.Sqrt((60*60+80*80)/2.0)) > 1e-9 || math.Abs(team.Volatility-0.06) > 1e-12 {
+		t.Errorf("unexpected team snapshot %+v", team)
+	}
+}
@@ -121,7 +121,7 @@ func generateQuestionsFromTemplates(count int) ([]matchQuestion, error) {
 			ID:      0,
 			Prompt:  prompt,
 			Answer:  set.Language,
-			Choices: buildChoices(set.Language, rng),
+			Choices: buildChoices(set.Language, rng, defaultChoiceCount),
 		})
 	}
 
@@ -0,0 +1,25 @@
+package websocket
+
+// suddenDeathRounds はモードごとの延長戦（サドンデス）の既定の最大ラウンド数（MatchRules で上書きできる）
+// 規定ラウンド終了時に1位が同点なら、決着がつくかこの回数に達するまで1問ずつ追加する
+// 0 のモードは延長せずそのまま引き分けで終える
+var suddenDeathRounds = map[string]int{
+	"text-major":  3,
+	"text-rare":   3,
+	"audio-major": 1,
+	"audio-rare":  1,
+}
+
+// startSuddenDeathLocked は1位が同点なら延長戦のラウンドを1つ追加してtrueを返す（r.mu を保持して呼ぶ）
+// 予備の問題が残っていない場合や上限に達した場合は延長しない
+func (r *room) startSuddenDeathLocked() bool {
+	if r.suddenDeath >= r.rules.SuddenDeathRounds || r.round >= len(r.questions) {
+		return false
+	}
+	if r.activeSideCount() < 2 || r.winnerName() != "" {
+		return false
+	}
+	r.suddenDeath++
+	r.maxRounds++
+	return true
+}
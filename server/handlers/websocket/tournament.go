@@ -0,0 +1,233 @@
+package websocket
+
+import (
+	"encoding/json"
+	"log"
+	"strings"
+	"sync"
+	"time"
+
+	"example.com/mathkun-tmp-/server/db"
+	"example.com/mathkun-tmp-/server/services"
+)
+
+// tournamentTickInterval は大会の自動開始と参加締め切りを確認する間隔
+const tournamentTickInterval = 15 * time.Second
+
+// tournamentSchedulerOnce は大会の定期処理のゴルーチンを1度だけ起動するためのもの
+var tournamentSchedulerOnce sync.Once
+
+// tournamentSeat は大会の対戦カードとして行う試合の情報
+type tournamentSeat struct {
+	matchID      uint // 対戦カード（tournament_matches.id）
+	tournamentID uint
+	round        int
+}
+
+// handleTournamentJoin は大会の現在の対戦への参加を処理する
+// 参加を記録し、対戦相手が既に待っていれば試合を始め、いなければ tournament:waiting を返す
+func handleTournamentJoin(c *client, payload json.RawMessage) {
+	var req tournamentJoinPayload
+	if err := json.Unmarshal(payload, &req); err != nil || strings.TrimSpace(req.Token) == "" || req.TournamentID == 0 {
+		sendError(c, codeInvalidPayload, "invalid tournament join payload")
+		return
+	}
+	if !authenticateClient(c, req.Token) {
+		return
+	}
+	if c.userID == 0 {
+		sendError(c, codeUnauthorized, "unauthorized")
+		return
+	}
+	// 他の対戦中に参加を記録しない（締め切りで相手の不戦敗にしてしまうため）
+	if !state.IsIdle(c) {
+		sendErrorFor(c, errAlreadyInRoom)
+		return
+	}
+
+	pairing, err := services.NewTournamentService(db.DB).CheckIn(req.TournamentID, c.username)
+	if err != nil {
+		sendErrorFor(c, err)
+		return
+	}
+	useModeRating(c, pairing.Mode)
+
+	r, ready, err := state.JoinTournamentMatch(c, pairing)
+	if err != nil {
+		sendErrorFor(c, err)
+		return
+	}
+	if !ready {
+		c.send(wsMessage{Type: "tournament:waiting", Payload: mustJSON(tournamentWaitingPayload{
+			TournamentID:      pairing.TournamentID,
+			Round:             pairing.Round,
+			RoomID:            r.id,
+			Opponent:          pairing.Opponent,
+			DeadlineInSeconds: max(int(time.Until(pairing.DeadlineAt)/time.Second), 0),
+		})})
+		return
+	}
+
+	// 両者が揃ったので、締め切りで不戦敗にならないよう進行中にしてから始める
+	if err := services.NewTournamentService(db.DB).MarkPlaying(pairing.MatchID, r.rules.timeLimit()); err != nil {
+		log.Printf("failed to mark tournament match %d as playing: %v", pairing.MatchID, err)
+	}
+	startMatch(r)
+}
+
+// JoinTournamentMatch は大会の対戦カードのルームにクライアントを入れる
+// ルームがなければ作り、両者が揃ったら開始済みにして true を返す
+func (s *matchState) JoinTournamentMatch(c *client, pairing *services.TournamentPairingDTO) (*room, bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if !s.isAvailableLocked(c) {
+		return nil, false, errAlreadyInRoom
+	}
+
+	r := s.tournamentRooms[pairing.MatchID]
+	if r == nil {
+		r = &room{
+			id:         newRoomID(),
+			minPlayers: minRoomPlayers,
+			maxPlayers: minRoomPlayers,
+			mode:       pairing.Mode,
+			rated:      pairing.Rated,
+			rules:      rulesFor(pairing.Mode, pairing.Rated),
+			tournament: &tournamentSeat{
+				matchID:      pairing.MatchID,
+				tournamentID: pairing.TournamentID,
+				round:        pairing.Round,
+			},
+		}
+		s.rooms[r.id] = r
+		s.tournamentRooms[pairing.MatchID] = r
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.started {
+		return nil, false, errRoomStarted
+	}
+	for _, p := range r.players {
+		if p.userID == c.userID {
+			return nil, false, errAlreadyInRoom
+		}
+	}
+	r.players = append(r.players, c)
+	c.roomID = r.id
+	c.mode = r.mode
+	r.started = len(r.players) >= r.minPlayers
+	return r, r.started, nil
+}
+
+// leaveTournamentRoomLocked は開始前の大会のルームからクライアントを外す（s.mu を保持して呼ぶ）
+// 誰もいなくなればルームを閉じる
+func (s *matchState) leaveTournamentRoomLocked(r *room, c *client) {
+	c.roomID = ""
+	r.mu.Lock()
+	remaining := make([]*client, 0, len(r.players))
+	for _, p := range r.players {
+		if p.id != c.id {
+			remaining = append(remaining, p)
+		}
+	}
+	r.players = remaining
+	r.mu.Unlock()
+
+	if len(remaining) == 0 {
+		delete(s.rooms, r.id)
+		delete(s.tournamentRooms, r.tournament.matchID)
+	}
+}
+
+// closeTournamentRoom は締め切りで決着した対戦カードの待機中のルームを閉じ、待っていた参加者に知らせる
+func (s *matchState) closeTournamentRoom(matchID uint) {
+	s.mu.Lock()
+	r := s.tournamentRooms[matchID]
+	if r == nil {
+		s.mu.Unlock()
+		return
+	}
+	r.mu.Lock()
+	if r.started {
+		r.mu.Unlock()
+		s.mu.Unlock()
+		return
+	}
+	players := r.players
+	r.players = nil
+	seat := *r.tournament
+	r.mu.Unlock()
+	delete(s.rooms, r.id)
+	delete(s.tournamentRooms, matchID)
+	for _, p := range players {
+		p.roomID = ""
+	}
+	s.mu.Unlock()
+
+	for _, p := range players {
+		p.send(wsMessage{Type: "tournament:walkover", Payload: mustJSON(tournamentWalkoverPayload{
+			TournamentID: seat.tournamentID,
+			Round:        seat.round,
+			RoomID:       r.id,
+		})})
+	}
+}
+
+// recordTournamentResult は大会の試合の結果を対戦カードに記録する（大会の試合でなければ何もしない）
+// 勝敗が決まらなかった場合（引き分け）は勝者なしとして記録する
+func recordTournamentResult(r *room, winner string, matchRecordID uint) {
+	r.mu.Lock()
+	seat := r.tournament
+	var winnerID uint
+	for _, p := range r.players {
+		if p != nil && winner != "" && p.username == winner {
+			winnerID = p.userID
+		}
+	}
+	r.mu.Unlock()
+	if seat == nil {
+		return
+	}
+
+	if err := services.NewTournamentService(db.DB).RecordResult(seat.matchID, winnerID, matchRecordID); err != nil {
+		log.Printf("failed to record tournament match %d: %v", seat.matchID, err)
+	}
+}
+
+// resetTournamentMatch は始められなかった大会の試合を参加待ちに戻す（大会の試合でなければ何もしない）
+func resetTournamentMatch(r *room) {
+	r.mu.Lock()
+	seat := r.tournament
+	r.mu.Unlock()
+	if seat == nil {
+		return
+	}
+	if err := services.NewTournamentService(db.DB).ResetMatch(seat.matchID); err != nil {
+		log.Printf("failed to reset tournament match %d: %v", seat.matchID, err)
+	}
+}
+
+// StartTournamentScheduler は大会の定期処理のゴルーチンを起動する（2回目以降は何もしない）
+// 開始予定を過ぎた大会の開始と、参加の締め切りを過ぎた対戦カードの決着を行う
+func StartTournamentScheduler() {
+	tournamentSchedulerOnce.Do(func() {
+		go runTournamentScheduler(state, tournamentTickInterval)
+	})
+}
+
+// runTournamentScheduler は一定間隔で大会を進め、締め切りで決着した対戦の待機ルームを閉じる
+func runTournamentScheduler(s *matchState, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for range ticker.C {
+		resolved, err := services.NewTournamentService(db.DB).Tick()
+		if err != nil {
+			log.Printf("tournament tick failed: %v", err)
+		}
+		for _, matchID := range resolved {
+			s.closeTournamentRoom(matchID)
+		}
+	}
+}
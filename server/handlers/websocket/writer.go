@@ -0,0 +1,130 @@
+package websocket
+
+import (
+	"io"
+	"time"
+)
+
+// sendBufferSize はクライアントごとの送信待ちメッセージの上限
+// これを超えて溜まるのは受信の遅い相手なので、待たずに切断する
+const sendBufferSize = 64
+
+// deadlineWriter は書き込み期限を設定できる接続（*websocket.Conn が該当、bot は該当しない）
+type deadlineWriter interface {
+	SetWriteDeadline(t time.Time) error
+}
+
+// newClient は接続を包んだクライアントを作り、送信用のゴルーチンを起動する
+// 接続への書き込みはこのゴルーチンだけが行うので、どこから send を呼んでも同時書き込みにならない
+func newClient(conn clientConn) *client {
+	c := &client{
+		id:       newClientID(),
+		conn:     conn,
+		outbound: make(chan wsMessage, sendBufferSize),
+		done:     make(chan struct{}),
+	}
+	go c.writeLoop()
+	return c
+}
+
+// send はメッセージを送信待ちに積む（ブロックしない）
+// 閉じたクライアントへの送信は捨て、送信待ちが一杯なら受信の遅い相手として切断する
+func (c *client) send(msg wsMessage) {
+	if c.outbound == nil {
+		return
+	}
+	select {
+	case <-c.done:
+		return
+	default:
+	}
+	select {
+	case c.outbound <- msg:
+	case <-c.done:
+	default:
+		c.abort()
+	}
+}
+
+// close は送信待ちのメッセージを書き終えてから接続を閉じる（何度呼んでもよい）
+// 実際の接続が閉じると読み込みループがエラーで抜け、RemoveClient で後片付けされる
+func (c *client) close() {
+	c.shutdown(true)
+}
+
+// abort は送信待ちのメッセージを捨ててすぐに接続を閉じる（送信待ちが溢れた受信の遅い相手に使う）
+func (c *client) abort() {
+	c.shutdown(false)
+}
+
+// shutdown は送信ゴルーチンに終了を伝える（接続を閉じるのは writeLoop）
+// flush が true なら、それまでに積まれたメッセージを書き終えてから閉じる
+func (c *client) shutdown(flush bool) {
+	c.closeOnce.Do(func() {
+		if c.done == nil {
+			closeConn(c.conn)
+			return
+		}
+		c.flushOnClose = flush
+		close(c.done)
+	})
+}
+
+// closeConn は接続を閉じる（bot など閉じる必要のない接続では何もしない）
+func closeConn(conn clientConn) {
+	if closer, ok := conn.(io.Closer); ok {
+		_ = closer.Close()
+	}
+}
+
+// isClosed は接続が閉じられたかを返す
+func (c *client) isClosed() bool {
+	select {
+	case <-c.done:
+		return true
+	default:
+		return false
+	}
+}
+
+// writeLoop は送信待ちのメッセージを順に書き込む（クライアントごとに1つだけ動く）
+// 書き込みに失敗したり期限を過ぎたりした接続は閉じる
+func (c *client) writeLoop() {
+	defer closeConn(c.conn)
+	for {
+		select {
+		case <-c.done:
+			if c.flushOnClose {
+				c.flush()
+			}
+			return
+		case msg := <-c.outbound:
+			if err := c.write(msg); err != nil {
+				c.abort()
+				return
+			}
+		}
+	}
+}
+
+// flush は送信待ちに残っているメッセージを書き込む（失敗したらそこでやめる）
+func (c *client) flush() {
+	for {
+		select {
+		case msg := <-c.outbound:
+			if err := c.write(msg); err != nil {
+				return
+			}
+		default:
+			return
+		}
+	}
+}
+
+// write はメッセージを1つ書き込む（1回の書き込みは writeWait まで）
+func (c *client) write(msg wsMessage) error {
+	if dw, ok := c.conn.(deadlineWriter); ok {
+		_ = dw.SetWriteDeadline(time.Now().Add(writeWait))
+	}
+	return c.conn.WriteJSON(msg)
+}
@@ -0,0 +1,186 @@
+package websocket
+
+import (
+	"encoding/json"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+)
+
+// simConn は届いた問題にすぐ回答するテスト用の接続
+// 回答は実際の読み込みループと同じ handleAnswer を通す
+type simConn struct {
+	client   *client
+	finished chan struct{} // match:finished を受け取ったら閉じる
+	once     sync.Once
+	check    func(wsMessage) // 設定されていれば届いたメッセージをすべて渡す（スキーマの検査用）
+	idle     bool            // true なら出題に回答しない（ラウンドは制限時間まで続く）
+}
+
+// newSimClient は simConn につながったクライアントを作る
+func newSimClient(username string) (*client, *simConn) {
+	conn := &simConn{finished: make(chan struct{})}
+	c := newClient(conn)
+	c.username = username
+	c.rating = defaultRating
+	conn.client = c
+	return c, conn
+}
+
+// WriteJSON は出題なら先頭の選択肢で回答し、試合終了なら finished を閉じる
+func (s *simConn) WriteJSON(v any) error {
+	msg, ok := v.(wsMessage)
+	if !ok {
+		return nil
+	}
+	if s.check != nil {
+		s.check(msg)
+	}
+	switch msg.Type {
+	case "match:started", "match:round":
+		if s.idle {
+			return nil
+		}
+		var round roundPayload
+		if err := json.Unmarshal(msg.Payload, &round); err != nil || len(round.Question.Choices) == 0 {
+			return nil
+		}
+		answer := mustJSON(answerPayload{RoomID: round.RoomID, Answer: round.Question.Choices[0]})
+		go handleAnswer(s.client, answer)
+	case "match:finished":
+		s.once.Do(func() { close(s.finished) })
+	}
+	return nil
+}
+
+// useSimulatedMatches は DB を使わない問題の取得と結果の保存に差し替え、テスト後に元に戻す
+func useSimulatedMatches(t *testing.T) {
+	t.Helper()
+	prevState, prevLoad, prevSave := state, loadMatchQuestions, saveMatchResult
+	state = newMatchState(time.Now)
+	loadMatchQuestions = func(count int, mode string, choiceCount int) ([]matchQuestion, error) {
+		questions := make([]matchQuestion, count)
+		for i := range questions {
+			questions[i] = matchQuestion{
+				ID:      uint(i + 1),
+				Prompt:  fmt.Sprintf("q%d", i+1),
+				Answer:  "a",
+				Choices: []string{"a", "b", "c", "d"},
+			}
+		}
+		return questions, nil
+	}
+	saveMatchResult = func(r *room, standings []standingItem, winner, status string) (ratingResult, error) {
+		return ratingResult{Ratings: map[string]int{}, Deltas: map[string]int{}}, nil
+	}
+	t.Cleanup(func() {
+		state, loadMatchQuestions, saveMatchResult = prevState, prevLoad, prevSave
+	})
+}
+
+// TestSimulatedMatchesRace は多数の試合を同時に進め、go test -race でデータ競合がないことを確かめる
+// 一部の試合では途中で切断・復帰と観戦を挟み、送信中に席が付け替えられる場合も通す
+func TestSimulatedMatchesRace(t *testing.T) {
+	useSimulatedMatches(t)
+
+	const matches = 300
+	var wg sync.WaitGroup
+	for i := 0; i < matches; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			mode := "text-major"
+			if i%2 == 1 {
+				mode = "text-rare"
+			}
+			// 試合ごとにレーティングを離しておき、同時に待つ他の試合の参加者と組まないようにする
+			a, aConn := newSimClient(fmt.Sprintf("a%d", i))
+			b, _ := newSimClient(fmt.Sprintf("b%d", i))
+			a.rating = defaultRating + i*(queueBaseWindow*3)
+			b.rating = a.rating
+			if _, _, err := state.Join(a, mode); err != nil {
+				t.Errorf("match %d: join a: %v", i, err)
+				return
+			}
+			r, _, err := state.Join(b, mode)
+			if err != nil || r == nil {
+				t.Errorf("match %d: expected a room, got %v (%v)", i, r, err)
+				return
+			}
+			r.rules.RoundTime = 200 * time.Millisecond
+			r.rules.RoundGap = 0
+			go startMatch(r)
+
+			if i%3 == 0 {
+				viewer, _ := newSimClient(fmt.Sprintf("viewer%d", i))
+				if _, err := state.Spectate(viewer, r.id, ""); err != nil && err != errRoomNotFound {
+					t.Errorf("match %d: spectate: %v", i, err)
+				}
+				defer state.RemoveClient(viewer)
+			}
+			if i%4 == 0 {
+				// b が切断し、同じユーザーの新しい接続で復帰する
+				state.RemoveClient(b)
+				b.close()
+				resumed, _ := newSimClient(b.username)
+				if _, err := state.Resume(resumed); err != nil && err != errNoHeldSeat {
+					t.Errorf("match %d: resume: %v", i, err)
+				}
+				defer resumed.close()
+			}
+
+			select {
+			case <-aConn.finished:
+			case <-time.After(20 * time.Second):
+				t.Errorf("match %d did not finish", i)
+			}
+			a.close()
+			b.close()
+		}(i)
+	}
+	wg.Wait()
+}
+
+// TestClientCloseFlushesQueuedMessages は close の前に積んだメッセージが捨てられずに書き込まれることを確かめる
+func TestClientCloseFlushesQueuedMessages(t *testing.T) {
+	conn := &recordingConn{release: make(chan struct{})}
+	c := newClient(conn)
+	for i := 0; i < 5; i++ {
+		c.send(wsMessage{Type: fmt.Sprintf("m%d", i)})
+	}
+	c.close()
+	close(conn.release)
+
+	deadline := time.Now().Add(2 * time.Second)
+	for time.Now().Before(deadline) {
+		if conn.count() == 5 {
+			return
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	t.Fatalf("expected 5 flushed messages, got %d", conn.count())
+}
+
+// recordingConn は書き込まれたメッセージを数える接続（release が閉じるまで最初の書き込みを止める）
+type recordingConn struct {
+	release chan struct{}
+	mu      sync.Mutex
+	written int
+}
+
+// WriteJSON は release を待ってから書き込みを数える
+func (r *recordingConn) WriteJSON(v any) error {
+	<-r.release
+	r.mu.Lock()
+	r.written++
+	r.mu.Unlock()
+	return nil
+}
+
+// count は書き込まれたメッセージの数を返す
+func (r *recordingConn) count() int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.written
+}
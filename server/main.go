@@ -1,9 +1,14 @@
 package main
 
 import (
+	"log"
+	"os"
+
 	"example.com/mathkun-tmp-/server/db"
 	"example.com/mathkun-tmp-/server/handlers"
+	"example.com/mathkun-tmp-/server/handlers/websocket"
 	"example.com/mathkun-tmp-/server/models"
+	"example.com/mathkun-tmp-/server/repositories"
 	"example.com/mathkun-tmp-/server/router"
 
 	"github.com/gin-contrib/cors"
@@ -26,10 +31,50 @@ func main() {
 		&models.Question{},
 		&models.RareQuestion{},
 		&models.AudioQuestion{},
+		&models.RareAudioQuestion{},
+		&models.Match{},
+		&models.MatchParticipant{},
+		&models.MatchRound{},
+		&models.SuspiciousPlayFlag{},
+		&models.UserModeRating{},
+		&models.RatingHistory{},
+		&models.Season{},
+		&models.SeasonStanding{},
+		&models.Tournament{},
+		&models.TournamentEntry{},
+		&models.TournamentMatch{},
+		&models.CorrespondenceMatch{},
+		&models.CorrespondencePlayer{},
+		&models.CorrespondenceAnswer{},
 	)
+	// RD導入前から対戦しているユーザーは、対戦数からRDを推定して埋める（モード別の行に写す前に行う）
+	if err := repositories.NewUserRepository(db.DB).BackfillDeviations(); err != nil {
+		log.Fatal(err)
+	}
+	// モード別レーティング導入前のユーザーは、全体のレーティングを各モードの初期値にする
+	if err := repositories.NewUserModeRatingRepository(db.DB).BackfillFromUsers(models.RatingModes); err != nil {
+		log.Fatal(err)
+	}
+
+	// 管理用コマンドが指定されていればそれだけ実行して終了する
+	if len(os.Args) > 1 {
+		if err := runCommand(os.Args[1:]); err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
+
+	// 試合のルールの設定（MATCH_RULES）を読む。壊れていれば起動しない
+	if err := websocket.LoadMatchRules(); err != nil {
+		log.Fatal(err)
+	}
 
 	// 2. ハンドラーのサービス初期化（DB接続後に実行）
 	handlers.InitHandlers(db.DB)
+	// 大会の自動開始と参加締め切りの処理を始める
+	websocket.StartTournamentScheduler()
+	// 非同期対戦の期限切れの処理を始める
+	websocket.StartCorrespondenceScheduler()
 
 	// 3. ルーター設定
 	r := gin.Default()
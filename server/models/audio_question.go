@@ -1,19 +1,23 @@
 package models
 
+// AudioQuestion はメジャー言語の音声問題
 type AudioQuestion struct {
 	ID       uint   `gorm:"primaryKey"`
 	Language string `gorm:"type:varchar(100);not null"`
 	AudioURL string `gorm:"type:text;not null"`
+	QuestionAudit
 }
 
 func (AudioQuestion) TableName() string {
 	return "major_audio"
 }
 
+// RareAudioQuestion はレア言語の音声問題（項目は AudioQuestion と同じ）
 type RareAudioQuestion struct {
 	ID       uint   `gorm:"primaryKey"`
 	Language string `gorm:"type:varchar(100);not null"`
 	AudioURL string `gorm:"type:text;not null"`
+	QuestionAudit
 }
 
 func (RareAudioQuestion) TableName() string {
@@ -0,0 +1,53 @@
+package models
+
+import "time"
+
+// CorrespondenceMatch は2人が別々の時間に同じ問題を解く非同期対戦
+// 作成時に問題セットを固定して保存し、両者が期限までに解き終えたら結果を確定する
+// 受付中（"open"）→ 終了（"completed"）の順に進み、期限までに決着しなければ "expired"、断られたら "declined" になる
+type CorrespondenceMatch struct {
+	ID             uint   `gorm:"primaryKey"`
+	Mode           string `gorm:"type:varchar(32);not null;index"`                // 対戦モード（text-major など）
+	Rated          bool   `gorm:"not null;default:false"`                         // 決着時にレーティングを変動させるか
+	Status         string `gorm:"type:varchar(32);not null;default:'open';index"` // 進行状況
+	ChallengerID   uint   `gorm:"not null;index"`                                 // 挑戦を作ったユーザー
+	ChallengerName string `gorm:"type:varchar(255);not null"`
+	OpponentID     uint   `gorm:"not null;default:0;index"` // 指名された相手（誰でも受けられる挑戦は受けた人、それまでは0）
+	OpponentName   string `gorm:"type:varchar(255)"`
+	// 出題順の問題セット（JSON、正解を含むのでクライアントにはそのまま返さない）
+	Questions  string    `gorm:"type:text;not null"`
+	RoundMs    int       `gorm:"not null"`       // 1問の制限時間
+	DeadlineAt time.Time `gorm:"not null;index"` // 両者が解き終えるまでの期限
+	MatchID    *uint     // 確定した試合の対戦履歴（matches.id）
+	WinnerName string    `gorm:"type:varchar(255)"` // 引き分け・不成立は空
+	FinishedAt *time.Time
+	CreatedAt  time.Time
+
+	Players []CorrespondencePlayer `gorm:"foreignKey:CorrespondenceMatchID"`
+}
+
+// CorrespondencePlayer は非同期対戦の1人分の進み具合
+type CorrespondencePlayer struct {
+	ID                    uint       `gorm:"primaryKey"`
+	CorrespondenceMatchID uint       `gorm:"not null;uniqueIndex:idx_correspondence_player,priority:1"`
+	UserID                uint       `gorm:"not null;uniqueIndex:idx_correspondence_player,priority:2"`
+	Username              string     `gorm:"type:varchar(255);not null"`
+	Round                 int        `gorm:"not null;default:0"` // 出題済みの問題数（出題中の問題を含む）
+	Score                 int        `gorm:"not null;default:0"`
+	ServedAt              *time.Time // 出題中の問題を出した日時（回答待ちでなければnil）
+	FinishedAt            *time.Time // 全問を解き終えた日時
+	CreatedAt             time.Time  // 対戦を始めた日時
+
+	Answers []CorrespondenceAnswer `gorm:"foreignKey:CorrespondencePlayerID"`
+}
+
+// CorrespondenceAnswer は非同期対戦の1問分の回答
+type CorrespondenceAnswer struct {
+	ID                     uint   `gorm:"primaryKey"`
+	CorrespondencePlayerID uint   `gorm:"not null;index"`
+	Round                  int    `gorm:"not null"`
+	Answer                 string `gorm:"type:varchar(100)"` // 時間切れなら空
+	ResponseMs             int64  // 出題から回答までの時間（時間切れは0）
+	Correct                bool   `gorm:"not null;default:false"`
+	Points                 int    `gorm:"not null;default:0"` // 速さに応じて得た点数
+}
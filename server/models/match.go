@@ -0,0 +1,48 @@
+package models
+
+import "time"
+
+// Match は終了した対戦1試合分の記録
+type Match struct {
+	ID          uint   `gorm:"primaryKey"`
+	Mode        string `gorm:"type:varchar(32);not null;index"`
+	Status      string `gorm:"type:varchar(32);not null"` // "completed", "forfeit" など
+	Rated       bool   `gorm:"not null;default:true"`
+	QuestionIDs string `gorm:"type:text"` // 出題順の問題ID（カンマ区切り、テーブルはModeで決まる）
+	Winner      string `gorm:"type:varchar(255)"`
+	StartedAt   time.Time
+	FinishedAt  time.Time `gorm:"index"`
+	CreatedAt   time.Time
+
+	Participants []MatchParticipant `gorm:"foreignKey:MatchID"`
+	Rounds       []MatchRound       `gorm:"foreignKey:MatchID"`
+}
+
+// MatchParticipant は試合に参加した1プレイヤー分の成績
+type MatchParticipant struct {
+	ID           uint   `gorm:"primaryKey"`
+	MatchID      uint   `gorm:"not null;index"`
+	UserID       uint   `gorm:"not null;index"`
+	Username     string `gorm:"type:varchar(255);not null"`
+	Score        int    `gorm:"not null;default:0"`
+	Rank         int    `gorm:"not null;default:0"`
+	Forfeited    bool   `gorm:"not null;default:false"`
+	Team         string `gorm:"type:varchar(16)"` // チーム戦で所属したチーム（個人戦は空）
+	RatingBefore int    `gorm:"not null"`         // 対戦モードのモード別レーティング（試合前、チーム戦はチーム戦用の値）
+	RatingAfter  int    `gorm:"not null"`         // 対戦モードのモード別レーティング（試合後、チーム戦はチーム戦用の値）
+}
+
+// MatchRound は1ラウンドにおける1プレイヤー分の回答
+type MatchRound struct {
+	ID            uint   `gorm:"primaryKey"`
+	MatchID       uint   `gorm:"not null;index"`
+	Round         int    `gorm:"not null"`
+	QuestionID    uint   `gorm:"not null"`
+	CorrectAnswer string `gorm:"type:varchar(100);not null"`
+	UserID        uint   `gorm:"not null"`
+	Username      string `gorm:"type:varchar(255);not null"`
+	Answer        string `gorm:"type:varchar(100)"` // 未回答なら空
+	ResponseMs    int64  // ラウンド開始から回答までの時間（未回答は0）
+	Correct       bool   `gorm:"not null;default:false"`
+	Points        int    `gorm:"not null;default:0"` // 速さに応じて得た点数
+}
@@ -1,23 +1,41 @@
 package models
 
-import "time"
+import (
+	"time"
 
-type Question struct {
-	ID        uint   `gorm:"primaryKey"`
-	Prompt    string `gorm:"not null"`
-	Answer    string `gorm:"not null"`
+	"gorm.io/gorm"
+)
+
+// QuestionAudit は4つの問題バンクに共通する監査用の項目
+// 管理APIで作成・更新・削除した場合は操作したユーザー名を残す（SQLで直接入れた行は空）
+// 削除は論理削除で、削除済みの問題は出題されない
+type QuestionAudit struct {
 	CreatedAt time.Time
+	UpdatedAt time.Time
+	DeletedAt gorm.DeletedAt `gorm:"index"`
+	CreatedBy string         `gorm:"type:varchar(255)"`
+	UpdatedBy string         `gorm:"type:varchar(255)"`
+	DeletedBy string         `gorm:"type:varchar(255)"`
+}
+
+// Question はメジャー言語のテキスト問題
+type Question struct {
+	ID     uint   `gorm:"primaryKey"`
+	Prompt string `gorm:"not null"`
+	Answer string `gorm:"not null"`
+	QuestionAudit
 }
 
 func (Question) TableName() string {
 	return "major_text"
 }
 
+// RareQuestion はレア言語のテキスト問題（項目は Question と同じ）
 type RareQuestion struct {
-	ID        uint   `gorm:"primaryKey"`
-	Prompt    string `gorm:"not null"`
-	Answer    string `gorm:"not null"`
-	CreatedAt time.Time
+	ID     uint   `gorm:"primaryKey"`
+	Prompt string `gorm:"not null"`
+	Answer string `gorm:"not null"`
+	QuestionAudit
 }
 
 func (RareQuestion) TableName() string {
@@ -0,0 +1,18 @@
+package models
+
+import "time"
+
+// RatingHistory はレーティング戦1試合ごとのモード別レーティングの推移
+// 1試合につき参加者ごとに1件作られ、推移グラフや連勝記録の集計に使う
+type RatingHistory struct {
+	ID           uint      `gorm:"primaryKey"`
+	UserID       uint      `gorm:"not null;index:idx_rating_history_user,priority:1"`
+	MatchID      uint      `gorm:"not null;index"`
+	Mode         string    `gorm:"type:varchar(32);not null;index:idx_rating_history_user,priority:2"`
+	RatingBefore int       `gorm:"not null"`
+	RatingAfter  int       `gorm:"not null"`
+	Outcome      string    `gorm:"type:varchar(16);not null"`                         // "victory", "defeat", "draw"
+	Opponent     string    `gorm:"type:text;not null"`                                // 対戦相手のユーザー名（3人以上の試合はカンマ区切り）
+	PlayedAt     time.Time `gorm:"not null;index:idx_rating_history_user,priority:3"` // 試合が終わった日時
+	CreatedAt    time.Time
+}
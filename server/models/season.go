@@ -0,0 +1,35 @@
+package models
+
+import "time"
+
+// SeasonOverallMode は全モード共通レーティングの順位を表す SeasonStanding.Mode の値
+const SeasonOverallMode = "overall"
+
+// Season はランキングの区切りとなるシーズン
+// 同時に開催中（Status が "active"）のシーズンは1つだけ
+type Season struct {
+	ID       uint      `gorm:"primaryKey"`
+	Name     string    `gorm:"type:varchar(255);not null"`
+	StartsAt time.Time `gorm:"not null"`
+	EndsAt   time.Time `gorm:"not null"`                                         // 予定の終了日時（締めた時点で実際の終了日時に更新）
+	Status   string    `gorm:"type:varchar(32);not null;default:'active';index"` // "active", "archived"
+	// 締めたときに適用したソフトリセットの係数（0なら全員1000に戻す、1ならそのまま）
+	SoftResetFactor float64 `gorm:"not null;default:0"`
+	ArchivedAt      *time.Time
+	CreatedAt       time.Time
+}
+
+// SeasonStanding はシーズン終了時点の最終順位と、そのシーズン中の戦績
+// モード別と全モード共通（Mode が "overall"）の両方を保存する
+type SeasonStanding struct {
+	ID       uint   `gorm:"primaryKey"`
+	SeasonID uint   `gorm:"not null;index:idx_season_standing,priority:1"`
+	Mode     string `gorm:"type:varchar(32);not null;index:idx_season_standing,priority:2"`
+	UserID   uint   `gorm:"not null;index"`
+	Username string `gorm:"type:varchar(255);not null"`
+	Rank     *int   `gorm:"index:idx_season_standing,priority:3"` // 試合数やRDが条件を満たさない場合はnil
+	Rating   int    `gorm:"not null"`                             // シーズン終了時（リセット前）のレーティング
+	Wins     int    `gorm:"not null;default:0"`
+	Losses   int    `gorm:"not null;default:0"`
+	Draws    int    `gorm:"not null;default:0"`
+}
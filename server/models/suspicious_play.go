@@ -0,0 +1,21 @@
+package models
+
+import "time"
+
+// SuspiciousPlayFlag は不自然に速い正解が続いたアカウントを管理者が確認するための記録
+// 1試合につき該当ユーザーごとに1件作られる
+type SuspiciousPlayFlag struct {
+	ID               uint   `gorm:"primaryKey"`
+	UserID           uint   `gorm:"not null;index"`
+	Username         string `gorm:"type:varchar(255);not null;index"`
+	MatchID          uint   `gorm:"not null;index"`
+	Mode             string `gorm:"type:varchar(32);not null"`
+	Reason           string `gorm:"type:varchar(255);not null"`
+	FastCorrectCount int    `gorm:"not null"`                                          // しきい値より速かった正解の数
+	CorrectCount     int    `gorm:"not null"`                                          // その試合の正解数
+	MinResponseMs    int64  `gorm:"not null"`                                          // 最速の正解までの時間
+	Status           string `gorm:"type:varchar(32);not null;default:'pending';index"` // "pending", "cleared", "confirmed"
+	ReviewedBy       string `gorm:"type:varchar(255)"`
+	ReviewedAt       *time.Time
+	CreatedAt        time.Time
+}
@@ -0,0 +1,61 @@
+package models
+
+import "time"
+
+// Tournament は主催者が開く大会（シングルイリミネーションかスイス式）
+// 登録受付中（"registration"）→ 開催中（"running"）→ 終了（"finished"）の順に進み、人数不足なら "cancelled" になる
+type Tournament struct {
+	ID          uint   `gorm:"primaryKey"`
+	Name        string `gorm:"type:varchar(255);not null"`
+	Mode        string `gorm:"type:varchar(32);not null"`                              // 対戦モード（text-major など）
+	Format      string `gorm:"type:varchar(32);not null"`                              // "single_elimination", "swiss"
+	Status      string `gorm:"type:varchar(32);not null;default:'registration';index"` // 進行状況
+	Rated       bool   `gorm:"not null;default:false"`                                 // 大会の試合でレーティングを変動させるか
+	OrganizerID uint   `gorm:"not null;index"`                                         // 作成したユーザー（開始・中止ができる）
+	MaxPlayers  int    `gorm:"not null;default:0"`                                     // 参加人数の上限（0なら無制限）
+	TotalRounds int    `gorm:"not null;default:0"`                                     // 予定のラウンド数（開始時に確定）
+	// 進行中のラウンド（開始前は0）
+	CurrentRound int `gorm:"not null;default:0"`
+	// ラウンドの間隔（前のラウンドの開始からこの時間が経つまで次のラウンドを始めない）
+	RoundMinutes int `gorm:"not null;default:30"`
+	// 各ラウンドの開始から対戦に来るまでの猶予（過ぎたら来なかった方の不戦敗）
+	CheckInMinutes int       `gorm:"not null;default:10"`
+	StartsAt       time.Time `gorm:"not null;index"` // 開始予定日時（過ぎると自動で始まる）
+	StartedAt      *time.Time
+	FinishedAt     *time.Time
+	WinnerID       uint   `gorm:"not null;default:0"` // 優勝者（終了まで0）
+	WinnerName     string `gorm:"type:varchar(255)"`
+	CreatedAt      time.Time
+}
+
+// TournamentEntry は大会への参加登録
+type TournamentEntry struct {
+	ID           uint      `gorm:"primaryKey"`
+	TournamentID uint      `gorm:"not null;uniqueIndex:idx_tournament_entry,priority:1"`
+	UserID       uint      `gorm:"not null;uniqueIndex:idx_tournament_entry,priority:2"`
+	Username     string    `gorm:"type:varchar(255);not null"`
+	Rating       int       `gorm:"not null"`           // シード決めに使ったモード別レーティング（開始時に確定）
+	Seed         int       `gorm:"not null;default:0"` // 開始時に決まるシード順位（1始まり）
+	CreatedAt    time.Time // 登録日時
+}
+
+// TournamentMatch は大会の1対戦カード
+// PlayerBID が 0 の行は不戦勝（作成時点で決着済み）
+type TournamentMatch struct {
+	ID           uint   `gorm:"primaryKey"`
+	TournamentID uint   `gorm:"not null;index:idx_tournament_match,priority:1"`
+	Round        int    `gorm:"not null;index:idx_tournament_match,priority:2"`
+	Slot         int    `gorm:"not null"` // ラウンド内の位置（0始まり）
+	PlayerAID    uint   `gorm:"not null;index"`
+	PlayerBID    uint   `gorm:"not null;index"`
+	WinnerID     uint   `gorm:"not null;default:0"`                                // 引き分け・両者不参加は0
+	Status       string `gorm:"type:varchar(32);not null;default:'pending';index"` // "pending", "playing", "done"
+	Forfeit      bool   `gorm:"not null;default:false"`                            // 不参加による決着か
+	// 対戦に来た（WebSocketで参加した）かどうか
+	CheckedInA  bool       `gorm:"not null;default:false"`
+	CheckedInB  bool       `gorm:"not null;default:false"`
+	MatchID     *uint      // 実際に行った試合の対戦履歴（matches.id）
+	ScheduledAt time.Time  `gorm:"not null"`       // このラウンドの開始日時（これより前は参加できない）
+	DeadlineAt  time.Time  `gorm:"not null;index"` // 参加の締め切り
+	FinishedAt  *time.Time // 決着した日時
+}
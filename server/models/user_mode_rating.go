@@ -0,0 +1,72 @@
+package models
+
+import (
+	"strings"
+	"time"
+)
+
+// RatingModes はモード別レーティングを持つ対戦モード
+var RatingModes = []string{"text-major", "text-rare", "audio-major", "audio-rare"}
+
+// IsRatingMode はモード別レーティングの対象モードかどうかを返す
+func IsRatingMode(mode string) bool {
+	for _, m := range RatingModes {
+		if m == mode {
+			return true
+		}
+	}
+	return false
+}
+
+// TeamRatingMode は2対2のチーム戦で使うレーティングのモード名を返す（例: "team-text-major"）
+// チーム戦のレーティングは個人戦と分けて、同じテーブルにこのモード名で保存する
+func TeamRatingMode(mode string) string {
+	return "team-" + mode
+}
+
+// IsRatedMode はレーティングが記録されるモードかどうかを返す
+// 個人戦のモードに加えて、チーム戦（team-*）とバトルロイヤル（royale-*）のモードも含む
+func IsRatedMode(mode string) bool {
+	for _, prefix := range []string{"team-", "royale-"} {
+		if rest, ok := strings.CutPrefix(mode, prefix); ok {
+			return IsRatingMode(rest)
+		}
+	}
+	return IsRatingMode(mode)
+}
+
+// RoyaleRatingMode はバトルロイヤル（脱落戦）で使うレーティングのモード名を返す（例: "royale-text-major"）
+// 大人数の順位で動くので、1対1のレーティングとは分けて保存する
+func RoyaleRatingMode(mode string) string {
+	return "royale-" + mode
+}
+
+// UserModeRating はユーザーのモードごとのレーティングと戦績
+// まだそのモードで対戦していないユーザーの行は存在せず、初期値として扱う
+// 導入前からいるユーザーの行は、起動時に全体のレーティングとRDから作られる（BackfillFromUsers、戦績は0から）
+type UserModeRating struct {
+	ID               uint       `gorm:"primaryKey"`
+	UserID           uint       `gorm:"not null;uniqueIndex:idx_user_mode"`
+	Mode             string     `gorm:"type:varchar(32);not null;uniqueIndex:idx_user_mode;index:idx_mode_rating,priority:1"`
+	Rating           int        `gorm:"not null;default:1000;index:idx_mode_rating,priority:2" json:"rating"`
+	RatingDeviation  float64    `gorm:"not null;default:350" json:"ratingDeviation"`
+	RatingVolatility float64    `gorm:"not null;default:0.06" json:"ratingVolatility"`
+	RatedAt          *time.Time `json:"ratedAt"`
+	Wins             int        `gorm:"not null;default:0" json:"wins"`
+	Losses           int        `gorm:"not null;default:0" json:"losses"`
+	Draws            int        `gorm:"not null;default:0" json:"draws"`
+	User             User       `gorm:"constraint:OnDelete:CASCADE"`
+	CreatedAt        time.Time
+	UpdatedAt        time.Time
+}
+
+// NewUserModeRating はまだ対戦していないモードの初期状態を返す（DBのデフォルト値と揃える）
+func NewUserModeRating(userID uint, mode string) *UserModeRating {
+	return &UserModeRating{
+		UserID:           userID,
+		Mode:             mode,
+		Rating:           1000,
+		RatingDeviation:  350,
+		RatingVolatility: 0.06,
+	}
+}
@@ -1,15 +1,86 @@
 package models
 
-import "time"
+import (
+	"math"
+	"strings"
+	"time"
+)
+
+// ユーザーの権限
+const (
+	RoleUser  = "user"  // 一般ユーザー
+	RoleAdmin = "admin" // 問題の管理APIを使える
+)
+
+// BotUsernamePrefix は bot の対戦相手のユーザー名の先頭（"bot-easy" など）
+// 対戦中の得点や没収はユーザー名で管理するので、登録ユーザーにはこの名前を使わせない
+const BotUsernamePrefix = "bot-"
+
+// IsReservedUsername は bot 用に予約されたユーザー名かを返す（大文字小文字は区別しない）
+func IsReservedUsername(username string) bool {
+	return strings.HasPrefix(strings.ToLower(username), BotUsernamePrefix)
+}
 
 type User struct {
-	ID        uint   `gorm:"primaryKey"`
-	Username  string `gorm:"unique;not null"`
-	Password  string
-	ImageURL  string `gorm:"type:text" json:"imageUrl"`
-	Bio       string `gorm:"type:text" json:"bio"`
-	Rating    int    `gorm:"not null;default:1000" json:"rating"`
-	Wins      int    `gorm:"not null;default:0" json:"wins"`
-	Losses    int    `gorm:"not null;default:0" json:"losses"`
+	ID       uint   `gorm:"primaryKey"`
+	Username string `gorm:"unique;not null"`
+	Password string
+	ImageURL string `gorm:"type:text" json:"imageUrl"`
+	Bio      string `gorm:"type:text" json:"bio"`
+	Rating   int    `gorm:"not null;default:1000" json:"rating"`
+	Wins     int    `gorm:"not null;default:0" json:"wins"`
+	Losses   int    `gorm:"not null;default:0" json:"losses"`
+	Draws    int    `gorm:"not null;default:0" json:"draws"`
+	// Glicko-2 用のレーティングの不確かさ（RD）と変動性（σ）
+	// Elo を使う場合も RD は対戦数に応じて縮み、暫定判定とランキング掲載条件に使う
+	RatingDeviation  float64    `gorm:"not null;default:350" json:"ratingDeviation"`
+	RatingVolatility float64    `gorm:"not null;default:0.06" json:"ratingVolatility"`
+	RatedAt          *time.Time `json:"ratedAt"` // 最後にレーティングが更新された日時
+	// 権限（"user" / "admin"）。管理者への昇格は set-role コマンドで行う
+	Role      string `gorm:"type:varchar(32);not null;default:'user'" json:"role"`
 	CreatedAt time.Time
 }
+
+// レーティングの不確かさ（RD）の計算に使う Glicko-2 のパラメータ
+const (
+	DefaultRatingDeviation  = 350.0              // 新規ユーザーのRD（これより大きくはならない）
+	DefaultRatingVolatility = 0.06               // 新規ユーザーのσ
+	RatingPeriod            = 7 * 24 * time.Hour // この期間対戦しないごとにRDが広がる
+	glickoScale             = 173.7178           // Glicko スケールと Glicko-2 内部スケールの変換係数
+)
+
+// CurrentDeviation は保存されたRDに、最後の対戦から経ったレーティング期間の分の広がりを加えたRDを返す
+// 広がりは対戦時にしか保存されないので、ランキングの掲載判定など読み出し時にはこちらを使う
+// 例: RD 140・σ 0.06 なら、対戦しないまま4週間で約142、1年で約159（暫定扱いに戻る）
+func CurrentDeviation(deviation, volatility float64, ratedAt *time.Time, now time.Time) float64 {
+	if deviation <= 0 || deviation > DefaultRatingDeviation {
+		deviation = DefaultRatingDeviation
+	}
+	if volatility <= 0 {
+		volatility = DefaultRatingVolatility
+	}
+	if ratedAt == nil {
+		return deviation
+	}
+	step := volatility * glickoScale
+	for periods := int(now.Sub(*ratedAt) / RatingPeriod); periods > 0 && deviation < DefaultRatingDeviation; periods-- {
+		deviation = math.Min(math.Sqrt(deviation*deviation+step*step), DefaultRatingDeviation)
+	}
+	return deviation
+}
+
+// EstimatedDeviation は対戦数だけが分かっているユーザーのRDを推定する（RD導入前のユーザーの移行用）
+// 同じ強さ・同じRDの相手と games 回対戦したものとして、対戦ごとに Glicko-2 と同じ式でRDを縮める
+// 例: 5試合で約174、8試合で約138、20試合で約88
+func EstimatedDeviation(games int) float64 {
+	phi := DefaultRatingDeviation / glickoScale
+	sigma := DefaultRatingVolatility
+	for i := 0; i < games; i++ {
+		g := 1 / math.Sqrt(1+3*phi*phi/(math.Pi*math.Pi))
+		// 期待勝率 0.5 の相手なので推定分散は v = 1 / (g² × 0.25)
+		v := 1 / (g * g * 0.25)
+		phiStar := math.Sqrt(phi*phi + sigma*sigma)
+		phi = 1 / math.Sqrt(1/(phiStar*phiStar)+1/v)
+	}
+	return phi * glickoScale
+}
@@ -50,3 +50,75 @@ func (r *AudioQuestionRepository) FindRandomNRare(limit int) ([]models.RareAudio
 	}
 	return questions, nil
 }
+
+// Search は管理用にメジャー言語の音声問題を検索し、1ページ分と総件数を返す（ID順）
+func (r *AudioQuestionRepository) Search(s QuestionSearch) ([]models.AudioQuestion, int64, error) {
+	return searchQuestions[models.AudioQuestion](r.db, audioQuestionColumns, "language", s)
+}
+
+// FindByID はIDでメジャー言語の音声問題を1件取得する（見つからなければnil）
+// withDeleted が true なら削除済みの問題も返す
+func (r *AudioQuestionRepository) FindByID(id uint, withDeleted bool) (*models.AudioQuestion, error) {
+	return findQuestion[models.AudioQuestion](r.db, id, withDeleted)
+}
+
+// Create はメジャー言語の音声問題を追加する（ID は自動採番）
+func (r *AudioQuestionRepository) Create(q *models.AudioQuestion) error {
+	return r.db.Create(q).Error
+}
+
+// Save はメジャー言語の音声問題の内容を上書きする
+func (r *AudioQuestionRepository) Save(q *models.AudioQuestion) error {
+	return r.db.Save(q).Error
+}
+
+// Delete はメジャー言語の音声問題を論理削除する（存在しないか削除済みなら false）
+func (r *AudioQuestionRepository) Delete(id uint, by string) (bool, error) {
+	return deleteQuestion[models.AudioQuestion](r.db, id, by)
+}
+
+// Restore は論理削除したメジャー言語の音声問題を元に戻す（存在しないか削除されていなければ false）
+func (r *AudioQuestionRepository) Restore(id uint, by string) (bool, error) {
+	return restoreQuestion[models.AudioQuestion](r.db, id, by)
+}
+
+// SearchRare は管理用にレア言語の音声問題を検索し、1ページ分と総件数を返す（ID順）
+func (r *AudioQuestionRepository) SearchRare(s QuestionSearch) ([]models.RareAudioQuestion, int64, error) {
+	return searchQuestions[models.RareAudioQuestion](r.db, audioQuestionColumns, "language", s)
+}
+
+// FindRareByID はIDでレア言語の音声問題を1件取得する（見つからなければnil）
+// withDeleted が true なら削除済みの問題も返す
+func (r *AudioQuestionRepository) FindRareByID(id uint, withDeleted bool) (*models.RareAudioQuestion, error) {
+	return findQuestion[models.RareAudioQuestion](r.db, id, withDeleted)
+}
+
+// CreateRare はレア言語の音声問題を追加する（ID は自動採番）
+func (r *AudioQuestionRepository) CreateRare(q *models.RareAudioQuestion) error {
+	return r.db.Create(q).Error
+}
+
+// SaveRare はレア言語の音声問題の内容を上書きする
+func (r *AudioQuestionRepository) SaveRare(q *models.RareAudioQuestion) error {
+	return r.db.Save(q).Error
+}
+
+// DeleteRare はレア言語の音声問題を論理削除する（存在しないか削除済みなら false）
+func (r *AudioQuestionRepository) DeleteRare(id uint, by string) (bool, error) {
+	return deleteQuestion[models.RareAudioQuestion](r.db, id, by)
+}
+
+// RestoreRare は論理削除したレア言語の音声問題を元に戻す（存在しないか削除されていなければ false）
+func (r *AudioQuestionRepository) RestoreRare(id uint, by string) (bool, error) {
+	return restoreQuestion[models.RareAudioQuestion](r.db, id, by)
+}
+
+// FindAll は削除済みを除くメジャー言語の音声問題をすべてID順に返す
+func (r *AudioQuestionRepository) FindAll() ([]models.AudioQuestion, error) {
+	return findAllQuestions[models.AudioQuestion](r.db)
+}
+
+// FindAllRare は削除済みを除くレア言語の音声問題をすべてID順に返す
+func (r *AudioQuestionRepository) FindAllRare() ([]models.RareAudioQuestion, error) {
+	return findAllQuestions[models.RareAudioQuestion](r.db)
+}
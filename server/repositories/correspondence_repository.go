@@ -0,0 +1,143 @@
+package repositories
+
+import (
+	"errors"
+	"time"
+
+	"example.com/mathkun-tmp-/server/models"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// CorrespondenceRepository は非同期対戦・参加者の進み具合・回答へのDB操作をまとめる
+type CorrespondenceRepository struct {
+	db *gorm.DB // GORM DBインスタンス（非同期対戦関連テーブル操作用）
+}
+
+// NewCorrespondenceRepository はDB接続を受け取ってリポジトリを作る
+// 出題・回答・決着は行ロックを取ったトランザクション内のtxを渡して行う
+func NewCorrespondenceRepository(db *gorm.DB) *CorrespondenceRepository {
+	return &CorrespondenceRepository{db: db}
+}
+
+// Create は非同期対戦を保存する（Players を含めれば一緒に保存される）
+func (r *CorrespondenceRepository) Create(m *models.CorrespondenceMatch) error {
+	return r.db.Create(m).Error
+}
+
+// Save は非同期対戦の変更を保存する（参加者は保存しない）
+func (r *CorrespondenceRepository) Save(m *models.CorrespondenceMatch) error {
+	return r.db.Omit(clause.Associations).Save(m).Error
+}
+
+// FindByID はIDで非同期対戦を参加者付きで取得する（見つからなければnil）
+func (r *CorrespondenceRepository) FindByID(id uint) (*models.CorrespondenceMatch, error) {
+	var m models.CorrespondenceMatch
+	if err := r.db.Preload("Players").First(&m, id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &m, nil
+}
+
+// FindByIDForUpdate は行ロックを取って非同期対戦を取得する（トランザクション内で使う）
+// 同じ人の出題と回答、2人の決着が同時に来ても、進み具合を二重に進めないようにする
+func (r *CorrespondenceRepository) FindByIDForUpdate(id uint) (*models.CorrespondenceMatch, error) {
+	var m models.CorrespondenceMatch
+	if err := r.db.Clauses(clause.Locking{Strength: "UPDATE"}).First(&m, id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &m, nil
+}
+
+// FindByUser はユーザーが挑戦した・挑戦された非同期対戦を新しい順に参加者付きで取得する（status を指定すればその状態だけ）
+func (r *CorrespondenceRepository) FindByUser(userID uint, status string) ([]models.CorrespondenceMatch, error) {
+	query := r.db.Preload("Players").
+		Where("challenger_id = ? OR opponent_id = ?", userID, userID).
+		Order("created_at DESC, id DESC")
+	if status != "" {
+		query = query.Where("status = ?", status)
+	}
+	var matches []models.CorrespondenceMatch
+	if err := query.Find(&matches).Error; err != nil {
+		return nil, err
+	}
+	return matches, nil
+}
+
+// FindOpenChallenges は誰でも受けられる受付中の挑戦を期限の近い順に取得する
+// 自分の挑戦（excludeUserID）は除き、mode を指定すればそのモードだけ
+func (r *CorrespondenceRepository) FindOpenChallenges(mode string, excludeUserID uint, now time.Time) ([]models.CorrespondenceMatch, error) {
+	query := r.db.Preload("Players").
+		Where("status = ? AND opponent_id = 0 AND challenger_id <> ? AND deadline_at > ?", "open", excludeUserID, now).
+		Order("deadline_at ASC, id ASC")
+	if mode != "" {
+		query = query.Where("mode = ?", mode)
+	}
+	var matches []models.CorrespondenceMatch
+	if err := query.Find(&matches).Error; err != nil {
+		return nil, err
+	}
+	return matches, nil
+}
+
+// FindExpired は期限を過ぎても決着していない非同期対戦を取得する
+func (r *CorrespondenceRepository) FindExpired(now time.Time) ([]models.CorrespondenceMatch, error) {
+	var matches []models.CorrespondenceMatch
+	err := r.db.Where("status = ? AND deadline_at <= ?", "open", now).
+		Order("deadline_at ASC, id ASC").
+		Find(&matches).Error
+	if err != nil {
+		return nil, err
+	}
+	return matches, nil
+}
+
+// CreatePlayer は参加者の進み具合を保存する
+func (r *CorrespondenceRepository) CreatePlayer(p *models.CorrespondencePlayer) error {
+	return r.db.Create(p).Error
+}
+
+// SavePlayer は参加者の進み具合の変更を保存する（回答は保存しない）
+func (r *CorrespondenceRepository) SavePlayer(p *models.CorrespondencePlayer) error {
+	return r.db.Omit(clause.Associations).Save(p).Error
+}
+
+// FindPlayer はユーザーの進み具合を取得する（まだ始めていなければnil）
+func (r *CorrespondenceRepository) FindPlayer(matchID, userID uint) (*models.CorrespondencePlayer, error) {
+	var p models.CorrespondencePlayer
+	err := r.db.Where("correspondence_match_id = ? AND user_id = ?", matchID, userID).First(&p).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &p, nil
+}
+
+// FindPlayersWithAnswers は非同期対戦の参加者を始めた順に回答付きで取得する
+func (r *CorrespondenceRepository) FindPlayersWithAnswers(matchID uint) ([]models.CorrespondencePlayer, error) {
+	var players []models.CorrespondencePlayer
+	err := r.db.Preload("Answers", func(db *gorm.DB) *gorm.DB {
+		return db.Order("round ASC")
+	}).
+		Where("correspondence_match_id = ?", matchID).
+		Order("created_at ASC, id ASC").
+		Find(&players).Error
+	if err != nil {
+		return nil, err
+	}
+	return players, nil
+}
+
+// CreateAnswer は1問分の回答を保存する
+func (r *CorrespondenceRepository) CreateAnswer(a *models.CorrespondenceAnswer) error {
+	return r.db.Create(a).Error
+}
@@ -0,0 +1,69 @@
+package repositories
+
+import (
+	"errors"
+
+	"example.com/mathkun-tmp-/server/models"
+
+	"gorm.io/gorm"
+)
+
+// MatchRepository は対戦履歴へのDB操作をまとめる
+// 試合本体（matches）と参加者（match_participants）、ラウンド回答（match_rounds）を扱う
+type MatchRepository struct {
+	db *gorm.DB // GORM DBインスタンス（対戦履歴テーブル操作用）
+}
+
+// NewMatchRepository はDB接続を受け取ってリポジトリを作る
+// マッチ終了時はトランザクション内のtxを渡してレーティング更新と同時に保存する
+func NewMatchRepository(db *gorm.DB) *MatchRepository {
+	return &MatchRepository{db: db}
+}
+
+// Create は試合と参加者、ラウンド回答をまとめて保存する
+// Participants と Rounds はGORMの関連付けで同時にINSERTされる
+func (r *MatchRepository) Create(match *models.Match) error {
+	return r.db.Create(match).Error
+}
+
+// FindByID は試合をID指定で取得する（参加者とラウンド回答も読み込む、見つからなければnil）
+func (r *MatchRepository) FindByID(id uint) (*models.Match, error) {
+	var match models.Match
+	err := r.db.
+		Preload("Participants", func(db *gorm.DB) *gorm.DB { return db.Order("`rank` ASC") }).
+		Preload("Rounds", func(db *gorm.DB) *gorm.DB { return db.Order("round ASC, id ASC") }).
+		First(&match, id).Error
+	if err != nil {
+		// レコードが見つからない場合は nil を返す（エラーではない）
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &match, nil
+}
+
+// FindByUsername は指定ユーザーが参加した試合を新しい順に取得する
+// ページング用に総件数も返す（参加者一覧は読み込むがラウンド回答は読み込まない）
+func (r *MatchRepository) FindByUsername(username string, limit, offset int) ([]models.Match, int64, error) {
+	// 参加者テーブルから対象ユーザーの試合IDを絞り込むサブクエリ
+	sub := r.db.Model(&models.MatchParticipant{}).Select("match_id").Where("username = ?", username)
+
+	var total int64
+	if err := r.db.Model(&models.Match{}).Where("id IN (?)", sub).Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+
+	var matches []models.Match
+	err := r.db.
+		Preload("Participants", func(db *gorm.DB) *gorm.DB { return db.Order("`rank` ASC") }).
+		Where("id IN (?)", sub).
+		Order("finished_at DESC, id DESC").
+		Limit(limit).
+		Offset(offset).
+		Find(&matches).Error
+	if err != nil {
+		return nil, 0, err
+	}
+	return matches, total, nil
+}
@@ -0,0 +1,126 @@
+package repositories
+
+import (
+	"errors"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// 管理用の一覧で削除済みの問題をどう扱うか
+const (
+	DeletedExclude = ""        // 削除済みを除く（既定）
+	DeletedOnly    = "only"    // 削除済みだけ
+	DeletedInclude = "include" // 削除済みも含める
+)
+
+// 検索の対象にする列（テキスト問題と音声問題で列が違う）
+var (
+	textQuestionColumns  = []string{"prompt", "answer"}
+	audioQuestionColumns = []string{"language", "audio_url"}
+)
+
+// QuestionSearch は管理用の問題一覧の検索条件
+type QuestionSearch struct {
+	Query    string // 部分一致で探す文字列（テキスト問題は問題文と正解、音声問題は言語と音声のURL）
+	Language string // 正解の言語で絞り込む（完全一致、空なら全言語）
+	Deleted  string // 削除済みの扱い（DeletedExclude / DeletedOnly / DeletedInclude）
+	Limit    int
+	Offset   int
+}
+
+// searchQuestions は問題バンクのテーブルを検索して1ページ分と総件数を返す（ID順）
+// columns は Query を部分一致で探す列、languageColumn は Language で絞り込む列
+func searchQuestions[T any](db *gorm.DB, columns []string, languageColumn string, s QuestionSearch) ([]T, int64, error) {
+	q := db.Model(new(T))
+	switch s.Deleted {
+	case DeletedOnly:
+		q = q.Unscoped().Where("deleted_at IS NOT NULL")
+	case DeletedInclude:
+		q = q.Unscoped()
+	}
+	if s.Query != "" {
+		like := "%" + escapeLike(s.Query) + "%"
+		conds := make([]string, 0, len(columns))
+		args := make([]any, 0, len(columns))
+		for _, col := range columns {
+			conds = append(conds, col+" LIKE ?")
+			args = append(args, like)
+		}
+		q = q.Where(strings.Join(conds, " OR "), args...)
+	}
+	if s.Language != "" {
+		q = q.Where(languageColumn+" = ?", s.Language)
+	}
+	// 件数の取得と一覧の取得で同じ条件を使い回せるようにする
+	q = q.Session(&gorm.Session{})
+
+	var total int64
+	if err := q.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+	var rows []T
+	if err := q.Order("id ASC").Limit(s.Limit).Offset(s.Offset).Find(&rows).Error; err != nil {
+		return nil, 0, err
+	}
+	return rows, total, nil
+}
+
+// findAllQuestions は削除済みを除く問題をすべてID順に返す（取り込み時の重複検出と書き出しで使う）
+func findAllQuestions[T any](db *gorm.DB) ([]T, error) {
+	var rows []T
+	if err := db.Order("id ASC").Find(&rows).Error; err != nil {
+		return nil, err
+	}
+	return rows, nil
+}
+
+// findQuestion はIDで問題を1件取得する（見つからなければnil）
+// withDeleted が true なら削除済みの問題も返す
+func findQuestion[T any](db *gorm.DB, id uint, withDeleted bool) (*T, error) {
+	if withDeleted {
+		db = db.Unscoped()
+	}
+	var row T
+	if err := db.First(&row, id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &row, nil
+}
+
+// deleteQuestion は問題を論理削除し、削除したユーザーを記録する
+// 存在しないか削除済みなら false を返す
+func deleteQuestion[T any](db *gorm.DB, id uint, by string) (bool, error) {
+	// UpdateColumns で更新日時は変えずに削除の情報だけを書き込む
+	result := db.Model(new(T)).Where("id = ?", id).UpdateColumns(map[string]any{
+		"deleted_at": time.Now(),
+		"deleted_by": by,
+	})
+	if result.Error != nil {
+		return false, result.Error
+	}
+	return result.RowsAffected > 0, nil
+}
+
+// restoreQuestion は論理削除した問題を元に戻し、戻したユーザーを更新者として記録する
+// 存在しないか削除されていなければ false を返す
+func restoreQuestion[T any](db *gorm.DB, id uint, by string) (bool, error) {
+	result := db.Unscoped().Model(new(T)).Where("id = ? AND deleted_at IS NOT NULL", id).Updates(map[string]any{
+		"deleted_at": nil,
+		"deleted_by": "",
+		"updated_by": by,
+	})
+	if result.Error != nil {
+		return false, result.Error
+	}
+	return result.RowsAffected > 0, nil
+}
+
+// escapeLike は LIKE の検索語に含まれるワイルドカードをそのままの文字として扱うようにする
+func escapeLike(s string) string {
+	return strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`).Replace(s)
+}
@@ -61,3 +61,39 @@ func (r *QuestionRepository) FindRandomN(count int) ([]models.Question, error) {
 	}
 	return questions, nil
 }
+
+// Search は管理用にメジャー言語のテキスト問題を検索し、1ページ分と総件数を返す（ID順）
+func (r *QuestionRepository) Search(s QuestionSearch) ([]models.Question, int64, error) {
+	return searchQuestions[models.Question](r.db, textQuestionColumns, "answer", s)
+}
+
+// FindByID はIDでメジャー言語のテキスト問題を1件取得する（見つからなければnil）
+// withDeleted が true なら削除済みの問題も返す
+func (r *QuestionRepository) FindByID(id uint, withDeleted bool) (*models.Question, error) {
+	return findQuestion[models.Question](r.db, id, withDeleted)
+}
+
+// Create はメジャー言語のテキスト問題を追加する（ID は自動採番）
+func (r *QuestionRepository) Create(q *models.Question) error {
+	return r.db.Create(q).Error
+}
+
+// Save はメジャー言語のテキスト問題の内容を上書きする
+func (r *QuestionRepository) Save(q *models.Question) error {
+	return r.db.Save(q).Error
+}
+
+// Delete はメジャー言語のテキスト問題を論理削除する（存在しないか削除済みなら false）
+func (r *QuestionRepository) Delete(id uint, by string) (bool, error) {
+	return deleteQuestion[models.Question](r.db, id, by)
+}
+
+// Restore は論理削除したメジャー言語のテキスト問題を元に戻す（存在しないか削除されていなければ false）
+func (r *QuestionRepository) Restore(id uint, by string) (bool, error) {
+	return restoreQuestion[models.Question](r.db, id, by)
+}
+
+// FindAll は削除済みを除くメジャー言語のテキスト問題をすべてID順に返す
+func (r *QuestionRepository) FindAll() ([]models.Question, error) {
+	return findAllQuestions[models.Question](r.db)
+}
@@ -57,3 +57,39 @@ func (r *RareQuestionRepository) FindRandomN(count int) ([]models.RareQuestion,
 	}
 	return questions, nil
 }
+
+// Search は管理用にレア言語のテキスト問題を検索し、1ページ分と総件数を返す（ID順）
+func (r *RareQuestionRepository) Search(s QuestionSearch) ([]models.RareQuestion, int64, error) {
+	return searchQuestions[models.RareQuestion](r.db, textQuestionColumns, "answer", s)
+}
+
+// FindByID はIDでレア言語のテキスト問題を1件取得する（見つからなければnil）
+// withDeleted が true なら削除済みの問題も返す
+func (r *RareQuestionRepository) FindByID(id uint, withDeleted bool) (*models.RareQuestion, error) {
+	return findQuestion[models.RareQuestion](r.db, id, withDeleted)
+}
+
+// Create はレア言語のテキスト問題を追加する（ID は自動採番）
+func (r *RareQuestionRepository) Create(q *models.RareQuestion) error {
+	return r.db.Create(q).Error
+}
+
+// Save はレア言語のテキスト問題の内容を上書きする
+func (r *RareQuestionRepository) Save(q *models.RareQuestion) error {
+	return r.db.Save(q).Error
+}
+
+// Delete はレア言語のテキスト問題を論理削除する（存在しないか削除済みなら false）
+func (r *RareQuestionRepository) Delete(id uint, by string) (bool, error) {
+	return deleteQuestion[models.RareQuestion](r.db, id, by)
+}
+
+// Restore は論理削除したレア言語のテキスト問題を元に戻す（存在しないか削除されていなければ false）
+func (r *RareQuestionRepository) Restore(id uint, by string) (bool, error) {
+	return restoreQuestion[models.RareQuestion](r.db, id, by)
+}
+
+// FindAll は削除済みを除くレア言語のテキスト問題をすべてID順に返す
+func (r *RareQuestionRepository) FindAll() ([]models.RareQuestion, error) {
+	return findAllQuestions[models.RareQuestion](r.db)
+}
@@ -0,0 +1,64 @@
+package repositories
+
+import (
+	"slices"
+	"time"
+
+	"example.com/mathkun-tmp-/server/models"
+
+	"gorm.io/gorm"
+)
+
+// RatingHistoryRepository はレーティング推移へのDB操作をまとめる
+// マッチ終了時に追記され、参照は期間とモードで絞り込む（rating_histories）
+type RatingHistoryRepository struct {
+	db *gorm.DB // GORM DBインスタンス（rating_historiesテーブル操作用）
+}
+
+// NewRatingHistoryRepository はDB接続を受け取ってリポジトリを作る
+// マッチ終了時は対戦履歴と同じトランザクションのtxを渡す
+func NewRatingHistoryRepository(db *gorm.DB) *RatingHistoryRepository {
+	return &RatingHistoryRepository{db: db}
+}
+
+// Create はレーティング推移を1件保存する
+func (r *RatingHistoryRepository) Create(entry *models.RatingHistory) error {
+	return r.db.Create(entry).Error
+}
+
+// FindByUser は指定ユーザーのレーティング推移を古い順に取得する
+// from / to がゼロ値ならその側の期間を制限しない（to はその時刻を含まない）
+func (r *RatingHistoryRepository) FindByUser(userID uint, mode string, from, to time.Time) ([]models.RatingHistory, error) {
+	query := r.db.Where("user_id = ? AND mode = ?", userID, mode)
+	if !from.IsZero() {
+		query = query.Where("played_at >= ?", from)
+	}
+	if !to.IsZero() {
+		query = query.Where("played_at < ?", to)
+	}
+
+	var entries []models.RatingHistory
+	if err := query.Order("played_at ASC, id ASC").Find(&entries).Error; err != nil {
+		return nil, err
+	}
+	return entries, nil
+}
+
+// FindRecentByUser は指定ユーザーのレーティング推移を新しいものから limit 件まで取得し、古い順に並べて返す
+// 期間の指定は FindByUser と同じ
+func (r *RatingHistoryRepository) FindRecentByUser(userID uint, mode string, from, to time.Time, limit int) ([]models.RatingHistory, error) {
+	query := r.db.Where("user_id = ? AND mode = ?", userID, mode)
+	if !from.IsZero() {
+		query = query.Where("played_at >= ?", from)
+	}
+	if !to.IsZero() {
+		query = query.Where("played_at < ?", to)
+	}
+
+	var entries []models.RatingHistory
+	if err := query.Order("played_at DESC, id DESC").Limit(limit).Find(&entries).Error; err != nil {
+		return nil, err
+	}
+	slices.Reverse(entries)
+	return entries, nil
+}
@@ -0,0 +1,114 @@
+package repositories
+
+import (
+	"errors"
+	"time"
+
+	"example.com/mathkun-tmp-/server/models"
+
+	"gorm.io/gorm"
+)
+
+// SeasonRepository はシーズンと最終順位へのDB操作をまとめる
+// シーズン本体（seasons）とアーカイブした順位（season_standings）を扱う
+type SeasonRepository struct {
+	db *gorm.DB // GORM DBインスタンス（シーズン関連テーブル操作用）
+}
+
+// NewSeasonRepository はDB接続を受け取ってリポジトリを作る
+// シーズンの締めはトランザクション内のtxを渡してリセットと同時に行う
+func NewSeasonRepository(db *gorm.DB) *SeasonRepository {
+	return &SeasonRepository{db: db}
+}
+
+// SeasonStatRow はシーズン期間中のユーザー・モードごとの戦績
+type SeasonStatRow struct {
+	UserID uint
+	Mode   string
+	Wins   int
+	Losses int
+	Draws  int
+}
+
+// Create はシーズンを保存する
+func (r *SeasonRepository) Create(season *models.Season) error {
+	return r.db.Create(season).Error
+}
+
+// Save はシーズンの変更を保存する
+func (r *SeasonRepository) Save(season *models.Season) error {
+	return r.db.Save(season).Error
+}
+
+// FindAll は全シーズンを新しい順に取得する
+func (r *SeasonRepository) FindAll() ([]models.Season, error) {
+	var seasons []models.Season
+	if err := r.db.Order("starts_at DESC, id DESC").Find(&seasons).Error; err != nil {
+		return nil, err
+	}
+	return seasons, nil
+}
+
+// FindByID はシーズンをID指定で取得する（見つからなければnil）
+func (r *SeasonRepository) FindByID(id uint) (*models.Season, error) {
+	var season models.Season
+	if err := r.db.First(&season, id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &season, nil
+}
+
+// FindActive は開催中のシーズンを取得する（なければnil）
+func (r *SeasonRepository) FindActive() (*models.Season, error) {
+	var season models.Season
+	if err := r.db.Where("status = ?", "active").Order("starts_at DESC").First(&season).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &season, nil
+}
+
+// CreateStandings はアーカイブする最終順位をまとめて保存する
+func (r *SeasonRepository) CreateStandings(standings []models.SeasonStanding) error {
+	if len(standings) == 0 {
+		return nil
+	}
+	return r.db.CreateInBatches(standings, 500).Error
+}
+
+// FindStandings はアーカイブ済みの順位を上位から取得する（順位の付いていない行は含めない）
+func (r *SeasonRepository) FindStandings(seasonID uint, mode string, limit int) ([]models.SeasonStanding, error) {
+	var standings []models.SeasonStanding
+	err := r.db.
+		Where("season_id = ? AND mode = ? AND `rank` IS NOT NULL", seasonID, mode).
+		Order("`rank` ASC, id ASC").
+		Limit(limit).
+		Find(&standings).Error
+	if err != nil {
+		return nil, err
+	}
+	return standings, nil
+}
+
+// StatsBetween はレーティング推移から期間中のユーザー・モードごとの勝敗数を集計する
+// to はその時刻を含まない。modes のモードだけを数える（チーム戦・バトルロイヤルの推移を除くため）
+func (r *SeasonRepository) StatsBetween(from, to time.Time, modes []string) ([]SeasonStatRow, error) {
+	var rows []SeasonStatRow
+	err := r.db.Model(&models.RatingHistory{}).
+		Select("user_id, mode, "+
+			"SUM(CASE WHEN outcome = 'victory' THEN 1 ELSE 0 END) AS wins, "+
+			"SUM(CASE WHEN outcome = 'defeat' THEN 1 ELSE 0 END) AS losses, "+
+			"SUM(CASE WHEN outcome = 'draw' THEN 1 ELSE 0 END) AS draws").
+		Where("played_at >= ? AND played_at < ? AND mode IN ?", from, to, modes).
+		Group("user_id, mode").
+		Scan(&rows).Error
+	if err != nil {
+		return nil, err
+	}
+	return rows, nil
+}
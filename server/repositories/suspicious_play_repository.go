@@ -0,0 +1,59 @@
+package repositories
+
+import (
+	"errors"
+
+	"example.com/mathkun-tmp-/server/models"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// SuspiciousPlayRepository は不正の疑いがあるプレイ記録へのDB操作をまとめる
+// 管理者が後から確認するレビュー用テーブル（suspicious_play_flags）を扱う
+type SuspiciousPlayRepository struct {
+	db *gorm.DB // GORM DBインスタンス（suspicious_play_flagsテーブル操作用）
+}
+
+// NewSuspiciousPlayRepository はDB接続を受け取ってリポジトリを作る
+// マッチ終了時は対戦履歴と同じトランザクションのtxを渡す
+func NewSuspiciousPlayRepository(db *gorm.DB) *SuspiciousPlayRepository {
+	return &SuspiciousPlayRepository{db: db}
+}
+
+// Create は疑わしいプレイの記録を保存する
+func (r *SuspiciousPlayRepository) Create(flag *models.SuspiciousPlayFlag) error {
+	return r.db.Create(flag).Error
+}
+
+// FindPending は未確認の記録を新しい順に取得する
+// 管理画面のレビュー待ち一覧で使用
+func (r *SuspiciousPlayRepository) FindPending(limit int) ([]models.SuspiciousPlayFlag, error) {
+	// limitが不正な場合はデフォルトで50件にする
+	if limit <= 0 {
+		limit = 50
+	}
+	var flags []models.SuspiciousPlayFlag
+	if err := r.db.Where("status = ?", "pending").Order("created_at DESC").Limit(limit).Find(&flags).Error; err != nil {
+		return nil, err
+	}
+	return flags, nil
+}
+
+// FindByIDForUpdate はIDで記録を行ロック付きで取得する（見つからなければnil）
+// 同じ記録を2人の管理者が同時に確認しても、後から来た方が結果を上書きしないようにする
+func (r *SuspiciousPlayRepository) FindByIDForUpdate(id uint) (*models.SuspiciousPlayFlag, error) {
+	var flag models.SuspiciousPlayFlag
+	if err := r.db.Clauses(clause.Locking{Strength: "UPDATE"}).First(&flag, id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &flag, nil
+}
+
+// Save は記録の変更（確認結果）を保存する
+func (r *SuspiciousPlayRepository) Save(flag *models.SuspiciousPlayFlag) error {
+	return r.db.Save(flag).Error
+}
@@ -0,0 +1,198 @@
+package repositories
+
+import (
+	"errors"
+	"time"
+
+	"example.com/mathkun-tmp-/server/models"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// TournamentRepository は大会・参加登録・対戦カードへのDB操作をまとめる
+type TournamentRepository struct {
+	db *gorm.DB // GORM DBインスタンス（大会関連テーブル操作用）
+}
+
+// NewTournamentRepository はDB接続を受け取ってリポジトリを作る
+// 結果の記録と次のラウンドの作成はトランザクション内のtxを渡して同時に行う
+func NewTournamentRepository(db *gorm.DB) *TournamentRepository {
+	return &TournamentRepository{db: db}
+}
+
+// Create は大会を保存する
+func (r *TournamentRepository) Create(t *models.Tournament) error {
+	return r.db.Create(t).Error
+}
+
+// Save は大会の変更を保存する
+func (r *TournamentRepository) Save(t *models.Tournament) error {
+	return r.db.Save(t).Error
+}
+
+// FindByID はIDで大会を取得する（見つからなければnil）
+func (r *TournamentRepository) FindByID(id uint) (*models.Tournament, error) {
+	var t models.Tournament
+	if err := r.db.First(&t, id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &t, nil
+}
+
+// FindByIDForUpdate は行ロックを取って大会を取得する（トランザクション内で使う）
+// 同じラウンドの最後の2試合が同時に終わっても、次のラウンドを二重に作らないようにする
+func (r *TournamentRepository) FindByIDForUpdate(id uint) (*models.Tournament, error) {
+	var t models.Tournament
+	if err := r.db.Clauses(clause.Locking{Strength: "UPDATE"}).First(&t, id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &t, nil
+}
+
+// FindAll は大会を開始予定の新しい順に取得する（status を指定すればその状態だけ）
+func (r *TournamentRepository) FindAll(status string) ([]models.Tournament, error) {
+	query := r.db.Order("starts_at DESC, id DESC")
+	if status != "" {
+		query = query.Where("status = ?", status)
+	}
+	var tournaments []models.Tournament
+	if err := query.Find(&tournaments).Error; err != nil {
+		return nil, err
+	}
+	return tournaments, nil
+}
+
+// FindDueToStart は開始予定を過ぎた登録受付中の大会を取得する
+func (r *TournamentRepository) FindDueToStart(now time.Time) ([]models.Tournament, error) {
+	var tournaments []models.Tournament
+	err := r.db.Where("status = ? AND starts_at <= ?", "registration", now).
+		Order("starts_at ASC, id ASC").
+		Find(&tournaments).Error
+	if err != nil {
+		return nil, err
+	}
+	return tournaments, nil
+}
+
+// CreateEntry は参加登録を保存する
+func (r *TournamentRepository) CreateEntry(entry *models.TournamentEntry) error {
+	return r.db.Create(entry).Error
+}
+
+// SaveEntry は参加登録の変更（シードなど）を保存する
+func (r *TournamentRepository) SaveEntry(entry *models.TournamentEntry) error {
+	return r.db.Save(entry).Error
+}
+
+// DeleteEntry は参加登録を取り消す（登録がなければfalse）
+func (r *TournamentRepository) DeleteEntry(tournamentID, userID uint) (bool, error) {
+	result := r.db.Where("tournament_id = ? AND user_id = ?", tournamentID, userID).Delete(&models.TournamentEntry{})
+	if result.Error != nil {
+		return false, result.Error
+	}
+	return result.RowsAffected > 0, nil
+}
+
+// FindEntries は大会の参加登録を登録順に取得する
+func (r *TournamentRepository) FindEntries(tournamentID uint) ([]models.TournamentEntry, error) {
+	var entries []models.TournamentEntry
+	err := r.db.Where("tournament_id = ?", tournamentID).
+		Order("created_at ASC, id ASC").
+		Find(&entries).Error
+	if err != nil {
+		return nil, err
+	}
+	return entries, nil
+}
+
+// FindEntry はユーザーの参加登録を取得する（登録していなければnil）
+func (r *TournamentRepository) FindEntry(tournamentID, userID uint) (*models.TournamentEntry, error) {
+	var entry models.TournamentEntry
+	err := r.db.Where("tournament_id = ? AND user_id = ?", tournamentID, userID).First(&entry).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &entry, nil
+}
+
+// CountEntries は大会の参加人数を数える
+func (r *TournamentRepository) CountEntries(tournamentID uint) (int64, error) {
+	var count int64
+	err := r.db.Model(&models.TournamentEntry{}).Where("tournament_id = ?", tournamentID).Count(&count).Error
+	return count, err
+}
+
+// CreateMatches は対戦カードをまとめて保存する
+func (r *TournamentRepository) CreateMatches(matches []models.TournamentMatch) error {
+	if len(matches) == 0 {
+		return nil
+	}
+	return r.db.Create(&matches).Error
+}
+
+// SaveMatch は対戦カードの変更を保存する
+func (r *TournamentRepository) SaveMatch(match *models.TournamentMatch) error {
+	return r.db.Save(match).Error
+}
+
+// FindMatches は大会の全対戦カードをラウンド・枠の順に取得する
+func (r *TournamentRepository) FindMatches(tournamentID uint) ([]models.TournamentMatch, error) {
+	var matches []models.TournamentMatch
+	err := r.db.Where("tournament_id = ?", tournamentID).
+		Order("round ASC, slot ASC").
+		Find(&matches).Error
+	if err != nil {
+		return nil, err
+	}
+	return matches, nil
+}
+
+// FindMatchByID はIDで対戦カードを取得する（見つからなければnil）
+func (r *TournamentRepository) FindMatchByID(id uint) (*models.TournamentMatch, error) {
+	var match models.TournamentMatch
+	if err := r.db.First(&match, id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &match, nil
+}
+
+// FindOpenMatchForUser はユーザーのまだ決着していない対戦カードを取得する（なければnil）
+func (r *TournamentRepository) FindOpenMatchForUser(tournamentID, userID uint) (*models.TournamentMatch, error) {
+	var match models.TournamentMatch
+	err := r.db.Where("tournament_id = ? AND status <> ? AND (player_a_id = ? OR player_b_id = ?)", tournamentID, "done", userID, userID).
+		Order("round ASC, slot ASC").
+		First(&match).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &match, nil
+}
+
+// FindOverdueMatches は締め切りを過ぎても決着していない対戦カードを取得する
+// 参加待ちは参加の締め切り、進行中は試合が終わっているはずの時刻を deadline_at に持つ
+func (r *TournamentRepository) FindOverdueMatches(now time.Time) ([]models.TournamentMatch, error) {
+	var matches []models.TournamentMatch
+	err := r.db.Where("status IN ? AND deadline_at < ?", []string{"pending", "playing"}, now).
+		Order("tournament_id ASC, round ASC, slot ASC").
+		Find(&matches).Error
+	if err != nil {
+		return nil, err
+	}
+	return matches, nil
+}
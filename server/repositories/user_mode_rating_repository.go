@@ -0,0 +1,154 @@
+package repositories
+
+import (
+	"errors"
+	"time"
+
+	"example.com/mathkun-tmp-/server/models"
+
+	"gorm.io/gorm"
+)
+
+// UserModeRatingRepository はモード別レーティングへのDB操作をまとめる
+// ユーザーとモードの組ごとに1行（user_mode_ratings）を持つ
+type UserModeRatingRepository struct {
+	db *gorm.DB // GORM DBインスタンス（user_mode_ratingsテーブル操作用）
+}
+
+// NewUserModeRatingRepository はDB接続を受け取ってリポジトリを作る
+// マッチ終了時はレーティング更新と同じトランザクションのtxを渡す
+func NewUserModeRatingRepository(db *gorm.DB) *UserModeRatingRepository {
+	return &UserModeRatingRepository{db: db}
+}
+
+// FindOrDefault はユーザーのモード別レーティングを取得する
+// まだそのモードで対戦していなければ初期値（未保存）を返す
+func (r *UserModeRatingRepository) FindOrDefault(userID uint, mode string) (*models.UserModeRating, error) {
+	var rating models.UserModeRating
+	err := r.db.Where("user_id = ? AND mode = ?", userID, mode).First(&rating).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return models.NewUserModeRating(userID, mode), nil
+		}
+		return nil, err
+	}
+	return &rating, nil
+}
+
+// Save はモード別レーティングを保存する（IDがなければINSERT、あればUPDATE）
+func (r *UserModeRatingRepository) Save(rating *models.UserModeRating) error {
+	return r.db.Omit("User").Save(rating).Error
+}
+
+// FindTopByRating はモード別レーティング上位のユーザーを取得する
+// モード別リーダーボードで使用（そのモードで試合数条件を満たし、暫定でないユーザーのみ）
+// 暫定かどうかは UserRepository.FindTopByRating と同じく現在のRDで判定する
+func (r *UserModeRatingRepository) FindTopByRating(mode string, limit int) ([]models.UserModeRating, error) {
+	// limitが不正な場合はデフォルトで30人にする
+	if limit <= 0 {
+		limit = 30
+	}
+	now := time.Now()
+	ratings := make([]models.UserModeRating, 0, limit)
+	for offset := 0; ; offset += limit {
+		var page []models.UserModeRating
+		// ユーザー名と画像を表示するため User も読み込む
+		if err := r.db.Preload("User").
+			Where("mode = ? AND wins + losses + draws >= ? AND rating_deviation < ?", mode, MinRankedMatches, MaxRankedDeviation).
+			Order("rating DESC, id").
+			Offset(offset).
+			Limit(limit).
+			Find(&page).Error; err != nil {
+			return nil, err
+		}
+		for _, mr := range page {
+			if !isRankedDeviation(mr.RatingDeviation, mr.RatingVolatility, mr.RatedAt, now) {
+				continue
+			}
+			ratings = append(ratings, mr)
+			if len(ratings) == limit {
+				return ratings, nil
+			}
+		}
+		if len(page) < limit {
+			return ratings, nil
+		}
+	}
+}
+
+// CountHigherRating は指定モードで自分より高いレーティングを持つユーザー数を返す
+// モード別の順位計算に使用（条件はリーダーボードと同じ）
+func (r *UserModeRatingRepository) CountHigherRating(mode string, rating int) (int64, error) {
+	var rows []rankingRow
+	if err := r.db.Model(&models.UserModeRating{}).
+		Select("rating_deviation, rating_volatility, rated_at").
+		Where("mode = ? AND rating > ? AND wins + losses + draws >= ? AND rating_deviation < ?", mode, rating, MinRankedMatches, MaxRankedDeviation).
+		Find(&rows).Error; err != nil {
+		return 0, err
+	}
+	return countRanked(rows, time.Now()), nil
+}
+
+// FindByUserIDs は複数ユーザーの全モードのレーティングをまとめて取得する
+func (r *UserModeRatingRepository) FindByUserIDs(userIDs []uint) ([]models.UserModeRating, error) {
+	var ratings []models.UserModeRating
+	if len(userIDs) == 0 {
+		return ratings, nil
+	}
+	if err := r.db.Where("user_id IN ?", userIDs).Find(&ratings).Error; err != nil {
+		return nil, err
+	}
+	return ratings, nil
+}
+
+// SoftResetRatings は全ユーザー・全モードのレーティングを center に向けて縮める（シーズン切り替え用）
+// 計算式は UserRepository.SoftResetRatings と同じ
+func (r *UserModeRatingRepository) SoftResetRatings(center int, factor float64) error {
+	return r.db.Model(&models.UserModeRating{}).
+		Where("1 = 1").
+		Update("rating", gorm.Expr("ROUND(? + (rating - ?) * ?)", center, center, factor)).
+		Error
+}
+
+// BackfillFromUsers はモード別レーティング導入前からいるユーザーに、各モードの行を作る
+// user_mode_ratings が空のとき（導入直後の起動時）だけ実行し、全体のレーティングとRDを各モードの初期値として写す
+// 導入前の戦績はどのモードで対戦したか分からないので写さない（試合数の条件はモードごとに満たし直す）
+func (r *UserModeRatingRepository) BackfillFromUsers(modes []string) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		var count int64
+		if err := tx.Model(&models.UserModeRating{}).Count(&count).Error; err != nil {
+			return err
+		}
+		if count > 0 {
+			return nil
+		}
+		var users []models.User
+		if err := tx.Select("id, rating, rating_deviation, rating_volatility, rated_at").Find(&users).Error; err != nil {
+			return err
+		}
+		rows := seedModeRatings(users, modes)
+		if len(rows) == 0 {
+			return nil
+		}
+		return tx.Omit("User").CreateInBatches(rows, 500).Error
+	})
+}
+
+// seedModeRatings は導入前からいるユーザーの各モードの行を作る
+// レーティング・RD・σ・最後に対戦した日時は全体の値を写し、勝敗数は0から始める
+func seedModeRatings(users []models.User, modes []string) []models.UserModeRating {
+	rows := make([]models.UserModeRating, 0, len(users)*len(modes))
+	for _, u := range users {
+		for _, mode := range modes {
+			rows = append(rows, models.UserModeRating{
+				UserID:           u.ID,
+				Mode:             mode,
+				Rating:           u.Rating,
+				RatingDeviation:  u.RatingDeviation,
+				RatingVolatility: u.RatingVolatility,
+				RatedAt:          u.RatedAt,
+			})
+		}
+	}
+	return rows
+}
@@ -0,0 +1,52 @@
+package repositories
+
+import (
+	"testing"
+	"time"
+
+	"example.com/mathkun-tmp-/server/models"
+)
+
+func TestSeedModeRatingsCopiesRatingButNotRecord(t *testing.T) {
+	ratedAt := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	users := []models.User{
+		{ID: 1, Rating: 1320, Wins: 12, Losses: 3, Draws: 1, RatingDeviation: 80, RatingVolatility: 0.059, RatedAt: &ratedAt},
+		{ID: 2, Rating: 1000, RatingDeviation: 350, RatingVolatility: 0.06},
+	}
+	modes := []string{"text-major", "audio-rare"}
+
+	rows := seedModeRatings(users, modes)
+	if len(rows) != len(users)*len(modes) {
+		t.Fatalf("expected a row per user and mode, got %d", len(rows))
+	}
+	seen := map[uint]map[string]bool{}
+	for _, r := range rows {
+		var u models.User
+		for _, candidate := range users {
+			if candidate.ID == r.UserID {
+				u = candidate
+			}
+		}
+		if r.Rating != u.Rating || r.RatingDeviation != u.RatingDeviation || r.RatingVolatility != u.RatingVolatility || r.RatedAt != u.RatedAt {
+			t.Errorf("user %d %s: rating state not copied: %+v", r.UserID, r.Mode, r)
+		}
+		// 導入前の戦績はモードが分からないので、どのモードでも0から数え直す
+		if r.Wins != 0 || r.Losses != 0 || r.Draws != 0 {
+			t.Errorf("user %d %s: record should start at zero, got %d-%d-%d", r.UserID, r.Mode, r.Wins, r.Losses, r.Draws)
+		}
+		if seen[r.UserID] == nil {
+			seen[r.UserID] = map[string]bool{}
+		}
+		seen[r.UserID][r.Mode] = true
+	}
+	for _, u := range users {
+		for _, mode := range modes {
+			if !seen[u.ID][mode] {
+				t.Errorf("missing row for user %d in %s", u.ID, mode)
+			}
+		}
+	}
+	if got := seedModeRatings(nil, modes); len(got) != 0 {
+		t.Errorf("expected no rows without users, got %d", len(got))
+	}
+}
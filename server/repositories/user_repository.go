@@ -2,6 +2,7 @@ package repositories
 
 import (
 	"errors"
+	"time"
 
 	"example.com/mathkun-tmp-/server/models"
 
@@ -67,16 +68,20 @@ func (r *UserRepository) UpdateProfileByUsername(username string, updates map[st
 		Error
 }
 
-// UpdateRatingByUsername はレーティングと勝敗数を更新する
-// マッチ終了時にElo計算結果を反映するために使う
-func (r *UserRepository) UpdateRatingByUsername(username string, rating, wins, losses int) error {
-	// rating, wins, losses の3カラムを同時に更新
+// UpdateRatingStats はレーティング関連の値と勝敗数を更新する
+// マッチ終了時にレーティング計算結果を反映するために使う
+func (r *UserRepository) UpdateRatingStats(user *models.User) error {
+	// レーティング・RD・σ・更新日時と勝敗数をまとめて更新
 	return r.db.Model(&models.User{}).
-		Where("username = ?", username).
+		Where("username = ?", user.Username).
 		Updates(map[string]any{
-			"rating": rating,  // 新しいレーティング
-			"wins":   wins,    // 更新後の勝利数
-			"losses": losses,  // 更新後の敗北数
+			"rating":            user.Rating,           // 新しいレーティング
+			"rating_deviation":  user.RatingDeviation,  // 新しいRD
+			"rating_volatility": user.RatingVolatility, // 新しいσ
+			"rated_at":          user.RatedAt,          // レーティング更新日時
+			"wins":              user.Wins,             // 更新後の勝利数
+			"losses":            user.Losses,           // 更新後の敗北数
+			"draws":             user.Draws,            // 更新後の引き分け数
 		}).
 		Error
 }
@@ -85,33 +90,128 @@ func (r *UserRepository) UpdateRatingByUsername(username string, rating, wins, l
 // この試合数に満たないユーザーは順位が表示されない（初心者保護）
 const MinRankedMatches = 5
 
+// MaxRankedDeviation はランキングに表示されるRDの上限
+// RDがこれ以上のユーザーは実力が定まっていない暫定扱いとして順位を出さない
+const MaxRankedDeviation = 150.0
+
 // FindTopByRating はレーティング上位のユーザーを取得する
 // リーダーボード画面で使用（試合数条件を満たすユーザーのみ）
+// RDは対戦しない間に広がるが保存されるのは対戦時だけなので、保存値で絞った候補を現在のRDで判定し直す
 func (r *UserRepository) FindTopByRating(limit int) ([]models.User, error) {
 	// limitが不正な場合はデフォルトで30人にする
 	if limit <= 0 {
 		limit = 30
 	}
-	var users []models.User
-	// WHERE wins + losses >= 5（最低試合数条件）
+	now := time.Now()
+	users := make([]models.User, 0, limit)
+	// WHERE wins + losses + draws >= 5（最低試合数条件、引き分けも試合数に含める）
+	// AND rating_deviation < 150（保存時点で暫定のユーザーを除く）
 	// ORDER BY rating DESC（レーティング降順でソート）
-	// LIMIT で取得件数を制限
-	if err := r.db.Where("wins + losses >= ?", MinRankedMatches).Order("rating DESC").Limit(limit).Find(&users).Error; err != nil {
-		return nil, err
+	// 長く対戦していないユーザーを除いた分だけ、次のページを読んで埋める
+	for offset := 0; ; offset += limit {
+		var page []models.User
+		if err := r.db.Where("wins + losses + draws >= ? AND rating_deviation < ?", MinRankedMatches, MaxRankedDeviation).
+			Order("rating DESC, id").
+			Offset(offset).
+			Limit(limit).
+			Find(&page).Error; err != nil {
+			return nil, err
+		}
+		for _, u := range page {
+			if !isRankedDeviation(u.RatingDeviation, u.RatingVolatility, u.RatedAt, now) {
+				continue
+			}
+			users = append(users, u)
+			if len(users) == limit {
+				return users, nil
+			}
+		}
+		if len(page) < limit {
+			return users, nil
+		}
 	}
-	return users, nil
 }
 
 // CountHigherRating は自分より高いレーティングを持つユーザー数を返す
-// 順位計算に使用（例: 上に99人いれば自分は100位）
+// 順位計算に使用（例: 上に99人いれば自分は100位、条件はリーダーボードと同じ）
 func (r *UserRepository) CountHigherRating(rating int) (int64, error) {
-	var count int64
-	// WHERE rating > 自分のレーティング AND wins + losses >= 5
-	// 試合数条件を満たす、自分より強いユーザーの数を数える
+	var rows []rankingRow
+	// WHERE rating > 自分のレーティング AND wins + losses + draws >= 5 AND rating_deviation < 150
+	// 試合数条件を満たす、自分より強いユーザーを読み、現在のRDで暫定でない人を数える
 	if err := r.db.Model(&models.User{}).
-		Where("rating > ? AND wins + losses >= ?", rating, MinRankedMatches).
-		Count(&count).Error; err != nil {
+		Select("rating_deviation, rating_volatility, rated_at").
+		Where("rating > ? AND wins + losses + draws >= ? AND rating_deviation < ?", rating, MinRankedMatches, MaxRankedDeviation).
+		Find(&rows).Error; err != nil {
 		return 0, err
 	}
-	return count, nil
+	return countRanked(rows, time.Now()), nil
+}
+
+// rankingRow はランキング掲載の判定に必要な列だけを読んだ行
+type rankingRow struct {
+	RatingDeviation  float64
+	RatingVolatility float64
+	RatedAt          *time.Time
+}
+
+// isRankedDeviation は最後の対戦からの広がりを含めた現在のRDが、ランキング掲載の条件を満たすかを返す
+func isRankedDeviation(deviation, volatility float64, ratedAt *time.Time, now time.Time) bool {
+	return models.CurrentDeviation(deviation, volatility, ratedAt, now) < MaxRankedDeviation
+}
+
+// countRanked は現在のRDで暫定でない行の数を返す
+func countRanked(rows []rankingRow, now time.Time) int64 {
+	var count int64
+	for _, row := range rows {
+		if isRankedDeviation(row.RatingDeviation, row.RatingVolatility, row.RatedAt, now) {
+			count++
+		}
+	}
+	return count
+}
+
+// FindByIDs は複数のユーザーをIDでまとめて取得する
+// シーズンの順位集計などでユーザー名を引くために使う
+func (r *UserRepository) FindByIDs(ids []uint) ([]models.User, error) {
+	var users []models.User
+	if len(ids) == 0 {
+		return users, nil
+	}
+	if err := r.db.Where("id IN ?", ids).Find(&users).Error; err != nil {
+		return nil, err
+	}
+	return users, nil
+}
+
+// SoftResetRatings は全ユーザーのレーティングを center に向けて縮める（シーズン切り替え用）
+// 新レーティング = center + (旧レーティング - center) × factor
+// 例: center=1000, factor=0.5 なら 1400 → 1200、800 → 900
+func (r *UserRepository) SoftResetRatings(center int, factor float64) error {
+	return r.db.Model(&models.User{}).
+		Where("1 = 1").
+		Update("rating", gorm.Expr("ROUND(? + (rating - ?) * ?)", center, center, factor)).
+		Error
+}
+
+// BackfillDeviations はRD導入前から対戦していたユーザーのRDを対戦数から推定して埋める
+// 一度もレーティングが更新されていない（rated_at が空）のに試合数があるユーザーだけが対象なので、何度実行してもよい
+// 推定の起点がないので最後の対戦日時は移行時点とし、そこから対戦しなければRDが広がっていく
+func (r *UserRepository) BackfillDeviations() error {
+	var users []models.User
+	if err := r.db.Where("rated_at IS NULL AND wins + losses + draws > 0").Find(&users).Error; err != nil {
+		return err
+	}
+	now := time.Now()
+	for _, u := range users {
+		games := u.Wins + u.Losses + u.Draws
+		if err := r.db.Model(&models.User{}).
+			Where("id = ?", u.ID).
+			Updates(map[string]any{
+				"rating_deviation": models.EstimatedDeviation(games),
+				"rated_at":         now,
+			}).Error; err != nil {
+			return err
+		}
+	}
+	return nil
 }
@@ -0,0 +1,64 @@
+package repositories
+
+import (
+	"testing"
+	"time"
+
+	"example.com/mathkun-tmp-/server/models"
+)
+
+func TestIsRankedDeviationCutoff(t *testing.T) {
+	now := time.Date(2026, 6, 1, 0, 0, 0, 0, time.UTC)
+	recent := now.Add(-time.Hour)
+	cases := []struct {
+		name    string
+		rd      float64
+		ratedAt *time.Time
+		want    bool
+	}{
+		{"just below the cutoff", MaxRankedDeviation - 0.01, &recent, true},
+		{"at the cutoff", MaxRankedDeviation, &recent, false},
+		{"new player", models.DefaultRatingDeviation, nil, false},
+		{"settled player", 60, &recent, true},
+	}
+	for _, tc := range cases {
+		if got := isRankedDeviation(tc.rd, 0.06, tc.ratedAt, now); got != tc.want {
+			t.Errorf("%s: ranked = %v, want %v", tc.name, got, tc.want)
+		}
+	}
+}
+
+func TestIsRankedDeviationDropsInactivePlayers(t *testing.T) {
+	now := time.Date(2026, 6, 1, 0, 0, 0, 0, time.UTC)
+	// RD 140 は掲載されるが、対戦しない期間が続くとRDが広がって150を超え、暫定に戻る
+	var dropped time.Duration
+	for weeks := 0; weeks <= 52; weeks++ {
+		ratedAt := now.Add(-time.Duration(weeks) * models.RatingPeriod)
+		if !isRankedDeviation(140, 0.06, &ratedAt, now) {
+			dropped = time.Duration(weeks) * models.RatingPeriod
+			break
+		}
+	}
+	if dropped == 0 {
+		t.Fatal("RD 140 should widen past the cutoff within a year")
+	}
+	// 1期間ごとに RD² が (0.06×173.7)² ≈ 108.6 ずつ増えるので、150² − 140² = 2900 を超えるのは27週後
+	if weeks := dropped / models.RatingPeriod; weeks != 27 {
+		t.Errorf("dropped off the leaderboard after %d weeks, want 27", weeks)
+	}
+}
+
+func TestCountRankedUsesCurrentDeviation(t *testing.T) {
+	now := time.Date(2026, 6, 1, 0, 0, 0, 0, time.UTC)
+	recent := now.Add(-time.Hour)
+	longAgo := now.Add(-300 * models.RatingPeriod) // RD 60 でも約190まで広がる
+	rows := []rankingRow{
+		{RatingDeviation: 60, RatingVolatility: 0.06, RatedAt: &recent},
+		{RatingDeviation: 60, RatingVolatility: 0.06, RatedAt: &longAgo},
+		{RatingDeviation: 149, RatingVolatility: 0.06, RatedAt: &recent},
+		{RatingDeviation: 200, RatingVolatility: 0.06, RatedAt: &recent},
+	}
+	if got := countRanked(rows, now); got != 2 {
+		t.Errorf("countRanked = %d, want 2", got)
+	}
+}
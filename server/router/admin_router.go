@@ -0,0 +1,21 @@
+package router
+
+import (
+	"example.com/mathkun-tmp-/server/handlers"
+	"github.com/gin-gonic/gin"
+)
+
+// SetupAdminRoutes は管理者専用のルートを登録する（すべて RequireAdmin を通す）
+func SetupAdminRoutes(r *gin.Engine) {
+	admin := r.Group("/admin", handlers.RequireAdmin)
+	admin.GET("/questions/:bank", handlers.ListAdminQuestions)
+	admin.POST("/questions/:bank", handlers.CreateAdminQuestion)
+	admin.POST("/questions/:bank/import", handlers.ImportAdminQuestions)
+	admin.GET("/questions/:bank/export", handlers.ExportAdminQuestions)
+	admin.GET("/questions/:bank/:id", handlers.GetAdminQuestion)
+	admin.PUT("/questions/:bank/:id", handlers.UpdateAdminQuestion)
+	admin.DELETE("/questions/:bank/:id", handlers.DeleteAdminQuestion)
+	admin.POST("/questions/:bank/:id/restore", handlers.RestoreAdminQuestion)
+	admin.GET("/suspicious-plays", handlers.ListSuspiciousPlays)
+	admin.POST("/suspicious-plays/:id/review", handlers.ReviewSuspiciousPlay)
+}
@@ -0,0 +1,16 @@
+package router
+
+import (
+	"example.com/mathkun-tmp-/server/handlers"
+	"github.com/gin-gonic/gin"
+)
+
+func SetupCorrespondenceRoutes(r *gin.Engine) {
+	r.GET("/correspondence", handlers.ListCorrespondence)
+	r.POST("/correspondence", handlers.CreateCorrespondence)
+	r.GET("/correspondence/open", handlers.ListOpenCorrespondence)
+	r.GET("/correspondence/:id", handlers.GetCorrespondence)
+	r.DELETE("/correspondence/:id", handlers.DeclineCorrespondence)
+	r.POST("/correspondence/:id/question", handlers.CorrespondenceQuestion)
+	r.POST("/correspondence/:id/answer", handlers.AnswerCorrespondence)
+}
@@ -0,0 +1,11 @@
+package router
+
+import (
+	"example.com/mathkun-tmp-/server/handlers"
+	"github.com/gin-gonic/gin"
+)
+
+func SetupMatchRoutes(r *gin.Engine) {
+	r.GET("/users/:username/matches", handlers.GetUserMatches)
+	r.GET("/matches/:id", handlers.GetMatch)
+}
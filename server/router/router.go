@@ -10,5 +10,10 @@ func SetupRouter(r *gin.Engine) {
 	SetupUserRoutes(r)
 	SetupWebSocketRoutes(r)
 	SetupQuestionRoutes(r)
+	SetupMatchRoutes(r)
+	SetupSeasonRoutes(r)
+	SetupTournamentRoutes(r)
+	SetupCorrespondenceRoutes(r)
+	SetupAdminRoutes(r)
 	r.GET("/leaderboard", handlers.GetLeaderboard)
 }
@@ -0,0 +1,11 @@
+package router
+
+import (
+	"example.com/mathkun-tmp-/server/handlers"
+	"github.com/gin-gonic/gin"
+)
+
+func SetupSeasonRoutes(r *gin.Engine) {
+	r.GET("/seasons", handlers.ListSeasons)
+	r.GET("/seasons/:id/leaderboard", handlers.GetSeasonLeaderboard)
+}
@@ -0,0 +1,15 @@
+package router
+
+import (
+	"example.com/mathkun-tmp-/server/handlers"
+	"github.com/gin-gonic/gin"
+)
+
+func SetupTournamentRoutes(r *gin.Engine) {
+	r.GET("/tournaments", handlers.ListTournaments)
+	r.POST("/tournaments", handlers.CreateTournament)
+	r.GET("/tournaments/:id", handlers.GetTournament)
+	r.POST("/tournaments/:id/register", handlers.RegisterTournament)
+	r.DELETE("/tournaments/:id/register", handlers.WithdrawTournament)
+	r.POST("/tournaments/:id/start", handlers.StartTournament)
+}
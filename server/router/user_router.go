@@ -12,6 +12,7 @@ func SetupUserRoutes(r *gin.Engine) {
 	r.GET("/users/me", handlers.GetMe)
 	r.GET("/users/me/rank", handlers.GetMyRank)
 	r.GET("/users/:username", handlers.GetUserPublic)
+	r.GET("/users/:username/rating-history", handlers.GetRatingHistory)
 	r.PATCH("/users/me/avatar", handlers.UpdateAvatar)
 	r.PATCH("/users/me/profile", handlers.UpdateProfile)
 }
@@ -1,10 +1,13 @@
 package router
 
 import (
+	"example.com/mathkun-tmp-/server/handlers"
 	"example.com/mathkun-tmp-/server/handlers/websocket"
 	"github.com/gin-gonic/gin"
 )
 
 func SetupWebSocketRoutes(r *gin.Engine) {
 	r.GET("/ws", websocket.WebSocket)
+	r.GET("/ws/schema", websocket.ProtocolSchema)
+	r.GET("/rooms", handlers.ListRooms)
 }
@@ -16,6 +16,7 @@ import (
 var (
 	ErrInvalidCredentials = errors.New("invalid username or password") // ログイン失敗時のエラー
 	ErrUsernameTaken      = errors.New("username already taken")       // 登録時にユーザー名が重複
+	ErrUsernameReserved   = errors.New("username is reserved")         // 登録時にユーザー名が bot 用の予約名
 )
 
 // AuthService はアカウント登録とログインの処理をまとめる
@@ -49,6 +50,11 @@ func (s *AuthService) SignUp(username, password string) (*models.User, error) {
 		return nil, ErrInvalidCredentials
 	}
 
+	// bot と同じ名前になると対戦中の得点やレーティングの計算が混ざるので、予約名は登録させない
+	if models.IsReservedUsername(username) {
+		return nil, ErrUsernameReserved
+	}
+
 	// 既存チェックを先に行い、同名ユーザーの作成を防ぐ
 	// DBに同じusernameが既に存在していないか確認
 	existing, err := s.repo.FindByUsername(username)
@@ -71,7 +77,7 @@ func (s *AuthService) SignUp(username, password string) (*models.User, error) {
 	user := &models.User{
 		Username: username,
 		Password: string(hashedPassword), // ハッシュ化されたパスワードを保存
-		// Rating, Wins, Lossesは models.User の初期値（1500, 0, 0）が使われる
+		// Rating, Wins, Losses, Drawsは models.User の初期値（1500, 0, 0, 0）が使われる
 	}
 
 	// DBに保存（usersテーブルにINSERT）
@@ -0,0 +1,626 @@
+package services
+
+import (
+	"encoding/json"
+	"errors"
+	"strings"
+	"time"
+
+	"example.com/mathkun-tmp-/server/models"
+	"example.com/mathkun-tmp-/server/repositories"
+	"gorm.io/gorm"
+)
+
+// 非同期対戦関連のエラー
+var (
+	ErrCorrespondenceNotFound  = errors.New("correspondence match not found")
+	ErrInvalidCorrespondence   = errors.New("invalid correspondence match")
+	ErrCorrespondenceClosed    = errors.New("correspondence match is closed")
+	ErrNotCorrespondencePlayer = errors.New("not a player of this correspondence match")
+	ErrCorrespondenceFinished  = errors.New("already finished this correspondence match")
+	ErrCorrespondenceStarted   = errors.New("correspondence match has already started")
+	ErrNoCorrespondenceRound   = errors.New("answer is not for the current question")
+	ErrInvalidAnswerChoice     = errors.New("answer is not one of the choices")
+)
+
+// 非同期対戦の既定値
+const (
+	DefaultCorrespondenceHours = 48     // 両者が解き終えるまでの期限
+	maxCorrespondenceHours     = 7 * 24 // 指定できる期限の上限
+)
+
+// CreateCorrespondenceInput は非同期対戦の挑戦を作る入力
+type CreateCorrespondenceInput struct {
+	Mode          string `json:"mode"`
+	Opponent      string `json:"opponent"` // 指名する相手（空なら誰でも受けられる）
+	Rated         bool   `json:"rated"`
+	DeadlineHours int    `json:"deadlineHours"` // 0なら48時間
+}
+
+// CorrespondencePlayerDTO は非同期対戦の参加者1人分の進み具合
+type CorrespondencePlayerDTO struct {
+	Username string `json:"username"`
+	Answered int    `json:"answered"`        // 回答済み（時間切れを含む）の問題数
+	Score    *int   `json:"score,omitempty"` // 決着までは本人にだけ見せる
+	Finished bool   `json:"finished"`
+}
+
+// CorrespondenceDTO は非同期対戦1件分（一覧と詳細で使う）
+type CorrespondenceDTO struct {
+	ID          uint                      `json:"id"`
+	Mode        string                    `json:"mode"`
+	Rated       bool                      `json:"rated"`
+	Status      string                    `json:"status"` // "open", "completed", "expired", "declined"
+	Challenger  string                    `json:"challenger"`
+	Opponent    string                    `json:"opponent,omitempty"` // 誰でも受けられる挑戦で、まだ誰も受けていなければ空
+	TotalRounds int                       `json:"totalRounds"`
+	RoundMs     int                       `json:"roundMs"`
+	DeadlineAt  time.Time                 `json:"deadlineAt"`
+	Players     []CorrespondencePlayerDTO `json:"players"`
+	Winner      string                    `json:"winner,omitempty"`
+	MatchID     *uint                     `json:"matchId,omitempty"`
+	CreatedAt   time.Time                 `json:"createdAt"`
+	FinishedAt  *time.Time                `json:"finishedAt,omitempty"`
+}
+
+// CorrespondenceQuestionDTO は出題中の1問（正解は含めない）
+// 全問を解き終えていれば Finished だけを返す
+type CorrespondenceQuestionDTO struct {
+	ID          uint     `json:"id"` // 非同期対戦のID
+	Round       int      `json:"round,omitempty"`
+	TotalRounds int      `json:"totalRounds"`
+	Prompt      string   `json:"prompt,omitempty"`
+	AudioURL    string   `json:"audioUrl,omitempty"`
+	Choices     []string `json:"choices,omitempty"`
+	RemainingMs int64    `json:"remainingMs,omitempty"`
+	Finished    bool     `json:"finished"`
+}
+
+// CorrespondenceAnswerDTO は1問分の採点結果
+type CorrespondenceAnswerDTO struct {
+	ID            uint   `json:"id"` // 非同期対戦のID
+	Round         int    `json:"round"`
+	Answer        string `json:"answer,omitempty"`
+	CorrectAnswer string `json:"correctAnswer"`
+	Correct       bool   `json:"correct"`
+	TimedOut      bool   `json:"timedOut"`
+	Points        int    `json:"points"`
+	ResponseMs    int64  `json:"responseMs"`
+	Score         int    `json:"score"`
+	Finished      bool   `json:"finished"` // これで全問を解き終えたか
+}
+
+// CorrespondenceSettlement は決着した非同期対戦を対戦履歴に記録するための材料
+type CorrespondenceSettlement struct {
+	Match     models.CorrespondenceMatch
+	Questions []MatchQuestionDTO
+	Players   []models.CorrespondencePlayer // 始めた順、回答付き
+	Forfeited []string                      // 期限までに解き終えなかった参加者
+	Status    string                        // "completed", "forfeit"
+}
+
+// CorrespondenceRecorder は決着した非同期対戦を対戦履歴に記録し、履歴のIDと勝者を返す
+// レーティングの計算は WebSocket の対戦と同じ処理を使うので、呼び出し側から受け取る
+// 対戦の行をロックした Settle のトランザクション tx の中で書き込む（二重に記録しないため）
+type CorrespondenceRecorder func(tx *gorm.DB, s CorrespondenceSettlement) (uint, string, error)
+
+// CorrespondenceService は非同期対戦の挑戦・出題・回答・決着をまとめる
+// 問題セットと得点の計算は WebSocket の対戦と同じものを使うので、呼び出し側から受け取る
+type CorrespondenceService struct {
+	db  *gorm.DB
+	now func() time.Time // 現在時刻（テストでは固定の時計に差し替える）
+}
+
+// NewCorrespondenceService は依存するDB接続を受け取ってサービスを返す
+func NewCorrespondenceService(db *gorm.DB) *CorrespondenceService {
+	return NewCorrespondenceServiceWithClock(db, time.Now)
+}
+
+// NewCorrespondenceServiceWithClock は現在時刻の取得方法を指定してサービスを返す
+func NewCorrespondenceServiceWithClock(db *gorm.DB, now func() time.Time) *CorrespondenceService {
+	return &CorrespondenceService{db: db, now: now}
+}
+
+// Create は問題セットを固定した挑戦を作る（挑戦者はすぐに解き始められる）
+func (s *CorrespondenceService) Create(challenger string, in CreateCorrespondenceInput, questions []MatchQuestionDTO, roundTime time.Duration) (*CorrespondenceDTO, error) {
+	userRepo := repositories.NewUserRepository(s.db)
+	user, err := userRepo.FindByUsername(challenger)
+	if err != nil {
+		return nil, err
+	}
+	if user == nil {
+		return nil, ErrUserNotFound
+	}
+
+	if in.DeadlineHours == 0 {
+		in.DeadlineHours = DefaultCorrespondenceHours
+	}
+	switch {
+	case !models.IsRatingMode(in.Mode):
+		return nil, ErrInvalidMode
+	case in.DeadlineHours < 1 || in.DeadlineHours > maxCorrespondenceHours:
+		return nil, ErrInvalidCorrespondence
+	case len(questions) == 0 || roundTime <= 0:
+		return nil, ErrInvalidCorrespondence
+	}
+
+	m := models.CorrespondenceMatch{
+		Mode:           in.Mode,
+		Rated:          in.Rated,
+		Status:         "open",
+		ChallengerID:   user.ID,
+		ChallengerName: user.Username,
+		RoundMs:        int(roundTime / time.Millisecond),
+		DeadlineAt:     s.now().Add(time.Duration(in.DeadlineHours) * time.Hour),
+		Players:        []models.CorrespondencePlayer{{UserID: user.ID, Username: user.Username}},
+	}
+	if name := strings.TrimSpace(in.Opponent); name != "" {
+		opponent, err := userRepo.FindByUsername(name)
+		if err != nil {
+			return nil, err
+		}
+		if opponent == nil {
+			return nil, ErrUserNotFound
+		}
+		if opponent.ID == user.ID {
+			return nil, ErrInvalidCorrespondence
+		}
+		m.OpponentID = opponent.ID
+		m.OpponentName = opponent.Username
+	}
+	raw, err := json.Marshal(questions)
+	if err != nil {
+		return nil, err
+	}
+	m.Questions = string(raw)
+
+	if err := repositories.NewCorrespondenceRepository(s.db).Create(&m); err != nil {
+		return nil, err
+	}
+	dto := toCorrespondenceDTO(m, user.Username)
+	return &dto, nil
+}
+
+// List はユーザーが挑戦した・挑戦された非同期対戦を新しい順に返す（status を指定すればその状態だけ）
+func (s *CorrespondenceService) List(username, status string) ([]CorrespondenceDTO, error) {
+	user, err := repositories.NewUserRepository(s.db).FindByUsername(username)
+	if err != nil {
+		return nil, err
+	}
+	if user == nil {
+		return nil, ErrUserNotFound
+	}
+	matches, err := repositories.NewCorrespondenceRepository(s.db).FindByUser(user.ID, status)
+	if err != nil {
+		return nil, err
+	}
+	dtos := make([]CorrespondenceDTO, 0, len(matches))
+	for _, m := range matches {
+		dtos = append(dtos, toCorrespondenceDTO(m, user.Username))
+	}
+	return dtos, nil
+}
+
+// ListOpen は誰でも受けられる受付中の挑戦を期限の近い順に返す（自分の挑戦は除く）
+func (s *CorrespondenceService) ListOpen(username, mode string) ([]CorrespondenceDTO, error) {
+	user, err := repositories.NewUserRepository(s.db).FindByUsername(username)
+	if err != nil {
+		return nil, err
+	}
+	if user == nil {
+		return nil, ErrUserNotFound
+	}
+	if mode != "" && !models.IsRatingMode(mode) {
+		return nil, ErrInvalidMode
+	}
+	matches, err := repositories.NewCorrespondenceRepository(s.db).FindOpenChallenges(mode, user.ID, s.now())
+	if err != nil {
+		return nil, err
+	}
+	dtos := make([]CorrespondenceDTO, 0, len(matches))
+	for _, m := range matches {
+		dtos = append(dtos, toCorrespondenceDTO(m, user.Username))
+	}
+	return dtos, nil
+}
+
+// Get は非同期対戦1件を返す（参加者だけが見られる。相手の得点は決着まで見せない）
+func (s *CorrespondenceService) Get(id uint, username string) (*CorrespondenceDTO, error) {
+	user, err := repositories.NewUserRepository(s.db).FindByUsername(username)
+	if err != nil {
+		return nil, err
+	}
+	if user == nil {
+		return nil, ErrUserNotFound
+	}
+	m, err := repositories.NewCorrespondenceRepository(s.db).FindByID(id)
+	if err != nil {
+		return nil, err
+	}
+	if m == nil {
+		return nil, ErrCorrespondenceNotFound
+	}
+	if user.ID != m.ChallengerID && user.ID != m.OpponentID {
+		return nil, ErrNotCorrespondencePlayer
+	}
+	dto := toCorrespondenceDTO(*m, username)
+	return &dto, nil
+}
+
+// Decline は始める前の挑戦を取り下げる（挑戦者）か断る（指名された相手）
+// どちらかが1問でも解き始めていたら取り下げられない
+func (s *CorrespondenceService) Decline(id uint, username string) error {
+	return s.db.Transaction(func(tx *gorm.DB) error {
+		repo := repositories.NewCorrespondenceRepository(tx)
+		m, user, err := s.lockMatch(tx, id, username)
+		if err != nil {
+			return err
+		}
+		if user.ID != m.ChallengerID && user.ID != m.OpponentID {
+			return ErrNotCorrespondencePlayer
+		}
+		if m.Status != "open" {
+			return ErrCorrespondenceClosed
+		}
+		players, err := repo.FindPlayersWithAnswers(m.ID)
+		if err != nil {
+			return err
+		}
+		for _, p := range players {
+			if p.Round > 0 {
+				return ErrCorrespondenceStarted
+			}
+		}
+		now := s.now()
+		m.Status = "declined"
+		m.FinishedAt = &now
+		return repo.Save(m)
+	})
+}
+
+// Question は出題中の問題を返す（出題中でなければ次の問題を出して制限時間を計り始める）
+// 誰でも受けられる挑戦なら、挑戦者以外が最初に呼んだ時点でその人が相手になる
+// 制限時間を過ぎた出題中の問題は時間切れ（0点）として記録してから次へ進む
+// 全問を解き終えたら Finished を返すので、呼び出し側は Settle で決着を試みる
+func (s *CorrespondenceService) Question(id uint, username string) (*CorrespondenceQuestionDTO, error) {
+	var dto *CorrespondenceQuestionDTO
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		repo := repositories.NewCorrespondenceRepository(tx)
+		m, user, err := s.lockMatch(tx, id, username)
+		if err != nil {
+			return err
+		}
+		if m.Status != "open" || !s.now().Before(m.DeadlineAt) {
+			return ErrCorrespondenceClosed
+		}
+		questions, err := decodeCorrespondenceQuestions(m)
+		if err != nil {
+			return err
+		}
+
+		p, err := s.joinMatch(repo, m, user)
+		if err != nil {
+			return err
+		}
+		if p.FinishedAt == nil {
+			if err := s.expireServed(repo, m, p, len(questions)); err != nil {
+				return err
+			}
+		}
+		dto = &CorrespondenceQuestionDTO{ID: m.ID, TotalRounds: len(questions)}
+		if p.FinishedAt != nil {
+			dto.Finished = true
+			return nil
+		}
+
+		now := s.now()
+		if p.ServedAt == nil {
+			p.Round++
+			p.ServedAt = &now
+			if err := repo.SavePlayer(p); err != nil {
+				return err
+			}
+		}
+		q := questions[p.Round-1]
+		limit := time.Duration(m.RoundMs) * time.Millisecond
+		dto.Round = p.Round
+		dto.Prompt = q.Prompt
+		dto.AudioURL = q.AudioURL
+		dto.Choices = q.Choices
+		dto.RemainingMs = max(limit-now.Sub(*p.ServedAt), 0).Milliseconds()
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return dto, nil
+}
+
+// Answer は出題中の問題への回答を採点して記録する
+// 経過時間はサーバーで出題した時刻から計り、制限時間を過ぎていれば時間切れ（0点）にする
+// 正解の得点は points（経過時間と制限時間から点数を出す関数）で計算する
+func (s *CorrespondenceService) Answer(id uint, username string, round int, answer string, points func(elapsed, limit time.Duration) int) (*CorrespondenceAnswerDTO, error) {
+	var dto *CorrespondenceAnswerDTO
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		repo := repositories.NewCorrespondenceRepository(tx)
+		m, user, err := s.lockMatch(tx, id, username)
+		if err != nil {
+			return err
+		}
+		if m.Status != "open" || !s.now().Before(m.DeadlineAt) {
+			return ErrCorrespondenceClosed
+		}
+		p, err := repo.FindPlayer(m.ID, user.ID)
+		if err != nil {
+			return err
+		}
+		if p == nil {
+			return ErrNotCorrespondencePlayer
+		}
+		if p.FinishedAt != nil {
+			return ErrCorrespondenceFinished
+		}
+		if p.ServedAt == nil || p.Round != round {
+			return ErrNoCorrespondenceRound
+		}
+		questions, err := decodeCorrespondenceQuestions(m)
+		if err != nil {
+			return err
+		}
+		q := questions[p.Round-1]
+		answer = strings.TrimSpace(answer)
+		if !containsChoice(q.Choices, answer) {
+			return ErrInvalidAnswerChoice
+		}
+
+		limit := time.Duration(m.RoundMs) * time.Millisecond
+		elapsed := s.now().Sub(*p.ServedAt)
+		row := models.CorrespondenceAnswer{CorrespondencePlayerID: p.ID, Round: p.Round}
+		timedOut := elapsed > limit
+		if !timedOut {
+			row.Answer = answer
+			row.ResponseMs = elapsed.Milliseconds()
+			row.Correct = answer == q.Answer
+			if row.Correct {
+				row.Points = points(elapsed, limit)
+			}
+		}
+		if err := s.recordAnswer(repo, p, row, len(questions)); err != nil {
+			return err
+		}
+		dto = &CorrespondenceAnswerDTO{
+			ID:            m.ID,
+			Round:         row.Round,
+			Answer:        row.Answer,
+			CorrectAnswer: q.Answer,
+			Correct:       row.Correct,
+			TimedOut:      timedOut,
+			Points:        row.Points,
+			ResponseMs:    row.ResponseMs,
+			Score:         p.Score,
+			Finished:      p.FinishedAt != nil,
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return dto, nil
+}
+
+// Settle は決着がついていれば非同期対戦を確定させ、確定したら true を返す
+// 2人とも解き終えていれば通常の決着、期限を過ぎていれば解き終えなかった方の不戦敗にする
+// 期限までに相手が現れなかったか、どちらも解き終えなかった場合は記録せずに "expired" にする
+// 確定と記録は行ロックを取ったまま行うので、同時に呼ばれても二重に記録しない
+func (s *CorrespondenceService) Settle(id uint, record CorrespondenceRecorder) (bool, error) {
+	settled := false
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		repo := repositories.NewCorrespondenceRepository(tx)
+		m, err := repo.FindByIDForUpdate(id)
+		if err != nil {
+			return err
+		}
+		if m == nil {
+			return ErrCorrespondenceNotFound
+		}
+		if m.Status != "open" {
+			return nil
+		}
+		players, err := repo.FindPlayersWithAnswers(m.ID)
+		if err != nil {
+			return err
+		}
+
+		var finished, forfeited []string
+		for _, p := range players {
+			if p.FinishedAt != nil {
+				finished = append(finished, p.Username)
+			} else {
+				forfeited = append(forfeited, p.Username)
+			}
+		}
+		expired := !s.now().Before(m.DeadlineAt)
+		if len(players) < 2 || len(forfeited) > 0 {
+			if !expired {
+				return nil
+			}
+		}
+
+		now := s.now()
+		m.FinishedAt = &now
+		settled = true
+		if len(players) < 2 || len(finished) == 0 {
+			m.Status = "expired"
+			return repo.Save(m)
+		}
+
+		questions, err := decodeCorrespondenceQuestions(m)
+		if err != nil {
+			return err
+		}
+		status := "completed"
+		if len(forfeited) > 0 {
+			status = "forfeit"
+		}
+		matchID, winner, err := record(tx, CorrespondenceSettlement{
+			Match:     *m,
+			Questions: questions,
+			Players:   players,
+			Forfeited: forfeited,
+			Status:    status,
+		})
+		if err != nil {
+			return err
+		}
+		m.Status = "completed"
+		m.WinnerName = winner
+		if matchID != 0 {
+			m.MatchID = &matchID
+		}
+		return repo.Save(m)
+	})
+	if err != nil {
+		return false, err
+	}
+	return settled, nil
+}
+
+// Expired は期限を過ぎても決着していない非同期対戦のIDを返す（Settle で確定させる）
+func (s *CorrespondenceService) Expired() ([]uint, error) {
+	matches, err := repositories.NewCorrespondenceRepository(s.db).FindExpired(s.now())
+	if err != nil {
+		return nil, err
+	}
+	ids := make([]uint, 0, len(matches))
+	for _, m := range matches {
+		ids = append(ids, m.ID)
+	}
+	return ids, nil
+}
+
+// lockMatch は行ロックを取って非同期対戦と操作するユーザーを取得する（トランザクション内で使う）
+func (s *CorrespondenceService) lockMatch(tx *gorm.DB, id uint, username string) (*models.CorrespondenceMatch, *models.User, error) {
+	user, err := repositories.NewUserRepository(tx).FindByUsername(username)
+	if err != nil {
+		return nil, nil, err
+	}
+	if user == nil {
+		return nil, nil, ErrUserNotFound
+	}
+	m, err := repositories.NewCorrespondenceRepository(tx).FindByIDForUpdate(id)
+	if err != nil {
+		return nil, nil, err
+	}
+	if m == nil {
+		return nil, nil, ErrCorrespondenceNotFound
+	}
+	return m, user, nil
+}
+
+// joinMatch はユーザーの進み具合を返す（まだ始めていなければ作る）
+// 誰でも受けられる挑戦は、挑戦者以外が最初に始めた時点でその人を相手に決める
+func (s *CorrespondenceService) joinMatch(repo *repositories.CorrespondenceRepository, m *models.CorrespondenceMatch, user *models.User) (*models.CorrespondencePlayer, error) {
+	p, err := repo.FindPlayer(m.ID, user.ID)
+	if err != nil || p != nil {
+		return p, err
+	}
+	switch {
+	case user.ID == m.ChallengerID || user.ID == m.OpponentID:
+	case m.OpponentID == 0:
+		m.OpponentID = user.ID
+		m.OpponentName = user.Username
+		if err := repo.Save(m); err != nil {
+			return nil, err
+		}
+	default:
+		return nil, ErrNotCorrespondencePlayer
+	}
+	p = &models.CorrespondencePlayer{CorrespondenceMatchID: m.ID, UserID: user.ID, Username: user.Username}
+	if err := repo.CreatePlayer(p); err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+// expireServed は制限時間を過ぎた出題中の問題を時間切れとして記録する（出題中でなければ何もしない）
+func (s *CorrespondenceService) expireServed(repo *repositories.CorrespondenceRepository, m *models.CorrespondenceMatch, p *models.CorrespondencePlayer, total int) error {
+	limit := time.Duration(m.RoundMs) * time.Millisecond
+	if p.ServedAt == nil || s.now().Sub(*p.ServedAt) <= limit {
+		return nil
+	}
+	return s.recordAnswer(repo, p, models.CorrespondenceAnswer{CorrespondencePlayerID: p.ID, Round: p.Round}, total)
+}
+
+// recordAnswer は1問分の回答を保存して進み具合を進める（最後の問題なら解き終えたことにする）
+func (s *CorrespondenceService) recordAnswer(repo *repositories.CorrespondenceRepository, p *models.CorrespondencePlayer, row models.CorrespondenceAnswer, total int) error {
+	if err := repo.CreateAnswer(&row); err != nil {
+		return err
+	}
+	p.Score += row.Points
+	p.ServedAt = nil
+	if p.Round >= total {
+		now := s.now()
+		p.FinishedAt = &now
+	}
+	return repo.SavePlayer(p)
+}
+
+// decodeCorrespondenceQuestions は保存した問題セットを読み出す
+func decodeCorrespondenceQuestions(m *models.CorrespondenceMatch) ([]MatchQuestionDTO, error) {
+	var questions []MatchQuestionDTO
+	if err := json.Unmarshal([]byte(m.Questions), &questions); err != nil {
+		return nil, err
+	}
+	if len(questions) == 0 {
+		return nil, ErrInvalidCorrespondence
+	}
+	return questions, nil
+}
+
+// containsChoice は回答が選択肢に含まれるかを返す
+func containsChoice(choices []string, answer string) bool {
+	for _, choice := range choices {
+		if choice == answer {
+			return true
+		}
+	}
+	return false
+}
+
+// toCorrespondenceDTO は非同期対戦を返却用の形にする（viewer 以外の得点は決着まで隠す）
+func toCorrespondenceDTO(m models.CorrespondenceMatch, viewer string) CorrespondenceDTO {
+	questions, _ := decodeCorrespondenceQuestions(&m)
+	dto := CorrespondenceDTO{
+		ID:          m.ID,
+		Mode:        m.Mode,
+		Rated:       m.Rated,
+		Status:      m.Status,
+		Challenger:  m.ChallengerName,
+		Opponent:    m.OpponentName,
+		TotalRounds: len(questions),
+		RoundMs:     m.RoundMs,
+		DeadlineAt:  m.DeadlineAt,
+		Players:     make([]CorrespondencePlayerDTO, 0, len(m.Players)),
+		Winner:      m.WinnerName,
+		MatchID:     m.MatchID,
+		CreatedAt:   m.CreatedAt,
+		FinishedAt:  m.FinishedAt,
+	}
+	for _, p := range m.Players {
+		player := CorrespondencePlayerDTO{
+			Username: p.Username,
+			Answered: p.Round,
+			Finished: p.FinishedAt != nil,
+		}
+		// 出題中の問題はまだ回答していない
+		if p.ServedAt != nil {
+			player.Answered--
+		}
+		if m.Status != "open" || p.Username == viewer {
+			score := p.Score
+			player.Score = &score
+		}
+		dto.Players = append(dto.Players, player)
+	}
+	return dto
+}
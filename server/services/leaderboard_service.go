@@ -18,14 +18,16 @@ type LeaderboardRowDTO struct {
 // LeaderboardService はランキング関連のビジネスロジックをまとめる
 // 上位プレイヤーの取得、検索などを担当
 type LeaderboardService struct {
-	userRepo *repositories.UserRepository // ユーザーリポジトリ（DB操作）
+	userRepo     *repositories.UserRepository           // ユーザーリポジトリ（DB操作）
+	modeRankRepo *repositories.UserModeRatingRepository // モード別レーティングリポジトリ
 }
 
 // NewLeaderboardService は依存するリポジトリを組み立ててサービスを返す
 // DB接続を受け取ってサービスインスタンスを初期化
 func NewLeaderboardService(db *gorm.DB) *LeaderboardService {
 	return &LeaderboardService{
-		userRepo: repositories.NewUserRepository(db),
+		userRepo:     repositories.NewUserRepository(db),
+		modeRankRepo: repositories.NewUserModeRatingRepository(db),
 	}
 }
 
@@ -63,3 +65,30 @@ func (s *LeaderboardService) GetTopPlayers(limit int) ([]LeaderboardRowDTO, erro
 
 	return rows, nil
 }
+
+// GetTopPlayersByMode は指定モードのレーティング上位のプレイヤーを取得する
+// limit の補正は GetTopPlayers と同じ
+func (s *LeaderboardService) GetTopPlayersByMode(mode string, limit int) ([]LeaderboardRowDTO, error) {
+	if limit < 1 {
+		limit = 1
+	}
+	if limit > 100 {
+		limit = 100
+	}
+
+	ratings, err := s.modeRankRepo.FindTopByRating(mode, limit)
+	if err != nil {
+		return nil, err
+	}
+
+	rows := make([]LeaderboardRowDTO, 0, len(ratings))
+	for i, r := range ratings {
+		rows = append(rows, LeaderboardRowDTO{
+			Rank:     i + 1,
+			Username: r.User.Username,
+			Rating:   r.Rating,
+			ImageURL: r.User.ImageURL,
+		})
+	}
+	return rows, nil
+}
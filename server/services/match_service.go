@@ -0,0 +1,200 @@
+package services
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+
+	"example.com/mathkun-tmp-/server/models"
+	"example.com/mathkun-tmp-/server/repositories"
+	"gorm.io/gorm"
+)
+
+// ErrMatchNotFound は試合が見つからなかった場合のエラー
+var ErrMatchNotFound = errors.New("match not found")
+
+// MatchParticipantDTO は試合参加者1人分の成績
+type MatchParticipantDTO struct {
+	Username     string `json:"username"`
+	Score        int    `json:"score"`
+	Rank         int    `json:"rank"`
+	Forfeited    bool   `json:"forfeited,omitempty"`
+	RatingBefore int    `json:"ratingBefore"`
+	RatingAfter  int    `json:"ratingAfter"`
+	RatingDelta  int    `json:"ratingDelta"` // RatingAfter - RatingBefore
+}
+
+// MatchRoundAnswerDTO はラウンドごとの1プレイヤー分の回答
+type MatchRoundAnswerDTO struct {
+	Username   string `json:"username"`
+	Answer     string `json:"answer"`
+	ResponseMs int64  `json:"responseMs"`
+	Correct    bool   `json:"correct"`
+	Points     int    `json:"points"`
+}
+
+// MatchRoundDTO は1ラウンド分の出題と全員の回答
+type MatchRoundDTO struct {
+	Round         int                   `json:"round"`
+	QuestionID    uint                  `json:"questionId"`
+	CorrectAnswer string                `json:"correctAnswer"`
+	Answers       []MatchRoundAnswerDTO `json:"answers"`
+}
+
+// MatchSummaryDTO は対戦履歴一覧の1行分
+type MatchSummaryDTO struct {
+	ID           uint                  `json:"id"`
+	Mode         string                `json:"mode"`
+	Status       string                `json:"status"`
+	Rated        bool                  `json:"rated"`
+	Winner       string                `json:"winner,omitempty"`
+	StartedAt    time.Time             `json:"startedAt"`
+	FinishedAt   time.Time             `json:"finishedAt"`
+	Participants []MatchParticipantDTO `json:"participants"`
+}
+
+// MatchDetailDTO は1試合の詳細（ラウンドごとの回答付き）
+type MatchDetailDTO struct {
+	MatchSummaryDTO
+	QuestionIDs []uint          `json:"questionIds"`
+	Rounds      []MatchRoundDTO `json:"rounds"`
+}
+
+// MatchPageDTO はページング付きの対戦履歴一覧
+type MatchPageDTO struct {
+	Matches []MatchSummaryDTO `json:"matches"`
+	Page    int               `json:"page"`
+	PerPage int               `json:"perPage"`
+	Total   int64             `json:"total"`
+}
+
+// MatchService は対戦履歴の参照をまとめる
+type MatchService struct {
+	matchRepo *repositories.MatchRepository
+	userRepo  *repositories.UserRepository
+}
+
+// NewMatchService は依存するリポジトリを組み立ててサービスを返す
+func NewMatchService(db *gorm.DB) *MatchService {
+	return &MatchService{
+		matchRepo: repositories.NewMatchRepository(db),
+		userRepo:  repositories.NewUserRepository(db),
+	}
+}
+
+// GetUserMatches は指定ユーザーの対戦履歴を新しい順に1ページ分返す
+// page は1始まり、perPage は 1〜100 の範囲に補正する
+func (s *MatchService) GetUserMatches(username string, page, perPage int) (*MatchPageDTO, error) {
+	// ユーザーが存在しなければエラー（空の履歴と区別するため）
+	user, err := s.userRepo.FindByUsername(username)
+	if err != nil {
+		return nil, err
+	}
+	if user == nil {
+		return nil, ErrUserNotFound
+	}
+
+	if page < 1 {
+		page = 1
+	}
+	if perPage < 1 {
+		perPage = 1
+	}
+	if perPage > 100 {
+		perPage = 100
+	}
+
+	matches, total, err := s.matchRepo.FindByUsername(username, perPage, (page-1)*perPage)
+	if err != nil {
+		return nil, err
+	}
+
+	rows := make([]MatchSummaryDTO, 0, len(matches))
+	for _, m := range matches {
+		rows = append(rows, toMatchSummaryDTO(m))
+	}
+	return &MatchPageDTO{
+		Matches: rows,
+		Page:    page,
+		PerPage: perPage,
+		Total:   total,
+	}, nil
+}
+
+// GetMatch は1試合分の詳細を返す
+func (s *MatchService) GetMatch(id uint) (*MatchDetailDTO, error) {
+	match, err := s.matchRepo.FindByID(id)
+	if err != nil {
+		return nil, err
+	}
+	if match == nil {
+		return nil, ErrMatchNotFound
+	}
+
+	detail := &MatchDetailDTO{
+		MatchSummaryDTO: toMatchSummaryDTO(*match),
+		QuestionIDs:     parseQuestionIDs(match.QuestionIDs),
+		Rounds:          []MatchRoundDTO{},
+	}
+	// ラウンド番号ごとに回答をまとめる（Roundsはラウンド順に並んでいる）
+	for _, row := range match.Rounds {
+		n := len(detail.Rounds)
+		if n == 0 || detail.Rounds[n-1].Round != row.Round {
+			detail.Rounds = append(detail.Rounds, MatchRoundDTO{
+				Round:         row.Round,
+				QuestionID:    row.QuestionID,
+				CorrectAnswer: row.CorrectAnswer,
+				Answers:       []MatchRoundAnswerDTO{},
+			})
+			n++
+		}
+		detail.Rounds[n-1].Answers = append(detail.Rounds[n-1].Answers, MatchRoundAnswerDTO{
+			Username:   row.Username,
+			Answer:     row.Answer,
+			ResponseMs: row.ResponseMs,
+			Correct:    row.Correct,
+			Points:     row.Points,
+		})
+	}
+	return detail, nil
+}
+
+// toMatchSummaryDTO はモデルを一覧用DTOに変換する
+func toMatchSummaryDTO(m models.Match) MatchSummaryDTO {
+	participants := make([]MatchParticipantDTO, 0, len(m.Participants))
+	for _, p := range m.Participants {
+		participants = append(participants, MatchParticipantDTO{
+			Username:     p.Username,
+			Score:        p.Score,
+			Rank:         p.Rank,
+			Forfeited:    p.Forfeited,
+			RatingBefore: p.RatingBefore,
+			RatingAfter:  p.RatingAfter,
+			RatingDelta:  p.RatingAfter - p.RatingBefore,
+		})
+	}
+	return MatchSummaryDTO{
+		ID:           m.ID,
+		Mode:         m.Mode,
+		Status:       m.Status,
+		Rated:        m.Rated,
+		Winner:       m.Winner,
+		StartedAt:    m.StartedAt,
+		FinishedAt:   m.FinishedAt,
+		Participants: participants,
+	}
+}
+
+// parseQuestionIDs はカンマ区切りの問題IDを数値の配列に戻す（不正な値は読み飛ばす）
+func parseQuestionIDs(raw string) []uint {
+	ids := []uint{}
+	for _, part := range strings.Split(raw, ",") {
+		v, err := strconv.ParseUint(strings.TrimSpace(part), 10, 64)
+		if err != nil {
+			continue
+		}
+		ids = append(ids, uint(v))
+	}
+	return ids
+}
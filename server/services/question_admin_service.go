@@ -0,0 +1,516 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"example.com/mathkun-tmp-/server/models"
+	"example.com/mathkun-tmp-/server/repositories"
+	"gorm.io/gorm"
+)
+
+// 問題の管理関連のエラー
+var (
+	ErrInvalidQuestionBank = errors.New("invalid question bank")
+	ErrQuestionNotFound    = errors.New("question not found")
+	ErrInvalidQuestion     = errors.New("invalid question")
+	ErrForbidden           = errors.New("forbidden")
+)
+
+// 問題の入力の上限
+const (
+	maxPromptLength   = 1000 // テキスト問題の問題文（文字数）
+	maxLanguageLength = 100  // 正解の言語名（文字数、対戦の回答の列と揃える）
+	maxAudioURLLength = 2048 // 音声のURL
+)
+
+// QuestionFieldError は問題の入力のどの項目がなぜ不正かを表す
+// errors.Is(err, ErrInvalidQuestion) で不正な入力として判定できる
+type QuestionFieldError struct {
+	Field  string `json:"field"`
+	Reason string `json:"reason"`
+}
+
+func (e *QuestionFieldError) Error() string {
+	return fmt.Sprintf("%s: %s", e.Field, e.Reason)
+}
+
+func (e *QuestionFieldError) Unwrap() error {
+	return ErrInvalidQuestion
+}
+
+// QuestionInput は管理APIで問題を作成・更新する入力
+// テキスト問題（text-major / text-rare）は Prompt と Answer、音声問題（audio-major / audio-rare）は Language と AudioURL を使う
+type QuestionInput struct {
+	Prompt   string `json:"prompt"`
+	Answer   string `json:"answer"`
+	Language string `json:"language"`
+	AudioURL string `json:"audioUrl"`
+}
+
+// QuestionListInput は管理APIの問題一覧の検索条件
+type QuestionListInput struct {
+	Query    string // 部分一致で探す文字列
+	Language string // 正解の言語（完全一致）
+	Deleted  string // "" なら削除済みを除く、"only" なら削除済みだけ、"include" なら両方
+	Page     int    // 1始まり
+	PerPage  int    // 1〜100 の範囲に補正する
+}
+
+// AdminQuestionDTO は管理APIで返す問題1件分（削除済みと監査用の項目を含む）
+type AdminQuestionDTO struct {
+	ID        uint       `json:"id"`
+	Bank      string     `json:"bank"`
+	Prompt    string     `json:"prompt,omitempty"`
+	Answer    string     `json:"answer,omitempty"`
+	Language  string     `json:"language,omitempty"`
+	AudioURL  string     `json:"audioUrl,omitempty"`
+	CreatedAt time.Time  `json:"createdAt"`
+	UpdatedAt time.Time  `json:"updatedAt"`
+	DeletedAt *time.Time `json:"deletedAt,omitempty"`
+	CreatedBy string     `json:"createdBy,omitempty"`
+	UpdatedBy string     `json:"updatedBy,omitempty"`
+	DeletedBy string     `json:"deletedBy,omitempty"`
+}
+
+// AdminQuestionPageDTO はページング付きの問題一覧
+type AdminQuestionPageDTO struct {
+	Questions []AdminQuestionDTO `json:"questions"`
+	Page      int                `json:"page"`
+	PerPage   int                `json:"perPage"`
+	Total     int64              `json:"total"`
+}
+
+// QuestionAdminService は管理者による問題バンクの編集をまとめる
+// 問題バンクは対戦モードと同じ名前（text-major / text-rare / audio-major / audio-rare）で指定する
+type QuestionAdminService struct {
+	db            *gorm.DB
+	userRepo      *repositories.UserRepository
+	majorTextRepo *repositories.QuestionRepository
+	rareTextRepo  *repositories.RareQuestionRepository
+	audioRepo     *repositories.AudioQuestionRepository
+}
+
+// NewQuestionAdminService は依存するリポジトリを組み立ててサービスを返す
+func NewQuestionAdminService(db *gorm.DB) *QuestionAdminService {
+	return &QuestionAdminService{
+		db:            db,
+		userRepo:      repositories.NewUserRepository(db),
+		majorTextRepo: repositories.NewQuestionRepository(db),
+		rareTextRepo:  repositories.NewRareQuestionRepository(db),
+		audioRepo:     repositories.NewAudioQuestionRepository(db),
+	}
+}
+
+// RequireAdmin はユーザーが管理者かを確認する（管理者でなければ ErrForbidden）
+func (s *QuestionAdminService) RequireAdmin(username string) error {
+	user, err := s.userRepo.FindByUsername(username)
+	if err != nil {
+		return err
+	}
+	if user == nil {
+		return ErrUserNotFound
+	}
+	if user.Role != models.RoleAdmin {
+		return ErrForbidden
+	}
+	return nil
+}
+
+// List は問題バンクを検索して1ページ分を返す
+func (s *QuestionAdminService) List(bank string, in QuestionListInput) (*AdminQuestionPageDTO, error) {
+	if !models.IsRatingMode(bank) {
+		return nil, ErrInvalidQuestionBank
+	}
+	switch in.Deleted {
+	case repositories.DeletedExclude, repositories.DeletedOnly, repositories.DeletedInclude:
+	default:
+		return nil, ErrInvalidQuestion
+	}
+	if in.Page < 1 {
+		in.Page = 1
+	}
+	if in.PerPage < 1 {
+		in.PerPage = 1
+	}
+	if in.PerPage > 100 {
+		in.PerPage = 100
+	}
+	search := repositories.QuestionSearch{
+		Query:    strings.TrimSpace(in.Query),
+		Language: strings.TrimSpace(in.Language),
+		Deleted:  in.Deleted,
+		Limit:    in.PerPage,
+		Offset:   (in.Page - 1) * in.PerPage,
+	}
+
+	var (
+		rows  []AdminQuestionDTO
+		total int64
+	)
+	switch bank {
+	case "text-major":
+		found, n, err := s.majorTextRepo.Search(search)
+		if err != nil {
+			return nil, err
+		}
+		for _, q := range found {
+			rows = append(rows, adminQuestionFromText(bank, q.ID, q.Prompt, q.Answer, q.QuestionAudit))
+		}
+		total = n
+	case "text-rare":
+		found, n, err := s.rareTextRepo.Search(search)
+		if err != nil {
+			return nil, err
+		}
+		for _, q := range found {
+			rows = append(rows, adminQuestionFromText(bank, q.ID, q.Prompt, q.Answer, q.QuestionAudit))
+		}
+		total = n
+	case "audio-major":
+		found, n, err := s.audioRepo.Search(search)
+		if err != nil {
+			return nil, err
+		}
+		for _, q := range found {
+			rows = append(rows, adminQuestionFromAudio(bank, q.ID, q.Language, q.AudioURL, q.QuestionAudit))
+		}
+		total = n
+	case "audio-rare":
+		found, n, err := s.audioRepo.SearchRare(search)
+		if err != nil {
+			return nil, err
+		}
+		for _, q := range found {
+			rows = append(rows, adminQuestionFromAudio(bank, q.ID, q.Language, q.AudioURL, q.QuestionAudit))
+		}
+		total = n
+	}
+	if rows == nil {
+		rows = []AdminQuestionDTO{}
+	}
+	return &AdminQuestionPageDTO{
+		Questions: rows,
+		Page:      in.Page,
+		PerPage:   in.PerPage,
+		Total:     total,
+	}, nil
+}
+
+// Get は問題を1件返す（削除済みの問題も返す）
+func (s *QuestionAdminService) Get(bank string, id uint) (*AdminQuestionDTO, error) {
+	return s.find(bank, id, true)
+}
+
+// Create は入力を検証して問題を追加する（admin は作成者として記録する）
+func (s *QuestionAdminService) Create(bank, admin string, in QuestionInput) (*AdminQuestionDTO, error) {
+	if !models.IsRatingMode(bank) {
+		return nil, ErrInvalidQuestionBank
+	}
+	in, err := ValidateQuestionInput(bank, in)
+	if err != nil {
+		return nil, err
+	}
+
+	var id uint
+	switch bank {
+	case "text-major":
+		q := models.Question{Prompt: in.Prompt, Answer: in.Answer, QuestionAudit: models.QuestionAudit{CreatedBy: admin, UpdatedBy: admin}}
+		err = s.majorTextRepo.Create(&q)
+		id = q.ID
+	case "text-rare":
+		q := models.RareQuestion{Prompt: in.Prompt, Answer: in.Answer, QuestionAudit: models.QuestionAudit{CreatedBy: admin, UpdatedBy: admin}}
+		err = s.rareTextRepo.Create(&q)
+		id = q.ID
+	case "audio-major":
+		q := models.AudioQuestion{Language: in.Language, AudioURL: in.AudioURL, QuestionAudit: models.QuestionAudit{CreatedBy: admin, UpdatedBy: admin}}
+		err = s.audioRepo.Create(&q)
+		id = q.ID
+	case "audio-rare":
+		q := models.RareAudioQuestion{Language: in.Language, AudioURL: in.AudioURL, QuestionAudit: models.QuestionAudit{CreatedBy: admin, UpdatedBy: admin}}
+		err = s.audioRepo.CreateRare(&q)
+		id = q.ID
+	}
+	if err != nil {
+		return nil, err
+	}
+	return s.find(bank, id, false)
+}
+
+// Update は入力を検証して問題の内容を置き換える（削除済みの問題は更新できない）
+func (s *QuestionAdminService) Update(bank string, id uint, admin string, in QuestionInput) (*AdminQuestionDTO, error) {
+	if !models.IsRatingMode(bank) {
+		return nil, ErrInvalidQuestionBank
+	}
+	in, err := ValidateQuestionInput(bank, in)
+	if err != nil {
+		return nil, err
+	}
+
+	switch bank {
+	case "text-major":
+		q, err := s.majorTextRepo.FindByID(id, false)
+		if err != nil || q == nil {
+			return nil, notFoundOr(err)
+		}
+		q.Prompt, q.Answer, q.UpdatedBy = in.Prompt, in.Answer, admin
+		if err := s.majorTextRepo.Save(q); err != nil {
+			return nil, err
+		}
+	case "text-rare":
+		q, err := s.rareTextRepo.FindByID(id, false)
+		if err != nil || q == nil {
+			return nil, notFoundOr(err)
+		}
+		q.Prompt, q.Answer, q.UpdatedBy = in.Prompt, in.Answer, admin
+		if err := s.rareTextRepo.Save(q); err != nil {
+			return nil, err
+		}
+	case "audio-major":
+		q, err := s.audioRepo.FindByID(id, false)
+		if err != nil || q == nil {
+			return nil, notFoundOr(err)
+		}
+		q.Language, q.AudioURL, q.UpdatedBy = in.Language, in.AudioURL, admin
+		if err := s.audioRepo.Save(q); err != nil {
+			return nil, err
+		}
+	case "audio-rare":
+		q, err := s.audioRepo.FindRareByID(id, false)
+		if err != nil || q == nil {
+			return nil, notFoundOr(err)
+		}
+		q.Language, q.AudioURL, q.UpdatedBy = in.Language, in.AudioURL, admin
+		if err := s.audioRepo.SaveRare(q); err != nil {
+			return nil, err
+		}
+	}
+	return s.find(bank, id, false)
+}
+
+// Delete は問題を論理削除する（削除済みの問題は出題されなくなる）
+func (s *QuestionAdminService) Delete(bank string, id uint, admin string) (*AdminQuestionDTO, error) {
+	if !models.IsRatingMode(bank) {
+		return nil, ErrInvalidQuestionBank
+	}
+	var (
+		ok  bool
+		err error
+	)
+	switch bank {
+	case "text-major":
+		ok, err = s.majorTextRepo.Delete(id, admin)
+	case "text-rare":
+		ok, err = s.rareTextRepo.Delete(id, admin)
+	case "audio-major":
+		ok, err = s.audioRepo.Delete(id, admin)
+	case "audio-rare":
+		ok, err = s.audioRepo.DeleteRare(id, admin)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return nil, ErrQuestionNotFound
+	}
+	return s.find(bank, id, true)
+}
+
+// Restore は論理削除した問題を元に戻す
+func (s *QuestionAdminService) Restore(bank string, id uint, admin string) (*AdminQuestionDTO, error) {
+	if !models.IsRatingMode(bank) {
+		return nil, ErrInvalidQuestionBank
+	}
+	var (
+		ok  bool
+		err error
+	)
+	switch bank {
+	case "text-major":
+		ok, err = s.majorTextRepo.Restore(id, admin)
+	case "text-rare":
+		ok, err = s.rareTextRepo.Restore(id, admin)
+	case "audio-major":
+		ok, err = s.audioRepo.Restore(id, admin)
+	case "audio-rare":
+		ok, err = s.audioRepo.RestoreRare(id, admin)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return nil, ErrQuestionNotFound
+	}
+	return s.find(bank, id, false)
+}
+
+// find は問題を1件取得して返却用の形にする
+func (s *QuestionAdminService) find(bank string, id uint, withDeleted bool) (*AdminQuestionDTO, error) {
+	var dto AdminQuestionDTO
+	switch bank {
+	case "text-major":
+		q, err := s.majorTextRepo.FindByID(id, withDeleted)
+		if err != nil || q == nil {
+			return nil, notFoundOr(err)
+		}
+		dto = adminQuestionFromText(bank, q.ID, q.Prompt, q.Answer, q.QuestionAudit)
+	case "text-rare":
+		q, err := s.rareTextRepo.FindByID(id, withDeleted)
+		if err != nil || q == nil {
+			return nil, notFoundOr(err)
+		}
+		dto = adminQuestionFromText(bank, q.ID, q.Prompt, q.Answer, q.QuestionAudit)
+	case "audio-major":
+		q, err := s.audioRepo.FindByID(id, withDeleted)
+		if err != nil || q == nil {
+			return nil, notFoundOr(err)
+		}
+		dto = adminQuestionFromAudio(bank, q.ID, q.Language, q.AudioURL, q.QuestionAudit)
+	case "audio-rare":
+		q, err := s.audioRepo.FindRareByID(id, withDeleted)
+		if err != nil || q == nil {
+			return nil, notFoundOr(err)
+		}
+		dto = adminQuestionFromAudio(bank, q.ID, q.Language, q.AudioURL, q.QuestionAudit)
+	default:
+		return nil, ErrInvalidQuestionBank
+	}
+	return &dto, nil
+}
+
+// all は削除済みを除く問題をすべてID順に返す
+func (s *QuestionAdminService) all(bank string) ([]AdminQuestionDTO, error) {
+	var rows []AdminQuestionDTO
+	switch bank {
+	case "text-major":
+		found, err := s.majorTextRepo.FindAll()
+		if err != nil {
+			return nil, err
+		}
+		for _, q := range found {
+			rows = append(rows, adminQuestionFromText(bank, q.ID, q.Prompt, q.Answer, q.QuestionAudit))
+		}
+	case "text-rare":
+		found, err := s.rareTextRepo.FindAll()
+		if err != nil {
+			return nil, err
+		}
+		for _, q := range found {
+			rows = append(rows, adminQuestionFromText(bank, q.ID, q.Prompt, q.Answer, q.QuestionAudit))
+		}
+	case "audio-major":
+		found, err := s.audioRepo.FindAll()
+		if err != nil {
+			return nil, err
+		}
+		for _, q := range found {
+			rows = append(rows, adminQuestionFromAudio(bank, q.ID, q.Language, q.AudioURL, q.QuestionAudit))
+		}
+	case "audio-rare":
+		found, err := s.audioRepo.FindAllRare()
+		if err != nil {
+			return nil, err
+		}
+		for _, q := range found {
+			rows = append(rows, adminQuestionFromAudio(bank, q.ID, q.Language, q.AudioURL, q.QuestionAudit))
+		}
+	default:
+		return nil, ErrInvalidQuestionBank
+	}
+	return rows, nil
+}
+
+// ValidateQuestionInput は問題バンクに合わせて入力を検証し、前後の空白を除いた入力を返す
+// 不正な項目があれば最初の1つを *QuestionFieldError で返す
+func ValidateQuestionInput(bank string, in QuestionInput) (QuestionInput, error) {
+	in.Prompt = strings.TrimSpace(in.Prompt)
+	in.Answer = strings.TrimSpace(in.Answer)
+	in.Language = strings.TrimSpace(in.Language)
+	in.AudioURL = strings.TrimSpace(in.AudioURL)
+
+	if strings.HasPrefix(bank, "text-") {
+		switch {
+		case in.Prompt == "":
+			return in, &QuestionFieldError{Field: "prompt", Reason: "required"}
+		case utf8.RuneCountInString(in.Prompt) > maxPromptLength:
+			return in, &QuestionFieldError{Field: "prompt", Reason: fmt.Sprintf("must be at most %d characters", maxPromptLength)}
+		case in.Answer == "":
+			return in, &QuestionFieldError{Field: "answer", Reason: "required"}
+		case utf8.RuneCountInString(in.Answer) > maxLanguageLength:
+			return in, &QuestionFieldError{Field: "answer", Reason: fmt.Sprintf("must be at most %d characters", maxLanguageLength)}
+		}
+		return in, nil
+	}
+
+	switch {
+	case in.Language == "":
+		return in, &QuestionFieldError{Field: "language", Reason: "required"}
+	case utf8.RuneCountInString(in.Language) > maxLanguageLength:
+		return in, &QuestionFieldError{Field: "language", Reason: fmt.Sprintf("must be at most %d characters", maxLanguageLength)}
+	case in.AudioURL == "":
+		return in, &QuestionFieldError{Field: "audioUrl", Reason: "required"}
+	case len(in.AudioURL) > maxAudioURLLength:
+		return in, &QuestionFieldError{Field: "audioUrl", Reason: fmt.Sprintf("must be at most %d bytes", maxAudioURLLength)}
+	case !isAudioURL(in.AudioURL):
+		return in, &QuestionFieldError{Field: "audioUrl", Reason: "must be a path under /audio/ or an http(s) URL"}
+	}
+	return in, nil
+}
+
+// isAudioURL は音声のURLが配信中の /audio/ 以下のパスか http(s) のURLかを返す
+func isAudioURL(raw string) bool {
+	u, err := url.Parse(raw)
+	if err != nil {
+		return false
+	}
+	if u.Scheme == "http" || u.Scheme == "https" {
+		return u.Host != ""
+	}
+	return u.Scheme == "" && u.Host == "" && strings.HasPrefix(u.Path, "/audio/") && !strings.Contains(u.Path, "..")
+}
+
+// adminQuestionFromText はテキスト問題を返却用の形にする
+func adminQuestionFromText(bank string, id uint, prompt, answer string, audit models.QuestionAudit) AdminQuestionDTO {
+	dto := adminQuestionBase(bank, id, audit)
+	dto.Prompt = prompt
+	dto.Answer = answer
+	return dto
+}
+
+// adminQuestionFromAudio は音声問題を返却用の形にする
+func adminQuestionFromAudio(bank string, id uint, language, audioURL string, audit models.QuestionAudit) AdminQuestionDTO {
+	dto := adminQuestionBase(bank, id, audit)
+	dto.Language = language
+	dto.AudioURL = audioURL
+	return dto
+}
+
+// adminQuestionBase は返却用の形のうち問題バンクに共通する部分を作る
+func adminQuestionBase(bank string, id uint, audit models.QuestionAudit) AdminQuestionDTO {
+	dto := AdminQuestionDTO{
+		ID:        id,
+		Bank:      bank,
+		CreatedAt: audit.CreatedAt,
+		UpdatedAt: audit.UpdatedAt,
+		CreatedBy: audit.CreatedBy,
+		UpdatedBy: audit.UpdatedBy,
+		DeletedBy: audit.DeletedBy,
+	}
+	if audit.DeletedAt.Valid {
+		deletedAt := audit.DeletedAt.Time
+		dto.DeletedAt = &deletedAt
+	}
+	return dto
+}
+
+// notFoundOr は取得時のエラーがあればそれを、なければ ErrQuestionNotFound を返す
+func notFoundOr(err error) error {
+	if err != nil {
+		return err
+	}
+	return ErrQuestionNotFound
+}
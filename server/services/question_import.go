@@ -0,0 +1,450 @@
+package services
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"example.com/mathkun-tmp-/server/models"
+	"gorm.io/gorm"
+)
+
+// 問題の取り込み・書き出しの形式
+const (
+	FormatCSV   = "csv"   // 1行目が見出し（id, prompt, answer / id, language, audioUrl）
+	FormatJSONL = "jsonl" // 1行に1問のJSON（キーは CSV の見出しと同じ）
+)
+
+// 取り込みの各行の処理結果
+const (
+	ImportCreated   = "created"   // 新しい問題として追加した
+	ImportUpdated   = "updated"   // 既存の問題を書き換えた
+	ImportUnchanged = "unchanged" // 既存の問題と同じ内容だった
+	ImportFailed    = "error"     // 不正な行で、取り込まなかった
+)
+
+// DefaultAudioDir は音声問題の取り込みでファイルの存在を確かめるディレクトリ（/audio/ として配信している）
+const DefaultAudioDir = "public/audio"
+
+// 取り込みの上限
+const (
+	maxImportRows      = 10000   // 1ファイルの行数
+	maxImportLineBytes = 1 << 20 // JSONL の1行の長さ
+)
+
+// 取り込み・書き出し関連のエラー
+var (
+	ErrInvalidFormat = errors.New("invalid format")
+	ErrInvalidImport = errors.New("invalid import file")
+)
+
+// QuestionRecord は取り込み・書き出しの1問分
+// id を指定した行はその問題を更新し、省略した行は問題文（音声問題は音声のURL）が同じ問題があれば更新、なければ追加する
+type QuestionRecord struct {
+	ID       uint   `json:"id,omitempty"`
+	Prompt   string `json:"prompt,omitempty"`
+	Answer   string `json:"answer,omitempty"`
+	Language string `json:"language,omitempty"`
+	AudioURL string `json:"audioUrl,omitempty"`
+}
+
+// QuestionImportOptions は問題の取り込みの設定
+type QuestionImportOptions struct {
+	Bank     string // 取り込む問題バンク（text-major など）
+	Format   string // FormatCSV / FormatJSONL
+	DryRun   bool   // true なら検証と処理結果の報告だけを行い、DBは変えない
+	Actor    string // 作成者・更新者として記録する名前
+	AudioDir string // 音声ファイルを探すディレクトリ（空なら DefaultAudioDir）
+}
+
+// ImportRowResult は取り込みの1行分の処理結果
+type ImportRowResult struct {
+	Line   int    `json:"line"` // ファイル上の行番号（1始まり、CSVは見出しが1行目）
+	Action string `json:"action"`
+	ID     uint   `json:"id,omitempty"` // 追加・更新した問題（ドライランでの追加は0）
+	Field  string `json:"field,omitempty"`
+	Error  string `json:"error,omitempty"`
+}
+
+// QuestionImportReport は取り込みの結果
+type QuestionImportReport struct {
+	Bank      string            `json:"bank"`
+	Format    string            `json:"format"`
+	DryRun    bool              `json:"dryRun"`
+	Total     int               `json:"total"`
+	Created   int               `json:"created"`
+	Updated   int               `json:"updated"`
+	Unchanged int               `json:"unchanged"`
+	Failed    int               `json:"failed"`
+	Rows      []ImportRowResult `json:"rows"`
+}
+
+// importRecord は読み込んだ1行分（読み込めなかった行は err を持つ）
+type importRecord struct {
+	line   int
+	record QuestionRecord
+	err    error
+}
+
+// Import は CSV / JSONL の問題を問題バンクに取り込む
+// 不正な行は行番号と理由を報告して読み飛ばし、正しい行だけを取り込む（ファイル自体が読めなければエラー）
+// 同じファイル内で問題文（正規化したもの）や音声のURLが重なる行は、後の行を重複として取り込まない
+// 追加と更新は1つのトランザクションで行い、途中でDBのエラーが起きたら全体を取り消す
+func (s *QuestionAdminService) Import(r io.Reader, opts QuestionImportOptions) (*QuestionImportReport, error) {
+	if opts.AudioDir == "" {
+		opts.AudioDir = DefaultAudioDir
+	}
+	if !models.IsRatingMode(opts.Bank) {
+		return nil, ErrInvalidQuestionBank
+	}
+	var (
+		records []importRecord
+		err     error
+	)
+	switch opts.Format {
+	case FormatCSV:
+		records, err = readCSVRecords(r)
+	case FormatJSONL:
+		records, err = readJSONLRecords(r)
+	default:
+		return nil, ErrInvalidFormat
+	}
+	if err != nil {
+		return nil, err
+	}
+	if len(records) > maxImportRows {
+		return nil, fmt.Errorf("%w: more than %d rows", ErrInvalidImport, maxImportRows)
+	}
+
+	report := &QuestionImportReport{
+		Bank:   opts.Bank,
+		Format: opts.Format,
+		DryRun: opts.DryRun,
+		Total:  len(records),
+		Rows:   make([]ImportRowResult, 0, len(records)),
+	}
+	if opts.DryRun {
+		existing, err := s.all(opts.Bank)
+		if err != nil {
+			return nil, err
+		}
+		if err := s.importRecords(records, existing, opts, report); err != nil {
+			return nil, err
+		}
+		return report, nil
+	}
+	err = s.db.Transaction(func(tx *gorm.DB) error {
+		txService := NewQuestionAdminService(tx)
+		existing, err := txService.all(opts.Bank)
+		if err != nil {
+			return err
+		}
+		return txService.importRecords(records, existing, opts, report)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return report, nil
+}
+
+// importRecords は読み込んだ行を既存の問題と突き合わせて順に検証し、追加・更新する
+// ドライランなら処理結果を数えるだけで、DBには触れない
+func (s *QuestionAdminService) importRecords(records []importRecord, existing []AdminQuestionDTO, opts QuestionImportOptions, report *QuestionImportReport) error {
+	byID := make(map[uint]QuestionInput, len(existing))
+	byKey := make(map[string]uint, len(existing))
+	for _, q := range existing {
+		in := QuestionInput{Prompt: q.Prompt, Answer: q.Answer, Language: q.Language, AudioURL: q.AudioURL}
+		byID[q.ID] = in
+		byKey[questionKey(opts.Bank, in)] = q.ID
+	}
+	keyField := "prompt"
+	if isAudioBank(opts.Bank) {
+		keyField = "audioUrl"
+	}
+
+	seenKeys := map[string]int{}
+	seenIDs := map[uint]int{}
+	for _, rec := range records {
+		row := ImportRowResult{Line: rec.line}
+		fail := func(field, reason string) {
+			row.Action = ImportFailed
+			row.Field = field
+			row.Error = reason
+			report.Failed++
+			report.Rows = append(report.Rows, row)
+		}
+		if rec.err != nil {
+			fail("", rec.err.Error())
+			continue
+		}
+
+		in, err := ValidateQuestionInput(opts.Bank, QuestionInput{
+			Prompt:   rec.record.Prompt,
+			Answer:   rec.record.Answer,
+			Language: rec.record.Language,
+			AudioURL: rec.record.AudioURL,
+		})
+		var fieldErr *QuestionFieldError
+		if errors.As(err, &fieldErr) {
+			fail(fieldErr.Field, fieldErr.Reason)
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		if isAudioBank(opts.Bank) {
+			if reason := checkAudioFile(opts.AudioDir, in.AudioURL); reason != "" {
+				fail("audioUrl", reason)
+				continue
+			}
+		}
+
+		key := questionKey(opts.Bank, in)
+		if line, ok := seenKeys[key]; ok {
+			fail(keyField, fmt.Sprintf("duplicate of line %d", line))
+			continue
+		}
+		target := rec.record.ID
+		if target != 0 {
+			if line, ok := seenIDs[target]; ok {
+				fail("id", fmt.Sprintf("id %d is also on line %d", target, line))
+				continue
+			}
+			if _, ok := byID[target]; !ok {
+				fail("id", "question not found")
+				continue
+			}
+		}
+		if other, ok := byKey[key]; ok {
+			if target != 0 && other != target {
+				fail(keyField, fmt.Sprintf("duplicate of question %d", other))
+				continue
+			}
+			target = other
+		}
+		seenKeys[key] = rec.line
+		if target != 0 {
+			seenIDs[target] = rec.line
+		}
+
+		switch {
+		case target == 0:
+			row.Action = ImportCreated
+			report.Created++
+			if !opts.DryRun {
+				dto, err := s.Create(opts.Bank, opts.Actor, in)
+				if err != nil {
+					return err
+				}
+				row.ID = dto.ID
+			}
+		case byID[target] == in:
+			row.Action = ImportUnchanged
+			row.ID = target
+			report.Unchanged++
+		default:
+			row.Action = ImportUpdated
+			row.ID = target
+			report.Updated++
+			if !opts.DryRun {
+				if _, err := s.Update(opts.Bank, target, opts.Actor, in); err != nil {
+					return err
+				}
+			}
+			// 書き換える前の問題文で後の行が同じ問題に当たらないようにする
+			delete(byKey, questionKey(opts.Bank, byID[target]))
+			byKey[key] = target
+			byID[target] = in
+		}
+		report.Rows = append(report.Rows, row)
+	}
+	return nil
+}
+
+// Export は問題バンクの問題（削除済みを除く）を CSV / JSONL で書き出し、書き出した件数を返す
+// 書き出したファイルはそのまま Import で取り込める（id があるので同じ問題の更新になる）
+func (s *QuestionAdminService) Export(w io.Writer, bank, format string) (int, error) {
+	if !models.IsRatingMode(bank) {
+		return 0, ErrInvalidQuestionBank
+	}
+	if format != FormatCSV && format != FormatJSONL {
+		return 0, ErrInvalidFormat
+	}
+	questions, err := s.all(bank)
+	if err != nil {
+		return 0, err
+	}
+
+	if format == FormatJSONL {
+		enc := json.NewEncoder(w)
+		enc.SetEscapeHTML(false)
+		for _, q := range questions {
+			record := QuestionRecord{ID: q.ID, Prompt: q.Prompt, Answer: q.Answer, Language: q.Language, AudioURL: q.AudioURL}
+			if err := enc.Encode(record); err != nil {
+				return 0, err
+			}
+		}
+		return len(questions), nil
+	}
+
+	cw := csv.NewWriter(w)
+	header := []string{"id", "prompt", "answer"}
+	if isAudioBank(bank) {
+		header = []string{"id", "language", "audioUrl"}
+	}
+	if err := cw.Write(header); err != nil {
+		return 0, err
+	}
+	for _, q := range questions {
+		row := []string{strconv.FormatUint(uint64(q.ID), 10), q.Prompt, q.Answer}
+		if isAudioBank(bank) {
+			row = []string{strconv.FormatUint(uint64(q.ID), 10), q.Language, q.AudioURL}
+		}
+		if err := cw.Write(row); err != nil {
+			return 0, err
+		}
+	}
+	cw.Flush()
+	return len(questions), cw.Error()
+}
+
+// readCSVRecords は見出し付きの CSV を読み込む
+// 見出しは大文字小文字と "_" を区別しない（audio_url も audioUrl として扱う）。表計算ソフトが付ける BOM は読み飛ばす
+func readCSVRecords(r io.Reader) ([]importRecord, error) {
+	cr := csv.NewReader(r)
+	cr.FieldsPerRecord = -1
+	header, err := cr.Read()
+	if errors.Is(err, io.EOF) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidImport, err)
+	}
+	columns := make([]string, len(header))
+	for i, name := range header {
+		if i == 0 {
+			name = strings.TrimPrefix(name, "\ufeff")
+		}
+		name = strings.ToLower(strings.ReplaceAll(strings.TrimSpace(name), "_", ""))
+		switch name {
+		case "id", "prompt", "answer", "language", "audiourl":
+		default:
+			return nil, fmt.Errorf("%w: unknown column %q", ErrInvalidImport, header[i])
+		}
+		columns[i] = name
+	}
+
+	var records []importRecord
+	for {
+		fields, err := cr.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrInvalidImport, err)
+		}
+		line, _ := cr.FieldPos(0)
+		rec := importRecord{line: line}
+		if len(fields) != len(columns) {
+			rec.err = fmt.Errorf("expected %d columns, got %d", len(columns), len(fields))
+			records = append(records, rec)
+			continue
+		}
+		for i, value := range fields {
+			switch columns[i] {
+			case "id":
+				if value = strings.TrimSpace(value); value != "" {
+					id, err := strconv.ParseUint(value, 10, 32)
+					if err != nil || id == 0 {
+						rec.err = fmt.Errorf("invalid id %q", value)
+					}
+					rec.record.ID = uint(id)
+				}
+			case "prompt":
+				rec.record.Prompt = value
+			case "answer":
+				rec.record.Answer = value
+			case "language":
+				rec.record.Language = value
+			case "audiourl":
+				rec.record.AudioURL = value
+			}
+		}
+		records = append(records, rec)
+	}
+	return records, nil
+}
+
+// readJSONLRecords は1行に1問の JSON を読み込む（空行は読み飛ばす）
+// 知らないキーがある行は、見出しの打ち間違いに気付けるよう不正な行として扱う
+func readJSONLRecords(r io.Reader) ([]importRecord, error) {
+	sc := bufio.NewScanner(r)
+	sc.Buffer(make([]byte, 0, 64*1024), maxImportLineBytes)
+	var records []importRecord
+	line := 0
+	for sc.Scan() {
+		line++
+		text := strings.TrimSpace(sc.Text())
+		if line == 1 {
+			text = strings.TrimPrefix(text, "\ufeff")
+		}
+		if text == "" {
+			continue
+		}
+		rec := importRecord{line: line}
+		dec := json.NewDecoder(strings.NewReader(text))
+		dec.DisallowUnknownFields()
+		if err := dec.Decode(&rec.record); err != nil {
+			rec.err = fmt.Errorf("invalid JSON: %v", err)
+		} else if dec.More() {
+			rec.err = errors.New("invalid JSON: more than one value on the line")
+		}
+		records = append(records, rec)
+	}
+	if err := sc.Err(); err != nil {
+		return nil, fmt.Errorf("%w: line %d: %v", ErrInvalidImport, line+1, err)
+	}
+	return records, nil
+}
+
+// checkAudioFile は音声のURLが audioDir にあるファイルを指しているかを確かめ、問題があれば理由を返す
+// 取り込む音声は自分のサーバーで配信するものに限るので、外部のURLは受け付けない
+func checkAudioFile(audioDir, audioURL string) string {
+	cleaned := path.Clean(audioURL)
+	if !strings.HasPrefix(cleaned, "/audio/") {
+		return "must reference a file under /audio/"
+	}
+	full := filepath.Join(audioDir, filepath.FromSlash(strings.TrimPrefix(cleaned, "/audio/")))
+	info, err := os.Stat(full)
+	if err != nil || !info.Mode().IsRegular() {
+		return "audio file not found"
+	}
+	return ""
+}
+
+// questionKey は重複を見分けるためのキーを返す（テキスト問題は正規化した問題文、音声問題は音声のURL）
+func questionKey(bank string, in QuestionInput) string {
+	if isAudioBank(bank) {
+		return path.Clean(in.AudioURL)
+	}
+	return NormalizePrompt(in.Prompt)
+}
+
+// NormalizePrompt は重複の判定に使うよう問題文を正規化する
+// 大文字小文字と空白の違い（連続した空白・改行・全角スペース）を無視する
+func NormalizePrompt(prompt string) string {
+	return strings.ToLower(strings.Join(strings.Fields(prompt), " "))
+}
+
+// isAudioBank は音声問題のバンクかを返す
+func isAudioBank(bank string) bool {
+	return strings.HasPrefix(bank, "audio-")
+}
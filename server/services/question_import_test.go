@@ -0,0 +1,235 @@
+package services
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestReadCSVRecords(t *testing.T) {
+	cases := []struct {
+		name    string
+		input   string
+		want    []importRecord // err は有無だけを比べる
+		wantErr bool
+	}{
+		{
+			name:  "empty file",
+			input: "",
+		},
+		{
+			name:  "header aliases, BOM and line numbers",
+			input: "\ufeffID,Prompt,Answer\n1,Hello,English\n,\"Hola,\n amigo\",Spanish\n,Bonjour,French\n",
+			want: []importRecord{
+				{line: 2, record: QuestionRecord{ID: 1, Prompt: "Hello", Answer: "English"}},
+				{line: 3, record: QuestionRecord{Prompt: "Hola,\n amigo", Answer: "Spanish"}},
+				{line: 5, record: QuestionRecord{Prompt: "Bonjour", Answer: "French"}},
+			},
+		},
+		{
+			name:  "audio_url is audioUrl",
+			input: "language,audio_url\nJapanese,/audio/ja.mp3\n",
+			want:  []importRecord{{line: 2, record: QuestionRecord{Language: "Japanese", AudioURL: "/audio/ja.mp3"}}},
+		},
+		{
+			name:  "malformed rows are reported and the rest is read",
+			input: "id,prompt,answer\nx,Hello,English\n0,Hello,English\n,only two\n, Ciao ,Italian\n",
+			want: []importRecord{
+				{line: 2, err: errors.New("invalid id")},
+				{line: 3, err: errors.New("invalid id")},
+				{line: 4, err: errors.New("column count")},
+				{line: 5, record: QuestionRecord{Prompt: " Ciao ", Answer: "Italian"}},
+			},
+		},
+		{
+			name:    "unknown column",
+			input:   "id,question,answer\n",
+			wantErr: true,
+		},
+		{
+			name:    "broken quoting",
+			input:   "prompt,answer\n\"Hello,English\n",
+			wantErr: true,
+		},
+	}
+	for _, tc := range cases {
+		got, err := readCSVRecords(strings.NewReader(tc.input))
+		if tc.wantErr {
+			if !errors.Is(err, ErrInvalidImport) {
+				t.Errorf("%s: err = %v, want ErrInvalidImport", tc.name, err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatalf("%s: %v", tc.name, err)
+		}
+		compareImportRecords(t, tc.name, got, tc.want)
+	}
+}
+
+func TestReadJSONLRecords(t *testing.T) {
+	input := strings.Join([]string{
+		"\ufeff{\"id\":3,\"prompt\":\"Hello\",\"answer\":\"English\"}",
+		"",
+		"   ",
+		"{\"prompt\":\"Hola\",\"answer\":\"Spanish\"}",
+		"{\"prompt\":\"Hallo\",\"anwser\":\"German\"}",
+		"{\"prompt\":\"Ciao\"",
+		"{\"prompt\":\"Oi\"} {\"prompt\":\"Ola\"}",
+		"[1,2]",
+		"{\"language\":\"Japanese\",\"audioUrl\":\"/audio/ja.mp3\"}",
+	}, "\n")
+	want := []importRecord{
+		{line: 1, record: QuestionRecord{ID: 3, Prompt: "Hello", Answer: "English"}},
+		{line: 4, record: QuestionRecord{Prompt: "Hola", Answer: "Spanish"}},
+		{line: 5, err: errors.New("unknown field")},
+		{line: 6, err: errors.New("truncated")},
+		{line: 7, err: errors.New("two values")},
+		{line: 8, err: errors.New("not an object")},
+		{line: 9, record: QuestionRecord{Language: "Japanese", AudioURL: "/audio/ja.mp3"}},
+	}
+	got, err := readJSONLRecords(strings.NewReader(input))
+	if err != nil {
+		t.Fatal(err)
+	}
+	compareImportRecords(t, "jsonl", got, want)
+
+	long := "{\"prompt\":\"" + strings.Repeat("a", maxImportLineBytes) + "\"}"
+	if _, err := readJSONLRecords(strings.NewReader(long)); !errors.Is(err, ErrInvalidImport) {
+		t.Errorf("an over-long line should fail the file, got %v", err)
+	}
+}
+
+// compareImportRecords は行番号・内容・エラーの有無を比べる
+func compareImportRecords(t *testing.T, name string, got, want []importRecord) {
+	t.Helper()
+	if len(got) != len(want) {
+		t.Fatalf("%s: got %d records, want %d: %+v", name, len(got), len(want), got)
+	}
+	for i, w := range want {
+		g := got[i]
+		if g.line != w.line || (g.err != nil) != (w.err != nil) {
+			t.Errorf("%s[%d]: line %d err %v, want line %d err %v", name, i, g.line, g.err, w.line, w.err)
+			continue
+		}
+		if w.err == nil && g.record != w.record {
+			t.Errorf("%s[%d] = %+v, want %+v", name, i, g.record, w.record)
+		}
+	}
+}
+
+func TestNormalizePromptAndQuestionKey(t *testing.T) {
+	cases := []struct {
+		name string
+		bank string
+		a, b QuestionInput
+		same bool
+	}{
+		{"case", "text-major", QuestionInput{Prompt: "Hello World"}, QuestionInput{Prompt: "hello world"}, true},
+		{"inner spaces", "text-major", QuestionInput{Prompt: "Hello   World"}, QuestionInput{Prompt: "Hello World"}, true},
+		{"tabs, newlines and ideographic spaces", "text-major", QuestionInput{Prompt: " Hello\t\n　World "}, QuestionInput{Prompt: "Hello World"}, true},
+		{"answer does not matter", "text-rare", QuestionInput{Prompt: "Hello", Answer: "English"}, QuestionInput{Prompt: "hello", Answer: "German"}, true},
+		{"different words", "text-major", QuestionInput{Prompt: "Hello World"}, QuestionInput{Prompt: "HelloWorld"}, false},
+		{"audio uses the cleaned URL", "audio-major", QuestionInput{AudioURL: "/audio/./a/../ja.mp3"}, QuestionInput{AudioURL: "/audio/ja.mp3"}, true},
+		{"audio ignores the prompt", "audio-major", QuestionInput{Prompt: "x", AudioURL: "/audio/ja.mp3"}, QuestionInput{Prompt: "y", AudioURL: "/audio/ja.mp3"}, true},
+		{"audio URL case matters", "audio-major", QuestionInput{AudioURL: "/audio/JA.mp3"}, QuestionInput{AudioURL: "/audio/ja.mp3"}, false},
+	}
+	for _, tc := range cases {
+		a, b := questionKey(tc.bank, tc.a), questionKey(tc.bank, tc.b)
+		if (a == b) != tc.same {
+			t.Errorf("%s: keys %q and %q, want same=%v", tc.name, a, b, tc.same)
+		}
+	}
+	if got := NormalizePrompt("  Ｈello　 WORLD \n"); got != "ｈello world" {
+		t.Errorf("NormalizePrompt = %q", got)
+	}
+}
+
+func TestImportRecordsDryRun(t *testing.T) {
+	existing := []AdminQuestionDTO{
+		{ID: 10, Prompt: "Hello", Answer: "English"},
+		{ID: 11, Prompt: "Bonjour", Answer: "French"},
+		{ID: 12, Prompt: "Guten Tag", Answer: "German"},
+	}
+	records := []importRecord{
+		{line: 2, record: QuestionRecord{Prompt: "Hello", Answer: "English"}},            // 既存10と内容も同じ
+		{line: 3, record: QuestionRecord{Prompt: "Hola", Answer: "Spanish"}},             // 新しい問題
+		{line: 4, record: QuestionRecord{Prompt: "  HOLA ", Answer: "Spanish"}},          // 3行目の重複
+		{line: 5, record: QuestionRecord{ID: 11, Prompt: "Bonjour", Answer: "Français"}}, // 11を書き換える
+		{line: 6, record: QuestionRecord{ID: 11, Prompt: "Salut", Answer: "French"}},     // 同じidが2回
+		{line: 7, record: QuestionRecord{ID: 99, Prompt: "Ciao", Answer: "Italian"}},     // ないid
+		{line: 8, record: QuestionRecord{ID: 11, Prompt: "HELLO", Answer: "English"}},    // 2行目と同じ問題文
+		{line: 9, record: QuestionRecord{Prompt: "Hallo"}},                               // 正解がない
+		{line: 10, err: errors.New("expected 3 columns, got 2")},
+		{line: 11, record: QuestionRecord{Prompt: "Hello", Answer: "Englisch"}},    // 2行目の重複（既存と同じ問題文でも）
+		{line: 12, record: QuestionRecord{Prompt: "guten  tag", Answer: "German"}}, // 大文字小文字と空白だけ違うので12の書き換え
+	}
+	want := []ImportRowResult{
+		{Line: 2, Action: ImportUnchanged, ID: 10},
+		{Line: 3, Action: ImportCreated},
+		{Line: 4, Action: ImportFailed, Field: "prompt", Error: "duplicate of line 3"},
+		{Line: 5, Action: ImportUpdated, ID: 11},
+		{Line: 6, Action: ImportFailed, Field: "id", Error: "id 11 is also on line 5"},
+		{Line: 7, Action: ImportFailed, Field: "id", Error: "question not found"},
+		{Line: 8, Action: ImportFailed, Field: "prompt", Error: "duplicate of line 2"},
+		{Line: 9, Action: ImportFailed, Field: "answer", Error: "required"},
+		{Line: 10, Action: ImportFailed, Error: "expected 3 columns, got 2"},
+		{Line: 11, Action: ImportFailed, Field: "prompt", Error: "duplicate of line 2"},
+		{Line: 12, Action: ImportUpdated, ID: 12},
+	}
+
+	// ドライランは DB に触れないので、リポジトリのないサービスで呼べる
+	report := &QuestionImportReport{}
+	err := (&QuestionAdminService{}).importRecords(records, existing, QuestionImportOptions{Bank: "text-major", DryRun: true}, report)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.Created != 1 || report.Updated != 2 || report.Unchanged != 1 || report.Failed != 7 {
+		t.Errorf("counts created=%d updated=%d unchanged=%d failed=%d, want 1/2/1/7", report.Created, report.Updated, report.Unchanged, report.Failed)
+	}
+	if len(report.Rows) != len(want) {
+		t.Fatalf("got %d rows, want %d: %+v", len(report.Rows), len(want), report.Rows)
+	}
+	for i, w := range want {
+		if report.Rows[i] != w {
+			t.Errorf("row %d = %+v, want %+v", i, report.Rows[i], w)
+		}
+	}
+}
+
+func TestImportRecordsDryRunAudio(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "ja.mp3"), []byte("x"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	records := []importRecord{
+		{line: 2, record: QuestionRecord{Language: "Japanese", AudioURL: "/audio/ja.mp3"}},
+		{line: 3, record: QuestionRecord{Language: "Japanese", AudioURL: "/audio/./ja.mp3"}},
+		{line: 4, record: QuestionRecord{Language: "German", AudioURL: "/audio/de.mp3"}},
+		{line: 5, record: QuestionRecord{Language: "German", AudioURL: "https://example.com/de.mp3"}},
+		{line: 6, record: QuestionRecord{Language: "German", AudioURL: "/audio/../secret.mp3"}},
+	}
+	want := []ImportRowResult{
+		{Line: 2, Action: ImportCreated},
+		{Line: 3, Action: ImportFailed, Field: "audioUrl", Error: "duplicate of line 2"},
+		{Line: 4, Action: ImportFailed, Field: "audioUrl", Error: "audio file not found"},
+		{Line: 5, Action: ImportFailed, Field: "audioUrl", Error: "must reference a file under /audio/"},
+		{Line: 6, Action: ImportFailed, Field: "audioUrl", Error: "must be a path under /audio/ or an http(s) URL"},
+	}
+
+	report := &QuestionImportReport{}
+	opts := QuestionImportOptions{Bank: "audio-major", DryRun: true, AudioDir: dir}
+	if err := (&QuestionAdminService{}).importRecords(records, nil, opts, report); err != nil {
+		t.Fatal(err)
+	}
+	if len(report.Rows) != len(want) {
+		t.Fatalf("got %d rows, want %d: %+v", len(report.Rows), len(want), report.Rows)
+	}
+	for i, w := range want {
+		if report.Rows[i] != w {
+			t.Errorf("row %d = %+v, want %+v", i, report.Rows[i], w)
+		}
+	}
+}
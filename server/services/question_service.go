@@ -162,13 +162,13 @@ func (s *QuestionService) GetMatchTextQuestions(count int, mode string, buildCho
 		if len(rows) == 0 {
 			return nil, errors.New("no rare questions found")
 		}
-		// 各問題に4択の選択肢を付与
+		// 各問題に選択肢を付与（数は buildChoicesFn が決める）
 		for _, q := range rows {
 			questions = append(questions, MatchQuestionDTO{
 				ID:      q.ID,
 				Prompt:  q.Prompt,
 				Answer:  q.Answer,
-				Choices: buildChoicesFn(q.Answer, rng), // 正解を含む4択を生成
+				Choices: buildChoicesFn(q.Answer, rng), // 正解を含む選択肢を生成
 			})
 		}
 	} else {
@@ -180,13 +180,13 @@ func (s *QuestionService) GetMatchTextQuestions(count int, mode string, buildCho
 		if len(rows) == 0 {
 			return nil, errors.New("no questions found")
 		}
-		// 各問題に4択の選択肢を付与
+		// 各問題に選択肢を付与（数は buildChoicesFn が決める）
 		for _, q := range rows {
 			questions = append(questions, MatchQuestionDTO{
 				ID:      q.ID,
 				Prompt:  q.Prompt,
 				Answer:  q.Answer,
-				Choices: buildChoicesFn(q.Answer, rng), // 正解を含む4択を生成
+				Choices: buildChoicesFn(q.Answer, rng), // 正解を含む選択肢を生成
 			})
 		}
 	}
@@ -224,7 +224,7 @@ func (s *QuestionService) GetMatchAudioQuestions(count int, mode string, buildCh
 				Prompt:   "", // 音声問題はプロンプトなし（音声のみ）
 				Answer:   row.Language,
 				AudioURL: row.AudioURL,
-				Choices:  buildChoicesFn(row.Language, rng, choiceMode), // レア言語プールから4択
+				Choices:  buildChoicesFn(row.Language, rng, choiceMode), // レア言語プールから選択肢を生成
 			})
 		}
 	} else {
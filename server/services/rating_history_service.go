@@ -0,0 +1,237 @@
+package services
+
+import (
+	"errors"
+	"strings"
+	"time"
+
+	"example.com/mathkun-tmp-/server/models"
+	"example.com/mathkun-tmp-/server/repositories"
+	"gorm.io/gorm"
+)
+
+// レーティング推移の取得で使うエラー
+var (
+	ErrUserNotFound  = errors.New("user not found")
+	ErrInvalidMode   = errors.New("invalid mode")
+	ErrInvalidBucket = errors.New("invalid bucket")
+)
+
+// MaxRatingHistoryEntries は1試合ずつ返すときの最大件数（超える分は古い試合から省く）
+const MaxRatingHistoryEntries = 500
+
+// 集計の単位
+const (
+	RatingBucketDay  = "day"
+	RatingBucketWeek = "week"
+)
+
+// RatingHistoryQuery はレーティング推移の取得条件
+type RatingHistoryQuery struct {
+	Mode   string    // 対戦モード（空なら text-major、team-* / royale-* も指定できる）
+	From   time.Time // この日時以降（ゼロ値なら制限なし）
+	To     time.Time // この日時より前（ゼロ値なら制限なし）
+	Bucket string    // "day" / "week" で集計、空なら1試合ずつ返す
+}
+
+// RatingHistoryEntryDTO は1試合分のレーティング変動
+type RatingHistoryEntryDTO struct {
+	MatchID      uint      `json:"matchId"`
+	PlayedAt     time.Time `json:"playedAt"`
+	RatingBefore int       `json:"ratingBefore"`
+	RatingAfter  int       `json:"ratingAfter"`
+	RatingDelta  int       `json:"ratingDelta"`
+	Outcome      string    `json:"outcome"`
+	Opponents    []string  `json:"opponents"`
+}
+
+// RatingHistoryBucketDTO は1日または1週間分にまとめたレーティング変動（ローソク足のように使える）
+type RatingHistoryBucketDTO struct {
+	Start       time.Time `json:"start"`       // 期間の開始（日単位はその日の0時、週単位は月曜0時）
+	OpenRating  int       `json:"openRating"`  // 期間最初の試合前のレーティング
+	CloseRating int       `json:"closeRating"` // 期間最後の試合後のレーティング
+	HighRating  int       `json:"highRating"`
+	LowRating   int       `json:"lowRating"`
+	Matches     int       `json:"matches"`
+	Wins        int       `json:"wins"`
+	Losses      int       `json:"losses"`
+	Draws       int       `json:"draws"`
+}
+
+// RatingStreakDTO は期間内の連勝・連敗の記録
+type RatingStreakDTO struct {
+	Current        int    `json:"current"`                  // 直近から続いている同じ結果の回数
+	CurrentOutcome string `json:"currentOutcome,omitempty"` // その結果（"victory", "defeat", "draw"）
+	LongestWin     int    `json:"longestWin"`               // 期間内の最長連勝
+}
+
+// RatingHistoryDTO はレーティング推移APIのレスポンス
+type RatingHistoryDTO struct {
+	Username string                   `json:"username"`
+	Mode     string                   `json:"mode"`
+	Bucket   string                   `json:"bucket,omitempty"`
+	Entries  []RatingHistoryEntryDTO  `json:"entries,omitempty"`
+	Buckets  []RatingHistoryBucketDTO `json:"buckets,omitempty"`
+	Streak   RatingStreakDTO          `json:"streak"`
+	// Truncated は1試合ずつ返すときに件数の上限で古い試合を省いたかどうか
+	Truncated bool `json:"truncated,omitempty"`
+}
+
+// RatingHistoryService はレーティング推移の参照をまとめる
+type RatingHistoryService struct {
+	historyRepo *repositories.RatingHistoryRepository
+	userRepo    *repositories.UserRepository
+}
+
+// NewRatingHistoryService は依存するリポジトリを組み立ててサービスを返す
+func NewRatingHistoryService(db *gorm.DB) *RatingHistoryService {
+	return &RatingHistoryService{
+		historyRepo: repositories.NewRatingHistoryRepository(db),
+		userRepo:    repositories.NewUserRepository(db),
+	}
+}
+
+// GetRatingHistory は指定ユーザーのモード別レーティング推移を返す
+// Bucket を指定すると日・週単位に集計し、指定しなければ1試合ずつ返す
+func (s *RatingHistoryService) GetRatingHistory(username string, q RatingHistoryQuery) (*RatingHistoryDTO, error) {
+	if q.Mode == "" {
+		q.Mode = "text-major"
+	}
+	if !models.IsRatedMode(q.Mode) {
+		return nil, ErrInvalidMode
+	}
+	if q.Bucket != "" && q.Bucket != RatingBucketDay && q.Bucket != RatingBucketWeek {
+		return nil, ErrInvalidBucket
+	}
+
+	user, err := s.userRepo.FindByUsername(username)
+	if err != nil {
+		return nil, err
+	}
+	if user == nil {
+		return nil, ErrUserNotFound
+	}
+
+	// 集計するときは期間内の全試合を使い、1試合ずつ返すときは新しいものから上限まで
+	// （上限を超えたかを知るため1件多く取る）
+	var entries []models.RatingHistory
+	if q.Bucket != "" {
+		entries, err = s.historyRepo.FindByUser(user.ID, q.Mode, q.From, q.To)
+	} else {
+		entries, err = s.historyRepo.FindRecentByUser(user.ID, q.Mode, q.From, q.To, MaxRatingHistoryEntries+1)
+	}
+	if err != nil {
+		return nil, err
+	}
+	entries, truncated := capRatingHistory(entries, MaxRatingHistoryEntries)
+
+	result := &RatingHistoryDTO{
+		Username:  user.Username,
+		Mode:      q.Mode,
+		Bucket:    q.Bucket,
+		Streak:    ratingStreak(entries),
+		Truncated: truncated,
+	}
+	if q.Bucket != "" {
+		result.Buckets = bucketRatingHistory(entries, q.Bucket)
+		return result, nil
+	}
+
+	result.Entries = make([]RatingHistoryEntryDTO, 0, len(entries))
+	for _, e := range entries {
+		result.Entries = append(result.Entries, RatingHistoryEntryDTO{
+			MatchID:      e.MatchID,
+			PlayedAt:     e.PlayedAt,
+			RatingBefore: e.RatingBefore,
+			RatingAfter:  e.RatingAfter,
+			RatingDelta:  e.RatingAfter - e.RatingBefore,
+			Outcome:      e.Outcome,
+			Opponents:    splitOpponents(e.Opponent),
+		})
+	}
+	return result, nil
+}
+
+// capRatingHistory は古い順に並んだ推移を新しいものから limit 件に絞り、古い試合を省いたかを返す
+func capRatingHistory(entries []models.RatingHistory, limit int) ([]models.RatingHistory, bool) {
+	if len(entries) <= limit {
+		return entries, false
+	}
+	return entries[len(entries)-limit:], true
+}
+
+// bucketRatingHistory は古い順に並んだ推移を日・週単位にまとめる（試合のない期間は含めない）
+func bucketRatingHistory(entries []models.RatingHistory, bucket string) []RatingHistoryBucketDTO {
+	buckets := []RatingHistoryBucketDTO{}
+	for _, e := range entries {
+		start := bucketStart(e.PlayedAt, bucket)
+		n := len(buckets)
+		if n == 0 || !buckets[n-1].Start.Equal(start) {
+			buckets = append(buckets, RatingHistoryBucketDTO{
+				Start:      start,
+				OpenRating: e.RatingBefore,
+				HighRating: e.RatingBefore,
+				LowRating:  e.RatingBefore,
+			})
+			n++
+		}
+		b := &buckets[n-1]
+		b.CloseRating = e.RatingAfter
+		b.HighRating = max(b.HighRating, e.RatingAfter)
+		b.LowRating = min(b.LowRating, e.RatingAfter)
+		b.Matches++
+		switch e.Outcome {
+		case "victory":
+			b.Wins++
+		case "draw":
+			b.Draws++
+		default:
+			b.Losses++
+		}
+	}
+	return buckets
+}
+
+// bucketStart は日時が属する期間の開始時刻を返す（サーバーのタイムゾーン基準、週は月曜始まり）
+func bucketStart(t time.Time, bucket string) time.Time {
+	t = t.In(time.Local)
+	day := time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.Local)
+	if bucket != RatingBucketWeek {
+		return day
+	}
+	// Weekday は日曜が0なので、月曜からの日数に直す
+	offset := (int(day.Weekday()) + 6) % 7
+	return day.AddDate(0, 0, -offset)
+}
+
+// ratingStreak は古い順に並んだ推移から現在の連続記録と最長連勝を求める
+func ratingStreak(entries []models.RatingHistory) RatingStreakDTO {
+	streak := RatingStreakDTO{}
+	wins := 0
+	for _, e := range entries {
+		if e.Outcome == streak.CurrentOutcome {
+			streak.Current++
+		} else {
+			streak.CurrentOutcome = e.Outcome
+			streak.Current = 1
+		}
+		if e.Outcome == "victory" {
+			wins++
+			streak.LongestWin = max(streak.LongestWin, wins)
+		} else {
+			wins = 0
+		}
+	}
+	return streak
+}
+
+// splitOpponents はカンマ区切りの対戦相手を配列に戻す
+func splitOpponents(raw string) []string {
+	opponents := []string{}
+	for _, name := range strings.Split(raw, ",") {
+		if name = strings.TrimSpace(name); name != "" {
+			opponents = append(opponents, name)
+		}
+	}
+	return opponents
+}
@@ -0,0 +1,169 @@
+package services
+
+import (
+	"testing"
+	"time"
+
+	"example.com/mathkun-tmp-/server/models"
+)
+
+// localAt はサーバーのタイムゾーンでの日時を作る（集計はこのタイムゾーン基準）
+func localAt(month time.Month, day, hour, minute int) time.Time {
+	return time.Date(2026, month, day, hour, minute, 0, 0, time.Local)
+}
+
+// historyEntry は推移のテスト用の1試合を作る
+func historyEntry(playedAt time.Time, before, after int, outcome string) models.RatingHistory {
+	return models.RatingHistory{PlayedAt: playedAt, RatingBefore: before, RatingAfter: after, Outcome: outcome}
+}
+
+func TestBucketStart(t *testing.T) {
+	cases := []struct {
+		name   string
+		at     time.Time
+		bucket string
+		want   time.Time
+	}{
+		{"day keeps the date", localAt(10, 14, 23, 59), RatingBucketDay, localAt(10, 14, 0, 0)},
+		{"day at midnight", localAt(10, 15, 0, 0), RatingBucketDay, localAt(10, 15, 0, 0)},
+		{"monday starts its own week", localAt(10, 12, 0, 0), RatingBucketWeek, localAt(10, 12, 0, 0)},
+		{"wednesday goes back to monday", localAt(10, 14, 12, 30), RatingBucketWeek, localAt(10, 12, 0, 0)},
+		{"sunday belongs to the previous monday", localAt(10, 18, 23, 59), RatingBucketWeek, localAt(10, 12, 0, 0)},
+		{"week across a month boundary", localAt(10, 1, 8, 0), RatingBucketWeek, localAt(9, 28, 0, 0)},
+	}
+	for _, tc := range cases {
+		if got := bucketStart(tc.at, tc.bucket); !got.Equal(tc.want) {
+			t.Errorf("%s: bucketStart(%v) = %v, want %v", tc.name, tc.at, got, tc.want)
+		}
+	}
+}
+
+func TestBucketRatingHistory(t *testing.T) {
+	entries := []models.RatingHistory{
+		historyEntry(localAt(10, 11, 21, 0), 1500, 1510, "victory"), // 日曜（前の週）
+		historyEntry(localAt(10, 12, 9, 0), 1510, 1530, "victory"),  // 月曜
+		historyEntry(localAt(10, 12, 22, 0), 1530, 1490, "defeat"),
+		historyEntry(localAt(10, 14, 10, 0), 1490, 1490, "draw"),
+		historyEntry(localAt(10, 18, 23, 0), 1490, 1480, "forfeit"), // 日曜（同じ週）
+	}
+
+	cases := []struct {
+		bucket string
+		want   []RatingHistoryBucketDTO
+	}{
+		{
+			bucket: RatingBucketDay,
+			want: []RatingHistoryBucketDTO{
+				{Start: localAt(10, 11, 0, 0), OpenRating: 1500, CloseRating: 1510, HighRating: 1510, LowRating: 1500, Matches: 1, Wins: 1},
+				{Start: localAt(10, 12, 0, 0), OpenRating: 1510, CloseRating: 1490, HighRating: 1530, LowRating: 1490, Matches: 2, Wins: 1, Losses: 1},
+				{Start: localAt(10, 14, 0, 0), OpenRating: 1490, CloseRating: 1490, HighRating: 1490, LowRating: 1490, Matches: 1, Draws: 1},
+				{Start: localAt(10, 18, 0, 0), OpenRating: 1490, CloseRating: 1480, HighRating: 1490, LowRating: 1480, Matches: 1, Losses: 1},
+			},
+		},
+		{
+			bucket: RatingBucketWeek,
+			want: []RatingHistoryBucketDTO{
+				{Start: localAt(10, 5, 0, 0), OpenRating: 1500, CloseRating: 1510, HighRating: 1510, LowRating: 1500, Matches: 1, Wins: 1},
+				{Start: localAt(10, 12, 0, 0), OpenRating: 1510, CloseRating: 1480, HighRating: 1530, LowRating: 1480, Matches: 4, Wins: 1, Losses: 2, Draws: 1},
+			},
+		},
+	}
+	for _, tc := range cases {
+		got := bucketRatingHistory(entries, tc.bucket)
+		if len(got) != len(tc.want) {
+			t.Fatalf("%s: got %d buckets, want %d: %+v", tc.bucket, len(got), len(tc.want), got)
+		}
+		for i, w := range tc.want {
+			g := got[i]
+			if !g.Start.Equal(w.Start) {
+				t.Errorf("%s[%d]: start %v, want %v", tc.bucket, i, g.Start, w.Start)
+			}
+			g.Start = w.Start
+			if g != w {
+				t.Errorf("%s[%d] = %+v, want %+v", tc.bucket, i, g, w)
+			}
+		}
+	}
+
+	if got := bucketRatingHistory(nil, RatingBucketDay); got == nil || len(got) != 0 {
+		t.Errorf("no entries should give an empty, non-nil slice, got %#v", got)
+	}
+}
+
+func TestRatingStreak(t *testing.T) {
+	cases := []struct {
+		name     string
+		outcomes []string
+		want     RatingStreakDTO
+	}{
+		{"empty", nil, RatingStreakDTO{}},
+		{"single defeat", []string{"defeat"}, RatingStreakDTO{Current: 1, CurrentOutcome: "defeat"}},
+		{"current win streak", []string{"defeat", "victory", "victory", "victory"}, RatingStreakDTO{Current: 3, CurrentOutcome: "victory", LongestWin: 3}},
+		{"longest win is kept after it ends", []string{"victory", "victory", "victory", "draw", "victory", "defeat", "defeat"}, RatingStreakDTO{Current: 2, CurrentOutcome: "defeat", LongestWin: 3}},
+		{"draws break a win streak", []string{"victory", "draw", "victory"}, RatingStreakDTO{Current: 1, CurrentOutcome: "victory", LongestWin: 1}},
+		{"draw streak", []string{"victory", "draw", "draw"}, RatingStreakDTO{Current: 2, CurrentOutcome: "draw", LongestWin: 1}},
+	}
+	for _, tc := range cases {
+		entries := make([]models.RatingHistory, len(tc.outcomes))
+		for i, outcome := range tc.outcomes {
+			entries[i] = historyEntry(localAt(10, 1, i, 0), 1500, 1500, outcome)
+		}
+		if got := ratingStreak(entries); got != tc.want {
+			t.Errorf("%s: ratingStreak = %+v, want %+v", tc.name, got, tc.want)
+		}
+	}
+}
+
+func TestCapRatingHistory(t *testing.T) {
+	cases := []struct {
+		name          string
+		count, limit  int
+		wantFirst     int // 残った最初の試合の番号
+		wantLen       int
+		wantTruncated bool
+	}{
+		{"empty", 0, 3, 0, 0, false},
+		{"under the limit", 2, 3, 0, 2, false},
+		{"exactly the limit", 3, 3, 0, 3, false},
+		{"one over drops the oldest", 4, 3, 1, 3, true},
+		{"far over keeps the newest", 10, 3, 7, 3, true},
+	}
+	for _, tc := range cases {
+		entries := make([]models.RatingHistory, tc.count)
+		for i := range entries {
+			entries[i] = models.RatingHistory{MatchID: uint(i)}
+		}
+		got, truncated := capRatingHistory(entries, tc.limit)
+		if len(got) != tc.wantLen || truncated != tc.wantTruncated {
+			t.Errorf("%s: got %d entries truncated=%v, want %d truncated=%v", tc.name, len(got), truncated, tc.wantLen, tc.wantTruncated)
+			continue
+		}
+		if len(got) > 0 && (got[0].MatchID != uint(tc.wantFirst) || got[len(got)-1].MatchID != uint(tc.count-1)) {
+			t.Errorf("%s: kept matches %d..%d, want %d..%d", tc.name, got[0].MatchID, got[len(got)-1].MatchID, tc.wantFirst, tc.count-1)
+		}
+	}
+}
+
+func TestSplitOpponents(t *testing.T) {
+	cases := []struct {
+		raw  string
+		want []string
+	}{
+		{"", []string{}},
+		{"alice", []string{"alice"}},
+		{"alice, bob ,,carol", []string{"alice", "bob", "carol"}},
+	}
+	for _, tc := range cases {
+		got := splitOpponents(tc.raw)
+		if len(got) != len(tc.want) {
+			t.Errorf("splitOpponents(%q) = %q, want %q", tc.raw, got, tc.want)
+			continue
+		}
+		for i := range got {
+			if got[i] != tc.want[i] {
+				t.Errorf("splitOpponents(%q) = %q, want %q", tc.raw, got, tc.want)
+				break
+			}
+		}
+	}
+}
@@ -0,0 +1,379 @@
+package services
+
+import (
+	"errors"
+	"sort"
+	"time"
+
+	"example.com/mathkun-tmp-/server/models"
+	"example.com/mathkun-tmp-/server/repositories"
+	"gorm.io/gorm"
+)
+
+// シーズン関連のエラー
+var (
+	ErrSeasonNotFound = errors.New("season not found")
+	ErrSeasonNotDue   = errors.New("season has not ended yet")
+)
+
+// シーズンの既定値
+const (
+	SeasonResetCenter      = 1000                // ソフトリセットで寄せる中心のレーティング
+	DefaultSoftResetFactor = 0.5                 // 中心からの差をどれだけ残すか
+	DefaultSeasonLength    = 90 * 24 * time.Hour // 新しいシーズンの予定期間
+)
+
+// SeasonDTO はシーズン一覧の1行分
+type SeasonDTO struct {
+	ID         uint       `json:"id"`
+	Name       string     `json:"name"`
+	StartsAt   time.Time  `json:"startsAt"`
+	EndsAt     time.Time  `json:"endsAt"`
+	Status     string     `json:"status"`
+	ArchivedAt *time.Time `json:"archivedAt,omitempty"`
+}
+
+// SeasonStandingDTO はシーズンランキングの1行分
+type SeasonStandingDTO struct {
+	Rank     int    `json:"rank"`
+	Username string `json:"username"`
+	Rating   int    `json:"rating"`
+	Wins     int    `json:"wins"`
+	Losses   int    `json:"losses"`
+	Draws    int    `json:"draws"`
+}
+
+// SeasonLeaderboardDTO はシーズンランキングのレスポンス
+// 開催中のシーズンは現在の値から集計し、終了したシーズンはアーカイブした最終順位を返す
+type SeasonLeaderboardDTO struct {
+	Season    SeasonDTO           `json:"season"`
+	Mode      string              `json:"mode"`
+	Final     bool                `json:"final"` // アーカイブ済みの最終順位かどうか
+	Standings []SeasonStandingDTO `json:"standings"`
+}
+
+// RolloverOptions はシーズン切り替えの設定
+type RolloverOptions struct {
+	Name            string        // 新しいシーズンの名前
+	SoftResetFactor float64       // ソフトリセットの係数（0〜1）
+	Length          time.Duration // 新しいシーズンの予定期間
+	IfDue           bool          // trueなら予定の終了日時を過ぎている場合だけ切り替える
+}
+
+// SeasonService はシーズンの参照と切り替えをまとめる
+type SeasonService struct {
+	db  *gorm.DB
+	now func() time.Time // 現在時刻（テストでは固定の時計に差し替える）
+}
+
+// NewSeasonService は依存するDB接続を受け取ってサービスを返す
+func NewSeasonService(db *gorm.DB) *SeasonService {
+	return NewSeasonServiceWithClock(db, time.Now)
+}
+
+// NewSeasonServiceWithClock は現在時刻の取得方法を指定してサービスを返す
+func NewSeasonServiceWithClock(db *gorm.DB, now func() time.Time) *SeasonService {
+	return &SeasonService{db: db, now: now}
+}
+
+// ListSeasons は全シーズンを新しい順に返す
+func (s *SeasonService) ListSeasons() ([]SeasonDTO, error) {
+	seasons, err := repositories.NewSeasonRepository(s.db).FindAll()
+	if err != nil {
+		return nil, err
+	}
+	rows := make([]SeasonDTO, 0, len(seasons))
+	for _, season := range seasons {
+		rows = append(rows, toSeasonDTO(season))
+	}
+	return rows, nil
+}
+
+// GetLeaderboard はシーズンのランキングを返す
+// mode が空なら全モード共通、limit は 1〜100 の範囲に補正する
+func (s *SeasonService) GetLeaderboard(id uint, mode string, limit int) (*SeasonLeaderboardDTO, error) {
+	if mode == "" {
+		mode = models.SeasonOverallMode
+	}
+	if mode != models.SeasonOverallMode && !models.IsRatingMode(mode) {
+		return nil, ErrInvalidMode
+	}
+	if limit < 1 {
+		limit = 1
+	}
+	if limit > 100 {
+		limit = 100
+	}
+
+	seasonRepo := repositories.NewSeasonRepository(s.db)
+	season, err := seasonRepo.FindByID(id)
+	if err != nil {
+		return nil, err
+	}
+	if season == nil {
+		return nil, ErrSeasonNotFound
+	}
+
+	result := &SeasonLeaderboardDTO{
+		Season:    toSeasonDTO(*season),
+		Mode:      mode,
+		Final:     season.Status == "archived",
+		Standings: []SeasonStandingDTO{},
+	}
+
+	var standings []models.SeasonStanding
+	if result.Final {
+		standings, err = seasonRepo.FindStandings(season.ID, mode, limit)
+	} else {
+		standings, err = s.computeStandings(s.db, season.StartsAt, s.now())
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	for _, st := range standings {
+		if st.Mode != mode || st.Rank == nil {
+			continue
+		}
+		if len(result.Standings) >= limit {
+			break
+		}
+		result.Standings = append(result.Standings, SeasonStandingDTO{
+			Rank:     *st.Rank,
+			Username: st.Username,
+			Rating:   st.Rating,
+			Wins:     st.Wins,
+			Losses:   st.Losses,
+			Draws:    st.Draws,
+		})
+	}
+	return result, nil
+}
+
+// Rollover は開催中のシーズンを締めて新しいシーズンを始める
+// 最終順位とシーズン中の戦績をアーカイブし、全員のレーティングを1000に向けてソフトリセットする
+// 開催中のシーズンがなければ、最初のシーズンを作るだけ
+func (s *SeasonService) Rollover(opts RolloverOptions) (*SeasonDTO, error) {
+	if opts.SoftResetFactor < 0 || opts.SoftResetFactor > 1 {
+		return nil, errors.New("invalid soft reset factor")
+	}
+	if opts.Length <= 0 {
+		opts.Length = DefaultSeasonLength
+	}
+	now := s.now()
+
+	var next models.Season
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		seasonRepo := repositories.NewSeasonRepository(tx)
+		current, err := seasonRepo.FindActive()
+		if err != nil {
+			return err
+		}
+
+		next, err = nextSeason(current, opts, now)
+		if err != nil {
+			return err
+		}
+
+		if current != nil {
+			// 最終順位とシーズン中の戦績を保存する（リセット前のレーティングで順位を付ける）
+			standings, err := s.computeStandings(tx, current.StartsAt, now)
+			if err != nil {
+				return err
+			}
+			for i := range standings {
+				standings[i].SeasonID = current.ID
+			}
+			if err := seasonRepo.CreateStandings(standings); err != nil {
+				return err
+			}
+
+			archiveSeason(current, opts.SoftResetFactor, now)
+			if err := seasonRepo.Save(current); err != nil {
+				return err
+			}
+
+			// 全モード共通とモード別の両方のレーティングをソフトリセットする
+			if err := repositories.NewUserRepository(tx).SoftResetRatings(SeasonResetCenter, opts.SoftResetFactor); err != nil {
+				return err
+			}
+			if err := repositories.NewUserModeRatingRepository(tx).SoftResetRatings(SeasonResetCenter, opts.SoftResetFactor); err != nil {
+				return err
+			}
+		}
+
+		return seasonRepo.Create(&next)
+	})
+	if err != nil {
+		return nil, err
+	}
+	dto := toSeasonDTO(next)
+	return &dto, nil
+}
+
+// nextSeason は切り替え後に始めるシーズンを組み立てる
+// IfDue の指定があり、開催中のシーズンが予定の終了日時より前なら ErrSeasonNotDue を返す
+func nextSeason(current *models.Season, opts RolloverOptions, now time.Time) (models.Season, error) {
+	if current != nil && opts.IfDue && now.Before(current.EndsAt) {
+		return models.Season{}, ErrSeasonNotDue
+	}
+	next := models.Season{
+		Name:     opts.Name,
+		StartsAt: now,
+		EndsAt:   now.Add(opts.Length),
+		Status:   "active",
+	}
+	if next.Name == "" {
+		next.Name = "Season " + now.Format("2006-01")
+	}
+	return next, nil
+}
+
+// archiveSeason は締めたシーズンを終了済みにする（実際の終了日時と適用したソフトリセットの係数を残す）
+func archiveSeason(season *models.Season, factor float64, now time.Time) {
+	season.Status = "archived"
+	season.EndsAt = now
+	season.ArchivedAt = &now
+	season.SoftResetFactor = factor
+}
+
+// computeStandings は期間中にレーティング戦を戦ったユーザーの順位を、モード別と全モード共通で組み立てる
+// 順位は現在のレーティング順で、ランキングと同じく試合数とRDの条件を満たす人だけに付ける
+// 集計するのは個人戦のモードだけ（チーム戦・バトルロイヤルは別のレーティングなので含めない）
+func (s *SeasonService) computeStandings(db *gorm.DB, from, to time.Time) ([]models.SeasonStanding, error) {
+	stats, err := repositories.NewSeasonRepository(db).StatsBetween(from, to, models.RatingModes)
+	if err != nil {
+		return nil, err
+	}
+
+	userIDs := make([]uint, 0, len(stats))
+	seen := map[uint]bool{}
+	for _, row := range stats {
+		if !seen[row.UserID] {
+			seen[row.UserID] = true
+			userIDs = append(userIDs, row.UserID)
+		}
+	}
+	users, err := repositories.NewUserRepository(db).FindByIDs(userIDs)
+	if err != nil {
+		return nil, err
+	}
+	modeRatings, err := repositories.NewUserModeRatingRepository(db).FindByUserIDs(userIDs)
+	if err != nil {
+		return nil, err
+	}
+	return rankSeasonEntries(buildSeasonEntries(stats, users, modeRatings, to)), nil
+}
+
+// buildSeasonEntries は期間中の戦績に to 時点のレーティングとRDを合わせ、モード別と全モード共通の成績を作る
+// RDは対戦しない間に広がるので、最後に対戦した時点ではなく to 時点の値で順位を付けるかを判定する
+func buildSeasonEntries(stats []repositories.SeasonStatRow, users []models.User, modeRatings []models.UserModeRating, to time.Time) []seasonEntry {
+	usersByID := make(map[uint]models.User, len(users))
+	for _, u := range users {
+		usersByID[u.ID] = u
+	}
+	type key struct {
+		userID uint
+		mode   string
+	}
+	modeByKey := make(map[key]models.UserModeRating, len(modeRatings))
+	for _, mr := range modeRatings {
+		modeByKey[key{mr.UserID, mr.Mode}] = mr
+	}
+
+	entries := []seasonEntry{}
+	overall := map[uint]*seasonEntry{}
+	for _, row := range stats {
+		u, ok := usersByID[row.UserID]
+		if !ok {
+			continue
+		}
+		mr, ok := modeByKey[key{row.UserID, row.Mode}]
+		if !ok {
+			mr = *models.NewUserModeRating(row.UserID, row.Mode)
+		}
+		entries = append(entries, seasonEntry{
+			standing: models.SeasonStanding{
+				Mode:     row.Mode,
+				UserID:   u.ID,
+				Username: u.Username,
+				Rating:   mr.Rating,
+				Wins:     row.Wins,
+				Losses:   row.Losses,
+				Draws:    row.Draws,
+			},
+			deviation: models.CurrentDeviation(mr.RatingDeviation, mr.RatingVolatility, mr.RatedAt, to),
+		})
+
+		// 全モード共通は戦績を合算し、レーティングはユーザー本体の値を使う
+		o := overall[u.ID]
+		if o == nil {
+			o = &seasonEntry{
+				standing: models.SeasonStanding{
+					Mode:     models.SeasonOverallMode,
+					UserID:   u.ID,
+					Username: u.Username,
+					Rating:   u.Rating,
+				},
+				deviation: models.CurrentDeviation(u.RatingDeviation, u.RatingVolatility, u.RatedAt, to),
+			}
+			overall[u.ID] = o
+		}
+		o.standing.Wins += row.Wins
+		o.standing.Losses += row.Losses
+		o.standing.Draws += row.Draws
+	}
+	for _, row := range stats {
+		if o := overall[row.UserID]; o != nil {
+			entries = append(entries, *o)
+			delete(overall, row.UserID)
+		}
+	}
+	return entries
+}
+
+// seasonEntry は順位付け前のシーズン成績（RDは順位を付けるかどうかの判定にだけ使う）
+type seasonEntry struct {
+	standing  models.SeasonStanding
+	deviation float64
+}
+
+// rankSeasonEntries はモードごとにレーティング順に並べて順位を付ける
+// シーズン中の試合数が MinRankedMatches 未満、またはRDが MaxRankedDeviation 以上の人は順位なし
+func rankSeasonEntries(entries []seasonEntry) []models.SeasonStanding {
+	sort.SliceStable(entries, func(i, j int) bool {
+		if entries[i].standing.Mode != entries[j].standing.Mode {
+			return entries[i].standing.Mode < entries[j].standing.Mode
+		}
+		return entries[i].standing.Rating > entries[j].standing.Rating
+	})
+
+	standings := make([]models.SeasonStanding, 0, len(entries))
+	rank := 0
+	for i, e := range entries {
+		if i == 0 || e.standing.Mode != entries[i-1].standing.Mode {
+			rank = 0
+		}
+		st := e.standing
+		played := st.Wins + st.Losses + st.Draws
+		if played >= repositories.MinRankedMatches && e.deviation < repositories.MaxRankedDeviation {
+			rank++
+			r := rank
+			st.Rank = &r
+		}
+		standings = append(standings, st)
+	}
+	return standings
+}
+
+// toSeasonDTO はモデルを一覧用DTOに変換する
+func toSeasonDTO(season models.Season) SeasonDTO {
+	return SeasonDTO{
+		ID:         season.ID,
+		Name:       season.Name,
+		StartsAt:   season.StartsAt,
+		EndsAt:     season.EndsAt,
+		Status:     season.Status,
+		ArchivedAt: season.ArchivedAt,
+	}
+}
@@ -0,0 +1,179 @@
+package services
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"example.com/mathkun-tmp-/server/models"
+	"example.com/mathkun-tmp-/server/repositories"
+)
+
+// seasonClock はシーズンのテストで使う固定の時計
+type seasonClock struct {
+	now time.Time
+}
+
+// Now は現在の時刻を返す
+func (c *seasonClock) Now() time.Time {
+	return c.now
+}
+
+// Advance は時計を d だけ進める
+func (c *seasonClock) Advance(d time.Duration) {
+	c.now = c.now.Add(d)
+}
+
+// newSeasonClock は固定の時刻から始まる時計を作る
+func newSeasonClock() *seasonClock {
+	return &seasonClock{now: time.Date(2026, 4, 1, 9, 0, 0, 0, time.UTC)}
+}
+
+// rankOf はモードとユーザーの順位を返す（順位なしや見つからなければ0）
+func rankOf(standings []models.SeasonStanding, mode string, userID uint) int {
+	for _, st := range standings {
+		if st.Mode == mode && st.UserID == userID && st.Rank != nil {
+			return *st.Rank
+		}
+	}
+	return 0
+}
+
+func TestNextSeasonStartsAtClock(t *testing.T) {
+	clk := newSeasonClock()
+	next, err := nextSeason(nil, RolloverOptions{Length: DefaultSeasonLength}, clk.Now())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if next.Name != "Season 2026-04" || next.Status != "active" {
+		t.Errorf("unexpected season %q (%s)", next.Name, next.Status)
+	}
+	if !next.StartsAt.Equal(clk.Now()) || !next.EndsAt.Equal(clk.Now().Add(DefaultSeasonLength)) {
+		t.Errorf("season runs %v - %v, want %v - %v", next.StartsAt, next.EndsAt, clk.Now(), clk.Now().Add(DefaultSeasonLength))
+	}
+
+	named, err := nextSeason(nil, RolloverOptions{Name: "Spring", Length: time.Hour}, clk.Now())
+	if err != nil || named.Name != "Spring" {
+		t.Errorf("expected the given name, got %q (%v)", named.Name, err)
+	}
+}
+
+func TestNextSeasonWaitsForEndWhenIfDue(t *testing.T) {
+	clk := newSeasonClock()
+	current := &models.Season{StartsAt: clk.Now(), EndsAt: clk.Now().Add(DefaultSeasonLength), Status: "active"}
+
+	clk.Advance(DefaultSeasonLength - time.Second)
+	if _, err := nextSeason(current, RolloverOptions{IfDue: true, Length: DefaultSeasonLength}, clk.Now()); !errors.Is(err, ErrSeasonNotDue) {
+		t.Fatalf("before the end: got %v, want ErrSeasonNotDue", err)
+	}
+	// IfDue がなければ予定より前でも切り替える
+	if _, err := nextSeason(current, RolloverOptions{Length: DefaultSeasonLength}, clk.Now()); err != nil {
+		t.Fatalf("forced rollover: %v", err)
+	}
+
+	clk.Advance(time.Second)
+	next, err := nextSeason(current, RolloverOptions{IfDue: true, Length: DefaultSeasonLength}, clk.Now())
+	if err != nil {
+		t.Fatalf("at the end: %v", err)
+	}
+	if next.Name != "Season 2026-06" || !next.StartsAt.Equal(current.EndsAt) {
+		t.Errorf("unexpected next season %q starting %v", next.Name, next.StartsAt)
+	}
+}
+
+func TestArchiveSeasonRecordsActualEnd(t *testing.T) {
+	clk := newSeasonClock()
+	season := &models.Season{StartsAt: clk.Now(), EndsAt: clk.Now().Add(DefaultSeasonLength), Status: "active"}
+	clk.Advance(30 * 24 * time.Hour)
+
+	archiveSeason(season, 0.25, clk.Now())
+	if season.Status != "archived" || season.SoftResetFactor != 0.25 {
+		t.Errorf("unexpected archived season %+v", season)
+	}
+	if !season.EndsAt.Equal(clk.Now()) || season.ArchivedAt == nil || !season.ArchivedAt.Equal(clk.Now()) {
+		t.Errorf("season should end at the rollover time %v, got %v / %v", clk.Now(), season.EndsAt, season.ArchivedAt)
+	}
+}
+
+func TestSeasonStandingsUseDeviationAtRolloverTime(t *testing.T) {
+	clk := newSeasonClock()
+	ratedAt := clk.Now()
+	users := []models.User{
+		{ID: 1, Username: "alice", Rating: 1300, RatingDeviation: 60, RatingVolatility: 0.06, RatedAt: &ratedAt},
+		{ID: 2, Username: "bob", Rating: 1200, RatingDeviation: 60, RatingVolatility: 0.06, RatedAt: &ratedAt},
+	}
+	modeRatings := []models.UserModeRating{
+		{UserID: 1, Mode: "text-major", Rating: 1300, RatingDeviation: 60, RatingVolatility: 0.06, RatedAt: &ratedAt},
+		{UserID: 2, Mode: "text-major", Rating: 1200, RatingDeviation: 60, RatingVolatility: 0.06, RatedAt: &ratedAt},
+	}
+	stats := []repositories.SeasonStatRow{
+		{UserID: 1, Mode: "text-major", Wins: 4, Losses: 2},
+		{UserID: 2, Mode: "text-major", Wins: 2, Losses: 4},
+	}
+
+	// 締めた時点で最後の対戦から間もなければ、RDは狭いまま順位が付く
+	clk.Advance(24 * time.Hour)
+	standings := rankSeasonEntries(buildSeasonEntries(stats, users, modeRatings, clk.Now()))
+	if rankOf(standings, "text-major", 1) != 1 || rankOf(standings, "text-major", 2) != 2 {
+		t.Fatalf("expected both players ranked by rating: %+v", standings)
+	}
+	if rankOf(standings, models.SeasonOverallMode, 1) != 1 || rankOf(standings, models.SeasonOverallMode, 2) != 2 {
+		t.Fatalf("expected both players ranked overall: %+v", standings)
+	}
+
+	// bob が対戦をやめたまま何年も経つとRDが広がり、締めた時点では順位が付かない
+	later := clk.Now().Add(5 * 365 * 24 * time.Hour)
+	users[0].RatedAt = &later
+	modeRatings[0].RatedAt = &later
+	clk.Advance(5 * 365 * 24 * time.Hour)
+	standings = rankSeasonEntries(buildSeasonEntries(stats, users, modeRatings, clk.Now()))
+	if rankOf(standings, "text-major", 1) != 1 {
+		t.Errorf("active player should stay ranked: %+v", standings)
+	}
+	if rank := rankOf(standings, "text-major", 2); rank != 0 {
+		t.Errorf("inactive player should be unranked, got rank %d", rank)
+	}
+	if rank := rankOf(standings, models.SeasonOverallMode, 2); rank != 0 {
+		t.Errorf("inactive player should be unranked overall, got rank %d", rank)
+	}
+}
+
+func TestSeasonStandingsCombineModesForOverall(t *testing.T) {
+	clk := newSeasonClock()
+	ratedAt := clk.Now()
+	users := []models.User{
+		{ID: 1, Username: "alice", Rating: 1250, RatingDeviation: 80, RatedAt: &ratedAt},
+	}
+	modeRatings := []models.UserModeRating{
+		{UserID: 1, Mode: "text-major", Rating: 1400, RatingDeviation: 80, RatedAt: &ratedAt},
+	}
+	stats := []repositories.SeasonStatRow{
+		{UserID: 1, Mode: "text-major", Wins: 3},
+		{UserID: 1, Mode: "audio-major", Losses: 2, Draws: 1},
+		{UserID: 99, Mode: "text-major", Wins: 9}, // 削除されたユーザーは除く
+	}
+
+	standings := rankSeasonEntries(buildSeasonEntries(stats, users, modeRatings, clk.Now()))
+	if len(standings) != 3 {
+		t.Fatalf("expected two modes and overall, got %+v", standings)
+	}
+	for _, st := range standings {
+		switch st.Mode {
+		case models.SeasonOverallMode:
+			if st.Rating != 1250 || st.Wins != 3 || st.Losses != 2 || st.Draws != 1 || st.Rank == nil {
+				t.Errorf("overall should sum every mode and use the user's rating: %+v", st)
+			}
+		case "text-major":
+			if st.Rating != 1400 || st.Rank != nil {
+				t.Errorf("3 games in a mode should stay unranked at the mode rating: %+v", st)
+			}
+		case "audio-major":
+			// まだモード別の行がないモードは初期値のレーティングで数える
+			if st.Rating != 1000 || st.Rank != nil {
+				t.Errorf("unexpected audio-major standing: %+v", st)
+			}
+		default:
+			t.Errorf("unexpected mode %q", st.Mode)
+		}
+	}
+}
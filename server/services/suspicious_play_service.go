@@ -0,0 +1,124 @@
+package services
+
+import (
+	"errors"
+	"time"
+
+	"example.com/mathkun-tmp-/server/models"
+	"example.com/mathkun-tmp-/server/repositories"
+	"gorm.io/gorm"
+)
+
+// 不正の疑いの確認に関するエラー
+var (
+	ErrSuspiciousPlayNotFound = errors.New("suspicious play not found")
+	ErrInvalidReviewStatus    = errors.New("invalid review status")
+	ErrAlreadyReviewed        = errors.New("already reviewed")
+)
+
+// 確認の結果
+const (
+	ReviewCleared   = "cleared"   // 問題なし
+	ReviewConfirmed = "confirmed" // 不正と判断した
+)
+
+// SuspiciousPlayDTO は管理画面で確認する疑わしいプレイの記録1件分
+type SuspiciousPlayDTO struct {
+	ID               uint       `json:"id"`
+	UserID           uint       `json:"userId"`
+	Username         string     `json:"username"`
+	MatchID          uint       `json:"matchId"`
+	Mode             string     `json:"mode"`
+	Reason           string     `json:"reason"`
+	FastCorrectCount int        `json:"fastCorrectCount"`
+	CorrectCount     int        `json:"correctCount"`
+	MinResponseMs    int64      `json:"minResponseMs"`
+	Status           string     `json:"status"`
+	ReviewedBy       string     `json:"reviewedBy,omitempty"`
+	ReviewedAt       *time.Time `json:"reviewedAt,omitempty"`
+	CreatedAt        time.Time  `json:"createdAt"`
+}
+
+// SuspiciousPlayService は試合の終了時に記録された疑わしいプレイを管理者が確認する処理をまとめる
+type SuspiciousPlayService struct {
+	db  *gorm.DB
+	now func() time.Time // 現在時刻（テストでは固定の時計に差し替える）
+}
+
+// NewSuspiciousPlayService は依存するDB接続を受け取ってサービスを返す
+func NewSuspiciousPlayService(db *gorm.DB) *SuspiciousPlayService {
+	return &SuspiciousPlayService{db: db, now: time.Now}
+}
+
+// ListPending は確認待ちの記録を新しい順に返す（limit は 1〜200 の範囲に補正する）
+func (s *SuspiciousPlayService) ListPending(limit int) ([]SuspiciousPlayDTO, error) {
+	if limit < 1 {
+		limit = 50
+	}
+	if limit > 200 {
+		limit = 200
+	}
+	flags, err := repositories.NewSuspiciousPlayRepository(s.db).FindPending(limit)
+	if err != nil {
+		return nil, err
+	}
+	dtos := make([]SuspiciousPlayDTO, 0, len(flags))
+	for _, f := range flags {
+		dtos = append(dtos, toSuspiciousPlayDTO(f))
+	}
+	return dtos, nil
+}
+
+// Review は確認待ちの記録に確認結果（cleared / confirmed）と確認した管理者を記録する
+// 既に確認済みの記録は ErrAlreadyReviewed を返す
+func (s *SuspiciousPlayService) Review(id uint, reviewer, status string) (*SuspiciousPlayDTO, error) {
+	if status != ReviewCleared && status != ReviewConfirmed {
+		return nil, ErrInvalidReviewStatus
+	}
+	var dto SuspiciousPlayDTO
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		repo := repositories.NewSuspiciousPlayRepository(tx)
+		flag, err := repo.FindByIDForUpdate(id)
+		if err != nil {
+			return err
+		}
+		if flag == nil {
+			return ErrSuspiciousPlayNotFound
+		}
+		if flag.Status != "pending" {
+			return ErrAlreadyReviewed
+		}
+		now := s.now()
+		flag.Status = status
+		flag.ReviewedBy = reviewer
+		flag.ReviewedAt = &now
+		if err := repo.Save(flag); err != nil {
+			return err
+		}
+		dto = toSuspiciousPlayDTO(*flag)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &dto, nil
+}
+
+// toSuspiciousPlayDTO はモデルを管理画面用のDTOに変換する
+func toSuspiciousPlayDTO(f models.SuspiciousPlayFlag) SuspiciousPlayDTO {
+	return SuspiciousPlayDTO{
+		ID:               f.ID,
+		UserID:           f.UserID,
+		Username:         f.Username,
+		MatchID:          f.MatchID,
+		Mode:             f.Mode,
+		Reason:           f.Reason,
+		FastCorrectCount: f.FastCorrectCount,
+		CorrectCount:     f.CorrectCount,
+		MinResponseMs:    f.MinResponseMs,
+		Status:           f.Status,
+		ReviewedBy:       f.ReviewedBy,
+		ReviewedAt:       f.ReviewedAt,
+		CreatedAt:        f.CreatedAt,
+	}
+}
@@ -0,0 +1,341 @@
+package services
+
+import (
+	"math/bits"
+	"sort"
+)
+
+// トーナメントの形式
+const (
+	TournamentFormatSingleElimination = "single_elimination" // 負けたら敗退するトーナメント
+	TournamentFormatSwiss             = "swiss"              // 同じ勝ち点同士を当てていくスイス式
+)
+
+// トーナメント表の計算はDBを使わない純粋な関数にしてある（組み合わせの再現性をテストしやすくするため）
+// 同じ入力からは常に同じ組み合わせを返す
+
+// BracketPlayer はトーナメントの参加者1人分
+type BracketPlayer struct {
+	UserID   uint
+	Username string
+	Rating   int // シード決めに使うレーティング（登録時点のモード別レーティング）
+	Seed     int // 1始まりのシード順位（SeedPlayers で決まる）
+}
+
+// BracketPairing は1つの対戦カード
+// B が 0 なら A の不戦勝（bye）、Done で Winner が 0 なら引き分けか両者不参加
+type BracketPairing struct {
+	Round   int  // 1始まりのラウンド番号
+	Slot    int  // ラウンド内の位置（0始まり、シングルイリミネーションでは隣の枠の勝者同士が次に当たる）
+	A       uint // 参加者のユーザーID
+	B       uint // 参加者のユーザーID（不戦勝なら0）
+	Winner  uint // 勝者のユーザーID（未決着・引き分け・両者不参加は0）
+	Done    bool // 決着済みか
+	Forfeit bool // 不参加による決着か（両者不参加なら Winner は0で、どちらにも勝ち点を与えない）
+}
+
+// IsBye は不戦勝の枠かを返す
+func (p BracketPairing) IsBye() bool {
+	return p.B == 0
+}
+
+// IsDraw は引き分けで終わった対戦かを返す（両者不参加は含めない）
+func (p BracketPairing) IsDraw() bool {
+	return p.Done && !p.IsBye() && p.Winner == 0 && !p.Forfeit
+}
+
+// Has は指定ユーザーがこの対戦の参加者かを返す
+func (p BracketPairing) Has(userID uint) bool {
+	return userID != 0 && (p.A == userID || p.B == userID)
+}
+
+// SeedPlayers はレーティングの高い順にシードを付ける（同じレーティングはユーザー名順）
+func SeedPlayers(players []BracketPlayer) []BracketPlayer {
+	seeded := make([]BracketPlayer, len(players))
+	copy(seeded, players)
+	sort.SliceStable(seeded, func(i, j int) bool {
+		if seeded[i].Rating != seeded[j].Rating {
+			return seeded[i].Rating > seeded[j].Rating
+		}
+		return seeded[i].Username < seeded[j].Username
+	})
+	for i := range seeded {
+		seeded[i].Seed = i + 1
+	}
+	return seeded
+}
+
+// bracketSize は参加人数が収まる2の累乗の枠数を返す
+func bracketSize(players int) int {
+	if players <= 1 {
+		return 1
+	}
+	return 1 << bits.Len(uint(players-1))
+}
+
+// EliminationRoundCount はシングルイリミネーションのラウンド数を返す（8人なら3）
+func EliminationRoundCount(players int) int {
+	return bits.Len(uint(bracketSize(players))) - 1
+}
+
+// EliminationSeedOrder は1回戦の枠に並べるシード順を返す
+// 上位シード同士が決勝まで当たらない標準的な並び（8枠なら 1,8,4,5,2,7,3,6）
+func EliminationSeedOrder(size int) []int {
+	order := []int{1}
+	for len(order) < size {
+		n := len(order) * 2
+		next := make([]int, 0, n)
+		for _, seed := range order {
+			next = append(next, seed, n+1-seed)
+		}
+		order = next
+	}
+	return order
+}
+
+// SingleEliminationFirstRound はシード済みの参加者から1回戦の組み合わせを作る
+// 人数が2の累乗に満たない分は上位シードの不戦勝になる
+func SingleEliminationFirstRound(seeded []BracketPlayer) []BracketPairing {
+	size := bracketSize(len(seeded))
+	bySeed := make(map[int]uint, len(seeded))
+	for _, p := range seeded {
+		bySeed[p.Seed] = p.UserID
+	}
+
+	order := EliminationSeedOrder(size)
+	pairings := make([]BracketPairing, 0, size/2)
+	for i := 0; i+1 < len(order); i += 2 {
+		pairings = append(pairings, newPairing(1, i/2, bySeed[order[i]], bySeed[order[i+1]]))
+	}
+	return pairings
+}
+
+// NextEliminationRound は決着済みのラウンドから次のラウンドの組み合わせを作る
+// 隣り合う枠（0と1、2と3…）の勝ち上がり同士を当てる。決勝が終わっていればnilを返す
+func NextEliminationRound(previous []BracketPairing, seeds map[uint]int) []BracketPairing {
+	if len(previous) <= 1 {
+		return nil
+	}
+	ordered := sortedBySlot(previous)
+	round := ordered[0].Round + 1
+	next := make([]BracketPairing, 0, len(ordered)/2)
+	for i := 0; i+1 < len(ordered); i += 2 {
+		next = append(next, newPairing(round, i/2, EliminationAdvancer(ordered[i], seeds), EliminationAdvancer(ordered[i+1], seeds)))
+	}
+	return next
+}
+
+// EliminationAdvancer は対戦から勝ち上がるユーザーIDを返す
+// 引き分けや両者不参加で勝者がいない場合は上位シード（シード番号の小さい方）を勝ち上がらせる
+func EliminationAdvancer(p BracketPairing, seeds map[uint]int) uint {
+	if p.Winner != 0 {
+		return p.Winner
+	}
+	if p.IsBye() {
+		return p.A
+	}
+	if seeds[p.B] != 0 && seeds[p.B] < seeds[p.A] {
+		return p.B
+	}
+	return p.A
+}
+
+// SwissRoundCount はスイス式の既定のラウンド数を返す（全勝者が1人に絞れる回数、最低1）
+func SwissRoundCount(players int) int {
+	return max(EliminationRoundCount(players), 1)
+}
+
+// SwissStanding はスイス式の途中順位の1行分
+type SwissStanding struct {
+	Player   BracketPlayer
+	Rank     int
+	Points   float64 // 勝ち1、引き分け0.5、不戦勝1
+	Buchholz float64 // 対戦相手の勝ち点の合計（同点時の順位決め）
+	Wins     int
+	Losses   int
+	Draws    int
+	Byes     int
+}
+
+// SwissStandings は決着済みの対戦から順位を計算する
+// 勝ち点 → ブッフホルツ → シードの順に並べ、勝ち点とブッフホルツが同じなら同順位にする
+func SwissStandings(players []BracketPlayer, pairings []BracketPairing) []SwissStanding {
+	rows := make(map[uint]*SwissStanding, len(players))
+	opponents := make(map[uint][]uint, len(players))
+	for _, p := range players {
+		rows[p.UserID] = &SwissStanding{Player: p}
+	}
+
+	for _, p := range pairings {
+		if !p.Done {
+			continue
+		}
+		a, b := rows[p.A], rows[p.B]
+		if p.IsBye() {
+			if a != nil {
+				a.Byes++
+				a.Points++
+			}
+			continue
+		}
+		opponents[p.A] = append(opponents[p.A], p.B)
+		opponents[p.B] = append(opponents[p.B], p.A)
+		if a == nil || b == nil {
+			continue
+		}
+		switch {
+		case p.IsDraw():
+			a.Draws++
+			b.Draws++
+			a.Points += 0.5
+			b.Points += 0.5
+		case p.Winner == p.A:
+			a.Wins++
+			a.Points++
+			b.Losses++
+		case p.Winner == p.B:
+			b.Wins++
+			b.Points++
+			a.Losses++
+		default: // 両者不参加
+			a.Losses++
+			b.Losses++
+		}
+	}
+
+	standings := make([]SwissStanding, 0, len(players))
+	for _, p := range players {
+		row := rows[p.UserID]
+		for _, opp := range opponents[p.UserID] {
+			if o := rows[opp]; o != nil {
+				row.Buchholz += o.Points
+			}
+		}
+		standings = append(standings, *row)
+	}
+	sort.SliceStable(standings, func(i, j int) bool {
+		if standings[i].Points != standings[j].Points {
+			return standings[i].Points > standings[j].Points
+		}
+		if standings[i].Buchholz != standings[j].Buchholz {
+			return standings[i].Buchholz > standings[j].Buchholz
+		}
+		return standings[i].Player.Seed < standings[j].Player.Seed
+	})
+	for i := range standings {
+		if i > 0 && standings[i].Points == standings[i-1].Points && standings[i].Buchholz == standings[i-1].Buchholz {
+			standings[i].Rank = standings[i-1].Rank
+			continue
+		}
+		standings[i].Rank = i + 1
+	}
+	return standings
+}
+
+// SwissPairings は次のラウンド（round）の組み合わせを作る
+// 順位の近い者同士を上から当て、同じ相手との再戦はできる限り避ける
+// 奇数人数なら、まだ不戦勝をもらっていない最下位の人を不戦勝にする
+func SwissPairings(players []BracketPlayer, pairings []BracketPairing, round int) []BracketPairing {
+	standings := SwissStandings(players, pairings)
+	order := make([]uint, 0, len(standings))
+	byes := make(map[uint]int, len(standings))
+	for _, s := range standings {
+		order = append(order, s.Player.UserID)
+		byes[s.Player.UserID] = s.Byes
+	}
+
+	var bye uint
+	if len(order)%2 == 1 {
+		pick := len(order) - 1
+		for i := len(order) - 1; i >= 0; i-- {
+			if byes[order[i]] == 0 {
+				pick = i
+				break
+			}
+		}
+		bye = order[pick]
+		order = append(order[:pick:pick], order[pick+1:]...)
+	}
+
+	played := make(map[[2]uint]bool, len(pairings))
+	for _, p := range pairings {
+		if !p.IsBye() {
+			played[pairKey(p.A, p.B)] = true
+		}
+	}
+	budget := swissSearchBudget
+	matched, ok := pairWithoutRematch(order, played, &budget)
+	if !ok {
+		// 再戦を避けきれない場合は順位どおりに当てる
+		matched = matched[:0]
+		for i := 0; i+1 < len(order); i += 2 {
+			matched = append(matched, [2]uint{order[i], order[i+1]})
+		}
+	}
+
+	next := make([]BracketPairing, 0, len(matched)+1)
+	for i, m := range matched {
+		next = append(next, newPairing(round, i, m[0], m[1]))
+	}
+	if bye != 0 {
+		next = append(next, newPairing(round, len(next), bye, 0))
+	}
+	return next
+}
+
+// swissSearchBudget は再戦を避ける組み合わせ探しで試す回数の上限（人数が多いときに探索が終わらないのを防ぐ）
+const swissSearchBudget = 100000
+
+// pairWithoutRematch は上位から順に、まだ当たっていない中で最も順位の近い相手と組ませる（バックトラックあり）
+// budget を使い切ったら諦めてfalseを返す
+func pairWithoutRematch(order []uint, played map[[2]uint]bool, budget *int) ([][2]uint, bool) {
+	if len(order) == 0 {
+		return nil, true
+	}
+	if *budget <= 0 {
+		return nil, false
+	}
+	*budget--
+	first := order[0]
+	for i := 1; i < len(order); i++ {
+		if played[pairKey(first, order[i])] {
+			continue
+		}
+		rest := make([]uint, 0, len(order)-2)
+		rest = append(rest, order[1:i]...)
+		rest = append(rest, order[i+1:]...)
+		if matched, ok := pairWithoutRematch(rest, played, budget); ok {
+			return append([][2]uint{{first, order[i]}}, matched...), true
+		}
+	}
+	return nil, false
+}
+
+// newPairing は対戦カードを作る（不戦勝ならその場で決着済みにする）
+func newPairing(round, slot int, a, b uint) BracketPairing {
+	if a == 0 {
+		a, b = b, 0
+	}
+	p := BracketPairing{Round: round, Slot: slot, A: a, B: b}
+	if p.IsBye() {
+		p.Winner = a
+		p.Done = a != 0
+	}
+	return p
+}
+
+// pairKey は2人の組み合わせを順序によらない形にする
+func pairKey(a, b uint) [2]uint {
+	if a > b {
+		a, b = b, a
+	}
+	return [2]uint{a, b}
+}
+
+// sortedBySlot は枠の順に並べたコピーを返す
+func sortedBySlot(pairings []BracketPairing) []BracketPairing {
+	ordered := make([]BracketPairing, len(pairings))
+	copy(ordered, pairings)
+	sort.Slice(ordered, func(i, j int) bool { return ordered[i].Slot < ordered[j].Slot })
+	return ordered
+}
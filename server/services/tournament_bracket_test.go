@@ -0,0 +1,251 @@
+package services
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+)
+
+// bracketPlayers はシード順（1位から）に並んだ n 人の参加者を作る
+// シード i の参加者のユーザーIDは i*10 になる
+func bracketPlayers(n int) []BracketPlayer {
+	players := make([]BracketPlayer, 0, n)
+	for i := 1; i <= n; i++ {
+		players = append(players, BracketPlayer{
+			UserID:   uint(i * 10),
+			Username: fmt.Sprintf("p%d", i),
+			Rating:   2000 - i*10,
+		})
+	}
+	return SeedPlayers(players)
+}
+
+// bracketSeeds はユーザーIDからシード番号を引く表を作る
+func bracketSeeds(players []BracketPlayer) map[uint]int {
+	seeds := make(map[uint]int, len(players))
+	for _, p := range players {
+		seeds[p.UserID] = p.Seed
+	}
+	return seeds
+}
+
+// decide は対戦を winner の勝ちで決着させる（0なら引き分け）
+func decide(p BracketPairing, winner uint) BracketPairing {
+	p.Done = true
+	p.Winner = winner
+	return p
+}
+
+func TestSeedPlayersOrdersByRatingThenName(t *testing.T) {
+	players := []BracketPlayer{
+		{UserID: 1, Username: "carol", Rating: 1500},
+		{UserID: 2, Username: "alice", Rating: 1600},
+		{UserID: 3, Username: "bob", Rating: 1500},
+	}
+	seeded := SeedPlayers(players)
+
+	want := []struct {
+		id   uint
+		seed int
+	}{{2, 1}, {3, 2}, {1, 3}}
+	for i, w := range want {
+		if seeded[i].UserID != w.id || seeded[i].Seed != w.seed {
+			t.Errorf("seeded[%d] = user %d seed %d, want user %d seed %d", i, seeded[i].UserID, seeded[i].Seed, w.id, w.seed)
+		}
+	}
+	if players[0].Seed != 0 {
+		t.Error("SeedPlayers should not modify its input")
+	}
+}
+
+func TestEliminationSeedOrder(t *testing.T) {
+	cases := map[int][]int{
+		1: {1},
+		2: {1, 2},
+		4: {1, 4, 2, 3},
+		8: {1, 8, 4, 5, 2, 7, 3, 6},
+	}
+	for size, want := range cases {
+		if got := EliminationSeedOrder(size); !reflect.DeepEqual(got, want) {
+			t.Errorf("EliminationSeedOrder(%d) = %v, want %v", size, got, want)
+		}
+	}
+}
+
+func TestEliminationRoundCount(t *testing.T) {
+	cases := map[int]int{1: 0, 2: 1, 3: 2, 4: 2, 5: 3, 8: 3, 9: 4}
+	for players, want := range cases {
+		if got := EliminationRoundCount(players); got != want {
+			t.Errorf("EliminationRoundCount(%d) = %d, want %d", players, got, want)
+		}
+	}
+}
+
+func TestSingleEliminationFirstRoundGivesTopSeedsByes(t *testing.T) {
+	// 6人は8枠に入るので、シード1と2が不戦勝になる
+	first := SingleEliminationFirstRound(bracketPlayers(6))
+	want := []BracketPairing{
+		{Round: 1, Slot: 0, A: 10, Winner: 10, Done: true},
+		{Round: 1, Slot: 1, A: 40, B: 50},
+		{Round: 1, Slot: 2, A: 20, Winner: 20, Done: true},
+		{Round: 1, Slot: 3, A: 30, B: 60},
+	}
+	if !reflect.DeepEqual(first, want) {
+		t.Fatalf("first round = %+v, want %+v", first, want)
+	}
+}
+
+func TestNextEliminationRoundAdvancesWinners(t *testing.T) {
+	players := bracketPlayers(6)
+	seeds := bracketSeeds(players)
+	first := SingleEliminationFirstRound(players)
+
+	// 5位が4位に勝ち、3位と6位は引き分け（上位シードの3位が勝ち上がる）
+	first[1] = decide(first[1], 50)
+	first[3] = decide(first[3], 0)
+	// 枠の順に並べ直すことを確かめるため、逆順で渡す
+	reversed := []BracketPairing{first[3], first[2], first[1], first[0]}
+
+	second := NextEliminationRound(reversed, seeds)
+	want := []BracketPairing{
+		{Round: 2, Slot: 0, A: 10, B: 50},
+		{Round: 2, Slot: 1, A: 20, B: 30},
+	}
+	if !reflect.DeepEqual(second, want) {
+		t.Fatalf("second round = %+v, want %+v", second, want)
+	}
+
+	second[0] = decide(second[0], 50)
+	second[1] = decide(second[1], 20)
+	final := NextEliminationRound(second, seeds)
+	if len(final) != 1 || final[0].A != 50 || final[0].B != 20 || final[0].Round != 3 {
+		t.Fatalf("final = %+v, want 50 vs 20 in round 3", final)
+	}
+
+	final[0] = decide(final[0], 20)
+	if next := NextEliminationRound(final, seeds); next != nil {
+		t.Errorf("expected no round after the final, got %+v", next)
+	}
+}
+
+func TestEliminationAdvancerPrefersHigherSeedWithoutWinner(t *testing.T) {
+	seeds := map[uint]int{30: 3, 60: 6}
+	cases := []struct {
+		name string
+		p    BracketPairing
+		want uint
+	}{
+		{"winner", BracketPairing{A: 30, B: 60, Winner: 60, Done: true}, 60},
+		{"draw keeps A as higher seed", BracketPairing{A: 30, B: 60, Done: true}, 30},
+		{"draw with higher seed as B", BracketPairing{A: 60, B: 30, Done: true}, 30},
+		{"both no-show", BracketPairing{A: 60, B: 30, Done: true, Forfeit: true}, 30},
+		{"bye", BracketPairing{A: 60, Winner: 60, Done: true}, 60},
+	}
+	for _, tc := range cases {
+		if got := EliminationAdvancer(tc.p, seeds); got != tc.want {
+			t.Errorf("%s: advancer = %d, want %d", tc.name, got, tc.want)
+		}
+	}
+}
+
+func TestSwissPairingsGiveByeToLowestWithoutBye(t *testing.T) {
+	players := bracketPlayers(5)
+
+	// 1回戦は順位（シード）どおりに上から当て、最下位のシード5が不戦勝
+	first := SwissPairings(players, nil, 1)
+	want := []BracketPairing{
+		{Round: 1, Slot: 0, A: 10, B: 20},
+		{Round: 1, Slot: 1, A: 30, B: 40},
+		{Round: 1, Slot: 2, A: 50, Winner: 50, Done: true},
+	}
+	if !reflect.DeepEqual(first, want) {
+		t.Fatalf("round 1 = %+v, want %+v", first, want)
+	}
+
+	// 1位と4位が勝つと、勝ち点1の 10, 40, 50 と勝ち点0の 20, 30 に分かれる
+	// シード5は不戦勝をもらったので、2回戦の不戦勝は勝ち点0の下位の30に回る
+	first[0] = decide(first[0], 10)
+	first[1] = decide(first[1], 40)
+	second := SwissPairings(players, first, 2)
+	want = []BracketPairing{
+		{Round: 2, Slot: 0, A: 10, B: 40},
+		{Round: 2, Slot: 1, A: 50, B: 20},
+		{Round: 2, Slot: 2, A: 30, Winner: 30, Done: true},
+	}
+	if !reflect.DeepEqual(second, want) {
+		t.Fatalf("round 2 = %+v, want %+v", second, want)
+	}
+}
+
+func TestSwissPairingsAvoidRematches(t *testing.T) {
+	players := bracketPlayers(4)
+	first := SwissPairings(players, nil, 1)
+	// 両方引き分けだと全員が同点になり、順位どおりなら 10 と 20 が再戦してしまう
+	first[0] = decide(first[0], 0)
+	first[1] = decide(first[1], 0)
+
+	second := SwissPairings(players, first, 2)
+	want := []BracketPairing{
+		{Round: 2, Slot: 0, A: 10, B: 30},
+		{Round: 2, Slot: 1, A: 20, B: 40},
+	}
+	if !reflect.DeepEqual(second, want) {
+		t.Fatalf("round 2 = %+v, want %+v", second, want)
+	}
+	if again := SwissPairings(players, first, 2); !reflect.DeepEqual(again, second) {
+		t.Errorf("pairings should be deterministic: %+v vs %+v", again, second)
+	}
+}
+
+func TestSwissStandingsSharesRanksOnTies(t *testing.T) {
+	players := bracketPlayers(5)
+	first := SwissPairings(players, nil, 1)
+	first[0] = decide(first[0], 10)
+	first[1] = decide(first[1], 40)
+
+	standings := SwissStandings(players, first)
+	want := []struct {
+		id       uint
+		rank     int
+		points   float64
+		buchholz float64
+	}{
+		{10, 1, 1, 0},
+		{40, 1, 1, 0},
+		{50, 1, 1, 0},
+		{20, 4, 0, 1},
+		{30, 4, 0, 1},
+	}
+	for i, w := range want {
+		s := standings[i]
+		if s.Player.UserID != w.id || s.Rank != w.rank || s.Points != w.points || s.Buchholz != w.buchholz {
+			t.Errorf("standings[%d] = user %d rank %d points %v buchholz %v, want user %d rank %d points %v buchholz %v",
+				i, s.Player.UserID, s.Rank, s.Points, s.Buchholz, w.id, w.rank, w.points, w.buchholz)
+		}
+	}
+	if standings[2].Byes != 1 || standings[2].Wins != 0 {
+		t.Errorf("bye should count as a bye, not a win: %+v", standings[2])
+	}
+}
+
+func TestSwissStandingsScoresDrawsAndNoShows(t *testing.T) {
+	players := bracketPlayers(4)
+	pairings := []BracketPairing{
+		{Round: 1, Slot: 0, A: 10, B: 20, Done: true},
+		{Round: 1, Slot: 1, A: 30, B: 40, Done: true, Forfeit: true},
+	}
+	standings := SwissStandings(players, pairings)
+
+	byID := make(map[uint]SwissStanding, len(standings))
+	for _, s := range standings {
+		byID[s.Player.UserID] = s
+	}
+	if s := byID[10]; s.Points != 0.5 || s.Draws != 1 {
+		t.Errorf("draw should give half a point: %+v", s)
+	}
+	for _, id := range []uint{30, 40} {
+		if s := byID[id]; s.Points != 0 || s.Losses != 1 {
+			t.Errorf("both no-show should count as a loss for %d: %+v", id, s)
+		}
+	}
+}
@@ -0,0 +1,906 @@
+package services
+
+import (
+	"errors"
+	"strings"
+	"time"
+
+	"example.com/mathkun-tmp-/server/models"
+	"example.com/mathkun-tmp-/server/repositories"
+	"gorm.io/gorm"
+)
+
+// 大会関連のエラー
+var (
+	ErrTournamentNotFound  = errors.New("tournament not found")
+	ErrInvalidTournament   = errors.New("invalid tournament")
+	ErrRegistrationClosed  = errors.New("registration is closed")
+	ErrTournamentFull      = errors.New("tournament is full")
+	ErrAlreadyRegistered   = errors.New("already registered")
+	ErrNotRegistered       = errors.New("not registered")
+	ErrNotOrganizer        = errors.New("not the organizer")
+	ErrNotEnoughPlayers    = errors.New("not enough players")
+	ErrNoTournamentMatch   = errors.New("no tournament match to play")
+	ErrRoundNotStarted     = errors.New("round has not started yet")
+	ErrInvalidMatchOutcome = errors.New("winner is not a player of this match")
+)
+
+// 大会の既定値
+const (
+	DefaultRoundMinutes   = 30 // ラウンドの間隔
+	DefaultCheckInMinutes = 10 // 各ラウンドの参加の猶予
+	maxTournamentRounds   = 20 // スイス式で指定できるラウンド数の上限
+)
+
+// TournamentPlayGrace は進行中の対戦カードの締め切りに足す猶予（切断からの復帰や結果の保存の遅れを見込む）
+const TournamentPlayGrace = 5 * time.Minute
+
+// CreateTournamentInput は大会作成の入力
+type CreateTournamentInput struct {
+	Name           string    `json:"name"`
+	Mode           string    `json:"mode"`
+	Format         string    `json:"format"` // "single_elimination", "swiss"
+	Rated          bool      `json:"rated"`
+	MaxPlayers     int       `json:"maxPlayers"`     // 0なら無制限
+	Rounds         int       `json:"rounds"`         // スイス式のラウンド数（0なら人数から決める）
+	RoundMinutes   int       `json:"roundMinutes"`   // 0なら30分
+	CheckInMinutes int       `json:"checkInMinutes"` // 0なら10分
+	StartsAt       time.Time `json:"startsAt"`
+}
+
+// TournamentDTO は大会一覧の1行分
+type TournamentDTO struct {
+	ID             uint       `json:"id"`
+	Name           string     `json:"name"`
+	Mode           string     `json:"mode"`
+	Format         string     `json:"format"`
+	Status         string     `json:"status"`
+	Rated          bool       `json:"rated"`
+	MaxPlayers     int        `json:"maxPlayers"`
+	TotalRounds    int        `json:"totalRounds"`
+	CurrentRound   int        `json:"currentRound"`
+	RoundMinutes   int        `json:"roundMinutes"`
+	CheckInMinutes int        `json:"checkInMinutes"`
+	StartsAt       time.Time  `json:"startsAt"`
+	StartedAt      *time.Time `json:"startedAt,omitempty"`
+	FinishedAt     *time.Time `json:"finishedAt,omitempty"`
+	Winner         string     `json:"winner,omitempty"`
+}
+
+// TournamentEntryDTO は大会の参加者1人分（スイス式は途中順位を含む）
+type TournamentEntryDTO struct {
+	Seed       int     `json:"seed"` // 開始前は0
+	Username   string  `json:"username"`
+	Rating     int     `json:"rating"`
+	Rank       int     `json:"rank,omitempty"` // スイス式の順位
+	Points     float64 `json:"points"`
+	Buchholz   float64 `json:"buchholz"`
+	Wins       int     `json:"wins"`
+	Losses     int     `json:"losses"`
+	Draws      int     `json:"draws"`
+	Eliminated bool    `json:"eliminated,omitempty"` // シングルイリミネーションで敗退したか
+}
+
+// TournamentMatchDTO は対戦カード1つ分
+type TournamentMatchDTO struct {
+	ID          uint      `json:"id"`
+	Round       int       `json:"round"`
+	Slot        int       `json:"slot"`
+	PlayerA     string    `json:"playerA"`
+	PlayerB     string    `json:"playerB,omitempty"` // 不戦勝なら空
+	Winner      string    `json:"winner,omitempty"`
+	Status      string    `json:"status"`           // "pending", "playing", "done"
+	Result      string    `json:"result,omitempty"` // "win", "draw", "bye", "forfeit", "double_forfeit"
+	MatchID     *uint     `json:"matchId,omitempty"`
+	ScheduledAt time.Time `json:"scheduledAt"`
+	DeadlineAt  time.Time `json:"deadlineAt"`
+}
+
+// TournamentRoundDTO は1ラウンド分の対戦カード
+type TournamentRoundDTO struct {
+	Round   int                  `json:"round"`
+	Matches []TournamentMatchDTO `json:"matches"`
+}
+
+// TournamentBracketDTO は大会の組み合わせと途中経過
+type TournamentBracketDTO struct {
+	Tournament TournamentDTO        `json:"tournament"`
+	Entries    []TournamentEntryDTO `json:"entries"`
+	Rounds     []TournamentRoundDTO `json:"rounds"`
+}
+
+// TournamentPairingDTO は WebSocket で対戦に参加するための対戦カード情報
+type TournamentPairingDTO struct {
+	MatchID      uint
+	TournamentID uint
+	Round        int
+	Mode         string
+	Rated        bool
+	PlayerAID    uint
+	PlayerBID    uint
+	Opponent     string
+	DeadlineAt   time.Time
+}
+
+// TournamentService は大会の登録・進行・結果の記録をまとめる
+// 組み合わせの計算は tournament_bracket.go の純粋な関数に任せ、ここではDBへの保存と状態遷移を扱う
+type TournamentService struct {
+	db  *gorm.DB
+	now func() time.Time // 現在時刻（テストでは固定の時計に差し替える）
+}
+
+// NewTournamentService は依存するDB接続を受け取ってサービスを返す
+func NewTournamentService(db *gorm.DB) *TournamentService {
+	return NewTournamentServiceWithClock(db, time.Now)
+}
+
+// NewTournamentServiceWithClock は現在時刻の取得方法を指定してサービスを返す
+func NewTournamentServiceWithClock(db *gorm.DB, now func() time.Time) *TournamentService {
+	return &TournamentService{db: db, now: now}
+}
+
+// CreateTournament は登録受付中の大会を作る（作成者が主催者になる）
+func (s *TournamentService) CreateTournament(organizer string, in CreateTournamentInput) (*TournamentDTO, error) {
+	user, err := repositories.NewUserRepository(s.db).FindByUsername(organizer)
+	if err != nil {
+		return nil, err
+	}
+	if user == nil {
+		return nil, ErrUserNotFound
+	}
+
+	in.Name = strings.TrimSpace(in.Name)
+	if in.RoundMinutes == 0 {
+		in.RoundMinutes = DefaultRoundMinutes
+	}
+	if in.CheckInMinutes == 0 {
+		in.CheckInMinutes = min(DefaultCheckInMinutes, in.RoundMinutes)
+	}
+	switch {
+	case in.Name == "" || len(in.Name) > 255:
+		return nil, ErrInvalidTournament
+	case !models.IsRatingMode(in.Mode):
+		return nil, ErrInvalidMode
+	case in.Format != TournamentFormatSingleElimination && in.Format != TournamentFormatSwiss:
+		return nil, ErrInvalidTournament
+	case in.MaxPlayers < 0 || in.MaxPlayers == 1:
+		return nil, ErrInvalidTournament
+	case in.Rounds < 0 || in.Rounds > maxTournamentRounds:
+		return nil, ErrInvalidTournament
+	case in.RoundMinutes < 1 || in.RoundMinutes > 24*60:
+		return nil, ErrInvalidTournament
+	case in.CheckInMinutes < 1 || in.CheckInMinutes > in.RoundMinutes:
+		return nil, ErrInvalidTournament
+	case in.StartsAt.Before(s.now()):
+		return nil, ErrInvalidTournament
+	}
+
+	t := models.Tournament{
+		Name:           in.Name,
+		Mode:           in.Mode,
+		Format:         in.Format,
+		Status:         "registration",
+		Rated:          in.Rated,
+		OrganizerID:    user.ID,
+		MaxPlayers:     in.MaxPlayers,
+		TotalRounds:    in.Rounds,
+		RoundMinutes:   in.RoundMinutes,
+		CheckInMinutes: in.CheckInMinutes,
+		StartsAt:       in.StartsAt,
+	}
+	if err := repositories.NewTournamentRepository(s.db).Create(&t); err != nil {
+		return nil, err
+	}
+	dto := toTournamentDTO(t)
+	return &dto, nil
+}
+
+// ListTournaments は大会を開始予定の新しい順に返す（status を指定すればその状態だけ）
+func (s *TournamentService) ListTournaments(status string) ([]TournamentDTO, error) {
+	tournaments, err := repositories.NewTournamentRepository(s.db).FindAll(status)
+	if err != nil {
+		return nil, err
+	}
+	rows := make([]TournamentDTO, 0, len(tournaments))
+	for _, t := range tournaments {
+		rows = append(rows, toTournamentDTO(t))
+	}
+	return rows, nil
+}
+
+// Register は登録受付中の大会に参加登録する
+func (s *TournamentService) Register(id uint, username string) error {
+	return s.db.Transaction(func(tx *gorm.DB) error {
+		repo := repositories.NewTournamentRepository(tx)
+		t, err := repo.FindByIDForUpdate(id)
+		if err != nil {
+			return err
+		}
+		if t == nil {
+			return ErrTournamentNotFound
+		}
+		if t.Status != "registration" {
+			return ErrRegistrationClosed
+		}
+		user, err := repositories.NewUserRepository(tx).FindByUsername(username)
+		if err != nil {
+			return err
+		}
+		if user == nil {
+			return ErrUserNotFound
+		}
+
+		existing, err := repo.FindEntry(t.ID, user.ID)
+		if err != nil {
+			return err
+		}
+		if existing != nil {
+			return ErrAlreadyRegistered
+		}
+		if t.MaxPlayers > 0 {
+			count, err := repo.CountEntries(t.ID)
+			if err != nil {
+				return err
+			}
+			if count >= int64(t.MaxPlayers) {
+				return ErrTournamentFull
+			}
+		}
+
+		// シードは開始時のレーティングで決め直すので、ここでは表示用に現在の値を入れておく
+		modeRating, err := repositories.NewUserModeRatingRepository(tx).FindOrDefault(user.ID, t.Mode)
+		if err != nil {
+			return err
+		}
+		return repo.CreateEntry(&models.TournamentEntry{
+			TournamentID: t.ID,
+			UserID:       user.ID,
+			Username:     user.Username,
+			Rating:       modeRating.Rating,
+		})
+	})
+}
+
+// Withdraw は開始前の大会の参加登録を取り消す
+// Register・Start と同じく大会の行ロックを取り、開始（シード決め）と入れ違わないようにする
+func (s *TournamentService) Withdraw(id uint, username string) error {
+	user, err := repositories.NewUserRepository(s.db).FindByUsername(username)
+	if err != nil {
+		return err
+	}
+	if user == nil {
+		return ErrUserNotFound
+	}
+	return s.db.Transaction(func(tx *gorm.DB) error {
+		repo := repositories.NewTournamentRepository(tx)
+		t, err := repo.FindByIDForUpdate(id)
+		if err != nil {
+			return err
+		}
+		if t == nil {
+			return ErrTournamentNotFound
+		}
+		if t.Status != "registration" {
+			return ErrRegistrationClosed
+		}
+		deleted, err := repo.DeleteEntry(t.ID, user.ID)
+		if err != nil {
+			return err
+		}
+		if !deleted {
+			return ErrNotRegistered
+		}
+		return nil
+	})
+}
+
+// Start は開始予定を待たずに大会を始める（主催者のみ）
+func (s *TournamentService) Start(id uint, username string) error {
+	user, err := repositories.NewUserRepository(s.db).FindByUsername(username)
+	if err != nil {
+		return err
+	}
+	if user == nil {
+		return ErrUserNotFound
+	}
+	return s.db.Transaction(func(tx *gorm.DB) error {
+		t, err := repositories.NewTournamentRepository(tx).FindByIDForUpdate(id)
+		if err != nil {
+			return err
+		}
+		if t == nil {
+			return ErrTournamentNotFound
+		}
+		if t.OrganizerID != user.ID {
+			return ErrNotOrganizer
+		}
+		if t.Status != "registration" {
+			return ErrRegistrationClosed
+		}
+		return s.start(tx, t, s.now())
+	})
+}
+
+// Tick は開始予定を過ぎた大会を始め、締め切りを過ぎた対戦カードを不戦勝・不戦敗・引き分けで決着させる
+// WebSocket サーバーの定期処理から呼ばれ、締め切りで決着させた対戦カードのIDを返す（待機中のルームを閉じるため）
+func (s *TournamentService) Tick() ([]uint, error) {
+	now := s.now()
+	repo := repositories.NewTournamentRepository(s.db)
+
+	due, err := repo.FindDueToStart(now)
+	if err != nil {
+		return nil, err
+	}
+	var errs []error
+	for _, t := range due {
+		errs = append(errs, s.db.Transaction(func(tx *gorm.DB) error {
+			locked, err := repositories.NewTournamentRepository(tx).FindByIDForUpdate(t.ID)
+			if err != nil || locked == nil || locked.Status != "registration" {
+				return err
+			}
+			// 人数が揃わなかった大会は中止にする
+			if err := s.start(tx, locked, now); errors.Is(err, ErrNotEnoughPlayers) {
+				locked.Status = "cancelled"
+				locked.FinishedAt = &now
+				return repositories.NewTournamentRepository(tx).Save(locked)
+			} else if err != nil {
+				return err
+			}
+			return nil
+		}))
+	}
+
+	overdue, err := repo.FindOverdueMatches(now)
+	if err != nil {
+		return nil, errors.Join(append(errs, err)...)
+	}
+	var resolved []uint
+	for _, m := range overdue {
+		ok, err := s.resolveNoShow(m.ID, now)
+		if ok {
+			resolved = append(resolved, m.ID)
+		}
+		errs = append(errs, err)
+	}
+	return resolved, errors.Join(errs...)
+}
+
+// CheckIn はユーザーの現在の対戦カードに参加を記録して返す
+// 次のラウンドがまだ始まっていなければ ErrRoundNotStarted、対戦がなければ ErrNoTournamentMatch
+// 締め切りの不戦敗の判定（resolveNoShow）と同じく大会の行ロックを取り、判定と参加の記録が入れ違わないようにする
+func (s *TournamentService) CheckIn(id uint, username string) (*TournamentPairingDTO, error) {
+	user, err := repositories.NewUserRepository(s.db).FindByUsername(username)
+	if err != nil {
+		return nil, err
+	}
+	if user == nil {
+		return nil, ErrUserNotFound
+	}
+
+	var pairing *TournamentPairingDTO
+	err = s.db.Transaction(func(tx *gorm.DB) error {
+		repo := repositories.NewTournamentRepository(tx)
+		t, err := repo.FindByIDForUpdate(id)
+		if err != nil {
+			return err
+		}
+		if t == nil {
+			return ErrTournamentNotFound
+		}
+		if t.Status != "running" {
+			return ErrNoTournamentMatch
+		}
+
+		m, err := repo.FindOpenMatchForUser(t.ID, user.ID)
+		if err != nil {
+			return err
+		}
+		if m == nil || m.Status != "pending" {
+			return ErrNoTournamentMatch
+		}
+		if s.now().Before(m.ScheduledAt) {
+			return ErrRoundNotStarted
+		}
+
+		opponentID := m.PlayerBID
+		if m.PlayerAID == user.ID {
+			m.CheckedInA = true
+		} else {
+			m.CheckedInB = true
+			opponentID = m.PlayerAID
+		}
+		if err := repo.SaveMatch(m); err != nil {
+			return err
+		}
+
+		pairing = &TournamentPairingDTO{
+			MatchID:      m.ID,
+			TournamentID: t.ID,
+			Round:        m.Round,
+			Mode:         t.Mode,
+			Rated:        t.Rated,
+			PlayerAID:    m.PlayerAID,
+			PlayerBID:    m.PlayerBID,
+			DeadlineAt:   m.DeadlineAt,
+		}
+		if opponent, err := repo.FindEntry(t.ID, opponentID); err == nil && opponent != nil {
+			pairing.Opponent = opponent.Username
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return pairing, nil
+}
+
+// MarkPlaying は両者が揃って試合が始まった対戦カードを進行中にする（参加の締め切りで不戦敗にしないため）
+// limit は試合が終わるまでの最長の時間で、猶予を足した時刻を新しい締め切りにする
+// 試合中にサーバーが止まったり結果の記録に失敗したりしても、締め切りを過ぎれば Tick が決着させる
+func (s *TournamentService) MarkPlaying(matchID uint, limit time.Duration) error {
+	return s.updateMatchStatus(matchID, "pending", "playing", s.now().Add(limit+TournamentPlayGrace))
+}
+
+// ResetMatch は試合を始められなかった（問題が取得できなかった）対戦カードを参加待ちに戻す
+// 締め切りは参加の猶予ぶん延ばす
+func (s *TournamentService) ResetMatch(matchID uint) error {
+	repo := repositories.NewTournamentRepository(s.db)
+	m, err := repo.FindMatchByID(matchID)
+	if err != nil || m == nil {
+		return err
+	}
+	t, err := repo.FindByID(m.TournamentID)
+	if err != nil || t == nil {
+		return err
+	}
+	return s.updateMatchStatus(matchID, "playing", "pending", s.now().Add(time.Duration(t.CheckInMinutes)*time.Minute))
+}
+
+// updateMatchStatus は対戦カードの状態を from から to に変える（既に変わっていれば何もしない）
+// 大会の行ロックを取ってから読み直し、締め切りの判定や結果の記録と入れ違わないようにする
+func (s *TournamentService) updateMatchStatus(matchID uint, from, to string, deadline time.Time) error {
+	return s.db.Transaction(func(tx *gorm.DB) error {
+		repo := repositories.NewTournamentRepository(tx)
+		m, err := repo.FindMatchByID(matchID)
+		if err != nil {
+			return err
+		}
+		if m == nil {
+			return ErrNoTournamentMatch
+		}
+		if _, err := repo.FindByIDForUpdate(m.TournamentID); err != nil {
+			return err
+		}
+		if m, err = repo.FindMatchByID(matchID); err != nil {
+			return err
+		}
+		if m.Status != from {
+			return nil
+		}
+		m.Status = to
+		if !deadline.IsZero() {
+			m.DeadlineAt = deadline
+		}
+		return repo.SaveMatch(m)
+	})
+}
+
+// RecordResult は試合の結果を記録し、ラウンドが全て終わっていれば次のラウンドを組む
+// winnerID が0なら引き分け（シングルイリミネーションでは上位シードが勝ち上がる）、recordID は対戦履歴のID（なければ0）
+func (s *TournamentService) RecordResult(matchID, winnerID, recordID uint) error {
+	now := s.now()
+	return s.db.Transaction(func(tx *gorm.DB) error {
+		repo := repositories.NewTournamentRepository(tx)
+		m, err := repo.FindMatchByID(matchID)
+		if err != nil {
+			return err
+		}
+		if m == nil {
+			return ErrNoTournamentMatch
+		}
+		t, err := repo.FindByIDForUpdate(m.TournamentID)
+		if err != nil {
+			return err
+		}
+		if t == nil {
+			return ErrTournamentNotFound
+		}
+		// ロックを取ってから読み直す（同じ対戦を二重に記録しない）
+		if m, err = repo.FindMatchByID(matchID); err != nil {
+			return err
+		}
+		if m.Status == "done" {
+			return nil
+		}
+		if winnerID != 0 && winnerID != m.PlayerAID && winnerID != m.PlayerBID {
+			return ErrInvalidMatchOutcome
+		}
+
+		m.Status = "done"
+		m.WinnerID = winnerID
+		m.FinishedAt = &now
+		if recordID != 0 {
+			m.MatchID = &recordID
+		}
+		if err := repo.SaveMatch(m); err != nil {
+			return err
+		}
+		return s.advance(tx, t, now)
+	})
+}
+
+// resolveNoShow は締め切りを過ぎた対戦カードを決着させる
+// 片方だけ来ていればその人の不戦勝、両方来ていて始まらなかった場合は引き分け、どちらも来なければ両者不戦敗
+// 進行中のまま試合の締め切りを過ぎた（サーバーの再起動などで結果が届かなかった）場合は、両者来ていたので引き分けにする
+// 既に決着していれば何もせずfalseを返す
+func (s *TournamentService) resolveNoShow(matchID uint, now time.Time) (bool, error) {
+	resolved := false
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		repo := repositories.NewTournamentRepository(tx)
+		m, err := repo.FindMatchByID(matchID)
+		if err != nil || m == nil {
+			return err
+		}
+		t, err := repo.FindByIDForUpdate(m.TournamentID)
+		if err != nil || t == nil {
+			return err
+		}
+		if m, err = repo.FindMatchByID(matchID); err != nil {
+			return err
+		}
+		if (m.Status != "pending" && m.Status != "playing") || !now.After(m.DeadlineAt) {
+			return nil
+		}
+
+		m.Status = "done"
+		m.FinishedAt = &now
+		switch {
+		case m.CheckedInA && !m.CheckedInB:
+			m.WinnerID = m.PlayerAID
+			m.Forfeit = true
+		case m.CheckedInB && !m.CheckedInA:
+			m.WinnerID = m.PlayerBID
+			m.Forfeit = true
+		case !m.CheckedInA && !m.CheckedInB:
+			m.Forfeit = true
+		}
+		if err := repo.SaveMatch(m); err != nil {
+			return err
+		}
+		resolved = true
+		return s.advance(tx, t, now)
+	})
+	return resolved && err == nil, err
+}
+
+// start はシードを決めて1回戦を組み、大会を開催中にする（大会の行ロックを取ったトランザクション内で呼ぶ）
+func (s *TournamentService) start(tx *gorm.DB, t *models.Tournament, now time.Time) error {
+	repo := repositories.NewTournamentRepository(tx)
+	entries, err := repo.FindEntries(t.ID)
+	if err != nil {
+		return err
+	}
+	if len(entries) < 2 {
+		return ErrNotEnoughPlayers
+	}
+
+	// 開始時点のモード別レーティングでシードを決める
+	userIDs := make([]uint, 0, len(entries))
+	for _, e := range entries {
+		userIDs = append(userIDs, e.UserID)
+	}
+	modeRatings, err := repositories.NewUserModeRatingRepository(tx).FindByUserIDs(userIDs)
+	if err != nil {
+		return err
+	}
+	ratings := make(map[uint]int, len(modeRatings))
+	for _, mr := range modeRatings {
+		if mr.Mode == t.Mode {
+			ratings[mr.UserID] = mr.Rating
+		}
+	}
+	players := make([]BracketPlayer, 0, len(entries))
+	for _, e := range entries {
+		if rating, ok := ratings[e.UserID]; ok {
+			e.Rating = rating
+		}
+		players = append(players, BracketPlayer{UserID: e.UserID, Username: e.Username, Rating: e.Rating})
+	}
+	players = SeedPlayers(players)
+	seeds := make(map[uint]BracketPlayer, len(players))
+	for _, p := range players {
+		seeds[p.UserID] = p
+	}
+	for i := range entries {
+		entries[i].Seed = seeds[entries[i].UserID].Seed
+		entries[i].Rating = seeds[entries[i].UserID].Rating
+		if err := repo.SaveEntry(&entries[i]); err != nil {
+			return err
+		}
+	}
+
+	var first []BracketPairing
+	if t.Format == TournamentFormatSwiss {
+		// スイス式で全員と当たり終える回数を超えるラウンドは組めない
+		if t.TotalRounds == 0 {
+			t.TotalRounds = SwissRoundCount(len(players))
+		}
+		t.TotalRounds = min(t.TotalRounds, max(len(players)-1, 1))
+		first = SwissPairings(players, nil, 1)
+	} else {
+		t.TotalRounds = EliminationRoundCount(len(players))
+		first = SingleEliminationFirstRound(players)
+	}
+
+	t.Status = "running"
+	t.CurrentRound = 1
+	t.StartedAt = &now
+	if err := repo.Save(t); err != nil {
+		return err
+	}
+	if err := repo.CreateMatches(toTournamentMatches(t, first, now)); err != nil {
+		return err
+	}
+	// 全員が不戦勝ということはないが、念のため決着済みなら次へ進める
+	return s.advance(tx, t, now)
+}
+
+// advance は現在のラウンドが全て決着していれば次のラウンドを組むか、大会を終える（大会の行ロックを取ったトランザクション内で呼ぶ）
+// 次のラウンドは前のラウンドの開始から RoundMinutes が経ってから始まる
+func (s *TournamentService) advance(tx *gorm.DB, t *models.Tournament, now time.Time) error {
+	repo := repositories.NewTournamentRepository(tx)
+	matches, err := repo.FindMatches(t.ID)
+	if err != nil {
+		return err
+	}
+	entries, err := repo.FindEntries(t.ID)
+	if err != nil {
+		return err
+	}
+
+	all := make([]BracketPairing, 0, len(matches))
+	current := make([]BracketPairing, 0, len(matches))
+	var roundStart time.Time
+	for _, m := range matches {
+		p := toBracketPairing(m)
+		all = append(all, p)
+		if m.Round != t.CurrentRound {
+			continue
+		}
+		if !p.Done {
+			return nil
+		}
+		current = append(current, p)
+		roundStart = m.ScheduledAt
+	}
+	if len(current) == 0 {
+		return nil
+	}
+
+	players := toBracketPlayers(entries)
+	var next []BracketPairing
+	var winner uint
+	if t.Format == TournamentFormatSwiss {
+		if t.CurrentRound >= t.TotalRounds {
+			winner = SwissStandings(players, all)[0].Player.UserID
+		} else {
+			next = SwissPairings(players, all, t.CurrentRound+1)
+		}
+	} else {
+		seeds := make(map[uint]int, len(players))
+		for _, p := range players {
+			seeds[p.UserID] = p.Seed
+		}
+		next = NextEliminationRound(current, seeds)
+		if next == nil {
+			winner = EliminationAdvancer(current[0], seeds)
+		}
+	}
+
+	if next == nil {
+		t.Status = "finished"
+		t.FinishedAt = &now
+		t.WinnerID = winner
+		for _, e := range entries {
+			if e.UserID == winner {
+				t.WinnerName = e.Username
+			}
+		}
+		return repo.Save(t)
+	}
+
+	scheduledAt := roundStart.Add(time.Duration(t.RoundMinutes) * time.Minute)
+	if scheduledAt.Before(now) {
+		scheduledAt = now
+	}
+	t.CurrentRound++
+	if err := repo.Save(t); err != nil {
+		return err
+	}
+	if err := repo.CreateMatches(toTournamentMatches(t, next, scheduledAt)); err != nil {
+		return err
+	}
+	// 次のラウンドも全て不戦勝で決着していればさらに進める
+	return s.advance(tx, t, now)
+}
+
+// GetBracket は大会の組み合わせと途中経過を返す
+func (s *TournamentService) GetBracket(id uint) (*TournamentBracketDTO, error) {
+	repo := repositories.NewTournamentRepository(s.db)
+	t, err := repo.FindByID(id)
+	if err != nil {
+		return nil, err
+	}
+	if t == nil {
+		return nil, ErrTournamentNotFound
+	}
+	entries, err := repo.FindEntries(t.ID)
+	if err != nil {
+		return nil, err
+	}
+	matches, err := repo.FindMatches(t.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	names := make(map[uint]string, len(entries))
+	for _, e := range entries {
+		names[e.UserID] = e.Username
+	}
+	players := toBracketPlayers(entries)
+	pairings := make([]BracketPairing, 0, len(matches))
+	for _, m := range matches {
+		pairings = append(pairings, toBracketPairing(m))
+	}
+
+	// シングルイリミネーションで負けた（勝ち上がれなかった）参加者
+	eliminated := map[uint]bool{}
+	if t.Format == TournamentFormatSingleElimination {
+		seeds := make(map[uint]int, len(players))
+		for _, p := range players {
+			seeds[p.UserID] = p.Seed
+		}
+		for _, p := range pairings {
+			if !p.Done || p.IsBye() {
+				continue
+			}
+			advancer := EliminationAdvancer(p, seeds)
+			for _, userID := range []uint{p.A, p.B} {
+				if userID != advancer {
+					eliminated[userID] = true
+				}
+			}
+		}
+	}
+
+	result := &TournamentBracketDTO{
+		Tournament: toTournamentDTO(*t),
+		Entries:    make([]TournamentEntryDTO, 0, len(entries)),
+		Rounds:     []TournamentRoundDTO{},
+	}
+	for _, st := range SwissStandings(players, pairings) {
+		row := TournamentEntryDTO{
+			Seed:       st.Player.Seed,
+			Username:   st.Player.Username,
+			Rating:     st.Player.Rating,
+			Points:     st.Points,
+			Buchholz:   st.Buchholz,
+			Wins:       st.Wins,
+			Losses:     st.Losses,
+			Draws:      st.Draws,
+			Eliminated: eliminated[st.Player.UserID],
+		}
+		if t.Format == TournamentFormatSwiss && t.Status != "registration" {
+			row.Rank = st.Rank
+		}
+		result.Entries = append(result.Entries, row)
+	}
+
+	for _, m := range matches {
+		if len(result.Rounds) == 0 || result.Rounds[len(result.Rounds)-1].Round != m.Round {
+			result.Rounds = append(result.Rounds, TournamentRoundDTO{Round: m.Round, Matches: []TournamentMatchDTO{}})
+		}
+		last := &result.Rounds[len(result.Rounds)-1]
+		last.Matches = append(last.Matches, toTournamentMatchDTO(m, names))
+	}
+	return result, nil
+}
+
+// toTournamentMatches は組み合わせを保存用の対戦カードにする（不戦勝は決着済みにする）
+func toTournamentMatches(t *models.Tournament, pairings []BracketPairing, scheduledAt time.Time) []models.TournamentMatch {
+	matches := make([]models.TournamentMatch, 0, len(pairings))
+	for _, p := range pairings {
+		m := models.TournamentMatch{
+			TournamentID: t.ID,
+			Round:        p.Round,
+			Slot:         p.Slot,
+			PlayerAID:    p.A,
+			PlayerBID:    p.B,
+			Status:       "pending",
+			ScheduledAt:  scheduledAt,
+			DeadlineAt:   scheduledAt.Add(time.Duration(t.CheckInMinutes) * time.Minute),
+		}
+		if p.Done {
+			finishedAt := scheduledAt
+			m.Status = "done"
+			m.WinnerID = p.Winner
+			m.FinishedAt = &finishedAt
+		}
+		matches = append(matches, m)
+	}
+	return matches
+}
+
+// toBracketPairing は保存した対戦カードを組み合わせ計算用の形にする
+func toBracketPairing(m models.TournamentMatch) BracketPairing {
+	return BracketPairing{
+		Round:   m.Round,
+		Slot:    m.Slot,
+		A:       m.PlayerAID,
+		B:       m.PlayerBID,
+		Winner:  m.WinnerID,
+		Done:    m.Status == "done",
+		Forfeit: m.Forfeit,
+	}
+}
+
+// toBracketPlayers は参加登録を組み合わせ計算用の形にする
+func toBracketPlayers(entries []models.TournamentEntry) []BracketPlayer {
+	players := make([]BracketPlayer, 0, len(entries))
+	for _, e := range entries {
+		players = append(players, BracketPlayer{UserID: e.UserID, Username: e.Username, Rating: e.Rating, Seed: e.Seed})
+	}
+	return players
+}
+
+// toTournamentDTO は大会のモデルをレスポンス用に変換する
+func toTournamentDTO(t models.Tournament) TournamentDTO {
+	return TournamentDTO{
+		ID:             t.ID,
+		Name:           t.Name,
+		Mode:           t.Mode,
+		Format:         t.Format,
+		Status:         t.Status,
+		Rated:          t.Rated,
+		MaxPlayers:     t.MaxPlayers,
+		TotalRounds:    t.TotalRounds,
+		CurrentRound:   t.CurrentRound,
+		RoundMinutes:   t.RoundMinutes,
+		CheckInMinutes: t.CheckInMinutes,
+		StartsAt:       t.StartsAt,
+		StartedAt:      t.StartedAt,
+		FinishedAt:     t.FinishedAt,
+		Winner:         t.WinnerName,
+	}
+}
+
+// toTournamentMatchDTO は対戦カードをレスポンス用に変換する（ユーザーIDは名前に置き換える）
+func toTournamentMatchDTO(m models.TournamentMatch, names map[uint]string) TournamentMatchDTO {
+	dto := TournamentMatchDTO{
+		ID:          m.ID,
+		Round:       m.Round,
+		Slot:        m.Slot,
+		PlayerA:     names[m.PlayerAID],
+		PlayerB:     names[m.PlayerBID],
+		Winner:      names[m.WinnerID],
+		Status:      m.Status,
+		MatchID:     m.MatchID,
+		ScheduledAt: m.ScheduledAt,
+		DeadlineAt:  m.DeadlineAt,
+	}
+	if m.Status == "done" {
+		switch {
+		case m.PlayerBID == 0:
+			dto.Result = "bye"
+		case m.Forfeit && m.WinnerID == 0:
+			dto.Result = "double_forfeit"
+		case m.Forfeit:
+			dto.Result = "forfeit"
+		case m.WinnerID == 0:
+			dto.Result = "draw"
+		default:
+			dto.Result = "win"
+		}
+	}
+	return dto
+}
@@ -2,6 +2,7 @@ package services
 
 import (
 	"errors"
+	"time"
 
 	"example.com/mathkun-tmp-/server/models"
 	"example.com/mathkun-tmp-/server/repositories"
@@ -20,9 +21,11 @@ type UserDTO struct {
 // UserRankDTO はユーザーの順位情報を返すDTO
 // レーティングと順位、試合数をまとめて返す
 type UserRankDTO struct {
-	Rating     int  `json:"rating"`              // 現在のレーティング
-	MatchCount int  `json:"matchCount"`          // 総試合数（勝ち+負け）
-	Rank       *int `json:"rank,omitempty"`      // 全体順位（試合数が足りない場合はnil）
+	Rating      int     `json:"rating"`         // 現在のレーティング
+	MatchCount  int     `json:"matchCount"`     // 総試合数（勝ち+負け+引き分け）
+	Rank        *int    `json:"rank,omitempty"` // 全体順位（試合数が足りない場合やRDが大きい場合はnil）
+	Deviation   float64 `json:"deviation"`      // レーティングの不確かさ（RD）
+	Provisional bool    `json:"provisional"`    // RDが大きく暫定レーティングかどうか
 }
 
 // ProfileUpdateDTO はプロフィール更新時に受け取るデータ
@@ -34,13 +37,15 @@ type ProfileUpdateDTO struct {
 
 // UserService はユーザー関連のビジネスロジックをまとめる
 type UserService struct {
-	userRepo *repositories.UserRepository
+	userRepo     *repositories.UserRepository
+	modeRankRepo *repositories.UserModeRatingRepository
 }
 
 // NewUserService は依存するリポジトリを組み立ててサービスを返す
 func NewUserService(db *gorm.DB) *UserService {
 	return &UserService{
-		userRepo: repositories.NewUserRepository(db),
+		userRepo:     repositories.NewUserRepository(db),
+		modeRankRepo: repositories.NewUserModeRatingRepository(db),
 	}
 }
 
@@ -78,16 +83,21 @@ func (s *UserService) GetUserRank(username string) (*UserRankDTO, error) {
 		return nil, err
 	}
 
-	// 総試合数を計算（勝ち数 + 負け数）
-	matchCount := user.Wins + user.Losses
+	// 総試合数を計算（勝ち数 + 負け数 + 引き分け数）
+	matchCount := user.Wins + user.Losses + user.Draws
 	// 基本情報をDTOに詰める
+	// RDは最後の対戦から時間が経つと広がるので、現在の値で暫定かどうかを判定する
+	deviation := models.CurrentDeviation(user.RatingDeviation, user.RatingVolatility, user.RatedAt, time.Now())
+	provisional := deviation >= repositories.MaxRankedDeviation
 	result := &UserRankDTO{
-		Rating:     user.Rating,
-		MatchCount: matchCount,
+		Rating:      user.Rating,
+		MatchCount:  matchCount,
+		Deviation:   deviation,
+		Provisional: provisional,
 	}
 
-	// 最低試合数に達していれば順位を表示（初心者には順位を表示しない）
-	if matchCount >= repositories.MinRankedMatches {
+	// 最低試合数に達し、暫定でなければ順位を表示（初心者には順位を表示しない）
+	if matchCount >= repositories.MinRankedMatches && !provisional {
 		// 順位 = 上位人数 + 1（例: 上に99人いれば100位）
 		rank := int(higherCount + 1) // int64からintに変換
 		result.Rank = &rank           // ポインタで渡す（nilとの区別のため）
@@ -96,6 +106,40 @@ func (s *UserService) GetUserRank(username string) (*UserRankDTO, error) {
 	return result, nil
 }
 
+// GetUserModeRank はユーザーの指定モードでの順位を取得する
+// 試合数と暫定判定の条件は GetUserRank と同じものをモード内の戦績に適用する
+func (s *UserService) GetUserModeRank(username, mode string) (*UserRankDTO, error) {
+	user, err := s.userRepo.FindByUsername(username)
+	if err != nil || user == nil {
+		return nil, errors.New("user not found")
+	}
+
+	// そのモードで未対戦なら初期値が返る
+	modeRating, err := s.modeRankRepo.FindOrDefault(user.ID, mode)
+	if err != nil {
+		return nil, err
+	}
+	higherCount, err := s.modeRankRepo.CountHigherRating(mode, modeRating.Rating)
+	if err != nil {
+		return nil, err
+	}
+
+	matchCount := modeRating.Wins + modeRating.Losses + modeRating.Draws
+	deviation := models.CurrentDeviation(modeRating.RatingDeviation, modeRating.RatingVolatility, modeRating.RatedAt, time.Now())
+	provisional := deviation >= repositories.MaxRankedDeviation
+	result := &UserRankDTO{
+		Rating:      modeRating.Rating,
+		MatchCount:  matchCount,
+		Deviation:   deviation,
+		Provisional: provisional,
+	}
+	if matchCount >= repositories.MinRankedMatches && !provisional {
+		rank := int(higherCount + 1)
+		result.Rank = &rank
+	}
+	return result, nil
+}
+
 // GetPublicProfile は公開プロフィール情報を取得する
 // 他のユーザーのプロフィールを閲覧する際に使用（ログイン不要）
 func (s *UserService) GetPublicProfile(username string) (*UserDTO, error) {
@@ -152,3 +196,26 @@ func (s *UserService) GetUserByUsername(username string) (*models.User, error) {
 	// リポジトリ経由でユーザーモデルを返す（DTO変換なし）
 	return s.userRepo.FindByUsername(username)
 }
+
+// ErrInvalidRole は存在しない権限を指定した場合のエラー
+var ErrInvalidRole = errors.New("invalid role")
+
+// SetRole はユーザーの権限（"user" / "admin"）を変更する
+// 管理用コマンド（set-role）から呼ばれる
+func (s *UserService) SetRole(username, role string) error {
+	// 決まった権限以外は受け付けない
+	if role != models.RoleUser && role != models.RoleAdmin {
+		return ErrInvalidRole
+	}
+	// ユーザーが存在しなければエラー
+	user, err := s.userRepo.FindByUsername(username)
+	if err != nil {
+		return err
+	}
+	if user == nil {
+		return ErrUserNotFound
+	}
+	return s.userRepo.UpdateProfileByUsername(username, map[string]any{
+		"role": role,
+	})
+}